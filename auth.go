@@ -0,0 +1,277 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// defaultRateBurst is how many requests a key may make at once before
+// -rate-limit spaces them out.
+const defaultRateBurst = 10
+
+// apiKeyHeader carries the API key of a request that doesn't send it as an
+// Authorization: Bearer token.
+const apiKeyHeader = "X-API-Key"
+
+var (
+	errNoAPIKey          = errors.New("missing or unknown API key")
+	errRateLimited       = errors.New("request rate limit exceeded for this API key")
+	errTooManyConcurrent = errors.New("too many concurrent requests for this API key")
+)
+
+// authFlags are the serve flags controlling access to the server.
+type authFlags struct {
+	keys       *string
+	keysFile   *string
+	rate       *float64
+	burst      *int
+	concurrent *int
+}
+
+// addAuthFlags registers -api-keys, -api-keys-file, -rate-limit, -rate-burst
+// and -max-concurrent on fs.
+func addAuthFlags(fs *flag.FlagSet) authFlags {
+	return authFlags{
+		keys:       fs.String("api-keys", "", "comma-separated API keys requests must carry (Authorization: Bearer or X-API-Key); none leaves the server open"),
+		keysFile:   fs.String("api-keys-file", "", "file of further API keys, one per line (# starts a comment)"),
+		rate:       fs.Float64("rate-limit", 0, "requests per second each API key may make on average (0 = unlimited)"),
+		burst:      fs.Int("rate-burst", defaultRateBurst, "requests an API key may make at once before -rate-limit applies"),
+		concurrent: fs.Int("max-concurrent", 0, "requests each API key may have in flight at once (0 = unlimited)"),
+	}
+}
+
+// apiKeys admits requests carrying one of its keys, within the per-key limits.
+type apiKeys struct {
+	keys []*apiKey
+	// rate is the requests per second refilled into each key's bucket, 0 for
+	// no limit, and burst the bucket's size.
+	rate  float64
+	burst int
+	// concurrent caps the requests in flight per key, 0 for no limit.
+	concurrent int
+}
+
+// apiKey is one accepted key and the state of its limits.
+type apiKey struct {
+	key []byte
+	// id names the key in logs without revealing it.
+	id string
+
+	mu     sync.Mutex
+	tokens float64
+	filled time.Time
+	active int
+}
+
+// config reports flag problems into p and returns the keys configured, or nil
+// when there are none and the server is open.
+func (f authFlags) config(p *configProblems) *apiKeys {
+	p.check(*f.rate >= 0, "-rate-limit must not be negative, got %v", *f.rate)
+	p.check(*f.burst >= 1, "-rate-burst must be at least 1, got %d", *f.burst)
+	p.check(*f.concurrent >= 0, "-max-concurrent must not be negative, got %d", *f.concurrent)
+
+	var keys []string
+	for _, key := range strings.Split(*f.keys, ",") {
+		if key = strings.TrimSpace(key); key != "" {
+			keys = append(keys, key)
+		}
+	}
+	if *f.keysFile != "" {
+		fromFile, err := readAPIKeys(*f.keysFile)
+		p.check(err == nil, "-api-keys-file: %v", err)
+		keys = append(keys, fromFile...)
+	}
+	if len(keys) == 0 {
+		p.check(*f.rate == 0, "-rate-limit needs -api-keys or -api-keys-file")
+		p.check(*f.concurrent == 0, "-max-concurrent needs -api-keys or -api-keys-file")
+		return nil
+	}
+
+	a := &apiKeys{rate: *f.rate, burst: *f.burst, concurrent: *f.concurrent}
+	for _, key := range keys {
+		sum := sha256.Sum256([]byte(key))
+		a.keys = append(a.keys, &apiKey{key: []byte(key), id: hex.EncodeToString(sum[:4]), tokens: float64(a.burst)})
+	}
+	return a
+}
+
+// readAPIKeys returns the keys listed in the file at path, one per line.
+// Blank lines and lines starting with # are skipped.
+func readAPIKeys(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var keys []string
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		if line := strings.TrimSpace(sc.Text()); line != "" && !strings.HasPrefix(line, "#") {
+			keys = append(keys, line)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("%s lists no keys", path)
+	}
+	return keys, nil
+}
+
+// lookup returns the key matching token, or nil. Every key is compared in
+// constant time so the answer doesn't reveal how close a guess came.
+func (a *apiKeys) lookup(token string) *apiKey {
+	var found *apiKey
+	for _, k := range a.keys {
+		if subtle.ConstantTimeCompare(k.key, []byte(token)) == 1 {
+			found = k
+		}
+	}
+	return found
+}
+
+// admit checks token against the keys and their limits. On success the
+// returned release must be called once the request is done; on
+// errRateLimited, retryAfter is when the key's next request would be admitted.
+func (a *apiKeys) admit(token string) (k *apiKey, release func(), retryAfter time.Duration, err error) {
+	k = a.lookup(token)
+	if k == nil {
+		return nil, nil, 0, errNoAPIKey
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if a.concurrent > 0 && k.active >= a.concurrent {
+		return k, nil, 0, errTooManyConcurrent
+	}
+	if a.rate > 0 {
+		now := time.Now()
+		if !k.filled.IsZero() {
+			k.tokens = min(float64(a.burst), k.tokens+now.Sub(k.filled).Seconds()*a.rate)
+		}
+		k.filled = now
+		if k.tokens < 1 {
+			return k, nil, time.Duration((1 - k.tokens) / a.rate * float64(time.Second)), errRateLimited
+		}
+		k.tokens--
+	}
+	k.active++
+	return k, func() {
+		k.mu.Lock()
+		k.active--
+		k.mu.Unlock()
+	}, 0, nil
+}
+
+// requestToken returns the API key r carries, as a Bearer token or in
+// apiKeyHeader.
+func requestToken(r *http.Request) string {
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return strings.TrimSpace(token)
+	}
+	return r.Header.Get(apiKeyHeader)
+}
+
+// protect wraps h so it only runs for requests admitted by a: others are
+// answered 401 without a valid key and 429 over the key's limits. With no
+// keys configured h is returned as is.
+func (a *apiKeys) protect(h http.HandlerFunc) http.Handler {
+	if a == nil {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		k, release, retryAfter, err := a.admit(requestToken(r))
+		switch {
+		case errors.Is(err, errNoAPIKey):
+			slog.Warn("Request rejected", "method", r.Method, "path", r.URL.Path, "remote", r.RemoteAddr, "error", err)
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeError(w, http.StatusUnauthorized, err)
+			return
+		case err != nil:
+			slog.Warn("Request rejected", "method", r.Method, "path", r.URL.Path, "key", k.id, "error", err)
+			w.Header().Set("Retry-After", strconv.Itoa(max(1, int(math.Ceil(retryAfter.Seconds())))))
+			writeError(w, http.StatusTooManyRequests, err)
+			return
+		}
+		defer release()
+		h(w, r)
+	})
+}
+
+// grpcToken returns the API key in the metadata of a gRPC call, from the
+// same headers as requestToken.
+func grpcToken(ctx context.Context) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if v := md.Get("authorization"); len(v) > 0 {
+		if token, ok := strings.CutPrefix(v[0], "Bearer "); ok {
+			return strings.TrimSpace(token)
+		}
+	}
+	if v := md.Get(strings.ToLower(apiKeyHeader)); len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
+
+// grpcAdmit is admit for gRPC calls, with its errors as statuses. Health
+// checks are let through without a key, as probes carry none.
+func (a *apiKeys) grpcAdmit(ctx context.Context, method string) (func(), error) {
+	if strings.HasPrefix(method, "/"+healthpb.Health_ServiceDesc.ServiceName+"/") {
+		return func() {}, nil
+	}
+	k, release, _, err := a.admit(grpcToken(ctx))
+	switch {
+	case errors.Is(err, errNoAPIKey):
+		slog.Warn("Call rejected", "method", method, "error", err)
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	case err != nil:
+		slog.Warn("Call rejected", "method", method, "key", k.id, "error", err)
+		return nil, status.Error(codes.ResourceExhausted, err.Error())
+	}
+	return release, nil
+}
+
+// grpcOptions returns the interceptors that apply a to every gRPC call, or
+// none with no keys configured.
+func (a *apiKeys) grpcOptions() []grpc.ServerOption {
+	if a == nil {
+		return nil
+	}
+	unary := func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		release, err := a.grpcAdmit(ctx, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+		defer release()
+		return handler(ctx, req)
+	}
+	stream := func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		release, err := a.grpcAdmit(ss.Context(), info.FullMethod)
+		if err != nil {
+			return err
+		}
+		defer release()
+		return handler(srv, ss)
+	}
+	return []grpc.ServerOption{grpc.UnaryInterceptor(unary), grpc.StreamInterceptor(stream)}
+}
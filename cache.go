@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"poc-pdf/signature"
+)
+
+// redisKeyPrefix namespaces the cache entries in a shared Redis database.
+const redisKeyPrefix = "poc-pdf:cache:"
+
+// redisDialTimeout bounds connecting to Redis when the context has no deadline.
+const redisDialTimeout = 5 * time.Second
+
+// cacheFlags are the result cache flags of the subcommands that process
+// documents on request.
+type cacheFlags struct {
+	cache *string
+	ttl   *time.Duration
+}
+
+// addCacheFlags registers -cache and -cache-ttl on fs.
+func addCacheFlags(fs *flag.FlagSet) cacheFlags {
+	return cacheFlags{
+		cache: fs.String("cache", "", "restore the outputs of documents processed before with the same options from this directory or redis://host:port/db, and store new ones there"),
+		ttl:   fs.Duration("cache-ttl", 0, "forget cache entries older than this (e.g. 24h); 0 keeps them"),
+	}
+}
+
+// apply opens the cache of -cache, if any, into opts and reports flag
+// problems into p.
+func (f cacheFlags) apply(opts *signature.Options, p *configProblems) {
+	p.check(*f.ttl >= 0, "-cache-ttl must not be negative, got %v", *f.ttl)
+	p.check(*f.ttl == 0 || *f.cache != "", "-cache-ttl requires -cache")
+	if *f.cache == "" {
+		return
+	}
+	p.check(!isObjectURL(*f.cache), "-cache must be a local directory or a redis:// URL, got %s", *f.cache)
+	cache, err := openCache(*f.cache, *f.ttl)
+	p.check(err == nil, "-cache: %v", err)
+	opts.Cache = cache
+}
+
+// openCache returns the cache at spec: a Redis database for a redis:// URL,
+// a local directory otherwise.
+func openCache(spec string, ttl time.Duration) (signature.Cache, error) {
+	if !strings.HasPrefix(spec, "redis://") {
+		return signature.DirCache{Dir: spec, TTL: ttl}, nil
+	}
+	u, err := url.Parse(spec)
+	if err != nil {
+		return nil, err
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("%q names no Redis host", spec)
+	}
+	c := &redisCache{addr: u.Host, ttl: ttl}
+	if u.Port() == "" {
+		c.addr = net.JoinHostPort(u.Hostname(), "6379")
+	}
+	if p, ok := u.User.Password(); ok {
+		c.password = p
+	}
+	if db := strings.Trim(u.Path, "/"); db != "" {
+		if c.db, err = strconv.Atoi(db); err != nil || c.db < 0 {
+			return nil, fmt.Errorf("invalid Redis database %q in %q", db, spec)
+		}
+	}
+	return c, nil
+}
+
+// redisCache is a signature.Cache in a Redis database, spoken to with the
+// few RESP commands it needs over a connection per call; entries expire after
+// ttl when non-zero.
+type redisCache struct {
+	addr     string
+	password string
+	db       int
+	ttl      time.Duration
+}
+
+// Get implements signature.Cache.
+func (c *redisCache) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	reply, err := c.do(ctx, "GET", redisKeyPrefix+key)
+	if err != nil {
+		return nil, false, err
+	}
+	return reply, reply != nil, nil
+}
+
+// Put implements signature.Cache.
+func (c *redisCache) Put(ctx context.Context, key string, entry []byte) error {
+	args := []string{"SET", redisKeyPrefix + key, string(entry)}
+	if c.ttl > 0 {
+		args = append(args, "PX", strconv.FormatInt(c.ttl.Milliseconds(), 10))
+	}
+	_, err := c.do(ctx, args...)
+	return err
+}
+
+// do sends one command, after AUTH and SELECT when configured, and returns
+// the reply to it: the bulk string, or nil for a missing key.
+func (c *redisCache) do(ctx context.Context, args ...string) ([]byte, error) {
+	dialer := net.Dialer{Timeout: redisDialTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", c.addr)
+	if err != nil {
+		return nil, fmt.Errorf("redis: %v", err)
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	// Cancelling ctx unblocks the reads and writes below
+	stop := context.AfterFunc(ctx, func() { conn.SetDeadline(time.Now()) })
+	defer stop()
+
+	var commands [][]string
+	if c.password != "" {
+		commands = append(commands, []string{"AUTH", c.password})
+	}
+	if c.db != 0 {
+		commands = append(commands, []string{"SELECT", strconv.Itoa(c.db)})
+	}
+	commands = append(commands, args)
+	w := bufio.NewWriter(conn)
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "*%d\r\n", len(cmd))
+		for _, arg := range cmd {
+			fmt.Fprintf(w, "$%d\r\n%s\r\n", len(arg), arg)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return nil, fmt.Errorf("redis: %v", err)
+	}
+
+	r := bufio.NewReader(conn)
+	var reply []byte
+	for _, cmd := range commands {
+		if reply, err = readRESP(r); err != nil {
+			return nil, fmt.Errorf("redis %s: %v", cmd[0], err)
+		}
+	}
+	return reply, nil
+}
+
+// readRESP reads one reply that is not an array: the content of a simple or
+// bulk string or integer, nil for a null bulk string, or the error Redis
+// answered with.
+func readRESP(r *bufio.Reader) ([]byte, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, errors.New("empty reply")
+	}
+	switch line[0] {
+	case '+', ':':
+		return []byte(line[1:]), nil
+	case '-':
+		return nil, errors.New(line[1:])
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("malformed reply %q", line)
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		data := make([]byte, n+2)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil, err
+		}
+		return data[:n], nil
+	}
+	return nil, fmt.Errorf("unexpected reply %q", line)
+}
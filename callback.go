@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"poc-pdf/signature"
+)
+
+// Outcomes reported in a callbackPayload.
+const (
+	callbackDone   = "done"
+	callbackFailed = "failed"
+)
+
+// callbackPayload is the JSON body posted to the callback_url of a request
+// once its document is processed.
+type callbackPayload struct {
+	// ID is the job ID with serve, and the message's "id" with worker.
+	ID      string           `json:"id,omitempty"`
+	State   string           `json:"state"`
+	Error   string           `json:"error,omitempty"`
+	Results []webhookPayload `json:"results"`
+}
+
+// callbackFlags are the flags of the subcommands that accept a callback_url
+// per request.
+type callbackFlags struct {
+	secret  *string
+	timeout *time.Duration
+	retries *int
+}
+
+// addCallbackFlags registers -callback-secret, -callback-timeout and
+// -callback-retries on fs.
+func addCallbackFlags(fs *flag.FlagSet) callbackFlags {
+	return callbackFlags{
+		secret:  fs.String("callback-secret", "", "HMAC-SHA256 key the callback_url posts of requests are signed with (X-Signature-256); callbacks are refused without it"),
+		timeout: fs.Duration("callback-timeout", defaultWebhookTimeout, "bound each callback attempt"),
+		retries: fs.Int("callback-retries", defaultWebhookRetries, "retries of a failed callback, with exponential backoff"),
+	}
+}
+
+// callbacks posts the outcome of requests to the callback_url they name.
+type callbacks struct {
+	secret  []byte
+	timeout time.Duration
+	retries int
+	client  *http.Client
+}
+
+// config reports flag problems into p and returns the callbacks configured,
+// or nil with no -callback-secret.
+func (f callbackFlags) config(p *configProblems) *callbacks {
+	p.check(*f.timeout > 0, "-callback-timeout must be positive, got %v", *f.timeout)
+	p.check(*f.retries >= 0, "-callback-retries must not be negative, got %d", *f.retries)
+	if *f.secret == "" {
+		return nil
+	}
+	return &callbacks{secret: []byte(*f.secret), timeout: *f.timeout, retries: *f.retries, client: &http.Client{}}
+}
+
+// checkCallback validates the callback_url of a request; "" is no callback.
+func (c *callbacks) checkCallback(url string) error {
+	switch {
+	case url == "":
+		return nil
+	case c == nil:
+		return errors.New("callback_url needs the server to be started with -callback-secret")
+	case !validWebhookURL(url):
+		return fmt.Errorf("callback_url must be an http(s) URL, got %q", url)
+	}
+	return nil
+}
+
+// post signs and posts the outcome of the request id, results or err, to url,
+// with the images when includeImage is set. Output paths are left out when
+// clearPaths is set, for outputs in a scratch directory.
+func (c *callbacks) post(ctx context.Context, url, id string, results []*signature.Result, err error, includeImage, clearPaths bool) error {
+	payload := callbackPayload{ID: id, State: callbackDone, Results: []webhookPayload{}}
+	if err != nil {
+		payload.State, payload.Error = callbackFailed, err.Error()
+	}
+	for _, res := range results {
+		p, err := newWebhookPayload(res, includeImage)
+		if err != nil {
+			return err
+		}
+		if clearPaths {
+			p.OutputPath = ""
+		}
+		payload.Results = append(payload.Results, p)
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode callback: %v", err)
+	}
+	hook := &webhook{URL: url, Timeout: c.timeout, Retries: c.retries, Secret: c.secret, client: c.client}
+	if err := hook.send(ctx, body); err != nil {
+		return err
+	}
+	slog.Info("Callback posted", "id", id, "url", url, "state", payload.State)
+	return nil
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"os"
+	"runtime"
+	"strings"
+
+	"poc-pdf/signature"
+)
+
+// configProblems collects every configuration problem found before a run, so
+// they can all be reported at once instead of failing on the first one.
+type configProblems []string
+
+// check records the problem described by format unless ok holds.
+func (p *configProblems) check(ok bool, format string, args ...any) {
+	if !ok {
+		*p = append(*p, fmt.Sprintf(format, args...))
+	}
+}
+
+// Error lists the problems one per line.
+func (p configProblems) Error() string {
+	return "invalid configuration:\n  - " + strings.Join(p, "\n  - ")
+}
+
+// validateOptions checks the ranges of opts and how its settings combine. set
+// holds the names of the flags given on the command line, so that flags which
+// only matter together with another one can be reported when given alone.
+func validateOptions(opts signature.Options, set map[string]bool) configProblems {
+	var p configProblems
+
+	// Ranges
+	p.check(opts.RenderDPI > 0, "-render-dpi must be positive, got %g", opts.RenderDPI)
+	p.check(opts.OutputDPI > 0, "-output-dpi must be positive, got %g", opts.OutputDPI)
+	p.check(opts.DetectWidth >= 0, "-detect-width must not be negative, got %d", opts.DetectWidth)
+	p.check(opts.EdgeMargin >= 0, "-edge-margin must not be negative, got %d", opts.EdgeMargin)
+	p.check(opts.Workers >= 1, "-workers must be at least 1, got %d", opts.Workers)
+	p.check(opts.PipelineDepth >= 1, "-pipeline-depth must be at least 1, got %d", opts.PipelineDepth)
+	p.check(signature.ValidRasterizer(opts.Rasterizer), "-rasterizer must be %s, %s, %s, %s or %s, got %q",
+		signature.RasterizerAuto, signature.RasterizerPoppler, signature.RasterizerMutool, signature.RasterizerGhostscript, signature.RasterizerFitz, opts.Rasterizer)
+	p.check(signature.ValidBinarization(opts.Binarization), "-binarize must be %s, %s, %s or %s, got %q",
+		signature.BinarizeAuto, signature.BinarizeFixed, signature.BinarizeOtsu, signature.BinarizeAdaptive, opts.Binarization)
+	p.check(signature.ValidDetector(opts.Detector), "-detector must be %s or %s, got %q", signature.DetectorContours, signature.DetectorONNX, opts.Detector)
+	p.check(opts.MinDetectorScore >= 0 && opts.MinDetectorScore < 1, "-min-score must be in [0, 1), got %g", opts.MinDetectorScore)
+	p.check(signature.ValidFormat(opts.Format), "-format must be %s, %s, %s, %s, %s, %s, %s, %s or %s, got %q",
+		signature.FormatPNG, signature.FormatWebP, signature.FormatAVIF, signature.FormatTIFF, signature.FormatJPEG, signature.FormatRaw, signature.FormatSVG, signature.FormatPSD, signature.FormatStrokes, opts.Format)
+	p.check(signature.ValidEncoding(opts.Encode), "-encode must be %s or %s, got %q", signature.EncodeBase64, signature.EncodeDataURI, opts.Encode)
+	p.check(signature.ValidDedup(opts.Dedup), "-dedup must be %s, %s or %s, got %q", signature.DedupOff, signature.DedupLink, signature.DedupSkip, opts.Dedup)
+	p.check(opts.DedupDistance >= 1 && opts.DedupDistance <= 64, "-dedup-distance must be in 1-64, got %d", opts.DedupDistance)
+	p.check(opts.Quality >= 0 && opts.Quality <= 100, "-quality must be in 0-100, got %d", opts.Quality)
+	p.check(opts.MinPagePt > 0, "-min-page-pt must be positive, got %g", opts.MinPagePt)
+	p.check(opts.MaxPagePt >= opts.MinPagePt, "-max-page-pt (%g) must not be below -min-page-pt (%g)", opts.MaxPagePt, opts.MinPagePt)
+	p.check(opts.MaxRenderPx >= 1, "-max-render-px must be at least 1, got %d", opts.MaxRenderPx)
+	p.check(opts.MaxRenderBytes >= 0, "-max-render-mb must not be negative, got %d", opts.MaxRenderBytes>>20)
+	p.check(opts.MaxRasterizerMemory >= 0, "-max-rasterizer-memory-mb must not be negative, got %d", opts.MaxRasterizerMemory>>20)
+	p.check(opts.DocumentTimeout >= 0, "-document-timeout must not be negative, got %v", opts.DocumentTimeout)
+	p.check(opts.AlphaGamma > 0, "-alpha-gamma must be positive, got %g", opts.AlphaGamma)
+	p.check(opts.WhiteThreshold >= 1 && opts.WhiteThreshold <= 254, "-white-threshold must be in 1-254, got %d", opts.WhiteThreshold)
+	p.check(opts.MergeGapPx >= 0, "-merge-gap must not be negative, got %d", opts.MergeGapPx)
+	p.check(opts.MedianBlur == 0 || (opts.MedianBlur >= 3 && opts.MedianBlur%2 == 1), "-median-blur must be 0 or an odd size of at least 3, got %d", opts.MedianBlur)
+	p.check(opts.DespeckleArea >= 0, "-despeckle must not be negative, got %d", opts.DespeckleArea)
+	p.check(opts.CanvasPadding >= 0, "-canvas-padding must not be negative, got %d", opts.CanvasPadding)
+	p.check(signature.ValidCanvasAlign(opts.CanvasAlign), "-canvas-align must be %s, %s, %s, %s, %s or a corner such as bottom-left, got %q",
+		signature.AlignCenter, signature.AlignLeft, signature.AlignRight, signature.AlignTop, signature.AlignBottom, opts.CanvasAlign)
+	p.check(opts.Canvas == (image.Point{}) || (2*opts.CanvasPadding < opts.Canvas.X && 2*opts.CanvasPadding < opts.Canvas.Y),
+		"-canvas-padding %d leaves no room on the %dx%d canvas", opts.CanvasPadding, opts.Canvas.X, opts.Canvas.Y)
+	p.check(opts.Format != signature.FormatPSD || (!opts.Trim && opts.Canvas == (image.Point{})), "-trim and -canvas don't apply to -format %s, whose layers keep the crop's size", signature.FormatPSD)
+	p.check(opts.CropPaddingPx >= 0, "-crop-padding must not be negative, got %d", opts.CropPaddingPx)
+	p.check(opts.PrintDPI >= 0, "-print-dpi must not be negative, got %g", opts.PrintDPI)
+	p.check(opts.Palette == 0 || (opts.Palette >= signature.MinPaletteSize && opts.Palette <= signature.MaxPaletteSize),
+		"-palette must be 0 or in %d-%d, got %d", signature.MinPaletteSize, signature.MaxPaletteSize, opts.Palette)
+
+	// Mutually exclusive settings
+	p.check(!(opts.AutoOrient && opts.AssumeUpsideDown), "-auto-orient and -assume-upside-down are mutually exclusive")
+	p.check(!(opts.PCAAlign && opts.Straighten), "-pca-align and -straighten are mutually exclusive")
+	p.check(!(set["dpi"] && set["render-dpi"] && set["output-dpi"]), "-dpi has no effect when both -render-dpi and -output-dpi are set")
+	p.check(!(opts.SoftAlpha && opts.Decontaminate), "-soft-alpha already unmixes edge colors from the paper; drop -decontaminate")
+	p.check(!(opts.Decontaminate && opts.InkColor != "" && opts.InkColor != signature.InkOriginal), "-ink-color %s replaces the edge colors -decontaminate unmixes; drop -decontaminate", opts.InkColor)
+	p.check(opts.Password == "" || opts.Rasterizer != signature.RasterizerFitz, "-password needs the %s, %s or %s rasterizer; %s can't open encrypted PDFs", signature.RasterizerPoppler, signature.RasterizerMutool, signature.RasterizerGhostscript, signature.RasterizerFitz)
+	p.check(opts.Detector != signature.DetectorONNX || !opts.NoShapeFilter, "-no-shape-filter has no effect with -detector %s, which applies no shape filters", signature.DetectorONNX)
+	if opts.Template != nil {
+		p.check(opts.Pages == nil, "-pages has no effect with -template; each zone names its page")
+		p.check(!opts.AllRegions, "-all-regions has no effect with -template, which writes every zone")
+		p.check(len(opts.Anchors) == 0, "-anchor has no effect with -template, which crops its zones without searching")
+		p.check(opts.Detector != signature.DetectorONNX, "-detector %s has no effect with -template, which crops its zones without detecting", signature.DetectorONNX)
+		p.check(!opts.Marks, "-marks has no effect with -template; give the boxes as zones of kind %s or %s", signature.MarkCheckbox, signature.MarkInitials)
+	}
+	p.check(!(opts.Seals && opts.DryRun), "-seals can't be combined with -dry-run, whose preview lists signature candidates only")
+	p.check(!(opts.Marks && opts.DryRun), "-marks can't be combined with -dry-run, whose preview lists signature candidates only")
+	p.check(!(opts.SignerOCR && opts.DryRun), "-signer-ocr reads the text next to the signatures -dry-run does not extract")
+	p.check(!(opts.DryRun && set["dedup"]), "-dedup compares the signatures -dry-run does not extract")
+	p.check(!(opts.DryRun && opts.Encode != ""), "-encode encodes the signatures -dry-run does not write")
+	p.check(opts.Palette == 0 || opts.Format == signature.FormatPNG, "-palette only applies to -format %s, got %q", signature.FormatPNG, opts.Format)
+
+	p.check(opts.MaxRasterizerMemory == 0 || runtime.GOOS == "linux", "-max-rasterizer-memory-mb is only enforced on Linux")
+	p.check(opts.MaxRasterizerMemory == 0 || opts.Rasterizer != signature.RasterizerFitz, "-max-rasterizer-memory-mb caps subprocesses; the %s rasterizer runs in-process", signature.RasterizerFitz)
+
+	// Flags that need another one
+	p.check(!set["confidence-buckets"] || set["sort-by-confidence"] || opts.DryRun, "-confidence-buckets requires -sort-by-confidence or -dry-run")
+	p.check(!set["quality"] || opts.Format == signature.FormatAVIF || opts.Format == signature.FormatWebP || opts.Format == signature.FormatJPEG,
+		"-quality only applies to -format %s, %s or %s, got %q", signature.FormatAVIF, signature.FormatWebP, signature.FormatJPEG, opts.Format)
+	p.check(!set["canvas-padding"] || set["canvas"], "-canvas-padding requires -canvas")
+	p.check(!set["canvas-align"] || set["canvas"], "-canvas-align requires -canvas")
+	p.check(!set["strip-spacing"] || set["strip"], "-strip-spacing requires -strip")
+	p.check(!set["alpha-gamma"] || set["soft-alpha"], "-alpha-gamma requires -soft-alpha")
+	p.check(!set["signer-area"] || opts.SignerOCR, "-signer-area requires -signer-ocr")
+	p.check(!set["dedup-distance"] || opts.Dedup == signature.DedupLink || opts.Dedup == signature.DedupSkip, "-dedup-distance requires -dedup %s or %s", signature.DedupLink, signature.DedupSkip)
+	p.check(!set["ocr-lang"] || len(opts.Anchors) > 0 || len(opts.Templates) > 0, "-ocr-lang requires -anchor or -templates")
+	p.check(opts.Detector != signature.DetectorONNX || opts.DetectorModel != "", "-detector %s requires -model", signature.DetectorONNX)
+	p.check(opts.DetectorModel == "" || opts.Detector == signature.DetectorONNX, "-model requires -detector %s", signature.DetectorONNX)
+	p.check(!set["min-score"] || opts.Detector == signature.DetectorONNX, "-min-score requires -detector %s", signature.DetectorONNX)
+	if opts.DetectorModel != "" {
+		_, err := os.Stat(opts.DetectorModel)
+		p.check(err == nil, "cannot read -model: %v", err)
+	}
+	for _, name := range []string{"webhook-timeout", "webhook-retries", "webhook-image"} {
+		p.check(!set[name] || set["webhook"], "-%s requires -webhook", name)
+	}
+	return p
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"poc-pdf/signature"
+)
+
+func TestValidateOptionsReportsEveryProblem(t *testing.T) {
+	opts := signature.DefaultOptions()
+	opts.AutoOrient, opts.AssumeUpsideDown = true, true
+	opts.PCAAlign, opts.Straighten = true, true
+	opts.Quality = 200
+	p := validateOptions(opts, map[string]bool{})
+	for _, want := range []string{"-auto-orient and -assume-upside-down", "-pca-align and -straighten", "-quality must be in 0-100"} {
+		if !strings.Contains(p.Error(), want) {
+			t.Errorf("problems %q do not report %q", p, want)
+		}
+	}
+	if p := validateOptions(signature.DefaultOptions(), map[string]bool{}); len(p) != 0 {
+		t.Errorf("default options: %v", p)
+	}
+}
+
+func TestConflictingFlagsReportedTogether(t *testing.T) {
+	page := writeSignaturePNG(t, t.TempDir(), "page.png")
+	err := runExtract([]string{"-check-config", "-auto-orient", "-assume-upside-down", "-pca-align", "-straighten",
+		"-soft-alpha", "-decontaminate", "-format", "avif", "-quality", "200", page})
+	var problems configProblems
+	if !errors.As(err, &problems) {
+		t.Fatalf("runExtract = %v, want configProblems", err)
+	}
+	want := []string{
+		"-auto-orient and -assume-upside-down are mutually exclusive",
+		"-pca-align and -straighten are mutually exclusive",
+		"drop -decontaminate",
+		"-quality must be in 0-100, got 200",
+	}
+	for _, w := range want {
+		if !strings.Contains(problems.Error(), w) {
+			t.Errorf("problem %q missing from:\n%s", w, problems.Error())
+		}
+	}
+	if len(problems) != len(want) {
+		t.Errorf("%d problems, want only the %d conflicts given:\n%s", len(problems), len(want), problems.Error())
+	}
+}
@@ -0,0 +1,452 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"syscall"
+
+	"poc-pdf/signature"
+)
+
+// errTimedOut ends a run cut short by -timeout; exitOnError exits with
+// exitTimeout for it.
+var errTimedOut = errors.New("timed out")
+
+// timedOut reports whether the run failed with err because the deadline of
+// ctx passed. Only a failure the deadline caused is a timeout; a run that
+// failed on its own, or finished its work just before the deadline, exits as
+// usual.
+func timedOut(ctx context.Context, err error) bool {
+	return errors.Is(err, context.DeadlineExceeded) && errors.Is(ctx.Err(), context.DeadlineExceeded)
+}
+
+// runExtract implements the extract subcommand, also run when no subcommand is
+// named: it extracts the signatures of the PDFs, emails and images in args.
+func runExtract(args []string) error {
+	return runExtraction("extract", args, false)
+}
+
+// runBatch implements the batch subcommand: the extract flags applied to every
+// PDF below one directory, with outputs mirrored under -out.
+func runBatch(args []string) error {
+	return runExtraction("batch", args, true)
+}
+
+// runExtraction parses the extraction flags of the subcommand name from args
+// and runs the pipeline on the inputs; in batch mode the single input is a
+// directory.
+func runExtraction(name string, args []string, batchMode bool) error {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	dpi := fs.Float64("dpi", signature.DefaultDPI, "resolution used to render the PDF page (sets both -render-dpi and -output-dpi)")
+	renderDPI := fs.Float64("render-dpi", 0, "resolution of the render used for detection (default -dpi)")
+	outputDPI := fs.Float64("output-dpi", 0, "resolution of the render the output is cropped from (default -render-dpi)")
+	detectWidth := fs.Int("detect-width", 0, "detect on a copy of the page shrunk to at most this many pixels wide, then crop the output from the full-resolution render (e.g. 1000)")
+	edgeMargin := fs.Int("edge-margin", signature.DefaultEdgeMargin, "distance in pixels from the page border that counts as touching it")
+	minPagePt := fs.Float64("min-page-pt", signature.DefaultMinPagePt, "reject pages narrower or shorter than this many points")
+	maxPagePt := fs.Float64("max-page-pt", signature.DefaultMaxPagePt, "reject pages wider or taller than this many points")
+	maxRenderPx := fs.Int("max-render-px", signature.DefaultMaxRenderPx, "lower the DPI so no rendered page side exceeds this many pixels")
+	limits := addLimitFlags(fs)
+	cache := addCacheFlags(fs)
+	timeout := fs.Duration("timeout", 0, "bound the whole run (e.g. 30s, 10m); 0 means no limit. Exits with status 124 when exceeded")
+	warmup := fs.Bool("warmup", false, "validate the rasterizer with a tiny test render before processing and fail fast if broken")
+	inkColor := fs.String("ink-color", signature.InkOriginal, "color of the output ink: original (as scanned), black, or #RRGGBB; alpha is kept")
+	chromaKeyFlag := fs.String("chroma-key", "", "remove a colored paper background: auto (sample page corners) or #RRGGBB")
+	rasterizer := fs.String("rasterizer", signature.RasterizerAuto, "PDF rendering backend: auto (the first available of fitz if built with -tags fitz, poppler, mutool and ghostscript), poppler (pdftoppm/pdfinfo), mutool (MuPDF's command-line tool), ghostscript (gs) or fitz (built-in MuPDF)")
+	password := fs.String("password", "", "password of an encrypted PDF (not supported by -rasterizer fitz)")
+	pagesFlag := fs.String("pages", "", "pages to process, e.g. 1,3,5-7,last or 2-last (default all)")
+	roi := fs.String("roi", "", "render only this page region, in PDF points: llx,lly,urx,ury")
+	format := fs.String("format", signature.FormatPNG, "output format: png, webp (needs cwebp), avif (needs avifenc), tiff, jpeg (on white, no transparency), rgba (raw pixels), svg (traced vector outlines), psd (layered: original crop + signature) or strokes (JSON polylines)")
+	quality := fs.Int("quality", signature.DefaultQuality, "0-100 quality for lossy formats (avif, webp, jpeg)")
+	sortByConfidence := fs.Bool("sort-by-confidence", false, "write outputs into high/, medium/ and low/ folders by detection confidence")
+	bucketsFlag := fs.String("confidence-buckets", fmt.Sprintf("%g,%g", signature.DefaultHighConfidence, signature.DefaultMediumConfidence), "lower confidence bounds of the high and medium buckets")
+	palette := fs.Int("palette", 0, fmt.Sprintf("quantize PNG output to an indexed image of at most N colors (%d-%d) including transparency; 0 keeps full RGBA", signature.MinPaletteSize, signature.MaxPaletteSize))
+	webhookURL := fs.String("webhook", "", "POST each result as JSON to this URL as it completes")
+	webhookTimeout := fs.Duration("webhook-timeout", defaultWebhookTimeout, "timeout for each -webhook delivery attempt")
+	webhookRetries := fs.Int("webhook-retries", defaultWebhookRetries, "how many times a failed -webhook delivery is retried")
+	webhookImage := fs.Bool("webhook-image", false, "include the signature PNG, base64-encoded, in -webhook payloads")
+	strip := fs.String("strip", "", "also stack every signature of the run into this transparent PNG, labeled by document and page")
+	stripSpacing := fs.Int("strip-spacing", signature.DefaultStripSpacing, "gap between -strip entries in pixels")
+	watchDir := fs.String("watch", "", "instead of inputs, process every PDF dropped into this directory as it lands, moving it into done/ or failed/ below it, until interrupted")
+	watchSettle := fs.Duration("watch-settle", defaultWatchSettle, "how long a file dropped into -watch must go without writes before it is processed")
+	reportPath := fs.String("report", "", "also write a row per input of a batch or -watch (status, pages, signatures, confidence, duration, outputs, error) to this .csv or .jsonl file")
+	outDir := addOutFlag(fs, "directory or s3:// / gs:// prefix outputs are written to (default the current directory), or - to write the signature to stdout")
+	workers := fs.Int("workers", runtime.NumCPU(), "number of documents processed concurrently when several inputs are given")
+	pipelineDepth := fs.Int("pipeline-depth", signature.DefaultPipelineDepth, "pages rendered ahead of detection, and outputs queued for encoding behind it, per document")
+	strict := fs.Bool("strict", false, "reject signatures that touch the page edge instead of warning")
+	autoOrient := fs.Bool("auto-orient", false, "detect upside-down (180°) pages from the text and turn them over")
+	assumeUpsideDown := fs.Bool("assume-upside-down", false, "turn every page over by 180° without detection")
+	pcaAlign := fs.Bool("pca-align", false, "rotate the signature so the principal axis of its ink is horizontal")
+	straighten := fs.Bool("straighten", false, "rotate the signature so the long side of the minimum-area rectangle around its ink is horizontal")
+	deskew := fs.Bool("deskew", false, "measure the tilt of scanned pages from their text lines (up to 10°) and level them before detection")
+	decontaminate := fs.Bool("decontaminate", false, "remove the paper color from semi-transparent edge pixels (reduces halos on dark backgrounds)")
+	printDPI := fs.Float64("print-dpi", 0, "resample the output so it prints at its original physical size at this DPI")
+	detectBaseline := fs.Bool("detect-baseline", false, "report the signature baseline and write crops above and below it")
+	binarization := fs.String("binarize", signature.BinarizeAuto, "how ink is separated from paper: fixed (cutoff 200), otsu, adaptive (uneven lighting) or auto (chosen from the page histogram)")
+	colorInk := fs.Bool("color-ink", false, "also count strongly colored pixels (Lab chroma) as ink however light they are, so faint blue and purple ballpoint strokes are kept; use -chroma-key with it on tinted paper")
+	classes := fs.String("classes", "", "keep only regions of these comma-separated classes: handwritten, stamp, printed-text; e.g. handwritten skips facsimile stamps and typed names (default every class)")
+	noShapeFilter := fs.Bool("no-shape-filter", false, "do not reject regions that look like printed text or solid graphics (logos, stamps) before picking the signature")
+	detector := fs.String("detector", signature.DetectorContours, "how the signature is found: contours (binarization and shape heuristics) or onnx (an object-detection model, see -model)")
+	model := fs.String("model", "", "ONNX signature-detection model for -detector onnx (YOLOv8-style output)")
+	minScore := fs.Float64("min-score", signature.DefaultMinDetectorScore, "model score below which -detector onnx boxes are discarded")
+	mergeGap := fs.Int("merge-gap", 0, "group ink contours within this many pixels (at -render-dpi) into one region, for light-pressure signatures that break into pieces")
+	flattenIllumination := fs.Bool("flatten-illumination", false, "divide each page by a blurred estimate of its paper brightness, evening out shadows and gradients in phone photos")
+	perspective := fs.Bool("perspective", false, "find the outline of a document photographed at an angle and warp the page flat along it before detection")
+	profile := fs.String("profile", "", "preset of flags for a kind of input, or one defined in -config; explicit flags override it: "+profileUsage())
+	trim := fs.Bool("trim", false, "cut the output to the bounding box of its visible pixels")
+	canvas := fs.String("canvas", "", "trim the output and fit it onto a transparent canvas of this size, e.g. 600x200, so every output has the same size")
+	canvasPadding := fs.Int("canvas-padding", 0, "pixels kept free on every side of the -canvas")
+	canvasAlign := fs.String("canvas-align", signature.AlignCenter, "where the signature sits on the -canvas: center, left, right, top, bottom, or a corner such as bottom-left")
+	medianBlur := fs.Int("median-blur", 0, "median-filter the page with this odd kernel size (e.g. 3 or 5) before thresholding, to suppress dust and JPEG artifacts; 0 disables")
+	despeckle := fs.Int("despeckle", 0, "make groups of connected opaque pixels smaller than this many pixels transparent in the output; 0 disables")
+	removeLines := fs.Bool("remove-lines", false, "erase printed signing lines and form box edges from detection and output, repairing the strokes that cross them")
+	noFormFields := fs.Bool("no-form-fields", false, "ignore the PDF's AcroForm signature fields and detect the signature on every page")
+	digitalSignatures := fs.Bool("digital-signatures", false, "also read the PDF's digital signatures: log each with its signer, signing time and whether its byte range is intact, and add it to the -json metadata of the signature cropped from its field")
+	embeddedScans := fs.Bool("embedded-scans", false, "decode pages that are one scanned image (e.g. a JPEG per page) straight from the PDF at the scan's own resolution instead of rasterizing them; other pages are rasterized")
+	template := fs.String("template", "", "YAML or JSON file of named zones (a page and a rectangle in fractions of it) cropped from every document instead of detecting, with outputs named after the zones, e.g. buyer.png and seller.png")
+	templates := fs.String("templates", "", "directory of templates with match fingerprints (page size, page count, header text or hash; see the fingerprint command); each document is cropped with the one it matches, or detected when none does")
+	seals := fs.Bool("seals", false, "also extract round red and blue company seals (Hough circles in the seal colors) as transparent seal_1.png, seal_2.png, ... with their own metadata")
+	marks := fs.Bool("marks", false, "also find printed checkboxes and initials boxes, judge whether each was marked, and write crops of the marked ones plus a {name}_marks.json listing every box")
+	softAlpha := fs.Bool("soft-alpha", false, "derive alpha from ink darkness so anti-aliased stroke edges are partially transparent")
+	whiteThreshold := fs.Int("white-threshold", signature.DefaultWhiteThreshold, "per-channel level (1-254) above which a crop pixel becomes transparent; raise it to keep light pencil")
+	cropPadding := fs.Int("crop-padding", 0, "grow the crop by this many pixels (at -render-dpi) on every side so strokes on the bounding box aren't clipped")
+	alphaGamma := fs.Float64("alpha-gamma", signature.DefaultAlphaGamma, "gamma of the -soft-alpha curve; below 1 makes light strokes more opaque")
+	anchors := fs.String("anchor", "", "comma-separated printed labels (e.g. \"Signature:,Assinatura:\") to find by OCR; only the area right of and below them is searched (needs tesseract)")
+	ocrLang := fs.String("ocr-lang", signature.DefaultOCRLanguage, "Tesseract language(s) for -anchor, -templates header text and -signer-ocr, e.g. eng+por")
+	signerOCR := fs.Bool("signer-ocr", false, "read the printed name and the date next to each signature by OCR and report them in the -json metadata and webhook payloads (needs tesseract)")
+	signerArea := fs.String("signer-area", "5,10,25,10", "neighbourhood of the signature -signer-ocr reads, in millimetres: one distance for every side or TOP,RIGHT,BOTTOM,LEFT")
+	dedup := fs.String("dedup", signature.DedupOff, "what to do with a signature that looks the same as one written before in the run, e.g. another scan of the same page: off, link (name the first as duplicate_of in -json metadata) or skip (remove it)")
+	dedupDistance := fs.Int("dedup-distance", signature.DefaultDedupDistance, "how many of the 64 bits of two signatures' ink hashes may differ for -dedup to count them as the same")
+	encode := fs.String("encode", "", "print each output to stdout (and into its -json metadata) as base64 or datauri instead of keeping the file")
+	metadata := fs.Bool("json", false, "write a .meta.json file next to each output with its source, page, bounds (px and pt), confidence and DPI")
+	allRegions := fs.Bool("all-regions", false, "write every signature-sized ink region of a page as signature_1, signature_2, ... instead of only the largest")
+	dryRun := fs.Bool("dry-run", false, "detect without writing signatures: write each page's render with the candidate regions boxed by confidence as {name}_preview.png, and their listing as {name}_preview.json")
+	checkConfig := fs.Bool("check-config", false, "validate all flags and inputs, report every problem, and exit without processing")
+	keepTemp := fs.Bool("keep-temp", false, "debug: keep each document's temporary directory of page renders (below $TMPDIR) instead of removing it, and log where it is")
+	debugDir := fs.String("debug-dir", "", "debug: write each page's render, grayscale, binary mask, candidate boxes and pre-transparency crop here, numbered by stage")
+	logging := addLogFlags(fs)
+	traces := addTraceFlags(fs)
+	thresholdSweep := fs.String("threshold-sweep", "", "debug: comma-separated thresholds to render into threshold_sweep.gif (e.g. 150,175,200,225)")
+	fs.Usage = func() {
+		if batchMode {
+			fmt.Fprintln(fs.Output(), "Usage: go run . batch [flags] <directory>   (every PDF below it, outputs mirrored under -out)")
+		} else {
+			fmt.Fprintln(fs.Output(), "Usage: go run . [extract] [flags] <path_to_pdf_eml_or_image | -> [more.pdf ...]   (- reads stdin)")
+		}
+		fs.PrintDefaults()
+	}
+	// Flags given on the command line, in the environment or in -config; a
+	// -profile only fills in the others
+	set, problems := parseFlags(fs, args)
+	profileErr := applyProfile(fs, *profile, set)
+
+	if *renderDPI == 0 {
+		*renderDPI = *dpi
+	}
+	if *outputDPI == 0 {
+		*outputDPI = *renderDPI
+	}
+
+	opts := signature.Options{
+		RenderDPI:           *renderDPI,
+		OutputDPI:           *outputDPI,
+		DetectWidth:         *detectWidth,
+		EdgeMargin:          *edgeMargin,
+		Format:              *format,
+		Rasterizer:          *rasterizer,
+		Password:            *password,
+		Quality:             *quality,
+		Palette:             *palette,
+		MinPagePt:           *minPagePt,
+		MaxPagePt:           *maxPagePt,
+		MaxRenderPx:         *maxRenderPx,
+		OutputDir:           *outDir,
+		DebugDir:            *debugDir,
+		KeepTemp:            *keepTemp,
+		Workers:             *workers,
+		PipelineDepth:       *pipelineDepth,
+		Strict:              *strict,
+		AutoOrient:          *autoOrient,
+		AssumeUpsideDown:    *assumeUpsideDown,
+		PCAAlign:            *pcaAlign,
+		Straighten:          *straighten,
+		Deskew:              *deskew,
+		Decontaminate:       *decontaminate,
+		PrintDPI:            *printDPI,
+		DetectBaseline:      *detectBaseline,
+		AllRegions:          *allRegions,
+		DryRun:              *dryRun,
+		Metadata:            *metadata,
+		Dedup:               *dedup,
+		DedupDistance:       *dedupDistance,
+		Encode:              *encode,
+		Binarization:        *binarization,
+		ColorInk:            *colorInk,
+		NoShapeFilter:       *noShapeFilter,
+		NoFormFields:        *noFormFields,
+		EmbeddedScans:       *embeddedScans,
+		DigitalSignatures:   *digitalSignatures,
+		Seals:               *seals,
+		Marks:               *marks,
+		RemoveLines:         *removeLines,
+		MergeGapPx:          *mergeGap,
+		MedianBlur:          *medianBlur,
+		FlattenIllumination: *flattenIllumination,
+		CorrectPerspective:  *perspective,
+		DespeckleArea:       *despeckle,
+		Trim:                *trim,
+		CanvasPadding:       *canvasPadding,
+		CanvasAlign:         *canvasAlign,
+		Detector:            *detector,
+		DetectorModel:       *model,
+		MinDetectorScore:    *minScore,
+		SoftAlpha:           *softAlpha,
+		Anchors:             signature.ParseAnchors(*anchors),
+		OCRLanguage:         *ocrLang,
+		SignerOCR:           *signerOCR,
+		AlphaGamma:          *alphaGamma,
+		WhiteThreshold:      *whiteThreshold,
+		CropPaddingPx:       *cropPadding,
+	}
+	limits.apply(&opts)
+	cache.apply(&opts, &problems)
+
+	// Collect every problem before running so a misconfigured invocation is fixed in one go
+	problems.check(profileErr == nil, "-profile: %v", profileErr)
+	logger, err := setupLogging(logging)
+	problems.check(err == nil, "%v", err)
+	opts.Logger = logger
+	tracer := traces.config(&problems)
+	watching := *watchDir != ""
+	problems.check(fs.NArg() >= 1 || *checkConfig || watching, "no input file given")
+	var stdinInputs int
+	for _, input := range fs.Args() {
+		if input == stdioArg {
+			// Read once the configuration is known to be good
+			stdinInputs++
+			continue
+		}
+		if isObjectURL(input) {
+			// Fetched after validation; only the URL itself is checked here
+			_, err := parseObjectURL(input)
+			problems.check(err == nil, "input: %v", err)
+			continue
+		}
+		info, err := os.Stat(input)
+		problems.check(err == nil, "cannot read input: %v", err)
+		if err == nil {
+			problems.check(batchMode || !info.IsDir(), "%s is a directory; use \"batch %s\" to process the PDFs in it", input, input)
+			problems.check(!batchMode || info.IsDir(), "batch needs a directory, got the file %s", input)
+		}
+	}
+	problems.check(!batchMode || fs.NArg() <= 1, "batch takes exactly one directory, got %d inputs", fs.NArg())
+	problems.check(stdinInputs <= 1, "stdin (-) can only be read once, got it %d times", stdinInputs)
+	problems.check(!batchMode || stdinInputs == 0, "batch needs a directory, not stdin")
+	toStdout := *outDir == stdioArg
+	if toStdout {
+		problems.check(!batchMode && fs.NArg() <= 1, "-out - writes one signature to stdout; give a single input")
+		problems.check(!*metadata, "-json writes sidecar files, which -out - has nowhere to put")
+		problems.check(!*dryRun, "-out - writes the signature -dry-run does not extract")
+		problems.check(*encode == "", "-out - and -encode both write the signature to stdout; pick one")
+	}
+	var remoteOut *objectURL
+	if isObjectURL(*outDir) {
+		u, err := parseObjectURL(*outDir)
+		problems.check(err == nil, "-out: %v", err)
+		remoteOut = &u
+	}
+	problems.check(!isObjectURL(*strip), "-strip must be a local path, got %s", *strip)
+	problems.check(!isObjectURL(*debugDir), "-debug-dir must be a local path, got %s", *debugDir)
+	problems.check(*timeout >= 0, "-timeout must not be negative, got %v", *timeout)
+	if *reportPath != "" {
+		problems.check(!isObjectURL(*reportPath), "-report must be a local path, got %s", *reportPath)
+		problems.check(validReportPath(*reportPath), "-report: want a .csv, .jsonl or .ndjson file, got %s", *reportPath)
+		problems.check(batchMode || fs.NArg() > 1 || watching, "-report describes a batch; give several inputs or use batch or -watch")
+	}
+	if watching {
+		problems.check(!batchMode, "-watch processes the directory itself; use extract -watch, not batch")
+		problems.check(fs.NArg() == 0, "-watch takes no inputs, got %d", fs.NArg())
+		info, err := os.Stat(*watchDir)
+		problems.check(err == nil && info.IsDir(), "-watch needs a local directory, got %s", *watchDir)
+		problems.check(*watchSettle > 0, "-watch-settle must be positive, got %v", *watchSettle)
+		problems.check(remoteOut == nil && !toStdout, "-watch writes its outputs to a local -out directory")
+		problems.check(*strip == "", "-strip is written when a run ends, which -watch doesn't")
+		problems.check(*timeout == 0, "-timeout bounds a whole run, which -watch doesn't end; use -document-timeout")
+	}
+	problems.check(*stripSpacing >= 0, "-strip-spacing must not be negative, got %d", *stripSpacing)
+	problems.check(*webhookTimeout > 0, "-webhook-timeout must be positive, got %v", *webhookTimeout)
+	problems.check(*webhookRetries >= 0, "-webhook-retries must not be negative, got %d", *webhookRetries)
+	if *webhookURL != "" {
+		problems.check(validWebhookURL(*webhookURL), "-webhook must be an http(s) URL, got %q", *webhookURL)
+		hook := newWebhook(*webhookURL, *webhookTimeout, *webhookRetries, *webhookImage)
+		opts.OnResult = func(ctx context.Context, res *signature.Result) error {
+			if err := hook.post(ctx, res); err != nil {
+				return err
+			}
+			slog.Info("Result posted", "url", hook.URL, "page", res.Page)
+			return nil
+		}
+	}
+	if err := signature.ValidateInkColor(*inkColor); err != nil {
+		problems.check(false, "-ink-color: %v", err)
+	}
+	opts.InkColor = *inkColor
+	if err := signature.ValidateChromaKey(*chromaKeyFlag); err != nil {
+		problems.check(false, "-chroma-key: %v", err)
+	}
+	opts.ChromaKey = *chromaKeyFlag
+	if *sortByConfidence || set["confidence-buckets"] {
+		b, err := signature.ParseConfidenceBuckets(*bucketsFlag)
+		problems.check(err == nil, "-confidence-buckets: %v", err)
+		if *sortByConfidence || *dryRun {
+			// A dry run colors the preview boxes by the same buckets
+			opts.Buckets = &b
+		}
+	}
+	problems.check(!*dryRun || *strip == "", "-strip needs the signatures -dry-run does not write")
+	problems.check(!*dryRun || *webhookURL == "", "-webhook posts the signatures -dry-run does not write")
+	if set["signer-area"] {
+		a, err := signature.ParseSignerArea(*signerArea)
+		problems.check(err == nil, "-signer-area: %v", err)
+		opts.SignerArea = &a
+	}
+	if *classes != "" {
+		var err error
+		opts.Classes, err = signature.ParseClasses(*classes)
+		problems.check(err == nil, "-classes: %v", err)
+	}
+	if *pagesFlag != "" {
+		var err error
+		opts.Pages, err = signature.ParsePageSelection(*pagesFlag)
+		problems.check(err == nil, "-pages: %v", err)
+	}
+	if *roi != "" {
+		r, err := signature.ParsePDFRect(*roi)
+		problems.check(err == nil, "-roi: %v", err)
+		opts.ROI = &r
+	}
+	if *template != "" {
+		var err error
+		opts.Template, err = signature.ParseTemplate(*template)
+		problems.check(err == nil, "-template: %v", err)
+	}
+	if *templates != "" {
+		var err error
+		opts.Templates, err = signature.LoadTemplates(*templates)
+		problems.check(err == nil, "-templates: %v", err)
+	}
+	problems.check(*template == "" || *templates == "", "-template and -templates are mutually exclusive")
+	if *canvas != "" {
+		var err error
+		opts.Canvas, err = signature.ParseCanvasSize(*canvas)
+		problems.check(err == nil, "-canvas: %v", err)
+	}
+	if *thresholdSweep != "" {
+		var err error
+		opts.Sweep, err = signature.ParseThresholds(*thresholdSweep)
+		problems.check(err == nil, "-threshold-sweep: %v", err)
+	}
+	problems = append(problems, validateOptions(opts, set)...)
+
+	if len(problems) > 0 {
+		return problems
+	}
+	if *checkConfig {
+		fmt.Println("Configuration OK")
+		return nil
+	}
+	stopTracing, err := tracer.start(context.Background())
+	if err != nil {
+		return withStatus(exitEnvironment, err)
+	}
+	defer stopTracing()
+
+	if *outDir != "" && remoteOut == nil && !toStdout {
+		if err := os.MkdirAll(*outDir, 0o755); err != nil {
+			return withStatus(exitEnvironment, fmt.Errorf("failed to create output directory: %v", err))
+		}
+	}
+
+	// The root context bounds the whole run; everything below inherits its
+	// deadline. An interrupt cancels it too, so temporary files are still removed
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if *timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *timeout)
+		defer cancel()
+	}
+
+	logRasterizer(opts)
+	if *warmup {
+		if err := signature.WarmupRasterizer(ctx, *rasterizer); err != nil {
+			return withStatus(exitEnvironment, fmt.Errorf("environment check failed: %w", err))
+		}
+		slog.Info("Rasterizer warmup OK")
+	}
+
+	// Object storage inputs and -out go through a local staging directory
+	inputs := fs.Args()
+	stores := newObjectStores()
+	staging, err := os.MkdirTemp("", "poc-pdf-staging-")
+	if err != nil {
+		return withStatus(exitEnvironment, fmt.Errorf("failed to create staging directory: %v", err))
+	}
+	defer os.RemoveAll(staging)
+	if inputs, err = stageInputs(ctx, stores, inputs, batchMode, filepath.Join(staging, "in")); err != nil {
+		return withStatus(exitEnvironment, fmt.Errorf("failed to fetch inputs: %w", err))
+	}
+	if remoteOut != nil || toStdout {
+		opts.OutputDir = filepath.Join(staging, "out")
+		if err := os.MkdirAll(opts.OutputDir, 0o755); err != nil {
+			return withStatus(exitEnvironment, fmt.Errorf("failed to create output directory: %v", err))
+		}
+	}
+
+	var report *batchReport
+	if *reportPath != "" {
+		if report, err = openReport(*reportPath); err != nil {
+			return withStatus(exitEnvironment, err)
+		}
+	}
+	ex := signature.NewExtractor(opts)
+	var results []*signature.Result
+	if watching {
+		err = runWatch(ctx, ex, watchOptions{Dir: *watchDir, Settle: *watchSettle, Workers: *workers}, report)
+	} else {
+		results, err = runInputs(ctx, ex, inputs, batchMode, stripOptions{Path: *strip, Spacing: *stripSpacing}, report)
+	}
+	cutShort := timedOut(ctx, err)
+	if reportErr := report.close(); reportErr != nil {
+		err = errors.Join(err, withStatus(exitEnvironment, reportErr))
+	}
+	if toStdout && err == nil {
+		err = streamOutput(os.Stdout, results)
+	}
+	if opts.Encode != "" && !batchMode && len(inputs) == 1 {
+		// Batches print them on their OK lines
+		for _, res := range results {
+			fmt.Println(res.Encoded)
+		}
+	}
+	if remoteOut != nil {
+		// Upload whatever was written, including the outputs of a failed batch
+		n, uploadErr := publishOutputs(ctx, stores, opts.OutputDir, *remoteOut)
+		slog.Info("Uploaded outputs", "files", n, "to", remoteOut.String())
+		if uploadErr != nil {
+			err = errors.Join(err, withStatus(exitEnvironment, uploadErr))
+			cutShort = cutShort || timedOut(ctx, uploadErr)
+		}
+	}
+	if cutShort {
+		// Outputs of documents that finished in time are already on disk
+		return fmt.Errorf("%w after %v: %v", errTimedOut, *timeout, err)
+	}
+	return err
+}
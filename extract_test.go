@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"poc-pdf/signature"
+)
+
+// fakePoppler puts a pdfinfo describing a one-page letter document and a
+// pdftoppm that never finishes first on PATH, so every PDF input stalls in
+// rasterization until it is cancelled.
+func fakePoppler(t *testing.T) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake rasterizer is a shell script")
+	}
+	dir := t.TempDir()
+	tools := map[string]string{
+		"pdfinfo": "#!/bin/sh\necho 'Pages:          1'\n" +
+			"echo 'Page    1 CropBox:     0.00     0.00   612.00   792.00'\necho 'Page    1 rot:  0'\n",
+		"pdftoppm": "#!/bin/sh\nexec sleep 600\n",
+	}
+	for name, script := range tools {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(script), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+func TestTimeoutKeepsFinishedOutputs(t *testing.T) {
+	fakePoppler(t)
+	in, out := t.TempDir(), t.TempDir()
+	report := filepath.Join(out, "report.jsonl")
+	args := []string{"-dpi", "100", "-workers", "2", "-timeout", "3s", "-out", out, "-report", report}
+	// The image finishes in well under the deadline; the PDFs never do
+	args = append(args, writeSignaturePNG(t, in, "fast.png"))
+	for i := range 20 {
+		path := filepath.Join(in, fmt.Sprintf("slow_%02d.pdf", i))
+		if err := os.WriteFile(path, []byte("%PDF-1.4\n%%EOF\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		args = append(args, path)
+	}
+
+	start := time.Now()
+	err := runExtract(args)
+	elapsed := time.Since(start)
+	if !errors.Is(err, errTimedOut) {
+		t.Fatalf("runExtract = %v, want errTimedOut", err)
+	}
+	// The deadline, plus a few seconds for killed subprocesses to be reaped
+	if elapsed > 10*time.Second {
+		t.Errorf("run took %v after a 3s timeout", elapsed)
+	}
+
+	if _, err := os.Stat(filepath.Join(out, "fast_signature_result.png")); err != nil {
+		t.Errorf("output of the document finished in time is missing: %v", err)
+	}
+	statuses := map[string]int{}
+	f, err := os.Open(report)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var row reportRow
+		if err := json.Unmarshal(scanner.Bytes(), &row); err != nil {
+			t.Fatalf("report row %q: %v", scanner.Text(), err)
+		}
+		statuses[row.Status]++
+		if row.Status == reportOK && filepath.Base(row.Input) != "fast.png" {
+			t.Errorf("%s finished despite its rasterizer never returning", row.Input)
+		}
+	}
+	if statuses[reportOK] != 1 || statuses[reportNotStarted] == 0 || statuses[reportOK]+statuses[reportFailed]+statuses[reportNotStarted] != 21 {
+		t.Errorf("report statuses = %v, want 1 ok and the rest failed or not started", statuses)
+	}
+}
+
+func TestTimeoutNotReachedExitsNormally(t *testing.T) {
+	in, out := t.TempDir(), t.TempDir()
+	err := runExtract([]string{"-dpi", "100", "-timeout", "1m", "-out", out, writeSignaturePNG(t, in, "fast.png")})
+	if err != nil {
+		t.Fatalf("runExtract = %v, want success within the deadline", err)
+	}
+}
+
+func TestTimedOut(t *testing.T) {
+	expired, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+	running := context.Background()
+	noSignature := withStatus(exitNoSignature, fmt.Errorf("failed to process PDF: %w", signature.ErrNoSignature))
+	killed := withStatus(exitFailed, fmt.Errorf("failed to process PDF: %w", context.DeadlineExceeded))
+	tests := []struct {
+		name string
+		ctx  context.Context
+		err  error
+		want bool
+	}{
+		{"finished in time", expired, nil, false},
+		{"failed on its own after the deadline", expired, noSignature, false},
+		{"missing rasterizer after the deadline", expired, withStatus(exitEnvironment, signature.ErrRasterizerNotFound), false},
+		{"killed by the deadline", expired, killed, true},
+		// A document's own -document-timeout, before the run's deadline
+		{"document timeout", running, killed, false},
+	}
+	for _, tt := range tests {
+		if got := timedOut(tt.ctx, tt.err); got != tt.want {
+			t.Errorf("%s: timedOut = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestReportBatchWrapsDeadlineOnlyWhenCutShort(t *testing.T) {
+	expired, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+	paths := []string{"a.pdf", "b.pdf"}
+	batch := func(outcomes ...signature.BatchResult) error {
+		results := make(chan signature.BatchResult, len(outcomes))
+		for _, r := range outcomes {
+			results <- r
+		}
+		close(results)
+		_, err := reportBatch(expired, results, paths, false, nil)
+		return err
+	}
+	noSignature := fmt.Errorf("failed to extract signature: %w", signature.ErrNoSignature)
+
+	// Both documents ran to the end; the run failed on its own
+	err := batch(signature.BatchResult{Index: 0, Path: "a.pdf"}, signature.BatchResult{Index: 1, Path: "b.pdf", Err: noSignature})
+	if err == nil || timedOut(expired, err) {
+		t.Errorf("batch that finished = %v, want a failure that is not a timeout", err)
+	}
+	if status := exitStatus(err); status != exitNoSignature {
+		t.Errorf("batch that finished: exit status %d, want %d", status, exitNoSignature)
+	}
+	// One document never started
+	if err := batch(signature.BatchResult{Index: 1, Path: "b.pdf", Err: noSignature}); !timedOut(expired, err) {
+		t.Errorf("batch with a document not started = %v, want a timeout", err)
+	}
+	// One document was killed by the deadline
+	killed := fmt.Errorf("failed to render page 1: %w", context.DeadlineExceeded)
+	if err := batch(signature.BatchResult{Index: 0, Path: "a.pdf"}, signature.BatchResult{Index: 1, Path: "b.pdf", Err: killed}); !timedOut(expired, err) {
+		t.Errorf("batch with a document killed = %v, want a timeout", err)
+	}
+}
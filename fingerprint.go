@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"go.yaml.in/yaml/v3"
+
+	"poc-pdf/signature"
+)
+
+// runFingerprint implements the fingerprint subcommand: it measures a sample
+// document and prints the match section of a -templates entry for its layout.
+func runFingerprint(args []string) error {
+	fs := flag.NewFlagSet("fingerprint", flag.ExitOnError)
+	rasterizer := fs.String("rasterizer", signature.RasterizerAuto, "PDF rendering backend: auto, poppler, mutool, ghostscript or fitz")
+	password := fs.String("password", "", "password of an encrypted PDF")
+	verbose := fs.Bool("v", false, "print progress to standard error")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: go run . fingerprint [flags] <sample.pdf>")
+		fs.PrintDefaults()
+	}
+	_, problems := parseFlags(fs, args)
+
+	opts := signature.DefaultOptions()
+	opts.Rasterizer = *rasterizer
+	opts.Password = *password
+	if *verbose {
+		opts.Logf = func(format string, args ...any) { fmt.Fprintf(os.Stderr, format+"\n", args...) }
+	}
+	problems.check(fs.NArg() == 1, "fingerprint takes exactly one sample document, got %d", fs.NArg())
+	problems = append(problems, validateOptions(opts, nil)...)
+	if len(problems) > 0 {
+		return problems
+	}
+
+	fp, err := signature.NewExtractor(opts).Fingerprint(context.Background(), fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	data, err := yaml.Marshal(struct {
+		Match *signature.Fingerprint `yaml:"match"`
+	}{fp})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("# Fingerprint of %s; add text phrases or drop criteria as needed\n%s", fs.Arg(0), data)
+	return nil
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeSignaturePNG writes a white 850x1100 page (letter size at 100 DPI)
+// with one handwritten-looking stroke in its lower half to dir/name and
+// returns its path; the pipeline takes it as an image input.
+func writeSignaturePNG(t *testing.T, dir, name string) string {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, 850, 1100))
+	for i := range img.Pix {
+		img.Pix[i] = 255
+	}
+	ink := color.RGBA{R: 20, G: 30, B: 110, A: 255}
+	// One continuous looping stroke whose width varies like pen pressure
+	for u := 0.0; u <= 1; u += 0.0005 {
+		x := 300 + 260*u + 18*math.Sin(2*math.Pi*7*u)
+		y := 850 + 40*math.Sin(2*math.Pi*3*u) + 18*math.Cos(2*math.Pi*7*u)
+		r := 1.5 + 0.8*math.Sin(2*math.Pi*5*u)
+		for dy := -r; dy <= r; dy++ {
+			for dx := -r; dx <= r; dx++ {
+				if dx*dx+dy*dy <= r*r {
+					img.Set(int(x+dx), int(y+dy), ink)
+				}
+			}
+		}
+	}
+	path := filepath.Join(dir, name)
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := png.Encode(f, img); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"flag"
+	"log/slog"
+	"time"
+
+	"poc-pdf/signature"
+)
+
+// renderFlags are the -dpi and -rasterizer flags of the subcommands that render
+// PDF pages with a single resolution.
+type renderFlags struct {
+	dpi        *float64
+	rasterizer *string
+}
+
+// addRenderFlags registers -dpi and -rasterizer on fs.
+func addRenderFlags(fs *flag.FlagSet) renderFlags {
+	return renderFlags{
+		dpi:        fs.Float64("dpi", signature.DefaultDPI, "resolution used to render PDF pages"),
+		rasterizer: fs.String("rasterizer", signature.RasterizerAuto, "PDF rendering backend: auto, poppler, mutool, ghostscript or fitz"),
+	}
+}
+
+// apply sets the render and output DPI and the rasterizer of opts.
+func (f renderFlags) apply(opts *signature.Options) {
+	opts.RenderDPI, opts.OutputDPI = *f.dpi, *f.dpi
+	opts.Rasterizer = *f.rasterizer
+}
+
+// limitFlags are the resource ceilings of the subcommands that rasterize
+// documents they don't control.
+type limitFlags struct {
+	documentTimeout *time.Duration
+	maxRenderMB     *int64
+	maxMemoryMB     *int64
+}
+
+// addLimitFlags registers -document-timeout, -max-render-mb and
+// -max-rasterizer-memory-mb on fs.
+func addLimitFlags(fs *flag.FlagSet) limitFlags {
+	return limitFlags{
+		documentTimeout: fs.Duration("document-timeout", 0, "fail a document that takes longer than this (e.g. 2m), killing its rasterizer; 0 means no limit"),
+		maxRenderMB:     fs.Int64("max-render-mb", signature.DefaultMaxRenderBytes>>20, "fail a document whose page render file exceeds this many MiB; 0 means no limit"),
+		maxMemoryMB:     fs.Int64("max-rasterizer-memory-mb", 0, "cap the memory of each rasterizer subprocess at this many MiB (Linux only); 0 means no limit"),
+	}
+}
+
+// apply sets the resource ceilings of opts.
+func (f limitFlags) apply(opts *signature.Options) {
+	opts.DocumentTimeout = *f.documentTimeout
+	opts.MaxRenderBytes = *f.maxRenderMB << 20
+	opts.MaxRasterizerMemory = *f.maxMemoryMB << 20
+}
+
+// logRasterizer reports at startup which backend -rasterizer resolves to, or
+// why PDF inputs will fail. Images need no rasterizer, so a missing one is
+// only a warning here; each PDF then fails with the install instructions.
+func logRasterizer(opts signature.Options) {
+	name, err := signature.ResolveRasterizer(opts.Rasterizer, opts.Password)
+	if err != nil {
+		slog.Warn("No usable rasterizer, PDF inputs will fail", "error", err)
+		return
+	}
+	slog.Debug("Rasterizer selected", "rasterizer", name)
+}
+
+// flagAliases maps the second spelling of a flag to the flag it sets.
+var flagAliases = map[string]string{"output": "out"}
+
+// addOutFlag registers -out with usage on fs, and -output as its long spelling.
+func addOutFlag(fs *flag.FlagSet, usage string) *string {
+	out := fs.String("out", "", usage)
+	fs.StringVar(out, "output", "", "same as -out")
+	return out
+}
@@ -0,0 +1,291 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	grpchealth "google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+
+	"poc-pdf/signature"
+	"poc-pdf/signaturepb"
+)
+
+// grpcServer implements signaturepb.SignatureService with the same settings
+// and limits as the HTTP server.
+type grpcServer struct {
+	signaturepb.UnimplementedSignatureServiceServer
+	*server
+}
+
+// serveGRPC answers SignatureService calls, and the standard grpc.health.v1
+// service, on addr until ctx is cancelled. Health then turns NOT_SERVING and
+// in-flight calls get up to the shutdown timeout to finish.
+func serveGRPC(ctx context.Context, addr string, s *server) error {
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	// A unary Extract carries the whole PDF, so allow the upload limit plus the envelope
+	opts := append(s.auth.grpcOptions(), s.tracing.grpcOptions()...)
+	srv := grpc.NewServer(append(opts, grpc.MaxRecvMsgSize(int(s.maxUpload)+1<<20))...)
+	signaturepb.RegisterSignatureServiceServer(srv, &grpcServer{server: s})
+	hs := grpchealth.NewServer()
+	healthpb.RegisterHealthServer(srv, hs)
+	go s.watchHealth(ctx, hs)
+
+	errc := make(chan error, 1)
+	go func() { errc <- srv.Serve(lis) }()
+	slog.Info("Listening for gRPC", "addr", addr)
+
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+	slog.Info("Shutting down, draining calls", "timeout", s.shutdownTimeout)
+	s.health.draining.Store(true)
+	hs.Shutdown()
+	stopped := make(chan struct{})
+	go func() {
+		srv.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(s.shutdownTimeout):
+		srv.Stop()
+	}
+	return nil
+}
+
+// watchHealth keeps the serving status of hs, for the whole server (""), in
+// line with the readiness check until ctx is cancelled.
+func (s *server) watchHealth(ctx context.Context, hs *grpchealth.Server) {
+	ticker := time.NewTicker(readyCheckInterval)
+	defer ticker.Stop()
+	for {
+		status := healthpb.HealthCheckResponse_SERVING
+		if err := s.health.ready(ctx); err != nil {
+			slog.Warn("Not ready", "error", err)
+			status = healthpb.HealthCheckResponse_NOT_SERVING
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		hs.SetServingStatus("", status)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Extract runs the pipeline on a PDF sent in one message.
+func (g *grpcServer) Extract(ctx context.Context, req *signaturepb.ExtractRequest) (*signaturepb.ExtractResponse, error) {
+	if int64(len(req.GetPdf())) > g.maxUpload {
+		return nil, status.Errorf(codes.ResourceExhausted, "upload exceeds %d bytes", g.maxUpload)
+	}
+	dir, err := os.MkdirTemp("", "poc-pdf-grpc-")
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	defer os.RemoveAll(dir)
+	pdfPath := filepath.Join(dir, "upload.pdf")
+	if err := os.WriteFile(pdfPath, req.GetPdf(), 0o644); err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return g.extract(ctx, "Extract", pdfPath, req.GetOptions())
+}
+
+// ExtractStream is Extract for a PDF streamed in chunks, written to disk as
+// they arrive; the options are taken from the first chunk.
+func (g *grpcServer) ExtractStream(stream grpc.ClientStreamingServer[signaturepb.ExtractChunk, signaturepb.ExtractResponse]) error {
+	dir, err := os.MkdirTemp("", "poc-pdf-grpc-")
+	if err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+	defer os.RemoveAll(dir)
+	pdfPath := filepath.Join(dir, "upload.pdf")
+	out, err := os.Create(pdfPath)
+	if err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+	defer out.Close()
+
+	var options *signaturepb.ExtractOptions
+	var size int64
+	for first := true; ; first = false {
+		chunk, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if first {
+			options = chunk.GetOptions()
+		}
+		if size += int64(len(chunk.GetData())); size > g.maxUpload {
+			return status.Errorf(codes.ResourceExhausted, "upload exceeds %d bytes", g.maxUpload)
+		}
+		if _, err := out.Write(chunk.GetData()); err != nil {
+			return status.Error(codes.Internal, err.Error())
+		}
+	}
+	if err := out.Close(); err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+
+	resp, err := g.extract(stream.Context(), "ExtractStream", pdfPath, options)
+	if err != nil {
+		return err
+	}
+	return stream.SendAndClose(resp)
+}
+
+// extract runs the pipeline on the uploaded PDF at pdfPath, writing its outputs
+// next to it, and converts the results. method names the RPC in the log.
+func (g *grpcServer) extract(ctx context.Context, method, pdfPath string, options *signaturepb.ExtractOptions) (*signaturepb.ExtractResponse, error) {
+	opts := g.opts
+	if pages := options.GetPages(); pages != "" {
+		sel, err := signature.ParsePageSelection(pages)
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "pages: %v", err)
+		}
+		opts.Pages = sel
+	}
+	opts.Password = options.GetPassword()
+	opts.AllRegions = options.GetAllRegions()
+	opts.OutputDir = filepath.Dir(pdfPath)
+
+	ctx, cancel := context.WithTimeout(ctx, g.timeout)
+	defer cancel()
+	start := time.Now()
+	results, err := signature.NewExtractor(opts).ExtractFromPDF(ctx, pdfPath)
+	slog.Info("RPC done", "method", method, "signatures", len(results), "duration", time.Since(start).Round(time.Millisecond), "error", err)
+	recordDocument(results, err)
+	if err != nil {
+		return nil, g.grpcError(ctx, err)
+	}
+
+	resp := &signaturepb.ExtractResponse{Signatures: make([]*signaturepb.Signature, 0, len(results))}
+	for _, res := range results {
+		data, err := encodePNG(res.Image)
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "failed to encode image: %v", err)
+		}
+		resp.Signatures = append(resp.Signatures, &signaturepb.Signature{
+			Page:          int32(res.Page),
+			Region:        int32(res.Region),
+			Png:           data,
+			Dpi:           res.DPI,
+			Bounds:        &signaturepb.PixelRect{X: int32(res.Bounds.Min.X), Y: int32(res.Bounds.Min.Y), Width: int32(res.Bounds.Dx()), Height: int32(res.Bounds.Dy())},
+			PdfBounds:     pdfRectToProto(res.PDFBounds),
+			Confidence:    res.Confidence,
+			SignatureType: res.SignatureType,
+			EdgeTouch:     res.EdgeTouch,
+			FormField:     res.FormField,
+			Rotation:      int32(res.Rotation),
+			WidthMm:       res.WidthMM,
+			HeightMm:      res.HeightMM,
+		})
+	}
+	return resp, nil
+}
+
+// Verify reports which pages or fields of the PDF are signed.
+func (g *grpcServer) Verify(ctx context.Context, req *signaturepb.VerifyRequest) (*signaturepb.VerifyResponse, error) {
+	if int64(len(req.GetPdf())) > g.maxUpload {
+		return nil, status.Errorf(codes.ResourceExhausted, "upload exceeds %d bytes", g.maxUpload)
+	}
+	opts := g.opts
+	if pages := req.GetPages(); pages != "" {
+		sel, err := signature.ParsePageSelection(pages)
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "pages: %v", err)
+		}
+		opts.Pages = sel
+	}
+	opts.Password = req.GetPassword()
+	if c := req.GetMinCoverage(); c < 0 || c >= 1 {
+		return nil, status.Errorf(codes.InvalidArgument, "min_coverage must be in [0, 1), got %g", c)
+	}
+	vopts := signature.VerifyOptions{MinInkCoverage: req.GetMinCoverage()}
+	for i, f := range req.GetFields() {
+		field := signature.Field{Name: f.GetName(), Page: int(f.GetPage()), Rect: pdfRectFromProto(f.GetRect())}
+		if field.Page < 1 || field.Rect.Width() <= 0 || field.Rect.Height() <= 0 {
+			return nil, status.Errorf(codes.InvalidArgument, "field %d (%q) needs a page of at least 1 and a rect with area", i+1, field.Name)
+		}
+		vopts.Fields = append(vopts.Fields, field)
+	}
+
+	dir, err := os.MkdirTemp("", "poc-pdf-grpc-")
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	defer os.RemoveAll(dir)
+	pdfPath := filepath.Join(dir, "upload.pdf")
+	if err := os.WriteFile(pdfPath, req.GetPdf(), 0o644); err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, g.timeout)
+	defer cancel()
+	start := time.Now()
+	report, err := signature.NewExtractor(opts).Verify(ctx, pdfPath, vopts)
+	slog.Info("RPC done", "method", "Verify", "duration", time.Since(start).Round(time.Millisecond), "error", err)
+	if err != nil {
+		return nil, g.grpcError(ctx, err)
+	}
+
+	resp := &signaturepb.VerifyResponse{Signed: report.Signed, Checks: make([]*signaturepb.FieldCheck, 0, len(report.Checks))}
+	for _, c := range report.Checks {
+		resp.Checks = append(resp.Checks, &signaturepb.FieldCheck{
+			Page:        int32(c.Page),
+			Field:       c.Field,
+			Bounds:      pdfRectToProto(c.Bounds),
+			InkCoverage: c.InkCoverage,
+			Confidence:  c.Confidence,
+			Signed:      c.Signed,
+		})
+	}
+	return resp, nil
+}
+
+// grpcError maps a pipeline error to a status, as handleExtract maps them to
+// HTTP statuses; ctx is the call's context bounded by the request timeout.
+func (g *grpcServer) grpcError(ctx context.Context, err error) error {
+	switch {
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		return status.Errorf(codes.DeadlineExceeded, "extraction timed out after %v", g.timeout)
+	case errors.Is(err, signature.ErrNoSignature):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, signature.ErrEncrypted):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, signature.ErrCorruptPDF):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, signature.ErrResourceLimit):
+		return status.Error(codes.ResourceExhausted, err.Error())
+	default:
+		return status.Error(codes.Internal, err.Error())
+	}
+}
+
+func pdfRectToProto(r signature.PDFRect) *signaturepb.PDFRect {
+	return &signaturepb.PDFRect{Llx: r.LLX, Lly: r.LLY, Urx: r.URX, Ury: r.URY}
+}
+
+func pdfRectFromProto(r *signaturepb.PDFRect) signature.PDFRect {
+	return signature.PDFRect{LLX: r.GetLlx(), LLY: r.GetLly(), URX: r.GetUrx(), URY: r.GetUry()}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"poc-pdf/signature"
+)
+
+const (
+	// readyCheckInterval is how long a readiness check is trusted before
+	// /readyz runs it again, so frequent probes don't each spawn a render.
+	readyCheckInterval = 30 * time.Second
+	// readyCheckTimeout bounds one readiness check.
+	readyCheckTimeout = 10 * time.Second
+)
+
+// errDraining is the readiness error, and the answer to new work, once the
+// server is shutting down.
+var errDraining = errors.New("server is shutting down")
+
+// health answers the liveness and readiness probes of serve.
+type health struct {
+	// rasterizer is the Options.Rasterizer the readiness check renders with.
+	rasterizer string
+	// draining is set on SIGINT/SIGTERM, when the server stops taking work.
+	draining atomic.Bool
+
+	mu      sync.Mutex
+	checked time.Time
+	err     error
+}
+
+// newHealth returns the probes of a server rendering with rasterizer.
+func newHealth(rasterizer string) *health {
+	return &health{rasterizer: rasterizer}
+}
+
+// check reports whether the rasterizer renders a known-good PDF and OpenCV
+// finds a contour (see signature.WarmupRasterizer and signature.WarmupOpenCV).
+// The outcome is reused for readyCheckInterval.
+func (h *health) check(ctx context.Context) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if !h.checked.IsZero() && time.Since(h.checked) < readyCheckInterval {
+		return h.err
+	}
+	ctx, cancel := context.WithTimeout(ctx, readyCheckTimeout)
+	defer cancel()
+	h.err = signature.WarmupRasterizer(ctx, h.rasterizer)
+	if h.err == nil {
+		h.err = signature.WarmupOpenCV()
+	}
+	h.checked = time.Now()
+	return h.err
+}
+
+// ready is check, failing with errDraining once the server shuts down.
+func (h *health) ready(ctx context.Context) error {
+	if h.draining.Load() {
+		return errDraining
+	}
+	return h.check(ctx)
+}
+
+// healthStatus is the JSON body of /healthz and /readyz.
+type healthStatus struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// handleHealthz answers the liveness probe: 200 for as long as the process
+// serves HTTP, draining included, so it isn't restarted while jobs finish.
+func (h *health) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	writeHealth(w, http.StatusOK, healthStatus{Status: "ok"})
+}
+
+// handleReadyz answers the readiness probe: 200 when the server can process
+// documents, and 503 while it can't or is shutting down.
+func (h *health) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	if err := h.ready(r.Context()); err != nil {
+		writeHealth(w, http.StatusServiceUnavailable, healthStatus{Status: "unavailable", Error: err.Error()})
+		return
+	}
+	writeHealth(w, http.StatusOK, healthStatus{Status: "ready"})
+}
+
+// writeHealth answers with status and st as JSON.
+func writeHealth(w http.ResponseWriter, status int, st healthStatus) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(st)
+}
@@ -0,0 +1,357 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"poc-pdf/signature"
+)
+
+const (
+	// defaultJobWorkers is how many jobs are processed at once unless -workers is given.
+	defaultJobWorkers = 2
+	// defaultJobQueue is how many accepted jobs may wait for a worker.
+	defaultJobQueue = 64
+	// defaultJobTimeout bounds the pipeline for one job, well above defaultRequestTimeout.
+	defaultJobTimeout = 30 * time.Minute
+	// defaultJobRetention is how long a finished job and its result are kept.
+	defaultJobRetention = time.Hour
+)
+
+// jobState is the lifecycle stage of a job as reported by GET /jobs/{id}.
+type jobState string
+
+const (
+	jobQueued  jobState = "queued"
+	jobRunning jobState = "running"
+	jobDone    jobState = "done"
+	jobFailed  jobState = "failed"
+)
+
+// job is one PDF submitted to POST /jobs. The fields below created are
+// guarded by jobQueue.mu.
+type job struct {
+	id      string
+	opts    signature.Options
+	dir     string
+	pdfPath string
+	// callbackURL, when set, is posted the outcome (with the images when
+	// callbackImage is set) once the job finishes.
+	callbackURL   string
+	callbackImage bool
+	created       time.Time
+
+	state    jobState
+	started  time.Time
+	finished time.Time
+	results  []*signature.Result
+	// status is the HTTP status a failed job's result is answered with.
+	status int
+	err    error
+}
+
+// errQueueFull is the answer to a job submitted while the queue is full.
+var errQueueFull = errors.New("job queue is full, retry later")
+
+// jobQueue runs submitted jobs on a fixed pool of workers and keeps their
+// results in memory until the retention period after they finish.
+type jobQueue struct {
+	mu      sync.Mutex
+	jobs    map[string]*job
+	pending chan *job
+	// closed is set by drain, after which pending is closed and nothing more
+	// is submitted.
+	closed    bool
+	timeout   time.Duration
+	retention time.Duration
+	callbacks *callbacks
+	workers   sync.WaitGroup
+}
+
+// newJobQueue starts workers goroutines taking jobs from a queue of size
+// waiting jobs, and a janitor expiring finished ones. All of them stop when ctx
+// is cancelled, which also aborts running jobs. Jobs with a callback_url are
+// posted with callbacks.
+func newJobQueue(ctx context.Context, workers, size int, timeout, retention time.Duration, callbacks *callbacks) *jobQueue {
+	q := &jobQueue{
+		jobs:      make(map[string]*job),
+		pending:   make(chan *job, size),
+		timeout:   timeout,
+		retention: retention,
+		callbacks: callbacks,
+	}
+	for i := 0; i < workers; i++ {
+		q.workers.Add(1)
+		go func() {
+			defer q.workers.Done()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case j, ok := <-q.pending:
+					if !ok {
+						return
+					}
+					q.run(ctx, j)
+				}
+			}
+		}()
+	}
+	go q.expire(ctx)
+	return q
+}
+
+// submit registers j and queues it. It fails with errQueueFull when the queue
+// is full and with errDraining once drain has begun.
+func (q *jobQueue) submit(j *job) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return errDraining
+	}
+	select {
+	case q.pending <- j:
+		q.jobs[j.id] = j
+		return nil
+	default:
+		return errQueueFull
+	}
+}
+
+// drain stops taking jobs and waits for the workers to finish the running and
+// queued ones, or for ctx to end. It reports whether every job finished; the
+// rest are left to the cancellation of the queue's context and discard.
+func (q *jobQueue) drain(ctx context.Context) bool {
+	q.mu.Lock()
+	if !q.closed {
+		q.closed = true
+		close(q.pending)
+	}
+	q.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		q.workers.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// run extracts the signatures of j, records the outcome and posts it to the
+// job's callback_url, if any. The uploaded PDF is removed afterwards; only the
+// results stay in memory.
+func (q *jobQueue) run(ctx context.Context, j *job) {
+	q.mu.Lock()
+	j.state, j.started = jobRunning, time.Now()
+	q.mu.Unlock()
+
+	jctx, cancel := context.WithTimeout(ctx, q.timeout)
+	defer cancel()
+	results, err := signature.NewExtractor(j.opts).ExtractFromPDF(jctx, j.pdfPath)
+	recordDocument(results, err)
+	os.RemoveAll(j.dir)
+	slog.Info("Job done", "job", j.id, "signatures", len(results), "duration", time.Since(j.started).Round(time.Millisecond), "error", err)
+
+	q.mu.Lock()
+	j.finished = time.Now()
+	if err != nil {
+		j.state = jobFailed
+		j.status, j.err = pipelineError(jctx, err, q.timeout)
+		err = j.err
+	} else {
+		j.state, j.results = jobDone, results
+	}
+	q.mu.Unlock()
+
+	if j.callbackURL != "" {
+		if err := q.callbacks.post(ctx, j.callbackURL, j.id, results, err, j.callbackImage, true); err != nil {
+			slog.Warn("Callback failed", "job", j.id, "url", j.callbackURL, "error", err)
+		}
+	}
+}
+
+// expire forgets jobs that finished more than the retention period ago.
+func (q *jobQueue) expire(ctx context.Context) {
+	ticker := time.NewTicker(min(q.retention, time.Minute))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			q.mu.Lock()
+			for id, j := range q.jobs {
+				if !j.finished.IsZero() && now.Sub(j.finished) > q.retention {
+					delete(q.jobs, id)
+				}
+			}
+			q.mu.Unlock()
+		}
+	}
+}
+
+// discard waits for the workers to stop, once the queue's context is
+// cancelled, and removes the uploads of jobs that never ran.
+func (q *jobQueue) discard() {
+	q.workers.Wait()
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for _, j := range q.jobs {
+		if j.state == jobQueued {
+			os.RemoveAll(j.dir)
+		}
+	}
+}
+
+// get returns the job with the given id, or nil when it is unknown or expired.
+func (q *jobQueue) get(id string) *job {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.jobs[id]
+}
+
+// jobStatus is the JSON body of POST /jobs and GET /jobs/{id}.
+type jobStatus struct {
+	ID         string     `json:"id"`
+	State      jobState   `json:"state"`
+	CreatedAt  time.Time  `json:"created_at"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	Signatures int        `json:"signatures,omitempty"`
+	Error      string     `json:"error,omitempty"`
+}
+
+// snapshot returns the status of j; jobQueue.mu must be held.
+func (j *job) snapshot() jobStatus {
+	st := jobStatus{ID: j.id, State: j.state, CreatedAt: j.created, Signatures: len(j.results)}
+	if started := j.started; !started.IsZero() {
+		st.StartedAt = &started
+	}
+	if finished := j.finished; !finished.IsZero() {
+		st.FinishedAt = &finished
+	}
+	if j.err != nil {
+		st.Error = j.err.Error()
+	}
+	return st
+}
+
+// newJobID returns a random hex job identifier.
+func newJobID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// handleSubmitJob accepts the same upload as handleExtract, queues it and
+// answers 202 with the job's status, or 503 when the queue is full or the
+// server is shutting down.
+func (s *server) handleSubmitJob(w http.ResponseWriter, r *http.Request) {
+	if !s.accepting(w) || !s.limitUpload(w, r) {
+		return
+	}
+	opts := s.opts
+	if pages := r.URL.Query().Get("pages"); pages != "" {
+		sel, err := signature.ParsePageSelection(pages)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("pages: %v", err))
+			return
+		}
+		opts.Pages = sel
+	}
+
+	id, err := newJobID()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	dir, err := os.MkdirTemp("", "poc-pdf-job-")
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	pdfPath := filepath.Join(dir, "upload.pdf")
+	if status, err := saveUpload(r, pdfPath); err != nil {
+		os.RemoveAll(dir)
+		writeError(w, status, err)
+		return
+	}
+	opts.Password = r.FormValue("password")
+	opts.OutputDir = dir
+	callbackURL := r.FormValue("callback_url")
+	if err := s.callbacks.checkCallback(callbackURL); err != nil {
+		os.RemoveAll(dir)
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	j := &job{id: id, opts: opts, dir: dir, pdfPath: pdfPath, created: time.Now(), state: jobQueued,
+		callbackURL: callbackURL, callbackImage: r.FormValue("callback_image") == "true"}
+	if err := s.jobs.submit(j); err != nil {
+		os.RemoveAll(dir)
+		writeError(w, http.StatusServiceUnavailable, err)
+		return
+	}
+	slog.Info("Job queued", "method", r.Method, "path", r.URL.Path, "job", id)
+
+	s.jobs.mu.Lock()
+	st := j.snapshot()
+	s.jobs.mu.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", "/jobs/"+id)
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(st)
+}
+
+// handleJobStatus answers with the status of the job named in the path.
+func (s *server) handleJobStatus(w http.ResponseWriter, r *http.Request) {
+	j := s.jobs.get(r.PathValue("id"))
+	if j == nil {
+		writeError(w, http.StatusNotFound, errors.New("unknown or expired job"))
+		return
+	}
+	s.jobs.mu.Lock()
+	st := j.snapshot()
+	s.jobs.mu.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(st)
+}
+
+// handleJobResult answers with a finished job's signatures exactly as
+// handleExtract would, ?format=json included. A failed job answers with the
+// error its extraction ended with, and an unfinished one with 409.
+func (s *server) handleJobResult(w http.ResponseWriter, r *http.Request) {
+	j := s.jobs.get(r.PathValue("id"))
+	if j == nil {
+		writeError(w, http.StatusNotFound, errors.New("unknown or expired job"))
+		return
+	}
+	s.jobs.mu.Lock()
+	state, results, status, err := j.state, j.results, j.status, j.err
+	s.jobs.mu.Unlock()
+	switch state {
+	case jobDone:
+		writeResults(w, results, r.URL.Query().Get("format") == "json")
+	case jobFailed:
+		writeError(w, status, err)
+	default:
+		writeError(w, http.StatusConflict, fmt.Errorf("job is %s", state))
+	}
+}
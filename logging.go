@@ -0,0 +1,70 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// Supported values of -log-format.
+const (
+	logFormatText = "text"
+	logFormatJSON = "json"
+)
+
+// logFlags are the -log-level and -log-format flags of a flag set.
+type logFlags struct {
+	level  *string
+	format *string
+}
+
+// addLogFlags registers -log-level and -log-format on fs.
+func addLogFlags(fs *flag.FlagSet) logFlags {
+	return logFlags{
+		level:  fs.String("log-level", "info", "least severe diagnostics logged to standard error: debug (adds stage timings and detection decisions), info, warn or error"),
+		format: fs.String("log-format", logFormatText, "format of the diagnostics: text (key=value) or json (one object per line)"),
+	}
+}
+
+// logger builds the slog logger the flags describe, writing to standard error.
+func (f logFlags) logger() (*slog.Logger, error) {
+	return newLogger(os.Stderr, *f.level, *f.format)
+}
+
+// newLogger returns a text or JSON slog logger on w that drops records below
+// the named level.
+func newLogger(w io.Writer, level, format string) (*slog.Logger, error) {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		return nil, fmt.Errorf("-log-level: want debug, info, warn or error, got %q", level)
+	}
+	opts := &slog.HandlerOptions{Level: lvl}
+	switch strings.ToLower(format) {
+	case logFormatText:
+		return slog.New(slog.NewTextHandler(w, opts)), nil
+	case logFormatJSON:
+		return slog.New(slog.NewJSONHandler(w, opts)), nil
+	}
+	return nil, fmt.Errorf("-log-format: want text or json, got %q", format)
+}
+
+// setupLogging builds the logger of f and makes it the slog default, which
+// also routes the standard log package through it. The error is meant for
+// configProblems.
+func setupLogging(f logFlags) (*slog.Logger, error) {
+	logger, err := f.logger()
+	if err != nil {
+		return nil, err
+	}
+	slog.SetDefault(logger)
+	return logger, nil
+}
+
+// fatal logs msg with err at error level and exits with status.
+func fatal(msg string, err error, status int) {
+	slog.Error(msg, "error", err)
+	os.Exit(status)
+}
@@ -1,170 +1,323 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"image"
-	"image/color"
 	"image/png"
-	"log"
+	"log/slog"
+	"net/http"
 	"os"
-	"os/exec"
 	"path/filepath"
+	"strings"
 
-	"gocv.io/x/gocv"
+	"poc-pdf/signature"
 )
 
-// convertPDFToPNG uses pdftoppm CLI to convert the first page of a PDF to a PNG file.
-// Output is saved as {outputPrefix}.png in the same directory as the PDF.
-func convertPDFToPNG(pdfPath, outputPrefix string) (string, error) {
-	// Example: pdftoppm -png -singlefile input.pdf output
-	cmd := exec.Command("pdftoppm", "-png", "-singlefile", pdfPath, outputPrefix)
-	err := cmd.Run()
-	if err != nil {
-		return "", fmt.Errorf("pdftoppm error: %v", err)
-	}
+// Exit statuses of a run, so scripts can tell what went wrong. Invalid
+// configurations exit with 2 as well, like unknown flags do, and errors
+// outside the documents with 1.
+const (
+	// exitFailed is for a run in which some documents failed or never started.
+	exitFailed = 2
+	// exitNoSignature is for a run whose documents were all processed, some of
+	// them without finding a signature.
+	exitNoSignature = 3
+	// exitEnvironment is for a run that couldn't work properly: a rasterizer
+	// missing, an output that can't be written, storage out of reach.
+	exitEnvironment = 4
+	// exitTimeout is the exit status when -timeout expires, matching
+	// coreutils timeout(1).
+	exitTimeout = 124
+)
 
-	// The resulting file will be something like outputPrefix.png
-	dir := filepath.Dir(pdfPath)
-	outputFile := filepath.Join(dir, outputPrefix+".png")
-	return outputFile, nil
+// exitError is an error ending the process with an exit status of its own.
+type exitError struct {
+	status int
+	err    error
 }
 
-// extractSignature loads an image via gocv, thresholds it, finds the largest contour,
-// crops it, and returns a Mat containing just the signature region.
-func extractSignature(imgPath string) (gocv.Mat, error) {
-	// Read image in color
-	img := gocv.IMRead(imgPath, gocv.IMReadColor)
-	if img.Empty() {
-		return gocv.NewMat(), fmt.Errorf("unable to read image: %s", imgPath)
-	}
-	defer img.Close()
-
-	// Convert to grayscale
-	gray := gocv.NewMat()
-	gocv.CvtColor(img, &gray, gocv.ColorBGRToGray)
-	defer gray.Close()
-
-	// Threshold: convert signature (dark) to white, background (light) to black
-	//   Adjust threshold (200) as needed for your scans
-	bin := gocv.NewMat()
-	// We use ThresholdBinaryInv so that dark ink becomes white (255)
-	// and light background becomes black (0).
-	gocv.Threshold(gray, &bin, 200, 255, gocv.ThresholdBinaryInv)
-	defer bin.Close()
+func (e *exitError) Error() string { return e.err.Error() }
+func (e *exitError) Unwrap() error { return e.err }
 
-	// Find external contours
-	contours := gocv.FindContours(bin, gocv.RetrievalExternal, gocv.ChainApproxSimple)
-	defer contours.Close()
-
-	// If there are no contours, we can't find a signature
-	if contours.Size() == 0 {
-		return gocv.NewMat(), fmt.Errorf("no contours found - cannot find signature")
+// withStatus gives err the exit status status; a nil err stays nil.
+func withStatus(status int, err error) error {
+	if err == nil {
+		return nil
 	}
+	return &exitError{status: status, err: err}
+}
 
-	// Find largest contour by bounding-rectangle area
-	var maxArea float64
-	var maxRect image.Rectangle
-
-	// Iterate over the contours in the PointsVector
-	for i := 0; i < contours.Size(); i++ {
-		c := contours.At(i)          // c is of type gocv.Points
-		rect := gocv.BoundingRect(c) // bounding box of this contour
-		area := float64(rect.Dx() * rect.Dy())
+// documentStatus is the exit status of a document that failed with err.
+func documentStatus(err error) int {
+	switch {
+	case errors.Is(err, signature.ErrRasterizerNotFound):
+		return exitEnvironment
+	case errors.Is(err, signature.ErrNoSignature):
+		return exitNoSignature
+	}
+	return exitFailed
+}
 
-		if area > maxArea {
-			maxArea = area
-			maxRect = rect
+// exitStatus returns the status err ends the process with. When errors of
+// several statuses were joined, an environment error wins over failed
+// documents, which win over missing signatures; without any it is 1.
+func exitStatus(err error) int {
+	found := map[int]bool{}
+	collectStatuses(err, found)
+	for _, status := range []int{exitEnvironment, exitFailed, exitNoSignature} {
+		if found[status] {
+			return status
 		}
 	}
-
-	// Crop the largest contour area from the original color image (img)
-	signature := img.Region(maxRect)
-
-	// Return a copy so we can safely Close() signature
-	signatureCopy := signature.Clone()
-	signature.Close()
-
-	return signatureCopy, nil
+	return 1
 }
 
-// removeWhiteBackground converts near-white pixels to transparent (alpha=0)
-// and keeps signature pixels opaque.
-func removeWhiteBackground(input gocv.Mat) (image.Image, error) {
-	// input is a BGR image (3 channels).
-	if input.Channels() != 3 {
-		return nil, fmt.Errorf("expected 3-channel BGR image")
+// collectStatuses marks the status of every exitError in the tree of err.
+func collectStatuses(err error, found map[int]bool) {
+	switch e := err.(type) {
+	case *exitError:
+		found[e.status] = true
+	case interface{ Unwrap() []error }:
+		for _, err := range e.Unwrap() {
+			collectStatuses(err, found)
+		}
+	case interface{ Unwrap() error }:
+		collectStatuses(e.Unwrap(), found)
 	}
+}
 
-	rows := input.Rows()
-	cols := input.Cols()
-
-	// Create a new RGBA image in Go
-	output := image.NewRGBA(image.Rect(0, 0, cols, rows))
+// processBatch runs every input concurrently and reports a success/failure summary.
+// It returns the successful results in input order, and an error when at least one
+// document failed.
+func processBatch(ctx context.Context, ex *signature.Extractor, paths []string, report *batchReport) ([]*signature.Result, error) {
+	results, err := ex.ExtractBatch(ctx, paths)
+	if err != nil {
+		return nil, err
+	}
+	return reportBatch(ctx, results, paths, true, report)
+}
 
-	// Read each pixel, if it's near white => make alpha=0, else alpha=255
-	for y := 0; y < rows; y++ {
-		for x := 0; x < cols; x++ {
-			bVec := input.GetVecbAt(y, x)
-			// bVec[0] = Blue, bVec[1] = Green, bVec[2] = Red
-			b := bVec[0]
-			g := bVec[1]
-			r := bVec[2]
+// processDir runs every PDF found under dir concurrently, mirroring its layout in
+// the output directory, and reports a summary like processBatch. Results are only
+// kept when keep is set, so large nightly runs don't hold every image in memory.
+func processDir(ctx context.Context, ex *signature.Extractor, dir string, keep bool, report *batchReport) ([]*signature.Result, error) {
+	paths, results, err := ex.ExtractDir(ctx, dir)
+	if err != nil {
+		return nil, err
+	}
+	slog.Info("Found PDF files", "count", len(paths), "dir", dir)
+	return reportBatch(ctx, results, paths, keep, report)
+}
 
-			// Simple "near-white" threshold
-			if r > 200 && g > 200 && b > 200 {
-				// transparent
-				output.Set(x, y, color.RGBA{R: 255, G: 255, B: 255, A: 0})
-			} else {
-				// opaque
-				output.Set(x, y, color.RGBA{R: r, G: g, B: b, A: 255})
+// reportBatch prints each outcome of a batch over paths as it arrives, then a
+// summary, and logs the totals. Every input also gets a row in report. With
+// keep set it returns the successful results in input order. A failed
+// document doesn't stop the others; the error returned has the exit status of
+// the worst failure (see exitStatus), and wraps the error of ctx when ctx
+// ended before every document had finished.
+func reportBatch(ctx context.Context, results <-chan signature.BatchResult, paths []string, keep bool, report *batchReport) ([]*signature.Result, error) {
+	ordered := make([][]*signature.Result, len(paths))
+	done := make([]bool, len(paths))
+	summary := newBatchSummary()
+	var succeeded, failed, interrupted int
+	var environment bool
+	for r := range results {
+		row := newReportRow(r)
+		report.write(row)
+		summary.add(row)
+		done[r.Index] = true
+		printOutcome(r)
+		if r.Err != nil {
+			failed++
+			environment = environment || documentStatus(r.Err) == exitEnvironment
+			if errors.Is(r.Err, context.DeadlineExceeded) || errors.Is(r.Err, context.Canceled) {
+				interrupted++
 			}
+			continue
+		}
+		succeeded++
+		if keep {
+			ordered[r.Index] = r.Results
 		}
 	}
 
-	return output, nil
+	// Documents never started (e.g. after a timeout) count as neither
+	for i, path := range paths {
+		if !done[i] {
+			row := reportRow{Input: path, Status: reportNotStarted}
+			report.write(row)
+			summary.add(row)
+		}
+	}
+	summary.log()
+	skipped := len(paths) - succeeded - failed
+	fmt.Printf("Processed %d documents: %d succeeded, %d failed, %d not started\n", len(paths), succeeded, failed, skipped)
+	if failed > 0 || skipped > 0 {
+		status := exitNoSignature
+		switch {
+		case environment:
+			status = exitEnvironment
+		case summary.failed > 0 || skipped > 0:
+			status = exitFailed
+		}
+		err := fmt.Errorf("%d of %d documents did not complete", failed+skipped, len(paths))
+		if interrupted+skipped > 0 && ctx.Err() != nil {
+			err = fmt.Errorf("%w: %w", err, ctx.Err())
+		}
+		return compactResults(ordered), withStatus(status, err)
+	}
+	return compactResults(ordered), nil
 }
 
-func main() {
-	if len(os.Args) < 2 {
-		fmt.Println("Usage: go run main.go <path_to_pdf>")
+// printOutcome prints the FAILED line of a document of a batch, or an OK line
+// per signature it produced.
+func printOutcome(r signature.BatchResult) {
+	if r.Err != nil {
+		fmt.Printf("FAILED %s: %v\n", r.Path, r.Err)
 		return
 	}
+	for _, res := range r.Results {
+		if res.Encoded != "" {
+			fmt.Printf("OK %s p.%d %s\n", r.Path, res.Page, res.Encoded)
+			continue
+		}
+		fmt.Printf("OK %s p.%d -> %s\n", r.Path, res.Page, res.OutputPath)
+	}
+}
 
-	pdfPath := os.Args[1]
-	fmt.Printf("Converting PDF: %s\n", pdfPath)
-
-	// Step 1: Convert first page of PDF to PNG
-	outputPrefix := "pdf_page"
-	pngPath, err := convertPDFToPNG(pdfPath, outputPrefix)
-	if err != nil {
-		log.Fatalf("Failed to convert PDF to PNG: %v", err)
+// compactResults flattens per-document results in input order, skipping
+// documents that failed or never started.
+func compactResults(results [][]*signature.Result) []*signature.Result {
+	var out []*signature.Result
+	for _, r := range results {
+		out = append(out, r...)
 	}
+	return out
+}
 
-	fmt.Printf("PNG generated: %s\n", pngPath)
+// stripOptions configures the combined -strip image.
+type stripOptions struct {
+	// Path is where the strip is written; empty disables it.
+	Path string
+	// Spacing is the gap between entries in pixels.
+	Spacing int
+}
 
-	// Step 2: Extract signature region
-	signatureMat, err := extractSignature(pngPath)
-	if err != nil {
-		log.Fatalf("Failed to extract signature: %v", err)
+// runInputs dispatches the command-line inputs: in batch mode the single input
+// is a directory whose PDFs run as a batch, several files run as a batch, a
+// single .eml is unpacked, and a single PDF is processed directly. With
+// strip.Path set, every signature produced is also stacked into one image,
+// including when some documents failed. Batches write a row per input into
+// report. It returns the results it kept, which in batch mode is only with
+// strip.Path set.
+func runInputs(ctx context.Context, ex *signature.Extractor, inputs []string, batchMode bool, strip stripOptions, report *batchReport) ([]*signature.Result, error) {
+	var results []*signature.Result
+	var err error
+	switch {
+	case batchMode:
+		if results, err = processDir(ctx, ex, inputs[0], strip.Path != "", report); err != nil {
+			err = fmt.Errorf("batch finished with errors: %w", err)
+		}
+	case len(inputs) > 1:
+		if results, err = processBatch(ctx, ex, inputs, report); err != nil {
+			err = fmt.Errorf("batch finished with errors: %w", err)
+		}
+	case strings.EqualFold(filepath.Ext(inputs[0]), ".eml"):
+		if results, err = ex.ExtractFromEML(ctx, inputs[0]); err != nil {
+			err = withStatus(documentStatus(err), fmt.Errorf("failed to process email: %w", err))
+		}
+	default:
+		if results, err = ex.ExtractFromPDF(ctx, inputs[0]); err != nil {
+			err = withStatus(documentStatus(err), fmt.Errorf("failed to process PDF: %w", err))
+		}
 	}
-	defer signatureMat.Close()
 
-	// Step 3: Remove white background (convert near-white to transparent)
-	signatureImage, err := removeWhiteBackground(signatureMat)
-	if err != nil {
-		log.Fatalf("Failed to remove background: %v", err)
+	if strip.Path != "" && len(results) > 0 {
+		if stripErr := writePNG(signature.ComposeStrip(results, strip.Spacing), strip.Path); stripErr != nil {
+			return results, errors.Join(err, withStatus(exitEnvironment, fmt.Errorf("failed to write strip: %v", stripErr)))
+		}
+		slog.Info("Combined strip saved", "signatures", len(results), "path", strip.Path)
 	}
+	return results, err
+}
 
-	// Step 4: Save final PNG
-	outFile, err := os.Create("signature_result.png")
+// writePNG encodes img as a PNG file at path.
+func writePNG(img image.Image, path string) error {
+	f, err := os.Create(path)
 	if err != nil {
-		log.Fatalf("Failed to create output file: %v", err)
+		return fmt.Errorf("failed to create output file: %v", err)
+	}
+	defer f.Close()
+
+	if err := png.Encode(f, img); err != nil {
+		return fmt.Errorf("failed to encode PNG: %v", err)
 	}
-	defer outFile.Close()
+	return f.Close()
+}
+
+// subcommands maps each subcommand to its implementation, which parses its own
+// flags from the arguments after the name.
+var subcommands = map[string]func(args []string) error{
+	"batch":       runBatch,
+	"compare":     runCompare,
+	"extract":     runExtract,
+	"fingerprint": runFingerprint,
+	"serve":       runServe,
+	"stamp":       runStamp,
+	"verify":      runVerify,
+	"worker":      runWorker,
+}
 
-	if err := png.Encode(outFile, signatureImage); err != nil {
-		log.Fatalf("Failed to encode PNG: %v", err)
+// exitOnError ends a subcommand: configuration problems exit with status 2, a
+// run cut short by -timeout with exitTimeout, and other errors are fatal with
+// their exitStatus. A server closed by a signal is a normal exit.
+func exitOnError(err error) {
+	var problems configProblems
+	if errors.As(err, &problems) {
+		fmt.Fprintln(os.Stderr, problems.Error())
+		fmt.Fprintln(os.Stderr, "Run with -h for the list of flags.")
+		os.Exit(2)
 	}
+	if errors.Is(err, errTimedOut) {
+		slog.Error("Timed out", "error", err)
+		os.Exit(exitTimeout)
+	}
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		fatal("Command failed", err, exitStatus(err))
+	}
+}
+
+// usage lists the subcommands; each one documents its own flags with -h.
+func usage() {
+	fmt.Fprintln(os.Stderr, `Usage: go run . <command> [flags] [arguments]
 
-	fmt.Println("Signature with transparent background saved to signature_result.png")
+Commands:
+  extract      extract the signatures of PDFs, emails or images (the default command)
+  batch        extract every PDF below a directory, outputs mirrored under -out
+  stamp        overlay a signature PNG onto a PDF
+  verify       JSON report of which pages or fields are signed
+  fingerprint  print the -templates match section for a sample document
+  compare      similarity of two signature images or PDFs
+  serve        HTTP or gRPC extraction server
+  worker       consume PDF locations from SQS or RabbitMQ
+
+Run "go run . <command> -h" for the flags of a command.`)
+}
+
+func main() {
+	if len(os.Args) > 1 {
+		if run, ok := subcommands[os.Args[1]]; ok {
+			exitOnError(run(os.Args[2:]))
+			return
+		}
+		switch os.Args[1] {
+		case "help", "-h", "-help", "--help":
+			usage()
+			return
+		}
+	}
+	// Without a subcommand the arguments are those of extract
+	exitOnError(runExtract(os.Args[1:]))
 }
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"os"
+	"runtime"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"poc-pdf/signature"
+)
+
+// Prometheus metrics of the serve and worker modes, exposed on /metrics.
+var (
+	documentsProcessed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "poc_pdf_documents_processed_total",
+		Help: "Documents run through the pipeline, by outcome: ok, no_signature or failed.",
+	}, []string{"outcome"})
+	signaturesFound = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "poc_pdf_signatures_found_total",
+		Help: "Signatures extracted.",
+	})
+	stageFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "poc_pdf_stage_failures_total",
+		Help: "Pipeline stages that failed, by stage: rasterize, detect, background or encode.",
+	}, []string{"stage"})
+	stageDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "poc_pdf_stage_duration_seconds",
+		Help:    "Duration of each pipeline stage, by stage.",
+		Buckets: prometheus.ExponentialBuckets(0.01, 2, 14),
+	}, []string{"stage"})
+	outputBytes = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "poc_pdf_output_bytes",
+		Help:    "Size of each output image file.",
+		Buckets: prometheus.ExponentialBuckets(1024, 2, 14),
+	})
+)
+
+// observeStage is an Options.OnStage that records stage latencies and
+// failures. A page without a signature is an answer, not a detect failure.
+func observeStage(stage string, d time.Duration, err error) {
+	stageDuration.WithLabelValues(stage).Observe(d.Seconds())
+	if err != nil && !errors.Is(err, signature.ErrNoSignature) && !errors.Is(err, context.Canceled) {
+		stageFailures.WithLabelValues(stage).Inc()
+	}
+}
+
+// withMetrics returns opts with stage metrics enabled.
+func withMetrics(opts signature.Options) signature.Options {
+	opts.OnStage = observeStage
+	return opts
+}
+
+// recordDocument counts a finished document and its signatures, and the size
+// of every output still on disk; call it before the outputs are removed.
+func recordDocument(results []*signature.Result, err error) {
+	switch {
+	case errors.Is(err, signature.ErrNoSignature):
+		documentsProcessed.WithLabelValues("no_signature").Inc()
+	case err != nil:
+		documentsProcessed.WithLabelValues("failed").Inc()
+	default:
+		documentsProcessed.WithLabelValues("ok").Inc()
+	}
+	signaturesFound.Add(float64(len(results)))
+	for _, res := range results {
+		if info, err := os.Stat(res.OutputPath); err == nil {
+			outputBytes.Observe(float64(info.Size()))
+		}
+	}
+}
+
+// memStats is the JSON body of GET /debug/memstats: the native OpenCV images
+// next to the Go heap, whose figures come from runtime.MemStats.
+type memStats struct {
+	Mats           signature.MatStats `json:"mats"`
+	HeapAllocBytes uint64             `json:"heap_alloc_bytes"`
+	HeapObjects    uint64             `json:"heap_objects"`
+	SysBytes       uint64             `json:"sys_bytes"`
+	NumGC          uint32             `json:"num_gc"`
+	Goroutines     int                `json:"goroutines"`
+}
+
+// handleMemStats answers with the current memStats. Reading the Go figures
+// stops the world briefly, which is why it is a debug endpoint behind -memstats.
+func handleMemStats(w http.ResponseWriter, r *http.Request) {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	st := memStats{
+		Mats:           signature.ReadMatStats(),
+		HeapAllocBytes: m.HeapAlloc,
+		HeapObjects:    m.HeapObjects,
+		SysBytes:       m.Sys,
+		NumGC:          m.NumGC,
+		Goroutines:     runtime.NumGoroutine(),
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(st)
+}
+
+// serveMetrics exposes /metrics on addr until ctx is cancelled, for modes
+// whose main listener is not HTTP, and /debug/memstats with memstats. An
+// empty addr disables it.
+func serveMetrics(ctx context.Context, addr string, memstats bool) {
+	if addr == "" {
+		return
+	}
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", promhttp.Handler())
+	if memstats {
+		mux.HandleFunc("GET /debug/memstats", handleMemStats)
+	}
+	srv := &http.Server{Addr: addr, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("Metrics server failed", "error", err)
+		}
+	}()
+	go func() {
+		<-ctx.Done()
+		srv.Close()
+	}()
+	slog.Info("Serving metrics", "addr", addr, "path", "/metrics")
+}
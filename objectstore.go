@@ -0,0 +1,316 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"cloud.google.com/go/storage"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"google.golang.org/api/iterator"
+)
+
+// Object storage URL schemes accepted for inputs and -out.
+const (
+	schemeS3  = "s3"
+	schemeGCS = "gs"
+)
+
+// objectURL is a parsed s3://bucket/key or gs://bucket/key location. For a
+// directory (batch input or -out) Key is a prefix, possibly empty.
+type objectURL struct {
+	Scheme string
+	Bucket string
+	Key    string
+}
+
+// isObjectURL reports whether s names object storage rather than a local path.
+func isObjectURL(s string) bool {
+	return strings.HasPrefix(s, schemeS3+"://") || strings.HasPrefix(s, schemeGCS+"://")
+}
+
+// parseObjectURL parses an s3:// or gs:// URL.
+func parseObjectURL(s string) (objectURL, error) {
+	scheme, rest, ok := strings.Cut(s, "://")
+	if !ok || (scheme != schemeS3 && scheme != schemeGCS) {
+		return objectURL{}, fmt.Errorf("want s3://bucket/key or gs://bucket/key, got %q", s)
+	}
+	bucket, key, _ := strings.Cut(rest, "/")
+	if bucket == "" {
+		return objectURL{}, fmt.Errorf("no bucket in %q", s)
+	}
+	return objectURL{Scheme: scheme, Bucket: bucket, Key: key}, nil
+}
+
+func (u objectURL) String() string {
+	return u.Scheme + "://" + u.Bucket + "/" + u.Key
+}
+
+// join returns the object rel (slash-separated) below the prefix u.
+func (u objectURL) join(rel string) objectURL {
+	if u.Key != "" && !strings.HasSuffix(u.Key, "/") {
+		rel = "/" + rel
+	}
+	u.Key += rel
+	return u
+}
+
+// objectStore reads and writes the objects of one storage service.
+type objectStore interface {
+	// get opens the object for streaming.
+	get(ctx context.Context, bucket, key string) (io.ReadCloser, error)
+	// put stores the file f of size bytes as the object.
+	put(ctx context.Context, bucket, key string, f *os.File, size int64) error
+	// list returns the keys of every object whose key starts with prefix.
+	list(ctx context.Context, bucket, prefix string) ([]string, error)
+}
+
+// objectStores opens one client per scheme on first use, with credentials from
+// the SDK's standard chain (environment, shared config, instance or workload
+// identity). It is safe for concurrent use.
+type objectStores struct {
+	mu      sync.Mutex
+	clients map[string]objectStore
+}
+
+func newObjectStores() *objectStores {
+	return &objectStores{clients: make(map[string]objectStore)}
+}
+
+func (s *objectStores) store(ctx context.Context, scheme string) (objectStore, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if st, ok := s.clients[scheme]; ok {
+		return st, nil
+	}
+	var st objectStore
+	switch scheme {
+	case schemeS3:
+		cfg, err := config.LoadDefaultConfig(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load AWS configuration: %v", err)
+		}
+		st = s3Store{s3.NewFromConfig(cfg)}
+	case schemeGCS:
+		client, err := storage.NewClient(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create GCS client: %v", err)
+		}
+		st = gcsStore{client}
+	default:
+		return nil, fmt.Errorf("unsupported storage scheme %q", scheme)
+	}
+	s.clients[scheme] = st
+	return st, nil
+}
+
+// download streams the object u to the local file dst, creating its directory.
+func (s *objectStores) download(ctx context.Context, u objectURL, dst string) error {
+	st, err := s.store(ctx, u.Scheme)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return err
+	}
+	body, err := st.get(ctx, u.Bucket, u.Key)
+	if err != nil {
+		return fmt.Errorf("failed to download %s: %v", u, err)
+	}
+	defer body.Close()
+	f, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err := io.Copy(f, body); err != nil {
+		return fmt.Errorf("failed to download %s: %v", u, err)
+	}
+	return f.Close()
+}
+
+// stageInputs downloads the inputs that are object URLs below dir, and copies
+// stdin there for the input -, and returns the local paths to process in their
+// place; local inputs are kept as they are. In batch mode the single input is a prefix whose PDFs are all
+// downloaded, keeping their layout, and the staged directory is returned.
+// Single objects keep their file name, so outputs are named as for local files.
+func stageInputs(ctx context.Context, stores *objectStores, inputs []string, batchMode bool, dir string) ([]string, error) {
+	local := make([]string, len(inputs))
+	for i, input := range inputs {
+		if input == stdioArg {
+			var err error
+			if local[i], err = stageStdin(os.Stdin, filepath.Join(dir, strconv.Itoa(i))); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if !isObjectURL(input) {
+			local[i] = input
+			continue
+		}
+		u, err := parseObjectURL(input)
+		if err != nil {
+			return nil, err
+		}
+		if !batchMode {
+			local[i] = filepath.Join(dir, strconv.Itoa(i), path.Base(u.Key))
+			if err := stores.download(ctx, u, local[i]); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		st, err := stores.store(ctx, u.Scheme)
+		if err != nil {
+			return nil, err
+		}
+		// A prefix names a directory: "in" must not also match "inbox/"
+		prefix := u.Key
+		if prefix != "" && !strings.HasSuffix(prefix, "/") {
+			prefix += "/"
+		}
+		keys, err := st.list(ctx, u.Bucket, prefix)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list %s: %v", u, err)
+		}
+		local[i] = filepath.Join(dir, strconv.Itoa(i))
+		for _, key := range keys {
+			rel := strings.TrimPrefix(key, prefix)
+			if rel == "" || !strings.EqualFold(path.Ext(rel), ".pdf") {
+				continue
+			}
+			obj := objectURL{Scheme: u.Scheme, Bucket: u.Bucket, Key: key}
+			if err := stores.download(ctx, obj, filepath.Join(local[i], filepath.FromSlash(rel))); err != nil {
+				return nil, err
+			}
+		}
+		if err := os.MkdirAll(local[i], 0o755); err != nil {
+			return nil, err
+		}
+	}
+	return local, nil
+}
+
+// publishOutputs uploads every file below the local directory dir to the
+// prefix out, keeping the layout, and returns how many were uploaded. It does
+// as much as it can and reports every failure together.
+func publishOutputs(ctx context.Context, stores *objectStores, dir string, out objectURL) (int, error) {
+	st, err := stores.store(ctx, out.Scheme)
+	if err != nil {
+		return 0, err
+	}
+	var uploaded int
+	var errs []error
+	err = filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		obj := out.join(filepath.ToSlash(rel))
+		if err := putFile(ctx, st, obj, p); err != nil {
+			errs = append(errs, fmt.Errorf("failed to upload %s: %v", obj, err))
+			return ctx.Err()
+		}
+		uploaded++
+		return nil
+	})
+	return uploaded, errors.Join(append(errs, err)...)
+}
+
+// putFile streams the local file p to the object u.
+func putFile(ctx context.Context, st objectStore, u objectURL, p string) error {
+	f, err := os.Open(p)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	return st.put(ctx, u.Bucket, u.Key, f, info.Size())
+}
+
+// s3Store is Amazon S3 or a compatible service (AWS_ENDPOINT_URL_S3).
+type s3Store struct {
+	client *s3.Client
+}
+
+func (s s3Store) get(ctx context.Context, bucket, key string) (io.ReadCloser, error) {
+	out, err := s.client.GetObject(ctx, &s3.GetObjectInput{Bucket: aws.String(bucket), Key: aws.String(key)})
+	if err != nil {
+		return nil, err
+	}
+	return out.Body, nil
+}
+
+func (s s3Store) put(ctx context.Context, bucket, key string, f *os.File, size int64) error {
+	_, err := s.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:        aws.String(bucket),
+		Key:           aws.String(key),
+		Body:          f,
+		ContentLength: aws.Int64(size),
+	})
+	return err
+}
+
+func (s s3Store) list(ctx context.Context, bucket, prefix string) ([]string, error) {
+	var keys []string
+	pages := s3.NewListObjectsV2Paginator(s.client, &s3.ListObjectsV2Input{Bucket: aws.String(bucket), Prefix: aws.String(prefix)})
+	for pages.HasMorePages() {
+		page, err := pages.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, obj := range page.Contents {
+			keys = append(keys, aws.ToString(obj.Key))
+		}
+	}
+	return keys, nil
+}
+
+// gcsStore is Google Cloud Storage.
+type gcsStore struct {
+	client *storage.Client
+}
+
+func (g gcsStore) get(ctx context.Context, bucket, key string) (io.ReadCloser, error) {
+	return g.client.Bucket(bucket).Object(key).NewReader(ctx)
+}
+
+func (g gcsStore) put(ctx context.Context, bucket, key string, f *os.File, size int64) error {
+	w := g.client.Bucket(bucket).Object(key).NewWriter(ctx)
+	if _, err := io.Copy(w, f); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+func (g gcsStore) list(ctx context.Context, bucket, prefix string) ([]string, error) {
+	var keys []string
+	it := g.client.Bucket(bucket).Objects(ctx, &storage.Query{Prefix: prefix})
+	for {
+		attrs, err := it.Next()
+		if err == iterator.Done {
+			return keys, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, attrs.Name)
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// profiles are named presets of flag values for a kind of input, chosen with
+// -profile. Flags given explicitly win over the preset's values. A config file
+// can add its own (see defineProfiles).
+var profiles = map[string]map[string]string{
+	// Office scanner output: even lighting, scanner grain and pages fed slightly crooked
+	"scanned": {
+		"dpi":         "300",
+		"binarize":    "otsu",
+		"median-blur": "3",
+		"despeckle":   "10",
+		"deskew":      "true",
+	},
+	// Born-digital PDFs: clean vector or pasted signatures with anti-aliased edges
+	"digital": {
+		"dpi":        "200",
+		"binarize":   "fixed",
+		"soft-alpha": "true",
+	},
+	// Handheld phone captures: uneven lighting, JPEG noise, a slight tilt and
+	// pages shot at an angle
+	"photo": {
+		"flatten-illumination": "true",
+		"perspective":          "true",
+		"median-blur":          "3",
+		"despeckle":            "20",
+		"deskew":               "true",
+	},
+}
+
+// builtinProfiles lists the profiles that ship with the tool, in the order
+// -h describes them.
+var builtinProfiles = []string{"scanned", "digital", "photo"}
+
+// profileUsage describes the built-in profiles for the -profile flag.
+func profileUsage() string {
+	descs := make([]string, len(builtinProfiles))
+	for i, name := range builtinProfiles {
+		values := profiles[name]
+		flags := make([]string, 0, len(values))
+		for _, flagName := range sortedKeys(values) {
+			if values[flagName] == "true" {
+				flags = append(flags, "-"+flagName)
+			} else {
+				flags = append(flags, "-"+flagName+" "+values[flagName])
+			}
+		}
+		descs[i] = name + " (" + strings.Join(flags, " ") + ")"
+	}
+	return strings.Join(descs, ", ")
+}
+
+// defineProfiles adds the profiles of a config file, replacing built-in ones of
+// the same name.
+func defineProfiles(custom map[string]map[string]string) {
+	for name, values := range custom {
+		profiles[name] = values
+	}
+}
+
+// sortedKeys returns the keys of m, sorted.
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// profileNames lists the known profiles, sorted.
+func profileNames() []string {
+	names := make([]string, 0, len(profiles))
+	for name := range profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// applyProfile sets the flags of the named profile on fs, except those in set
+// (the flags given explicitly). An empty name applies nothing.
+func applyProfile(fs *flag.FlagSet, name string, set map[string]bool) error {
+	if name == "" {
+		return nil
+	}
+	values, ok := profiles[name]
+	if !ok {
+		return fmt.Errorf("unknown profile %q, want one of %s", name, strings.Join(profileNames(), ", "))
+	}
+	for _, flagName := range sortedKeys(values) {
+		if set[flagName] {
+			continue
+		}
+		if err := fs.Set(flagName, values[flagName]); err != nil {
+			return fmt.Errorf("profile %s: -%s: %v", name, flagName, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	sqstypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// messageQueue is the broker the worker subcommand consumes from.
+type messageQueue interface {
+	// receive blocks until a message is delivered or ctx is done.
+	receive(ctx context.Context) (queueMessage, error)
+	close() error
+}
+
+// queueMessage is one delivery, settled with exactly one of ack, retry or
+// deadLetter.
+type queueMessage interface {
+	body() []byte
+	// attempts counts the deliveries of the message so far, this one included.
+	attempts() int
+	// ack removes the message from the queue.
+	ack(ctx context.Context) error
+	// retry returns the message to the queue, to be delivered again after delay
+	// where the broker supports it.
+	retry(ctx context.Context, delay time.Duration) error
+	// deadLetter moves the message to the dead-letter queue, or drops it when
+	// there is none, recording why.
+	deadLetter(ctx context.Context, reason error) error
+	// extend keeps the message from being redelivered to another consumer for
+	// another visibility period while it is being processed.
+	extend(ctx context.Context) error
+}
+
+// sqsQueue consumes an Amazon SQS queue.
+type sqsQueue struct {
+	client     *sqs.Client
+	url        string
+	deadLetter string
+	visibility time.Duration
+}
+
+// newSQSQueue connects to the SQS queue at url with credentials from the
+// standard AWS chain. Messages stay invisible to other consumers for
+// visibility, and poison ones are sent to the queue at deadLetter when set.
+func newSQSQueue(ctx context.Context, url, deadLetter string, visibility time.Duration) (*sqsQueue, error) {
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS configuration: %v", err)
+	}
+	return &sqsQueue{client: sqs.NewFromConfig(cfg), url: url, deadLetter: deadLetter, visibility: visibility}, nil
+}
+
+func (q *sqsQueue) receive(ctx context.Context) (queueMessage, error) {
+	for {
+		out, err := q.client.ReceiveMessage(ctx, &sqs.ReceiveMessageInput{
+			QueueUrl:                    aws.String(q.url),
+			MaxNumberOfMessages:         1,
+			WaitTimeSeconds:             20,
+			VisibilityTimeout:           int32(q.visibility / time.Second),
+			MessageSystemAttributeNames: []sqstypes.MessageSystemAttributeName{sqstypes.MessageSystemAttributeNameApproximateReceiveCount},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if len(out.Messages) > 0 {
+			return &sqsMessage{q: q, msg: out.Messages[0]}, nil
+		}
+	}
+}
+
+func (q *sqsQueue) close() error { return nil }
+
+type sqsMessage struct {
+	q   *sqsQueue
+	msg sqstypes.Message
+}
+
+func (m *sqsMessage) body() []byte { return []byte(aws.ToString(m.msg.Body)) }
+
+func (m *sqsMessage) attempts() int {
+	n, err := strconv.Atoi(m.msg.Attributes[string(sqstypes.MessageSystemAttributeNameApproximateReceiveCount)])
+	if err != nil {
+		return 1
+	}
+	return n
+}
+
+func (m *sqsMessage) ack(ctx context.Context) error {
+	_, err := m.q.client.DeleteMessage(ctx, &sqs.DeleteMessageInput{QueueUrl: aws.String(m.q.url), ReceiptHandle: m.msg.ReceiptHandle})
+	return err
+}
+
+func (m *sqsMessage) retry(ctx context.Context, delay time.Duration) error {
+	return m.setVisibility(ctx, delay)
+}
+
+func (m *sqsMessage) extend(ctx context.Context) error {
+	return m.setVisibility(ctx, m.q.visibility)
+}
+
+// setVisibility makes the message visible again d from now.
+func (m *sqsMessage) setVisibility(ctx context.Context, d time.Duration) error {
+	_, err := m.q.client.ChangeMessageVisibility(ctx, &sqs.ChangeMessageVisibilityInput{
+		QueueUrl:          aws.String(m.q.url),
+		ReceiptHandle:     m.msg.ReceiptHandle,
+		VisibilityTimeout: int32(d / time.Second),
+	})
+	return err
+}
+
+func (m *sqsMessage) deadLetter(ctx context.Context, reason error) error {
+	if m.q.deadLetter != "" {
+		_, err := m.q.client.SendMessage(ctx, &sqs.SendMessageInput{
+			QueueUrl:    aws.String(m.q.deadLetter),
+			MessageBody: m.msg.Body,
+			MessageAttributes: map[string]sqstypes.MessageAttributeValue{
+				"error": {DataType: aws.String("String"), StringValue: aws.String(reason.Error())},
+			},
+		})
+		if err != nil {
+			return fmt.Errorf("failed to send to the dead-letter queue: %v", err)
+		}
+	}
+	return m.ack(ctx)
+}
+
+// amqpAttemptsHeader counts deliveries of a message across the republishes
+// retry does, since classic RabbitMQ queues keep no delivery count.
+const amqpAttemptsHeader = "x-poc-pdf-attempts"
+
+// amqpQueue consumes a RabbitMQ (AMQP 0-9-1) queue.
+type amqpQueue struct {
+	conn       *amqp.Connection
+	ch         *amqp.Channel
+	queue      string
+	deadLetter string
+	deliveries <-chan amqp.Delivery
+}
+
+// newAMQPQueue connects to the broker at url and consumes queue with at most
+// prefetch unacknowledged messages. Poison messages are published to the
+// deadLetter queue when set.
+func newAMQPQueue(url, queue, deadLetter string, prefetch int) (*amqpQueue, error) {
+	conn, err := amqp.Dial(url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to the AMQP broker: %v", err)
+	}
+	ch, err := conn.Channel()
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to open a channel: %v", err)
+	}
+	if err := ch.Qos(prefetch, 0, false); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to set prefetch: %v", err)
+	}
+	deliveries, err := ch.Consume(queue, "", false, false, false, false, nil)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to consume %s: %v", queue, err)
+	}
+	return &amqpQueue{conn: conn, ch: ch, queue: queue, deadLetter: deadLetter, deliveries: deliveries}, nil
+}
+
+func (q *amqpQueue) receive(ctx context.Context) (queueMessage, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case d, ok := <-q.deliveries:
+		if !ok {
+			return nil, fmt.Errorf("connection to the broker closed")
+		}
+		return &amqpMessage{q: q, d: d}, nil
+	}
+}
+
+func (q *amqpQueue) close() error { return q.conn.Close() }
+
+type amqpMessage struct {
+	q *amqpQueue
+	d amqp.Delivery
+}
+
+func (m *amqpMessage) body() []byte { return m.d.Body }
+
+func (m *amqpMessage) attempts() int {
+	switch n := m.d.Headers[amqpAttemptsHeader].(type) {
+	case int32:
+		return int(n) + 1
+	case int64:
+		return int(n) + 1
+	}
+	return 1
+}
+
+func (m *amqpMessage) ack(ctx context.Context) error { return m.d.Ack(false) }
+
+// retry republishes the message with its attempt count and acknowledges the
+// original. RabbitMQ has no per-message delay, so delay is ignored.
+func (m *amqpMessage) retry(ctx context.Context, delay time.Duration) error {
+	return m.republish(ctx, m.q.queue, amqp.Table{amqpAttemptsHeader: int64(m.attempts())})
+}
+
+func (m *amqpMessage) deadLetter(ctx context.Context, reason error) error {
+	if m.q.deadLetter == "" {
+		return m.d.Ack(false)
+	}
+	return m.republish(ctx, m.q.deadLetter, amqp.Table{amqpAttemptsHeader: int64(m.attempts()), "x-poc-pdf-error": reason.Error()})
+}
+
+// republish sends a copy of the message with headers to queue and then
+// acknowledges the original; if publishing fails the original is requeued.
+func (m *amqpMessage) republish(ctx context.Context, queue string, headers amqp.Table) error {
+	err := m.q.ch.PublishWithContext(ctx, "", queue, false, false, amqp.Publishing{
+		Headers:      headers,
+		ContentType:  m.d.ContentType,
+		DeliveryMode: amqp.Persistent,
+		Body:         m.d.Body,
+	})
+	if err != nil {
+		m.d.Nack(false, true)
+		return fmt.Errorf("failed to publish to %s: %v", queue, err)
+	}
+	return m.d.Ack(false)
+}
+
+// extend is a no-op: RabbitMQ redelivers only when the consumer goes away or
+// its acknowledgement timeout (30 minutes by default) expires.
+func (m *amqpMessage) extend(ctx context.Context) error { return nil }
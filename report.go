@@ -0,0 +1,223 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"poc-pdf/signature"
+)
+
+// Statuses of a document in a batch report.
+const (
+	reportOK          = "ok"
+	reportNoSignature = "no_signature"
+	reportFailed      = "failed"
+	reportNotStarted  = "not_started"
+)
+
+// reportColumns is the header of a CSV report, in the order of reportRow.
+var reportColumns = []string{"input", "status", "pages", "signatures", "confidence_min", "confidence_max", "duration_ms", "outputs", "error"}
+
+// reportRow is the outcome of one input of a batch, a line of the -report.
+type reportRow struct {
+	Input      string `json:"input"`
+	Status     string `json:"status"`
+	Pages      int    `json:"pages"`
+	Signatures int    `json:"signatures"`
+	// ConfidenceMin and ConfidenceMax span the confidence of the signatures,
+	// and are left out without any.
+	ConfidenceMin float64  `json:"confidence_min,omitempty"`
+	ConfidenceMax float64  `json:"confidence_max,omitempty"`
+	DurationMS    int64    `json:"duration_ms"`
+	Outputs       []string `json:"outputs,omitempty"`
+	Error         string   `json:"error,omitempty"`
+}
+
+// newReportRow describes the outcome r.
+func newReportRow(r signature.BatchResult) reportRow {
+	row := reportRow{
+		Input:      r.Path,
+		Status:     reportOK,
+		Pages:      r.Pages,
+		Signatures: len(r.Results),
+		DurationMS: r.Duration.Milliseconds(),
+	}
+	switch {
+	case errors.Is(r.Err, signature.ErrNoSignature):
+		row.Status, row.Error = reportNoSignature, r.Err.Error()
+	case r.Err != nil:
+		row.Status, row.Error = reportFailed, r.Err.Error()
+	}
+	for i, res := range r.Results {
+		if i == 0 || res.Confidence < row.ConfidenceMin {
+			row.ConfidenceMin = res.Confidence
+		}
+		row.ConfidenceMax = max(row.ConfidenceMax, res.Confidence)
+		if res.OutputPath != "" {
+			row.Outputs = append(row.Outputs, res.OutputPath)
+		}
+	}
+	return row
+}
+
+// record returns row as the fields of a CSV line; outputs are separated by
+// semicolons.
+func (row reportRow) record() []string {
+	var confMin, confMax string
+	if row.Signatures > 0 {
+		confMin = strconv.FormatFloat(row.ConfidenceMin, 'f', 3, 64)
+		confMax = strconv.FormatFloat(row.ConfidenceMax, 'f', 3, 64)
+	}
+	return []string{
+		row.Input,
+		row.Status,
+		strconv.Itoa(row.Pages),
+		strconv.Itoa(row.Signatures),
+		confMin,
+		confMax,
+		strconv.FormatInt(row.DurationMS, 10),
+		strings.Join(row.Outputs, ";"),
+		row.Error,
+	}
+}
+
+// validReportPath reports whether the extension of path names a report
+// format: .csv, or .jsonl / .ndjson for JSON Lines.
+func validReportPath(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv", ".jsonl", ".ndjson":
+		return true
+	}
+	return false
+}
+
+// batchReport writes the -report of a batch, a row per input as it finishes.
+// A nil *batchReport writes nothing.
+type batchReport struct {
+	path string
+	f    *os.File
+	// Exactly one of csv and jsonl is set, by the extension of path.
+	csv   *csv.Writer
+	jsonl *json.Encoder
+	// err is the first write error; later rows are dropped.
+	err error
+}
+
+// openReport creates the report at path, in the format its extension names
+// (see validReportPath).
+func openReport(path string) (*batchReport, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create report: %v", err)
+	}
+	r := &batchReport{path: path, f: f}
+	if strings.EqualFold(filepath.Ext(path), ".csv") {
+		r.csv = csv.NewWriter(f)
+		r.add(reportColumns)
+		return r, nil
+	}
+	r.jsonl = json.NewEncoder(f)
+	return r, nil
+}
+
+// write adds row to the report and flushes it, so the report of a run that
+// is killed still covers the documents that finished.
+func (r *batchReport) write(row reportRow) {
+	if r == nil || r.err != nil {
+		return
+	}
+	if r.jsonl != nil {
+		r.err = r.jsonl.Encode(row)
+		return
+	}
+	r.add(row.record())
+}
+
+// add writes a CSV line and flushes it.
+func (r *batchReport) add(record []string) {
+	r.csv.Write(record)
+	r.csv.Flush()
+	r.err = r.csv.Error()
+}
+
+// close closes the report file, returning the first error writing it.
+func (r *batchReport) close() error {
+	if r == nil {
+		return nil
+	}
+	if err := r.f.Close(); r.err == nil {
+		r.err = err
+	}
+	if r.err != nil {
+		return fmt.Errorf("failed to write report %s: %v", r.path, r.err)
+	}
+	slog.Info("Batch report saved", "path", r.path)
+	return nil
+}
+
+// batchSummary adds up the outcomes of a batch for its closing log line.
+type batchSummary struct {
+	start                               time.Time
+	ok, noSignature, failed, notStarted int
+	pages, signatures                   int
+	busy                                time.Duration
+	slowest                             string
+	slowestTime                         time.Duration
+}
+
+// newBatchSummary starts the summary of a batch starting now.
+func newBatchSummary() *batchSummary {
+	return &batchSummary{start: time.Now()}
+}
+
+// add counts row.
+func (s *batchSummary) add(row reportRow) {
+	switch row.Status {
+	case reportOK:
+		s.ok++
+	case reportNoSignature:
+		s.noSignature++
+	case reportFailed:
+		s.failed++
+	case reportNotStarted:
+		s.notStarted++
+		return
+	}
+	s.pages += row.Pages
+	s.signatures += row.Signatures
+	d := time.Duration(row.DurationMS) * time.Millisecond
+	s.busy += d
+	if s.slowest == "" || d > s.slowestTime {
+		s.slowest, s.slowestTime = row.Input, d
+	}
+}
+
+// log writes the summary to the log (stderr).
+func (s *batchSummary) log() {
+	attrs := []any{
+		"documents", s.ok + s.noSignature + s.failed + s.notStarted,
+		"ok", s.ok,
+		"no_signature", s.noSignature,
+		"failed", s.failed,
+		"not_started", s.notStarted,
+		"pages", s.pages,
+		"signatures", s.signatures,
+		"elapsed", time.Since(s.start).Round(time.Millisecond),
+	}
+	if done := s.ok + s.noSignature + s.failed; done > 0 {
+		attrs = append(attrs,
+			"mean", (s.busy / time.Duration(done)).Round(time.Millisecond),
+			"slowest", s.slowest,
+			"slowest_time", s.slowestTime.Round(time.Millisecond),
+		)
+	}
+	slog.Info("Batch summary", attrs...)
+}
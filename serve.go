@@ -0,0 +1,344 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"poc-pdf/signature"
+)
+
+const (
+	// defaultServeAddr is where the server listens unless -addr is given.
+	defaultServeAddr = ":8080"
+	// defaultMaxUploadMB caps the size of an uploaded PDF.
+	defaultMaxUploadMB = 32
+	// defaultRequestTimeout bounds the pipeline for one request.
+	defaultRequestTimeout = 2 * time.Minute
+	// defaultShutdownTimeout is how long in-flight jobs and requests get to
+	// finish on SIGINT/SIGTERM, within Kubernetes' default grace period.
+	defaultShutdownTimeout = 25 * time.Second
+)
+
+// server answers POST /extract with the signatures found in an uploaded PDF,
+// and POST /jobs with a job whose result is fetched later.
+type server struct {
+	// opts is the pipeline configuration; OutputDir is replaced per request.
+	opts signature.Options
+	// maxUpload is the largest accepted request body in bytes.
+	maxUpload int64
+	// timeout bounds the extraction of one request.
+	timeout time.Duration
+	// jobs runs the asynchronous POST /jobs submissions.
+	jobs *jobQueue
+	// callbacks posts finished jobs to their callback_url; nil refuses them.
+	callbacks *callbacks
+	// auth admits requests by API key; nil leaves the server open.
+	auth *apiKeys
+	// tracing adds a server span per request; nil leaves them out.
+	tracing *tracing
+	// health answers the probes and tells when the server is draining.
+	health *health
+	// shutdownTimeout bounds the draining on SIGINT/SIGTERM.
+	shutdownTimeout time.Duration
+}
+
+// runServe implements the serve subcommand: it parses args, listens until
+// interrupted and then drains in-flight requests.
+func runServe(args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	addr := fs.String("addr", defaultServeAddr, "address to listen on")
+	grpcMode := fs.Bool("grpc", false, "serve the gRPC SignatureService (see signaturepb) on -addr instead of HTTP")
+	metricsAddr := fs.String("metrics-addr", "", "also serve Prometheus /metrics on this address (HTTP mode always has it on -addr)")
+	memstats := fs.Bool("memstats", false, "serve GET /debug/memstats with the open OpenCV images and Go heap figures (beside /metrics)")
+	maxUploadMB := fs.Int64("max-upload-mb", defaultMaxUploadMB, "largest accepted PDF upload, in MiB")
+	requestTimeout := fs.Duration("request-timeout", defaultRequestTimeout, "bound the extraction of one request (e.g. 30s)")
+	shutdownTimeout := fs.Duration("shutdown-timeout", defaultShutdownTimeout, "on SIGINT/SIGTERM, how long running and queued jobs and in-flight requests get to finish before they are cancelled")
+	render := addRenderFlags(fs)
+	limits := addLimitFlags(fs)
+	cache := addCacheFlags(fs)
+	logging := addLogFlags(fs)
+	traces := addTraceFlags(fs)
+	workers := fs.Int("workers", defaultJobWorkers, "jobs from POST /jobs processed at once")
+	queueSize := fs.Int("job-queue", defaultJobQueue, "accepted jobs that may wait for a worker before POST /jobs answers 503")
+	jobTimeout := fs.Duration("job-timeout", defaultJobTimeout, "bound the extraction of one job")
+	jobRetention := fs.Duration("job-retention", defaultJobRetention, "how long a finished job's result is kept")
+	callback := addCallbackFlags(fs)
+	access := addAuthFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: go run . serve [flags]")
+		fs.PrintDefaults()
+	}
+	_, problems := parseFlags(fs, args)
+
+	opts := signature.DefaultOptions()
+	render.apply(&opts)
+	limits.apply(&opts)
+	cache.apply(&opts, &problems)
+
+	problems.check(fs.NArg() == 0, "unexpected arguments: %v", fs.Args())
+	problems.check(*maxUploadMB >= 1, "-max-upload-mb must be at least 1, got %d", *maxUploadMB)
+	problems.check(*requestTimeout > 0, "-request-timeout must be positive, got %v", *requestTimeout)
+	problems.check(*shutdownTimeout > 0, "-shutdown-timeout must be positive, got %v", *shutdownTimeout)
+	problems.check(*workers >= 1, "-workers must be at least 1, got %d", *workers)
+	problems.check(*queueSize >= 0, "-job-queue must not be negative, got %d", *queueSize)
+	problems.check(*jobTimeout > 0, "-job-timeout must be positive, got %v", *jobTimeout)
+	problems.check(*jobRetention > 0, "-job-retention must be positive, got %v", *jobRetention)
+	problems.check(!*memstats || !*grpcMode || *metricsAddr != "", "-memstats needs -metrics-addr with -grpc")
+	callbacks := callback.config(&problems)
+	auth := access.config(&problems)
+	tracer := traces.config(&problems)
+	logger, err := setupLogging(logging)
+	problems.check(err == nil, "%v", err)
+	opts.Logger = logger
+	problems = append(problems, validateOptions(opts, nil)...)
+	if len(problems) > 0 {
+		return problems
+	}
+
+	stopTracing, err := tracer.start(context.Background())
+	if err != nil {
+		return err
+	}
+	defer stopTracing()
+	logRasterizer(opts)
+	s := &server{opts: withMetrics(opts), maxUpload: *maxUploadMB << 20, timeout: *requestTimeout, callbacks: callbacks, auth: auth, tracing: tracer,
+		health: newHealth(opts.Rasterizer), shutdownTimeout: *shutdownTimeout}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	// Startup hook: a broken environment crashes the server now rather than
+	// answering the first request with 500, and the first readiness probe
+	// reuses the outcome
+	if err := s.health.check(ctx); err != nil {
+		return withStatus(exitEnvironment, fmt.Errorf("startup check failed: %w", err))
+	}
+	slog.Info("Startup check passed")
+	serveMetrics(ctx, *metricsAddr, *memstats)
+	if *grpcMode {
+		return serveGRPC(ctx, *addr, s)
+	}
+
+	jobsCtx, cancelJobs := context.WithCancel(context.Background())
+	s.jobs = newJobQueue(jobsCtx, *workers, *queueSize, *jobTimeout, *jobRetention, callbacks)
+	defer func() {
+		// Jobs live in memory only, so unfinished ones are abandoned
+		cancelJobs()
+		s.jobs.discard()
+	}()
+
+	mux := http.NewServeMux()
+	mux.Handle("POST /extract", s.auth.protect(s.handleExtract))
+	mux.Handle("POST /jobs", s.auth.protect(s.handleSubmitJob))
+	mux.Handle("GET /jobs/{id}", s.auth.protect(s.handleJobStatus))
+	mux.Handle("GET /jobs/{id}/result", s.auth.protect(s.handleJobResult))
+	mux.Handle("GET /metrics", promhttp.Handler())
+	mux.HandleFunc("GET /healthz", s.health.handleHealthz)
+	mux.HandleFunc("GET /readyz", s.health.handleReadyz)
+	if *memstats {
+		mux.HandleFunc("GET /debug/memstats", handleMemStats)
+	}
+	srv := &http.Server{
+		Addr:              *addr,
+		Handler:           s.tracing.httpHandler(mux),
+		ReadHeaderTimeout: 10 * time.Second,
+		// Leave room to receive the upload and write the response around the extraction itself
+		WriteTimeout: *requestTimeout + time.Minute,
+	}
+
+	errc := make(chan error, 1)
+	go func() { errc <- srv.ListenAndServe() }()
+	slog.Info("Listening", "addr", *addr)
+
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+	// A second signal exits at once
+	stop()
+	return s.drain(srv)
+}
+
+// drain shuts the HTTP server down within shutdownTimeout. Readiness fails and
+// new work is refused first, while the jobs already accepted run to the end
+// and their status and results can still be fetched; then the listener closes
+// and in-flight requests get what time is left.
+func (s *server) drain(srv *http.Server) error {
+	slog.Info("Shutting down, draining jobs and requests", "timeout", s.shutdownTimeout)
+	s.health.draining.Store(true)
+	ctx, cancel := context.WithTimeout(context.Background(), s.shutdownTimeout)
+	defer cancel()
+	if !s.jobs.drain(ctx) {
+		slog.Warn("Jobs still running at -shutdown-timeout are cancelled")
+	}
+	return srv.Shutdown(ctx)
+}
+
+// accepting answers 503 and reports false once the server is shutting down.
+func (s *server) accepting(w http.ResponseWriter) bool {
+	if s.health.draining.Load() {
+		w.Header().Set("Connection", "close")
+		writeError(w, http.StatusServiceUnavailable, errDraining)
+		return false
+	}
+	return true
+}
+
+// serveResponse is the JSON body returned for ?format=json.
+type serveResponse struct {
+	Results []webhookPayload `json:"results"`
+}
+
+// handleExtract reads the PDF from the multipart "file" field, runs the pipeline
+// in a scratch directory and returns the first signature as a PNG, or every
+// signature as JSON with ?format=json. The optional "pages" query parameter
+// takes a -pages selection, and an optional "password" form field opens
+// encrypted PDFs.
+func (s *server) handleExtract(w http.ResponseWriter, r *http.Request) {
+	if !s.accepting(w) || !s.limitUpload(w, r) {
+		return
+	}
+	opts := s.opts
+	if pages := r.URL.Query().Get("pages"); pages != "" {
+		sel, err := signature.ParsePageSelection(pages)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("pages: %v", err))
+			return
+		}
+		opts.Pages = sel
+	}
+	asJSON := r.URL.Query().Get("format") == "json"
+
+	dir, err := os.MkdirTemp("", "poc-pdf-serve-")
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	pdfPath := filepath.Join(dir, "upload.pdf")
+	if status, err := saveUpload(r, pdfPath); err != nil {
+		writeError(w, status, err)
+		return
+	}
+	opts.Password = r.FormValue("password")
+
+	ctx, cancel := context.WithTimeout(r.Context(), s.timeout)
+	defer cancel()
+	opts.OutputDir = dir
+	start := time.Now()
+	results, err := signature.NewExtractor(opts).ExtractFromPDF(ctx, pdfPath)
+	slog.Info("Request done", "method", r.Method, "path", r.URL.Path, "signatures", len(results), "duration", time.Since(start).Round(time.Millisecond), "error", err)
+	recordDocument(results, err)
+	if err != nil {
+		status, err := pipelineError(ctx, err, s.timeout)
+		writeError(w, status, err)
+		return
+	}
+	writeResults(w, results, asJSON)
+}
+
+// pipelineError maps an extraction error to the HTTP status to answer with;
+// ctx is the extraction's context, bounded by timeout.
+func pipelineError(ctx context.Context, err error, timeout time.Duration) (int, error) {
+	switch {
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		return http.StatusGatewayTimeout, fmt.Errorf("extraction timed out after %v", timeout)
+	case errors.Is(err, signature.ErrNoSignature), errors.Is(err, signature.ErrEncrypted), errors.Is(err, signature.ErrCorruptPDF),
+		errors.Is(err, signature.ErrResourceLimit):
+		return http.StatusUnprocessableEntity, err
+	default:
+		return http.StatusInternalServerError, err
+	}
+}
+
+// writeResults answers with the first of results as a PNG, or every one as
+// JSON when asJSON is set.
+func writeResults(w http.ResponseWriter, results []*signature.Result, asJSON bool) {
+	if asJSON {
+		resp := serveResponse{Results: make([]webhookPayload, 0, len(results))}
+		for _, res := range results {
+			p, err := newWebhookPayload(res, true)
+			if err != nil {
+				writeError(w, http.StatusInternalServerError, err)
+				return
+			}
+			// The scratch directory is gone once the response is written
+			p.OutputPath = ""
+			resp.Results = append(resp.Results, p)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+		return
+	}
+
+	data, err := encodePNG(results[0].Image)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("X-Signature-Page", strconv.Itoa(results[0].Page))
+	w.Header().Set("X-Signature-Count", strconv.Itoa(len(results)))
+	w.Header().Set("X-Signature-Confidence", strconv.FormatFloat(results[0].Confidence, 'f', 2, 64))
+	w.Write(data)
+}
+
+// limitUpload caps the body of r at maxUpload. A request whose Content-Length
+// already exceeds it is answered 413 before any of the body is read, and
+// limitUpload reports false.
+func (s *server) limitUpload(w http.ResponseWriter, r *http.Request) bool {
+	if r.ContentLength > s.maxUpload {
+		writeError(w, http.StatusRequestEntityTooLarge, fmt.Errorf("upload exceeds %d bytes", s.maxUpload))
+		return false
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, s.maxUpload)
+	return true
+}
+
+// saveUpload copies the multipart "file" field of r to path. On failure it
+// returns the HTTP status to answer with.
+func saveUpload(r *http.Request, path string) (int, error) {
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return http.StatusRequestEntityTooLarge, fmt.Errorf("upload exceeds %d bytes", tooLarge.Limit)
+		}
+		return http.StatusBadRequest, fmt.Errorf("expected a multipart upload with a \"file\" field: %v", err)
+	}
+	defer file.Close()
+
+	out, err := os.Create(path)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	defer out.Close()
+	if _, err := io.Copy(out, file); err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if err := out.Close(); err != nil {
+		return http.StatusInternalServerError, err
+	}
+	return http.StatusOK, nil
+}
+
+// writeError answers with status and a JSON {"error": ...} body.
+func writeError(w http.ResponseWriter, status int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"poc-pdf/signature"
+)
+
+func TestServeFailsFastWithoutRasterizer(t *testing.T) {
+	// An empty PATH has none of the rasterizer tools
+	t.Setenv("PATH", t.TempDir())
+	done := make(chan error, 1)
+	go func() { done <- runServe([]string{"-addr", "127.0.0.1:0", "-rasterizer", signature.RasterizerPoppler}) }()
+	select {
+	case err := <-done:
+		if !errors.Is(err, signature.ErrRasterizerNotFound) {
+			t.Errorf("runServe = %v, want ErrRasterizerNotFound", err)
+		}
+		if status := exitStatus(err); status != exitEnvironment {
+			t.Errorf("exit status %d, want %d", status, exitEnvironment)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("serve started listening without a rasterizer")
+	}
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"go.yaml.in/yaml/v3"
+)
+
+// envPrefix starts the environment variables that set flags: POCPDF_DPI sets
+// -dpi and POCPDF_REQUEST_TIMEOUT sets -request-timeout.
+const envPrefix = "POCPDF_"
+
+// parseFlags registers -config on fs and parses args, then fills in every flag
+// not given on the command line from its POCPDF_* environment variable or,
+// failing that, from the config file. It returns the names of the flags set
+// by any of the three, which count as given explicitly (see applyProfile), and
+// the problems found in the environment and the file.
+func parseFlags(fs *flag.FlagSet, args []string) (map[string]bool, configProblems) {
+	configPath := fs.String("config", "", "YAML (.yaml, .yml) or TOML (.toml) file of flag values; POCPDF_* environment variables and command-line flags override it")
+	fs.Parse(args)
+
+	set := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { markSet(set, f.Name) })
+
+	var problems configProblems
+	fromEnv := make(map[string]bool)
+	fs.VisitAll(func(f *flag.Flag) {
+		value, ok := os.LookupEnv(envName(f.Name))
+		if !ok || set[f.Name] || fromEnv[f.Name] {
+			return
+		}
+		err := fs.Set(f.Name, value)
+		problems.check(err == nil, "%s=%q: %v", envName(f.Name), value, err)
+		markSet(fromEnv, f.Name)
+	})
+	for name := range fromEnv {
+		set[name] = true
+	}
+
+	if *configPath == "" {
+		return set, problems
+	}
+	values, custom, err := readConfigFile(*configPath, fs)
+	if err != nil {
+		problems.check(false, "-config: %v", err)
+		return set, problems
+	}
+	defineProfiles(custom)
+	for _, name := range sortedKeys(values) {
+		if set[name] {
+			continue
+		}
+		err := fs.Set(name, values[name])
+		problems.check(err == nil, "-config %s: %s: %v", *configPath, name, err)
+		markSet(set, name)
+	}
+	return set, problems
+}
+
+// envName is the environment variable of the flag called name.
+func envName(name string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+}
+
+// markSet records name, and the other spelling of it when it has one (see
+// flagAliases), as given.
+func markSet(set map[string]bool, name string) {
+	set[name] = true
+	for alias, canonical := range flagAliases {
+		switch name {
+		case alias:
+			set[canonical] = true
+		case canonical:
+			set[alias] = true
+		}
+	}
+}
+
+// readConfigFile returns the flag values the config file at path holds for the
+// command of fs, and the -profile presets its "profiles" table defines.
+// Top-level keys are flag names and apply to every command that has the flag;
+// a table named after a command (e.g. "serve") holds values for that command
+// only, which win over the top-level ones. Lists are joined with commas, as
+// flags like -pages and -anchor expect.
+func readConfigFile(path string, fs *flag.FlagSet) (map[string]string, map[string]map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	var doc map[string]any
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &doc)
+	case ".toml":
+		err = toml.Unmarshal(data, &doc)
+	default:
+		return nil, nil, fmt.Errorf("%s: want a .yaml, .yml or .toml file", path)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %v", path, err)
+	}
+
+	values := make(map[string]string)
+	for key, v := range doc {
+		if _, isTable := v.(map[string]any); isTable || fs.Lookup(key) == nil {
+			// Another command's table or flag
+			continue
+		}
+		if values[key], err = configValue(v); err != nil {
+			return nil, nil, fmt.Errorf("%s: %s: %v", path, key, err)
+		}
+	}
+	if section, ok := doc[fs.Name()].(map[string]any); ok {
+		for key, v := range section {
+			if fs.Lookup(key) == nil {
+				return nil, nil, fmt.Errorf("%s: %s has no flag -%s", path, fs.Name(), key)
+			}
+			if values[key], err = configValue(v); err != nil {
+				return nil, nil, fmt.Errorf("%s: %s.%s: %v", path, fs.Name(), key, err)
+			}
+		}
+	}
+
+	custom := make(map[string]map[string]string)
+	if doc["profiles"] != nil {
+		table, ok := doc["profiles"].(map[string]any)
+		if !ok {
+			return nil, nil, fmt.Errorf("%s: profiles: want a table of profiles", path)
+		}
+		for name, v := range table {
+			preset, ok := v.(map[string]any)
+			if !ok {
+				return nil, nil, fmt.Errorf("%s: profiles.%s: want a table of flag values", path, name)
+			}
+			custom[name] = make(map[string]string, len(preset))
+			for key, v := range preset {
+				if custom[name][key], err = configValue(v); err != nil {
+					return nil, nil, fmt.Errorf("%s: profiles.%s.%s: %v", path, name, key, err)
+				}
+			}
+		}
+	}
+	return values, custom, nil
+}
+
+// configValue formats a decoded config value the way it would be given as a
+// flag.
+func configValue(v any) (string, error) {
+	switch v := v.(type) {
+	case []any:
+		parts := make([]string, len(v))
+		for i, item := range v {
+			s, err := configValue(item)
+			if err != nil {
+				return "", err
+			}
+			parts[i] = s
+		}
+		return strings.Join(parts, ","), nil
+	case map[string]any:
+		return "", fmt.Errorf("want a value, got a table")
+	case nil:
+		return "", nil
+	default:
+		return fmt.Sprint(v), nil
+	}
+}
@@ -0,0 +1,234 @@
+package signature
+
+import (
+	"errors"
+	"fmt"
+	"image"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+	"gocv.io/x/gocv"
+)
+
+// maxFieldDepth bounds the walk of the AcroForm field tree, which a malformed
+// PDF could make arbitrarily deep.
+const maxFieldDepth = 32
+
+// formSignatureFields returns the signature fields (/FT /Sig) of the PDF's
+// AcroForm, grouped by page, with their widget rectangles in PDF points. A PDF
+// without a form has none. Fields are named by their fully qualified name,
+// e.g. "buyer.signature".
+func formSignatureFields(pdfPath, password string) (map[int][]Field, error) {
+	ctx, err := readPDFStructure(pdfPath, password)
+	if err != nil {
+		return nil, err
+	}
+	xref := ctx.XRefTable
+	roots, err := acroFormFields(xref)
+	if err != nil || roots == nil {
+		return nil, err
+	}
+
+	// The field tree knows which widgets are signatures, the pages which
+	// widgets they show: walk the first, then look the widgets up in the second
+	widgets := map[int]Field{}
+	visited := map[int]bool{}
+	for _, root := range roots {
+		collectSignatureWidgets(xref, root, "", "", 0, visited, widgets)
+	}
+	if len(widgets) == 0 {
+		return nil, nil
+	}
+	annots, err := pageAnnotations(xref)
+	if err != nil {
+		return nil, err
+	}
+	byPage := map[int][]Field{}
+	for _, a := range annots {
+		if w, ok := widgets[a.objNr]; ok {
+			w.Page = a.page
+			byPage[a.page] = append(byPage[a.page], w)
+		}
+	}
+	return byPage, nil
+}
+
+// acroFormFields returns the root fields of the PDF's AcroForm, nil without a
+// form.
+func acroFormFields(xref *model.XRefTable) (types.Array, error) {
+	catalog, err := xref.Catalog()
+	if err != nil {
+		return nil, err
+	}
+	acroForm, err := xref.DereferenceDict(catalog["AcroForm"])
+	if err != nil || acroForm == nil {
+		return nil, err
+	}
+	return xref.DereferenceArray(acroForm["Fields"])
+}
+
+// pageAnnotation is an annotation referenced from a page's /Annots.
+type pageAnnotation struct {
+	objNr, page int
+}
+
+// pageAnnotations lists the annotations of every page, in page order and then
+// in the order of each page's /Annots.
+func pageAnnotations(xref *model.XRefTable) ([]pageAnnotation, error) {
+	if err := xref.EnsurePageCount(); err != nil {
+		return nil, err
+	}
+	var list []pageAnnotation
+	for page := 1; page <= xref.PageCount; page++ {
+		pageDict, _, _, err := xref.PageDict(page, false)
+		if err != nil {
+			return nil, fmt.Errorf("page %d: %v", page, err)
+		}
+		annots, err := xref.DereferenceArray(pageDict["Annots"])
+		if err != nil {
+			continue
+		}
+		for _, a := range annots {
+			if ref, ok := a.(types.IndirectRef); ok {
+				list = append(list, pageAnnotation{objNr: ref.ObjectNumber.Value(), page: page})
+			}
+		}
+	}
+	return list, nil
+}
+
+// collectSignatureWidgets walks the field (or widget) obj and its kids, adding
+// every widget of a signature field to widgets by object number. name and ft
+// are inherited from the parent; visited guards against cycles.
+func collectSignatureWidgets(xref *model.XRefTable, obj types.Object, name, ft string, depth int, visited map[int]bool, widgets map[int]Field) {
+	if depth > maxFieldDepth {
+		return
+	}
+	ref, isRef := obj.(types.IndirectRef)
+	if isRef {
+		if visited[ref.ObjectNumber.Value()] {
+			return
+		}
+		visited[ref.ObjectNumber.Value()] = true
+	}
+	d, err := xref.DereferenceDict(obj)
+	if err != nil || d == nil {
+		return
+	}
+
+	if t, ok := d.Find("T"); ok {
+		if s, err := types.StringOrHexLiteral(t); err == nil && s != nil {
+			if name != "" {
+				name += "."
+			}
+			name += *s
+		}
+	}
+	if own := d.NameEntry("FT"); own != nil {
+		ft = *own
+	}
+	if ft == "Sig" && isRef {
+		if rect, err := fieldRect(xref, d); err == nil {
+			widgets[ref.ObjectNumber.Value()] = Field{Name: name, Rect: rect}
+		}
+	}
+
+	kids, err := xref.DereferenceArray(d["Kids"])
+	if err != nil {
+		return
+	}
+	for _, kid := range kids {
+		collectSignatureWidgets(xref, kid, name, ft, depth+1, visited, widgets)
+	}
+}
+
+// fieldRect reads the /Rect of a widget, normalized so LL is the lower left.
+func fieldRect(xref *model.XRefTable, d types.Dict) (PDFRect, error) {
+	a, err := xref.DereferenceArray(d["Rect"])
+	if err != nil {
+		return PDFRect{}, err
+	}
+	if len(a) != 4 {
+		return PDFRect{}, errors.New("widget has no rectangle")
+	}
+	r, err := xref.RectForArray(a)
+	if err != nil {
+		return PDFRect{}, err
+	}
+	rect := PDFRect{
+		LLX: min(r.LL.X, r.UR.X), LLY: min(r.LL.Y, r.UR.Y),
+		URX: max(r.LL.X, r.UR.X), URY: max(r.LL.Y, r.UR.Y),
+	}
+	if rect.Width() <= 0 || rect.Height() <= 0 {
+		return PDFRect{}, errors.New("widget rectangle has no area")
+	}
+	return rect, nil
+}
+
+// fieldRegions crops the signature fields of a page straight from its render
+// instead of detecting contours. Fields without ink are skipped, as a form
+// sent out but not yet signed has them. Without all only the field with the
+// most ink is returned, as detection returns only the largest region. With
+// colorInk strongly colored pixels count as ink (see Options.ColorInk).
+func fieldRegions(page pageRender, fields []Field, dpi float64, box pageBox, upsideDown bool, binarization string, colorInk bool, all bool) ([]SignatureRegion, error) {
+	img, err := readImage(page.Path, gocv.IMReadColor)
+	if err != nil {
+		return nil, err
+	}
+	defer img.Close()
+	gray := pageBuffers.get()
+	defer pageBuffers.put(gray)
+	gocv.CvtColor(img, &gray, gocv.ColorBGRToGray)
+	bin, _, _ := binarize(gray, binarization, dpi)
+	defer pageBuffers.put(bin)
+	if colorInk {
+		if err := addColorInk(img, &bin); err != nil {
+			return nil, fmt.Errorf("failed to segment colored ink: %v", err)
+		}
+	}
+
+	renderRect := image.Rect(0, 0, page.Size.X, page.Size.Y)
+	var regions []SignatureRegion
+	var inkCounts []int
+	for _, f := range fields {
+		// Field rectangles are full-page pixels; the render may be an ROI or turned over
+		rect := pdfRectToPixels(f.Rect, dpi, box).Sub(page.Origin).Intersect(renderRect)
+		if rect.Empty() {
+			continue
+		}
+		if upsideDown {
+			rect = rotateRect180(rect, page.Size)
+		}
+		region := bin.Region(rect)
+		ink := gocv.CountNonZero(region)
+		region.Close()
+		if ink == 0 {
+			continue
+		}
+
+		crop := img.Region(rect)
+		factors := regionConfidence(bin, rect, page.Size)
+		regions = append(regions, SignatureRegion{Bounds: rect, Image: crop.Clone(), Ink: rect, Field: f.Name, Confidence: factors.Score(), Factors: factors})
+		crop.Close()
+		inkCounts = append(inkCounts, ink)
+	}
+	if len(regions) == 0 {
+		return nil, fmt.Errorf("%w: every signature field is blank", ErrNoSignature)
+	}
+	if all || len(regions) == 1 {
+		return regions, nil
+	}
+
+	best := 0
+	for i := range regions {
+		if inkCounts[i] > inkCounts[best] {
+			best = i
+		}
+	}
+	for i := range regions {
+		if i != best {
+			regions[i].Image.Close()
+		}
+	}
+	return regions[best : best+1], nil
+}
@@ -0,0 +1,95 @@
+package signature
+
+import (
+	"image"
+	"image/color"
+	"math"
+
+	"gocv.io/x/gocv"
+)
+
+// inkMask thresholds a BGR crop so ink pixels become 255 and background 0.
+// The caller owns the returned Mat.
+func inkMask(crop gocv.Mat) gocv.Mat {
+	gray := gocv.NewMat()
+	defer gray.Close()
+	gocv.CvtColor(crop, &gray, gocv.ColorBGRToGray)
+
+	mask := gocv.NewMat()
+	gocv.Threshold(gray, &mask, inkThreshold, 255, gocv.ThresholdBinaryInv)
+	return mask
+}
+
+// principalAxisAngle returns the angle, in degrees, between the x axis and the
+// principal axis of the ink point cloud in mask. Positive angles point down-right
+// (image y grows downwards). ok is false when there is too little ink to tell.
+func principalAxisAngle(mask gocv.Mat) (angle float64, ok bool) {
+	var n, sumX, sumY float64
+	for y := 0; y < mask.Rows(); y++ {
+		for x := 0; x < mask.Cols(); x++ {
+			if mask.GetUCharAt(y, x) != 0 {
+				n++
+				sumX += float64(x)
+				sumY += float64(y)
+			}
+		}
+	}
+	if n < 2 {
+		return 0, false
+	}
+	meanX, meanY := sumX/n, sumY/n
+
+	// Second central moments form the 2x2 covariance matrix of the ink
+	var sxx, syy, sxy float64
+	for y := 0; y < mask.Rows(); y++ {
+		for x := 0; x < mask.Cols(); x++ {
+			if mask.GetUCharAt(y, x) != 0 {
+				dx, dy := float64(x)-meanX, float64(y)-meanY
+				sxx += dx * dx
+				syy += dy * dy
+				sxy += dx * dy
+			}
+		}
+	}
+
+	// Orientation of the eigenvector with the largest eigenvalue
+	theta := 0.5 * math.Atan2(2*sxy, sxx-syy)
+	return theta * 180 / math.Pi, true
+}
+
+// alignToPrincipalAxis rotates a BGR crop so the principal axis of its ink is
+// horizontal, growing the canvas so no ink is clipped and filling with white.
+// It returns the rotated crop (owned by the caller) and the applied rotation in
+// degrees, counter-clockwise as OpenCV defines it.
+func alignToPrincipalAxis(crop gocv.Mat) (gocv.Mat, float64) {
+	mask := inkMask(crop)
+	angle, ok := principalAxisAngle(mask)
+	mask.Close()
+	if !ok || angle == 0 {
+		return crop.Clone(), 0
+	}
+	return rotateExpanded(crop, angle), angle
+}
+
+// rotateExpanded rotates a BGR crop by angle degrees (counter-clockwise as
+// OpenCV defines it), growing the canvas so no ink is clipped and filling with
+// white. The caller owns the returned Mat.
+func rotateExpanded(crop gocv.Mat, angle float64) gocv.Mat {
+	w, h := float64(crop.Cols()), float64(crop.Rows())
+	rad := angle * math.Pi / 180
+	cos, sin := math.Abs(math.Cos(rad)), math.Abs(math.Sin(rad))
+	newW := int(math.Ceil(w*cos + h*sin))
+	newH := int(math.Ceil(w*sin + h*cos))
+
+	// Rotate about the crop center, then shift so the result is centered on the larger canvas.
+	// A down-right axis (positive angle) needs a counter-clockwise turn, which is positive in OpenCV.
+	m := gocv.GetRotationMatrix2D(image.Pt(crop.Cols()/2, crop.Rows()/2), angle, 1)
+	defer m.Close()
+	m.SetDoubleAt(0, 2, m.GetDoubleAt(0, 2)+float64(newW-crop.Cols())/2)
+	m.SetDoubleAt(1, 2, m.GetDoubleAt(1, 2)+float64(newH-crop.Rows())/2)
+
+	rotated := gocv.NewMat()
+	white := color.RGBA{R: 255, G: 255, B: 255, A: 255}
+	gocv.WarpAffineWithParams(crop, &rotated, m, image.Pt(newW, newH), gocv.InterpolationCubic, gocv.BorderConstant, white)
+	return rotated
+}
@@ -0,0 +1,30 @@
+package signature
+
+import (
+	"math"
+	"testing"
+)
+
+func TestPCAAlignLevelsRotatedSignature(t *testing.T) {
+	img := newPage(850, 1100)
+	drawSignature(img, 300, 750, 25, 1, blueInk)
+	path := writeFixture(t, t.TempDir(), "rotated.png", img)
+	drawn := inkAngle(img)
+
+	opts := fixtureOptions(t)
+	opts.PCAAlign = true
+	results := extractFixture(t, opts, path)
+	if len(results) != 1 {
+		t.Fatalf("got %d results, want 1", len(results))
+	}
+	res := results[0]
+	if math.Abs(res.AlignAngle-drawn) > 2 {
+		t.Errorf("AlignAngle = %.2f, want about %.2f, the slant of the drawn ink", res.AlignAngle, drawn)
+	}
+	if angle := inkAngle(res.Image); math.Abs(angle) > 2 {
+		t.Errorf("aligned signature's ink runs at %.2f degrees, want horizontal", angle)
+	}
+	if b := res.Image.Bounds(); b.Dx() <= b.Dy() {
+		t.Errorf("aligned signature is %dx%d, want wider than tall", b.Dx(), b.Dy())
+	}
+}
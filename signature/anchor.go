@@ -0,0 +1,168 @@
+package signature
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"os/exec"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// DefaultOCRLanguage is the Tesseract language used to read anchor labels.
+const DefaultOCRLanguage = "eng"
+
+// Anchor search area, relative to the label. Pixel values are at thresholdReferenceDPI.
+const (
+	// anchorReachAbove is how far above the label, in label heights, a signature
+	// may rise: people sign over the line rather than on it.
+	anchorReachAbove = 3
+	// anchorReachBelow is how far below the label a signature may start, for
+	// forms that put the signing line under the label.
+	anchorReachBelow = 60
+)
+
+// ocrWord is one word Tesseract recognized, with its box in image pixels.
+type ocrWord struct {
+	Text string
+	Box  image.Rectangle
+	// line identifies the text line (block, paragraph, line) the word belongs to.
+	line [3]int
+}
+
+// ParseAnchors splits a comma-separated list of anchor phrases such as
+// "Signature:,Assinatura:", dropping empty entries.
+func ParseAnchors(list string) []string {
+	var anchors []string
+	for _, a := range strings.Split(list, ",") {
+		if a = strings.TrimSpace(a); a != "" {
+			anchors = append(anchors, a)
+		}
+	}
+	return anchors
+}
+
+// findAnchors runs Tesseract on the page image and returns the box of every
+// occurrence of any of the phrases. Matching ignores case and punctuation, and
+// the words of a phrase must follow each other on one text line.
+func findAnchors(ctx context.Context, pagePath string, phrases []string, lang string) ([]image.Rectangle, error) {
+	words, err := ocrWords(ctx, pagePath, lang)
+	if err != nil {
+		return nil, err
+	}
+
+	var anchors []image.Rectangle
+	for _, phrase := range phrases {
+		want := strings.Fields(normalizeWord(phrase))
+		if len(want) == 0 {
+			continue
+		}
+		for i := range words {
+			if box, ok := matchPhrase(words[i:], want); ok {
+				anchors = append(anchors, box)
+			}
+		}
+	}
+	return anchors, nil
+}
+
+// matchPhrase reports whether words starts with the normalized phrase want on a
+// single line, and returns the union of the matched word boxes.
+func matchPhrase(words []ocrWord, want []string) (image.Rectangle, bool) {
+	if len(words) < len(want) {
+		return image.Rectangle{}, false
+	}
+	box := words[0].Box
+	for i, w := range want {
+		if words[i].line != words[0].line || normalizeWord(words[i].Text) != w {
+			return image.Rectangle{}, false
+		}
+		box = box.Union(words[i].Box)
+	}
+	return box, true
+}
+
+// normalizeWord lowercases s and strips punctuation, so "Signature:" matches "signature".
+func normalizeWord(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsPunct(r) {
+			return -1
+		}
+		return unicode.ToLower(r)
+	}, s)
+}
+
+// ocrWords shells out to the tesseract CLI and parses its TSV output into words
+// in reading order.
+func ocrWords(ctx context.Context, pagePath, lang string) ([]ocrWord, error) {
+	tesseract, err := exec.LookPath("tesseract")
+	if err != nil {
+		return nil, fmt.Errorf("reading text needs the tesseract tool on PATH (e.g. brew install tesseract, apt-get install tesseract-ocr): %v", err)
+	}
+	// Example: tesseract page.png stdout -l eng tsv
+	cmd := exec.CommandContext(ctx, tesseract, pagePath, "stdout", "-l", lang, "tsv")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, fmt.Errorf("tesseract error: %v: %s", err, stderr.Bytes())
+	}
+
+	// Columns: level page_num block_num par_num line_num word_num left top width height conf text
+	var words []ocrWord
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		fields := strings.Split(scanner.Text(), "\t")
+		if len(fields) != 12 || fields[0] != "5" || strings.TrimSpace(fields[11]) == "" {
+			continue // header, or a block/paragraph/line row rather than a word
+		}
+		// block, paragraph and line numbers, then left, top, width and height
+		var v [7]int
+		for i, f := range append(fields[2:5:5], fields[6:10]...) {
+			if v[i], err = strconv.Atoi(f); err != nil {
+				return nil, fmt.Errorf("invalid tesseract output %q: %v", scanner.Text(), err)
+			}
+		}
+		words = append(words, ocrWord{
+			Text: fields[11],
+			Box:  image.Rect(v[3], v[4], v[3]+v[5], v[4]+v[6]),
+			line: [3]int{v[0], v[1], v[2]},
+		})
+	}
+	return words, scanner.Err()
+}
+
+// anchorSearchArea is where the signature belonging to the label at anchor is
+// looked for: from the label's left edge to the right border of the page, from
+// a few label heights above it to anchorReachBelow under it, clipped to page.
+func anchorSearchArea(anchor, page image.Rectangle, dpi float64) image.Rectangle {
+	return image.Rect(
+		anchor.Min.X,
+		anchor.Min.Y-anchorReachAbove*anchor.Dy(),
+		page.Max.X,
+		anchor.Max.Y+scaleLength(anchorReachBelow, dpi),
+	).Intersect(page)
+}
+
+// inSearchAreas reports whether rect belongs to one of the areas: its centre
+// lies inside the area and it is not part of the label text itself.
+func inSearchAreas(rect image.Rectangle, areas, labels []image.Rectangle) bool {
+	centre := image.Pt((rect.Min.X+rect.Max.X)/2, (rect.Min.Y+rect.Max.Y)/2)
+	for _, label := range labels {
+		if centre.In(label) {
+			return false
+		}
+	}
+	for _, area := range areas {
+		if centre.In(area) {
+			return true
+		}
+	}
+	return false
+}
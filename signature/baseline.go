@@ -0,0 +1,59 @@
+package signature
+
+import (
+	"image"
+
+	"gocv.io/x/gocv"
+)
+
+// baselineBandRatio is the fraction of the densest row's ink a row needs to
+// belong to the main body of the signature.
+const baselineBandRatio = 0.5
+
+// detectBaseline finds where the pen rests from the horizontal projection of the
+// ink mask: it takes the contiguous band of dense rows around the densest one (the
+// main body of the writing) and returns the row just below it. Descenders and
+// flourishes extend beyond the band, so the result always lies inside the ink's
+// vertical extent. ok is false when the mask has no ink.
+func detectBaseline(mask gocv.Mat) (y int, ok bool) {
+	rows := make([]int, mask.Rows())
+	peak := 0
+	for r := range rows {
+		for c := 0; c < mask.Cols(); c++ {
+			if mask.GetUCharAt(r, c) != 0 {
+				rows[r]++
+			}
+		}
+		if rows[r] > rows[peak] {
+			peak = r
+		}
+	}
+	if rows[peak] == 0 {
+		return 0, false
+	}
+
+	limit := float64(rows[peak]) * baselineBandRatio
+	bottom := peak
+	for bottom+1 < len(rows) && float64(rows[bottom+1]) >= limit {
+		bottom++
+	}
+	return bottom + 1, true
+}
+
+// splitAtBaseline returns copies of the crop above and below row y; the caller
+// closes both. The lower Mat is empty when the baseline is the last row.
+func splitAtBaseline(crop gocv.Mat, y int) (above, below gocv.Mat) {
+	w, h := crop.Cols(), crop.Rows()
+
+	top := crop.Region(image.Rect(0, 0, w, y))
+	above = top.Clone()
+	top.Close()
+
+	if y >= h {
+		return above, gocv.NewMat()
+	}
+	bottom := crop.Region(image.Rect(0, y, w, h))
+	below = bottom.Clone()
+	bottom.Close()
+	return above, below
+}
@@ -0,0 +1,43 @@
+package signature
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBaselineWithinInk(t *testing.T) {
+	opts := fixtureOptions(t)
+	opts.DetectBaseline = true
+	results := extractFixture(t, opts, signaturePage(t, t.TempDir()))
+	if len(results) != 1 {
+		t.Fatalf("got %d results, want 1", len(results))
+	}
+	res := results[0]
+
+	// The rows of the output holding ink
+	top, bottom := -1, -1
+	b := res.Image.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if isInk(res.Image.RGBAAt(x, y)) {
+				if top < 0 {
+					top = y
+				}
+				bottom = y
+				break
+			}
+		}
+	}
+	if top < 0 {
+		t.Fatal("output has no ink")
+	}
+	if res.Baseline < top || res.Baseline > bottom+1 {
+		t.Errorf("Baseline = %d, want within the ink rows %d-%d", res.Baseline, top, bottom)
+	}
+	for _, part := range []string{"signature_above.png", "signature_below.png"} {
+		if _, err := os.Stat(filepath.Join(opts.OutputDir, part)); err != nil {
+			t.Errorf("baseline split: %v", err)
+		}
+	}
+}
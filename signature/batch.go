@@ -0,0 +1,204 @@
+package signature
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// BatchResult is the outcome of one document in a batch.
+type BatchResult struct {
+	// Index is the position of Path in the input list.
+	Index int
+	// Path is the input PDF.
+	Path string
+	// Results holds one extraction per page with a signature; nil when Err is set.
+	Results []*Result
+	// Err is why the document failed, if it did.
+	Err error
+	// Pages is how many pages of the document were processed; 0 when it
+	// failed before any was.
+	Pages int
+	// Duration is how long the document took, cache lookups included.
+	Duration time.Duration
+}
+
+// ExtractBatch processes paths concurrently with Options.Workers workers and streams
+// each outcome as soon as that document finishes, in completion order. Outputs are
+// named after each input file. When ctx is cancelled no new documents are started,
+// in-flight subprocesses are killed, and the channel is closed once workers exit.
+func (e *Extractor) ExtractBatch(ctx context.Context, paths []string) (<-chan BatchResult, error) {
+	if len(paths) == 0 {
+		return nil, errors.New("no input files")
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	prefixes := outputPrefixes(paths)
+	jobs := make([]batchJob, len(paths))
+	for i, p := range paths {
+		jobs[i] = batchJob{path: p, outDir: e.opts.OutputDir, debugDir: e.opts.DebugDir, prefix: prefixes[i]}
+	}
+	return e.runBatch(ctx, jobs), nil
+}
+
+// ExtractAs processes the document at path like one document of ExtractBatch,
+// but names its outputs after name, a file name without extension, instead of
+// the input file. BatchResult.Index is 0.
+func (e *Extractor) ExtractAs(ctx context.Context, path, name string) BatchResult {
+	job := batchJob{path: path, outDir: e.opts.OutputDir, debugDir: e.opts.DebugDir, prefix: name}
+	var stats documentStats
+	start := time.Now()
+	res, err := e.extractTo(ctx, job, &stats)
+	return BatchResult{Path: path, Results: res, Err: err, Pages: stats.pages, Duration: time.Since(start)}
+}
+
+// ExtractDir finds every PDF under dir (recursively, any case of the .pdf
+// extension) and processes them like ExtractBatch. Outputs mirror the input
+// layout: dir/a/b.pdf writes Options.OutputDir/a/b_signature_result.png. It
+// returns the documents found in lexical order, which BatchResult.Index refers to.
+func (e *Extractor) ExtractDir(ctx context.Context, dir string) ([]string, <-chan BatchResult, error) {
+	var paths []string
+	var jobs []batchJob
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !strings.EqualFold(filepath.Ext(path), ".pdf") {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, filepath.Dir(path))
+		if err != nil {
+			return err
+		}
+		paths = append(paths, path)
+		job := batchJob{
+			path:   path,
+			outDir: filepath.Join(e.opts.OutputDir, rel),
+			prefix: strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)),
+		}
+		if e.opts.DebugDir != "" {
+			job.debugDir = filepath.Join(e.opts.DebugDir, rel)
+		}
+		jobs = append(jobs, job)
+		return nil
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to scan %s: %v", dir, err)
+	}
+	if len(paths) == 0 {
+		return nil, nil, fmt.Errorf("no PDF files found in %s", dir)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+	return paths, e.runBatch(ctx, jobs), nil
+}
+
+// batchJob is one document of a batch and where its outputs go.
+type batchJob struct {
+	path   string
+	outDir string
+	// debugDir replaces Options.DebugDir, mirroring the input layout like outDir.
+	debugDir string
+	prefix   string
+}
+
+// runBatch processes jobs with Options.Workers workers; see ExtractBatch.
+func (e *Extractor) runBatch(ctx context.Context, jobs []batchJob) <-chan BatchResult {
+	workers := min(max(e.opts.Workers, 1), len(jobs))
+
+	queue := make(chan int)
+	go func() {
+		defer close(queue)
+		for i := range jobs {
+			select {
+			case queue <- i:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	out := make(chan BatchResult)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range queue {
+				if ctx.Err() != nil {
+					return
+				}
+				job := jobs[i]
+				var stats documentStats
+				start := time.Now()
+				res, err := e.extractTo(ctx, job, &stats)
+				select {
+				case out <- BatchResult{Index: i, Path: job.path, Results: res, Err: err, Pages: stats.pages, Duration: time.Since(start)}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(out)
+	}()
+	return out
+}
+
+// extractTo runs the pipeline on one job, creating its output directory first
+// when it differs from Options.OutputDir.
+func (e *Extractor) extractTo(ctx context.Context, job batchJob, stats *documentStats) ([]*Result, error) {
+	if job.outDir == e.opts.OutputDir && job.debugDir == e.opts.DebugDir {
+		return e.extract(ctx, job.path, job.prefix, stats)
+	}
+	if err := os.MkdirAll(job.outDir, 0o755); err != nil {
+		return nil, err
+	}
+	sub := &Extractor{opts: e.opts, seen: e.seen}
+	sub.opts.OutputDir, sub.opts.DebugDir = job.outDir, job.debugDir
+	return sub.extract(ctx, job.path, job.prefix, stats)
+}
+
+// outputPrefixes names each input after its file name without extension,
+// numbering repeats (a, a_2, a_3, skipping a name another input has) so files
+// from different directories don't collide. The first input of each name
+// keeps it, so a_2.pdf stays a_2 even behind two a.pdf.
+func outputPrefixes(paths []string) []string {
+	used := map[string]bool{}
+	prefixes := make([]string, len(paths))
+	named := make([]bool, len(paths))
+	for i, p := range paths {
+		base := strings.TrimSuffix(filepath.Base(p), filepath.Ext(p))
+		if !used[base] {
+			used[base] = true
+			prefixes[i], named[i] = base, true
+		}
+	}
+	// The repeats are numbered past every name taken above
+	for i, p := range paths {
+		if named[i] {
+			continue
+		}
+		base := strings.TrimSuffix(filepath.Base(p), filepath.Ext(p))
+		prefix := base
+		for n := 2; used[prefix]; n++ {
+			prefix = base + "_" + strconv.Itoa(n)
+		}
+		used[prefix] = true
+		prefixes[i] = prefix
+	}
+	return prefixes
+}
@@ -0,0 +1,69 @@
+package signature
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestOutputPrefixes(t *testing.T) {
+	tests := []struct {
+		paths []string
+		want  []string
+	}{
+		{[]string{"a.pdf", "b.PDF"}, []string{"a", "b"}},
+		{[]string{"x/a.pdf", "y/a.pdf", "z/a.pdf"}, []string{"a", "a_2", "a_3"}},
+		{[]string{"x/a.pdf", "y/a.pdf", "a_2.pdf"}, []string{"a", "a_3", "a_2"}},
+		{[]string{"a.pdf", "a.pdf", "a_2.pdf"}, []string{"a", "a_3", "a_2"}},
+		{[]string{"a_2.pdf", "x/a_2.pdf", "a.pdf", "y/a.pdf"}, []string{"a_2", "a_2_2", "a", "a_3"}},
+		{[]string{"a_2.pdf", "x/a.pdf", "y/a.pdf"}, []string{"a_2", "a", "a_3"}},
+	}
+	for _, tt := range tests {
+		if got := outputPrefixes(tt.paths); !slices.Equal(got, tt.want) {
+			t.Errorf("outputPrefixes(%q) = %q, want %q", tt.paths, got, tt.want)
+		}
+	}
+}
+
+func TestExtractBatchCancel(t *testing.T) {
+	// Missing files fail straight away, so the batch outruns the reader
+	dir := t.TempDir()
+	paths := make([]string, 1000)
+	for i := range paths {
+		paths[i] = filepath.Join(dir, fmt.Sprintf("missing_%d.pdf", i))
+	}
+	opts := DefaultOptions()
+	opts.OutputDir = dir
+	opts.Workers = 4
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	results, err := NewExtractor(opts).ExtractBatch(ctx, paths)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := <-results
+	if r.Err == nil {
+		t.Fatalf("%s: want an error for a missing file", r.Path)
+	}
+	cancel()
+	n := 1
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case _, ok := <-results:
+			if !ok {
+				if n >= len(paths) {
+					t.Errorf("got all %d results after cancelling", n)
+				}
+				return
+			}
+			n++
+		case <-timeout:
+			t.Fatalf("results channel still open 5s after cancelling (%d results)", n)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package signature
+
+import (
+	"gocv.io/x/gocv"
+)
+
+// Supported values for Options.Binarization.
+const (
+	// BinarizeAuto picks one of the methods below from the page's gray-level histogram.
+	BinarizeAuto = "auto"
+	// BinarizeFixed treats every pixel darker than 200 as ink, which suits clean
+	// scans on white paper.
+	BinarizeFixed = "fixed"
+	// BinarizeOtsu picks the global cutoff that best separates ink from paper,
+	// for scans that are uniformly too dark or too light.
+	BinarizeOtsu = "otsu"
+	// BinarizeAdaptive compares each pixel with a Gaussian-weighted mean of its
+	// neighbourhood, for photographed pages with uneven lighting.
+	BinarizeAdaptive = "adaptive"
+)
+
+// Binarization tuning. Pixel values are at thresholdReferenceDPI.
+const (
+	// cleanPaperLevel is the median gray level above which the paper is white
+	// enough for the fixed cutoff.
+	cleanPaperLevel = 220
+	// unevenPaperSpread is the interquartile range of gray levels above which
+	// the background is not one flat tone and needs a local threshold.
+	unevenPaperSpread = 40
+	// adaptiveBlockSize is the side of the neighbourhood adaptive thresholding
+	// compares against; it must be well above the stroke width.
+	adaptiveBlockSize = 25
+	// adaptiveOffset is how much darker than its neighbourhood a pixel must be to count as ink.
+	adaptiveOffset = 15
+)
+
+// ValidBinarization reports whether name is a known Options.Binarization value.
+func ValidBinarization(name string) bool {
+	switch name {
+	case "", BinarizeAuto, BinarizeFixed, BinarizeOtsu, BinarizeAdaptive:
+		return true
+	}
+	return false
+}
+
+// binarize thresholds a grayscale image with method so ink becomes 255 and the
+// background 0, and returns the mask (a pageBuffers buffer the caller puts
+// back) with the method
+// actually used, which differs from method only for auto, and the global gray
+// cutoff (0 for adaptive, whose cutoff varies across the page). dpi is the
+// resolution of gray and sizes the adaptive neighbourhood.
+func binarize(gray gocv.Mat, method string, dpi float64) (gocv.Mat, string, float64) {
+	if method == "" || method == BinarizeAuto {
+		method = chooseBinarization(gray)
+	}
+
+	bin := pageBuffers.get()
+	var cutoff float32
+	switch method {
+	case BinarizeOtsu:
+		cutoff = gocv.Threshold(gray, &bin, 0, 255, gocv.ThresholdBinaryInv|gocv.ThresholdOtsu)
+	case BinarizeAdaptive:
+		// The block size must be odd
+		block := scaleLength(adaptiveBlockSize, dpi) | 1
+		gocv.AdaptiveThreshold(gray, &bin, 255, gocv.AdaptiveThresholdGaussian, gocv.ThresholdBinaryInv, block, adaptiveOffset)
+	default:
+		cutoff = gocv.Threshold(gray, &bin, inkThreshold, 255, gocv.ThresholdBinaryInv)
+	}
+	return bin, method, float64(cutoff)
+}
+
+// chooseBinarization inspects the gray-level histogram of a page. Most pixels of
+// a document are paper, so the median is the paper tone and the interquartile
+// range how much it varies: bright paper keeps the fixed cutoff, a spread-out
+// background (shadows, a lighting gradient) needs adaptive thresholding, and a
+// flat but dark or washed-out page gets Otsu.
+func chooseBinarization(gray gocv.Mat) string {
+	var hist [256]int
+	pixels := gray.ToBytes()
+	for _, v := range pixels {
+		hist[v]++
+	}
+	if len(pixels) == 0 {
+		return BinarizeFixed
+	}
+
+	median := histogramPercentile(hist, len(pixels), 0.5)
+	spread := histogramPercentile(hist, len(pixels), 0.75) - histogramPercentile(hist, len(pixels), 0.25)
+	switch {
+	case median >= cleanPaperLevel && spread < unevenPaperSpread:
+		return BinarizeFixed
+	case spread >= unevenPaperSpread:
+		return BinarizeAdaptive
+	default:
+		return BinarizeOtsu
+	}
+}
+
+// histogramPercentile returns the gray level below which fraction p of the total pixels lie.
+func histogramPercentile(hist [256]int, total int, p float64) int {
+	target := int(float64(total) * p)
+	seen := 0
+	for level, n := range hist {
+		seen += n
+		if seen > target {
+			return level
+		}
+	}
+	return len(hist) - 1
+}
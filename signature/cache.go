@@ -0,0 +1,302 @@
+package signature
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// cacheVersion is part of every cache key; bump it when the pipeline or the
+// entry layout changes what a document gives, so old entries are not served.
+const cacheVersion = "1"
+
+// Cache stores what processing a document gave, so processing the same bytes
+// with the same options again (see Options.Cache) restores it instead of
+// running the pipeline. It must be safe for concurrent use.
+type Cache interface {
+	// Get returns the entry stored under key; ok is false when there is none.
+	Get(ctx context.Context, key string) (entry []byte, ok bool, err error)
+	// Put stores entry under key, replacing any.
+	Put(ctx context.Context, key string, entry []byte) error
+}
+
+// DirCache is a Cache keeping one file per entry in a local directory, which
+// is created on the first Put. Entries older than TTL, when non-zero, count
+// as missing.
+type DirCache struct {
+	Dir string
+	TTL time.Duration
+}
+
+// Get implements Cache.
+func (c DirCache) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	path := filepath.Join(c.Dir, key+".json")
+	info, err := os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	if c.TTL > 0 && time.Since(info.ModTime()) > c.TTL {
+		return nil, false, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, false, nil
+	}
+	return data, err == nil, err
+}
+
+// Put implements Cache. The entry is written to a temporary file and renamed
+// into place, so a concurrent Get never sees half of it.
+func (c DirCache) Put(ctx context.Context, key string, entry []byte) error {
+	if err := os.MkdirAll(c.Dir, 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(c.Dir, key+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(entry); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filepath.Join(c.Dir, key+".json"))
+}
+
+// cacheEntry is what the cache holds for one document.
+type cacheEntry struct {
+	// NoSignature records that no page had one, which is worth remembering too.
+	NoSignature bool           `json:"no_signature,omitempty"`
+	Results     []cachedResult `json:"results,omitempty"`
+	// Pages is documentStats.pages of the run that stored the entry.
+	Pages int `json:"pages,omitempty"`
+}
+
+// cachedResult is one Result of a cacheEntry.
+type cachedResult struct {
+	// Result has no Image, and its OutputPath is relative to Options.OutputDir.
+	Result *Result `json:"result"`
+	// Image is Result.Image as PNG.
+	Image []byte `json:"image,omitempty"`
+	// Output is the content of the output file.
+	Output []byte `json:"output"`
+}
+
+// uncacheable reports why the outputs of a run with the options of e can't be
+// cached, or "" when they can. Only the outputs and metadata files of the
+// results are stored, so options writing other files are left out.
+func (e *Extractor) uncacheable() string {
+	opts := e.opts
+	for _, t := range append([]*Template{opts.Template}, opts.Templates...) {
+		if t != nil && len(markZones(t.Zones)) > 0 {
+			return "mark zones write a mark report"
+		}
+	}
+	switch {
+	case opts.DryRun:
+		return "-dry-run writes previews"
+	case opts.DebugDir != "", opts.Sweep != nil:
+		return "debug output is not cached"
+	case opts.KeepTemp:
+		return "-keep-temp keeps the page renders"
+	case opts.DetectBaseline:
+		return "-detect-baseline writes split images"
+	case opts.Marks:
+		return "-marks writes a mark report"
+	case opts.Encode != "":
+		return "-encode leaves no output files to store"
+	case opts.Dedup == DedupSkip:
+		return "-dedup skip removes outputs that depend on the rest of the run"
+	}
+	return ""
+}
+
+// cacheKey is the hex SHA-256 of the document at path and of the options that
+// shape its outputs, named with outPrefix. Where and how fast the work is done
+// (the output directory, callbacks, concurrency, time limits) and the password
+// don't change what it gives, so they are left out; the hooks and the cache
+// itself are tagged out of the encoding.
+func (e *Extractor) cacheKey(path, outPrefix string) (string, error) {
+	opts := e.opts
+	opts.OutputDir, opts.Password = "", ""
+	opts.Workers, opts.PipelineDepth, opts.DocumentTimeout = 0, 0, 0
+	settings, err := json.Marshal(struct {
+		Version string
+		Prefix  string
+		Options Options
+	}{cacheVersion, outPrefix, opts})
+	if err != nil {
+		return "", fmt.Errorf("failed to encode options: %v", err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	h.Write(settings)
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// cachedDocument is extractDocument behind Options.Cache: a document seen
+// before with the same options has its outputs restored from the cache, and
+// one that isn't is processed and stored. The cache only ever saves work; when
+// it fails, the document is processed as if there were none.
+func (e *Extractor) cachedDocument(ctx context.Context, pdfPath, outPrefix string, stats *documentStats) ([]*Result, error) {
+	cache := e.opts.Cache
+	if cache == nil {
+		return e.extractDocument(ctx, pdfPath, outPrefix, stats)
+	}
+	if reason := e.uncacheable(); reason != "" {
+		e.logf("Not caching: %s", reason)
+		return e.extractDocument(ctx, pdfPath, outPrefix, stats)
+	}
+	key, err := e.cacheKey(pdfPath, outPrefix)
+	if err != nil {
+		e.warnf("Not caching: %v", err)
+		return e.extractDocument(ctx, pdfPath, outPrefix, stats)
+	}
+
+	data, ok, err := cache.Get(ctx, key)
+	if err != nil {
+		e.warnf("Cache lookup failed: %v", err)
+	}
+	if ok {
+		results, err := e.restoreEntry(pdfPath, data, stats)
+		if err == nil || errors.Is(err, ErrNoSignature) {
+			e.logf("Restored %s from the cache (%d results)", pdfPath, len(results))
+			if err != nil {
+				return nil, err
+			}
+			for _, res := range results {
+				if err := e.deliver(ctx, res); err != nil {
+					return nil, err
+				}
+			}
+			return results, nil
+		}
+		e.warnf("Ignoring cache entry %s: %v", key, err)
+	}
+
+	results, err := e.extractDocument(ctx, pdfPath, outPrefix, stats)
+	entry := cacheEntry{Pages: stats.pages}
+	switch {
+	case errors.Is(err, ErrNoSignature):
+		entry.NoSignature = true
+	case err != nil:
+		// Failures may be transient, so they are tried again next time
+		return nil, err
+	default:
+		if entry.Results, err = e.cachedResults(results); err != nil {
+			e.warnf("Not caching: %v", err)
+			return results, nil
+		}
+	}
+	if data, err := json.Marshal(entry); err != nil {
+		e.warnf("Not caching: failed to encode cache entry: %v", err)
+	} else if err := cache.Put(ctx, key, data); err != nil {
+		e.warnf("Cache store failed: %v", err)
+	}
+	if entry.NoSignature {
+		return nil, err
+	}
+	return results, nil
+}
+
+// cachedResults reads back the outputs of results for a cacheEntry.
+func (e *Extractor) cachedResults(results []*Result) ([]cachedResult, error) {
+	cached := make([]cachedResult, len(results))
+	for i, res := range results {
+		rel, err := filepath.Rel(outputDir(e.opts.OutputDir), res.OutputPath)
+		if err != nil || strings.HasPrefix(rel, "..") {
+			return nil, fmt.Errorf("output %s lies outside the output directory", res.OutputPath)
+		}
+		output, err := os.ReadFile(res.OutputPath)
+		if err != nil {
+			return nil, err
+		}
+		c := *res
+		c.OutputPath, c.MetadataPath, c.PagePath, c.Source, c.Image = rel, "", "", "", nil
+		// Duplicates depend on the rest of the run, so are found again on restore
+		c.DuplicateOf = ""
+		cached[i] = cachedResult{Result: &c, Output: output}
+		if res.Image != nil {
+			var buf bytes.Buffer
+			if err := png.Encode(&buf, res.Image); err != nil {
+				return nil, fmt.Errorf("failed to encode PNG: %v", err)
+			}
+			cached[i].Image = buf.Bytes()
+		}
+	}
+	return cached, nil
+}
+
+// restoreEntry writes the outputs of a cacheEntry into Options.OutputDir as if
+// the document at pdfPath had just been processed, and returns their results,
+// which are yet to be delivered. An entry of a document without signatures
+// fails with ErrNoSignature. The entry's page count goes into stats.
+func (e *Extractor) restoreEntry(pdfPath string, data []byte, stats *documentStats) ([]*Result, error) {
+	var entry cacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, fmt.Errorf("failed to decode cache entry: %v", err)
+	}
+	stats.pages = entry.Pages
+	if entry.NoSignature {
+		return nil, fmt.Errorf("failed to extract signature: %w", ErrNoSignature)
+	}
+	results := make([]*Result, len(entry.Results))
+	for i, c := range entry.Results {
+		if c.Result == nil || !filepath.IsLocal(c.Result.OutputPath) {
+			return nil, errors.New("malformed cache entry")
+		}
+		res := c.Result
+		res.Source = pdfPath
+		res.OutputPath = filepath.Join(e.opts.OutputDir, res.OutputPath)
+		if c.Image != nil {
+			img, err := png.Decode(bytes.NewReader(c.Image))
+			if err != nil {
+				return nil, fmt.Errorf("failed to decode PNG: %v", err)
+			}
+			res.Image = image.NewRGBA(img.Bounds())
+			draw.Draw(res.Image, img.Bounds(), img, img.Bounds().Min, draw.Src)
+		}
+		if err := os.MkdirAll(filepath.Dir(res.OutputPath), 0o755); err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(res.OutputPath, c.Output, 0o644); err != nil {
+			return nil, fmt.Errorf("failed to write output: %v", err)
+		}
+		results[i] = res
+	}
+	return results, nil
+}
+
+// outputDir is dir, or "." for the current directory when it is empty.
+func outputDir(dir string) string {
+	if dir == "" {
+		return "."
+	}
+	return dir
+}
@@ -0,0 +1,75 @@
+package signature
+
+import (
+	"image"
+	"sort"
+
+	"gocv.io/x/gocv"
+)
+
+// Shape filters rejecting candidate regions that look like printed text or solid
+// graphics. Pixel values are at thresholdReferenceDPI.
+const (
+	// minCandidateAspect and maxCandidateAspect bound width/height: signatures are
+	// wider than tall, while rules, table borders and single text lines are far
+	// longer and vertical rules far narrower.
+	minCandidateAspect = 1.0 / 3
+	maxCandidateAspect = 15
+	// maxCandidateDensity is the largest fraction of the bounding box a signature
+	// inks; logos, stamps and filled table headers are solid.
+	maxCandidateDensity = 0.45
+	// maxStrokeHalfWidth is the thickest pen stroke accepted, as the mean distance
+	// from the stroke centre to its edge (about 1.7mm wide).
+	maxStrokeHalfWidth = 5
+	// printedStrokeVariation and printedMinDensity describe printed text: strokes of
+	// near-constant width packed densely, unlike handwriting or a sparse typed name.
+	printedStrokeVariation = 0.12
+	printedMinDensity      = 0.2
+)
+
+// plausibleCandidates keeps the rects (regions of the ink mask bin) whose shape,
+// ink density and stroke widths could be a signature, largest first. With
+// firstOnly it stops at the first one that passes, which is all that ranking by
+// size needs and saves measuring the rest.
+func plausibleCandidates(bin gocv.Mat, rects []image.Rectangle, dpi float64, firstOnly bool) []image.Rectangle {
+	sorted := append([]image.Rectangle(nil), rects...)
+	sort.SliceStable(sorted, func(i, j int) bool { return rectArea(sorted[i]) > rectArea(sorted[j]) })
+
+	var kept []image.Rectangle
+	for _, rect := range sorted {
+		if !plausibleSignature(bin, rect, dpi) {
+			continue
+		}
+		kept = append(kept, rect)
+		if firstOnly {
+			break
+		}
+	}
+	return kept
+}
+
+// plausibleSignature applies the shape filters to one candidate region of bin.
+// The cheap checks run first so most text fragments never reach the distance transform.
+func plausibleSignature(bin gocv.Mat, rect image.Rectangle, dpi float64) bool {
+	aspect := float64(rect.Dx()) / float64(rect.Dy())
+	if aspect < minCandidateAspect || aspect > maxCandidateAspect {
+		return false
+	}
+
+	region := bin.Region(rect)
+	defer region.Close()
+	density := float64(gocv.CountNonZero(region)) / float64(rectArea(rect))
+	if density > maxCandidateDensity {
+		return false
+	}
+
+	mean, variation, ok := strokeWidthStats(region)
+	if !ok {
+		// Too little ink to judge the strokes; the size filter already passed
+		return true
+	}
+	if mean > float64(scaleLength(maxStrokeHalfWidth, dpi)) {
+		return false
+	}
+	return !(variation < printedStrokeVariation && density > printedMinDensity)
+}
@@ -0,0 +1,121 @@
+package signature
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+	"strconv"
+	"strings"
+
+	xdraw "golang.org/x/image/draw"
+)
+
+// Supported values for Options.CanvasAlign: where the signature sits on the
+// canvas. Compounds such as "bottom-left" combine a vertical and a horizontal
+// edge; a single edge is centred along the other axis.
+const (
+	AlignCenter = "center"
+	AlignLeft   = "left"
+	AlignRight  = "right"
+	AlignTop    = "top"
+	AlignBottom = "bottom"
+)
+
+// ParseCanvasSize parses a "WIDTHxHEIGHT" pixel size, e.g. "600x200".
+func ParseCanvasSize(s string) (image.Point, error) {
+	w, h, ok := strings.Cut(strings.ToLower(strings.TrimSpace(s)), "x")
+	if !ok {
+		return image.Point{}, fmt.Errorf("want WIDTHxHEIGHT, got %q", s)
+	}
+	width, errW := strconv.Atoi(w)
+	height, errH := strconv.Atoi(h)
+	if errW != nil || errH != nil || width <= 0 || height <= 0 {
+		return image.Point{}, fmt.Errorf("want positive WIDTHxHEIGHT pixels, got %q", s)
+	}
+	return image.Pt(width, height), nil
+}
+
+// ValidCanvasAlign reports whether align is "" (centred) or a supported alignment.
+func ValidCanvasAlign(align string) bool {
+	_, _, ok := canvasAnchor(align)
+	return ok
+}
+
+// canvasAnchor maps an alignment to the fraction of the free space left of
+// and above the signature: 0 hugs the left or top edge, 1 the right or bottom.
+func canvasAnchor(align string) (fx, fy float64, ok bool) {
+	fx, fy = 0.5, 0.5
+	if align == "" || align == AlignCenter {
+		return fx, fy, true
+	}
+	vertical, horizontal, compound := strings.Cut(align, "-")
+	if !compound {
+		// A lone edge: decide which axis it belongs to
+		vertical, horizontal = align, ""
+		if align == AlignLeft || align == AlignRight {
+			vertical, horizontal = "", align
+		}
+	}
+	switch vertical {
+	case AlignTop:
+		fy = 0
+	case AlignBottom:
+		fy = 1
+	case "":
+	default:
+		return 0, 0, false
+	}
+	switch horizontal {
+	case AlignLeft:
+		fx = 0
+	case AlignRight:
+		fx = 1
+	case "":
+	default:
+		return 0, 0, false
+	}
+	return fx, fy, !compound || (vertical != "" && horizontal != "")
+}
+
+// trimTransparent returns img cut to the bounding box of its visible pixels,
+// dropping the paper margin and crop padding. An image without any visible
+// pixel is returned unchanged.
+func trimTransparent(img *image.RGBA) *image.RGBA {
+	b := img.Bounds()
+	minX, minY, maxX, maxY := b.Max.X, b.Max.Y, b.Min.X, b.Min.Y
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		row := img.Pix[img.PixOffset(b.Min.X, y):]
+		for x := 0; x < b.Dx(); x++ {
+			if row[x*4+3] != 0 {
+				minX, maxX = min(minX, b.Min.X+x), max(maxX, b.Min.X+x+1)
+				minY, maxY = min(minY, y), max(maxY, y+1)
+			}
+		}
+	}
+	tight := image.Rect(minX, minY, maxX, maxY)
+	if minX >= maxX || tight == b {
+		return img
+	}
+	trimmed := image.NewRGBA(image.Rect(0, 0, tight.Dx(), tight.Dy()))
+	draw.Draw(trimmed, trimmed.Bounds(), img, tight.Min, draw.Src)
+	return trimmed
+}
+
+// placeOnCanvas scales img to fit inside a transparent canvas of size, with
+// padding pixels kept free on every side, preserving its aspect ratio, and
+// positions it by align (see canvasAnchor).
+func placeOnCanvas(img *image.RGBA, size image.Point, padding int, align string) *image.RGBA {
+	canvas := image.NewRGBA(image.Rectangle{Max: size})
+	avail := size.Sub(image.Pt(2*padding, 2*padding))
+	b := img.Bounds()
+	if avail.X <= 0 || avail.Y <= 0 || b.Empty() {
+		return canvas
+	}
+
+	s := min(float64(avail.X)/float64(b.Dx()), float64(avail.Y)/float64(b.Dy()))
+	fit := image.Pt(max(1, int(float64(b.Dx())*s)), max(1, int(float64(b.Dy())*s)))
+	fx, fy, _ := canvasAnchor(align)
+	at := image.Pt(padding+int(float64(avail.X-fit.X)*fx), padding+int(float64(avail.Y-fit.Y)*fy))
+	xdraw.CatmullRom.Scale(canvas, image.Rectangle{Min: at, Max: at.Add(fit)}, img, b, xdraw.Src, nil)
+	return canvas
+}
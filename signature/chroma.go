@@ -0,0 +1,129 @@
+package signature
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+	"sort"
+
+	"gocv.io/x/gocv"
+)
+
+// Chroma-key tolerances. Paper varies a little in hue and saturation across a scan;
+// ink is much darker than the paper, which is what keeps it out of the key.
+const (
+	chromaHueTolerance   = 15.0 // degrees
+	chromaSatRatio       = 0.5  // pixel saturation must be at least this fraction of the key's
+	chromaValueTolerance = 0.25 // how much darker than the key a background pixel may be
+	chromaMinSaturation  = 0.05 // below this the key is effectively gray and has no hue
+	chromaCornerPatch    = 16   // side of the square sampled at each page corner, in pixels
+)
+
+// chromaKey is a background color in HSV: H in degrees, S and V in [0, 1].
+type chromaKey struct {
+	H, S, V float64
+}
+
+// ValidateChromaKey checks an Options.ChromaKey setting: "", "auto" or a hex color.
+func ValidateChromaKey(setting string) error {
+	if setting == "" || setting == "auto" {
+		return nil
+	}
+	_, err := parseChromaKeyColor(setting)
+	return err
+}
+
+// parseChromaKeyColor parses a hex color such as "#d8ecd0" or "d8ecd0".
+func parseChromaKeyColor(s string) (chromaKey, error) {
+	c, ok := parseHexColor(s)
+	if !ok {
+		return chromaKey{}, fmt.Errorf("invalid color %q: want auto or #RRGGBB", s)
+	}
+	return newChromaKey(c), nil
+}
+
+func newChromaKey(c color.RGBA) chromaKey {
+	h, s, v := rgbToHSV(c.R, c.G, c.B)
+	return chromaKey{H: h, S: s, V: v}
+}
+
+// sampleChromaKey estimates the paper color from the median of patches at the four
+// corners of the page, which are almost always blank.
+func sampleChromaKey(page gocv.Mat) chromaKey {
+	size := min(chromaCornerPatch, page.Cols()/4, page.Rows()/4)
+	size = max(size, 1)
+	var rs, gs, bs []int
+	for _, corner := range [][2]int{
+		{0, 0}, {page.Cols() - size, 0},
+		{0, page.Rows() - size}, {page.Cols() - size, page.Rows() - size},
+	} {
+		for y := corner[1]; y < corner[1]+size; y++ {
+			for x := corner[0]; x < corner[0]+size; x++ {
+				v := page.GetVecbAt(y, x)
+				bs = append(bs, int(v[0]))
+				gs = append(gs, int(v[1]))
+				rs = append(rs, int(v[2]))
+			}
+		}
+	}
+	return newChromaKey(color.RGBA{R: median(rs), G: median(gs), B: median(bs), A: 255})
+}
+
+func median(vals []int) uint8 {
+	sort.Ints(vals)
+	return uint8(vals[len(vals)/2])
+}
+
+// matches reports whether a pixel is the keyed background rather than ink.
+func (k chromaKey) matches(r, g, b uint8) bool {
+	h, s, v := rgbToHSV(r, g, b)
+	dh := math.Abs(h - k.H)
+	dh = math.Min(dh, 360-dh)
+	return dh <= chromaHueTolerance && s >= k.S*chromaSatRatio && v >= k.V-chromaValueTolerance
+}
+
+// whiten replaces every keyed pixel of a BGR Mat with white in place, so the
+// grayscale threshold and removeWhiteBackground treat the tinted paper like white paper.
+// A key without a meaningful hue (gray or white paper) is left to the normal path.
+func (k chromaKey) whiten(img *gocv.Mat) {
+	if k.S < chromaMinSaturation {
+		return
+	}
+	for y := 0; y < img.Rows(); y++ {
+		for x := 0; x < img.Cols(); x++ {
+			v := img.GetVecbAt(y, x)
+			if k.matches(v[2], v[1], v[0]) {
+				for ch := 0; ch < 3; ch++ {
+					img.SetUCharAt(y, x*3+ch, 255)
+				}
+			}
+		}
+	}
+}
+
+// rgbToHSV converts 8-bit RGB to hue in degrees and saturation/value in [0, 1].
+func rgbToHSV(r8, g8, b8 uint8) (h, s, v float64) {
+	r, g, b := float64(r8)/255, float64(g8)/255, float64(b8)/255
+	hi := math.Max(r, math.Max(g, b))
+	lo := math.Min(r, math.Min(g, b))
+	d := hi - lo
+
+	v = hi
+	if hi > 0 {
+		s = d / hi
+	}
+	switch {
+	case d == 0:
+		h = 0
+	case hi == r:
+		h = 60 * math.Mod((g-b)/d, 6)
+	case hi == g:
+		h = 60 * ((b-r)/d + 2)
+	default:
+		h = 60 * ((r-g)/d + 4)
+	}
+	if h < 0 {
+		h += 360
+	}
+	return h, s, v
+}
@@ -0,0 +1,75 @@
+package signature
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+)
+
+// safetyGreen is the pale green of security paper.
+var safetyGreen = color.RGBA{R: 190, G: 225, B: 180, A: 255}
+
+// safetyPaperPage returns a letter page at fixtureDPI printed on green safety
+// paper, its faint wavy pattern and all, with one signature in its lower half.
+func safetyPaperPage() *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, 850, 1100))
+	for y := range 1100 {
+		for x := range 850 {
+			d := 6 * math.Sin(float64(x)/9+4*math.Sin(float64(y)/23))
+			img.SetRGBA(x, y, color.RGBA{
+				R: uint8(float64(safetyGreen.R) + d),
+				G: uint8(float64(safetyGreen.G) + d),
+				B: uint8(float64(safetyGreen.B) + d),
+				A: 255,
+			})
+		}
+	}
+	drawSignature(img, 300, 850, 0, 1, blueInk)
+	return img
+}
+
+func TestChromaKeyMatchesPaperNotInk(t *testing.T) {
+	key := newChromaKey(safetyGreen)
+	for _, c := range []color.RGBA{safetyGreen, {R: 184, G: 219, B: 174}, {R: 196, G: 231, B: 186}} {
+		if !key.matches(c.R, c.G, c.B) {
+			t.Errorf("paper %v not keyed", c)
+		}
+	}
+	for _, c := range []color.RGBA{blueInk, {R: 10, G: 10, B: 10}, {R: 40, G: 90, B: 40}, {R: 255, G: 255, B: 255}} {
+		if key.matches(c.R, c.G, c.B) {
+			t.Errorf("ink %v keyed as paper", c)
+		}
+	}
+}
+
+func TestChromaKeyOnSafetyPaper(t *testing.T) {
+	path := writeFixture(t, t.TempDir(), "safety.png", safetyPaperPage())
+	for _, setting := range []string{"auto", "#bee1b4"} {
+		opts := fixtureOptions(t)
+		opts.ChromaKey = setting
+		results := extractFixture(t, opts, path)
+		if len(results) != 1 {
+			t.Fatalf("chroma key %s: got %d results, want 1", setting, len(results))
+		}
+		img := results[0].Image
+		var opaque, ink, total int
+		b := img.Bounds()
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			for x := b.Min.X; x < b.Max.X; x++ {
+				c := img.RGBAAt(x, y)
+				total++
+				if c.A > 0 {
+					opaque++
+				}
+				if isInk(c) {
+					ink++
+				}
+			}
+		}
+		// The stroke covers well under a fifth of its box; the paper must be gone
+		if ink == 0 || opaque > total/5 || opaque > 2*ink {
+			t.Errorf("chroma key %s: %d of %d pixels opaque, %d of them ink; want only the ink left", setting, opaque, total, ink)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package signature
+
+import (
+	"image"
+
+	"gocv.io/x/gocv"
+)
+
+// colorInkMinChroma is the Lab chroma (the distance of a and b from neutral,
+// on OpenCV's 8-bit scale) from which a pixel is colored ink rather than
+// paper, however light it is. White and gray paper stay under 10 and cream
+// paper under 15, while even faint ballpoint blue and purple exceed 25.
+const colorInkMinChroma = 20
+
+// chromaMask returns a mask, owned by the caller, that is 255 where the BGR
+// image img is strongly colored (see colorInkMinChroma) and 0 elsewhere.
+func chromaMask(img gocv.Mat) (gocv.Mat, error) {
+	lab := gocv.NewMat()
+	defer lab.Close()
+	gocv.CvtColor(img, &lab, gocv.ColorBGRToLab)
+	data := lab.ToBytes()
+	mask := make([]byte, img.Rows()*img.Cols())
+	for i := range mask {
+		da, db := int(data[3*i+1])-128, int(data[3*i+2])-128
+		if da*da+db*db >= colorInkMinChroma*colorInkMinChroma {
+			mask[i] = 255
+		}
+	}
+	return gocv.NewMatFromBytes(img.Rows(), img.Cols(), gocv.MatTypeCV8U, mask)
+}
+
+// addColorInk marks the strongly colored pixels of the BGR image img as ink
+// in bin, its binarized mask, so colored strokes lighter than the gray cutoff
+// are kept.
+func addColorInk(img gocv.Mat, bin *gocv.Mat) error {
+	mask, err := chromaMask(img)
+	if err != nil {
+		return err
+	}
+	defer mask.Close()
+	gocv.BitwiseOr(*bin, mask, bin)
+	return nil
+}
+
+// keepColorInk makes the strongly colored pixels of crop that removing the
+// background left (partly) transparent in out opaque again, in their scanned
+// color; out is the background-free image of crop.
+func keepColorInk(crop gocv.Mat, out *image.RGBA) error {
+	mask, err := chromaMask(crop)
+	if err != nil {
+		return err
+	}
+	defer mask.Close()
+	colored := mask.ToBytes()
+	data, err := bgrPixels(crop)
+	if err != nil {
+		return err
+	}
+	for i, j := 0, 0; i < len(colored); i, j = i+1, j+4 {
+		if colored[i] != 0 && out.Pix[j+3] != 255 {
+			out.Pix[j], out.Pix[j+1], out.Pix[j+2], out.Pix[j+3] = data[3*i+2], data[3*i+1], data[3*i], 255
+		}
+	}
+	return nil
+}
@@ -0,0 +1,202 @@
+package signature
+
+import (
+	"errors"
+	"image"
+	"math"
+
+	"gocv.io/x/gocv"
+	xdraw "golang.org/x/image/draw"
+)
+
+// DefaultMatchThreshold is the Similarity.Score below which two signatures are
+// flagged as a mismatch. It is a starting point; calibrate it on known pairs.
+const DefaultMatchThreshold = 0.5
+
+// Comparison parameters.
+const (
+	// compareWidth and compareHeight are the canvas both signatures are scaled
+	// onto, centred and keeping their aspect, so they compare at the same size.
+	compareWidth  = 384
+	compareHeight = 128
+	// hogCell is the side of a gradient histogram cell and hogBins the number of
+	// unsigned orientation bins per cell.
+	hogCell = 16
+	hogBins = 9
+	// orbRatio is Lowe's ratio test: a keypoint match counts only when it is
+	// clearly better than the second best candidate.
+	orbRatio = 0.75
+)
+
+// ErrNoInk reports a signature image without any ink to compare.
+var ErrNoInk = errors.New("image has no ink")
+
+// Similarity scores how alike two signatures are, each in [0, 1].
+type Similarity struct {
+	// Score is the mean of Keypoints and Shape.
+	Score float64 `json:"score"`
+	// Keypoints is the share of ORB keypoints, in both directions, that have a
+	// distinctive match in the other signature.
+	Keypoints float64 `json:"keypoints"`
+	// Shape is the cosine similarity of histograms of oriented gradients over
+	// the normalized signatures.
+	Shape float64 `json:"shape"`
+}
+
+// CompareSignatures compares two signature images, such as Result.Image or a
+// reference PNG on file. Both are composited over white, cropped to their ink
+// and scaled onto the same canvas first, so position, size and transparency
+// don't matter.
+func CompareSignatures(a, b image.Image) (Similarity, error) {
+	na, err := normalizeForCompare(a)
+	if err != nil {
+		return Similarity{}, err
+	}
+	nb, err := normalizeForCompare(b)
+	if err != nil {
+		return Similarity{}, err
+	}
+
+	keypoints, err := keypointSimilarity(na, nb)
+	if err != nil {
+		return Similarity{}, err
+	}
+	shape := cosineSimilarity(hogDescriptor(na), hogDescriptor(nb))
+	return Similarity{Score: (keypoints + shape) / 2, Keypoints: keypoints, Shape: shape}, nil
+}
+
+// normalizeForCompare composites img over white, crops it to the ink (pixels
+// darker than inkThreshold) and scales that onto a white compareWidth x
+// compareHeight canvas, centred.
+func normalizeForCompare(img image.Image) (*image.Gray, error) {
+	b := img.Bounds()
+	gray := image.NewGray(b)
+	ink := image.Rectangle{}
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			// RGBA is premultiplied, so adding the missing coverage blends with white
+			r, g, bl, a := img.At(x, y).RGBA()
+			white := 0xffff - a
+			lum := (299*(r+white) + 587*(g+white) + 114*(bl+white)) / 1000 >> 8
+			gray.Pix[gray.PixOffset(x, y)] = uint8(lum)
+			if lum < inkThreshold {
+				ink = ink.Union(image.Rect(x, y, x+1, y+1))
+			}
+		}
+	}
+	if ink.Empty() {
+		return nil, ErrNoInk
+	}
+
+	canvas := image.NewGray(image.Rect(0, 0, compareWidth, compareHeight))
+	for i := range canvas.Pix {
+		canvas.Pix[i] = 255
+	}
+	scale := math.Min(float64(compareWidth)/float64(ink.Dx()), float64(compareHeight)/float64(ink.Dy()))
+	w, h := int(math.Round(float64(ink.Dx())*scale)), int(math.Round(float64(ink.Dy())*scale))
+	dst := image.Rect(0, 0, max(w, 1), max(h, 1)).Add(image.Pt((compareWidth-w)/2, (compareHeight-h)/2))
+	xdraw.CatmullRom.Scale(canvas, dst, gray, ink, xdraw.Src, nil)
+	return canvas, nil
+}
+
+// keypointSimilarity matches ORB descriptors of a and b both ways and returns
+// the share of keypoints with a match passing the ratio test.
+func keypointSimilarity(a, b *image.Gray) (float64, error) {
+	ma, err := gocv.ImageGrayToMatGray(a)
+	if err != nil {
+		return 0, err
+	}
+	defer ma.Close()
+	mb, err := gocv.ImageGrayToMatGray(b)
+	if err != nil {
+		return 0, err
+	}
+	defer mb.Close()
+
+	orb := gocv.NewORB()
+	defer orb.Close()
+	noMask := gocv.NewMat()
+	defer noMask.Close()
+	kpA, descA := orb.DetectAndCompute(ma, noMask)
+	defer descA.Close()
+	kpB, descB := orb.DetectAndCompute(mb, noMask)
+	defer descB.Close()
+	if descA.Empty() || descB.Empty() {
+		// Too few corners to describe, e.g. a single straight stroke
+		return 0, nil
+	}
+
+	matcher := gocv.NewBFMatcherWithParams(gocv.NormHamming, false)
+	defer matcher.Close()
+	good := distinctiveMatches(matcher.KnnMatch(descA, descB, 2)) +
+		distinctiveMatches(matcher.KnnMatch(descB, descA, 2))
+	return float64(good) / float64(len(kpA)+len(kpB)), nil
+}
+
+// distinctiveMatches counts the k=2 nearest-neighbour matches passing the ratio test.
+func distinctiveMatches(matches [][]gocv.DMatch) int {
+	n := 0
+	for _, m := range matches {
+		if len(m) == 1 || (len(m) == 2 && m[0].Distance < orbRatio*m[1].Distance) {
+			n++
+		}
+	}
+	return n
+}
+
+// hogDescriptor returns a histogram of oriented gradients of img: per hogCell
+// cell, gradient magnitude summed into hogBins unsigned orientation bins, each
+// cell L2-normalized so faint and heavy pens weigh the same.
+func hogDescriptor(img *image.Gray) []float64 {
+	w, h := img.Rect.Dx(), img.Rect.Dy()
+	cols, rows := w/hogCell, h/hogCell
+	desc := make([]float64, cols*rows*hogBins)
+	at := func(x, y int) float64 {
+		x, y = min(max(x, 0), w-1), min(max(y, 0), h-1)
+		return float64(img.Pix[y*img.Stride+x])
+	}
+	for y := 0; y < rows*hogCell; y++ {
+		for x := 0; x < cols*hogCell; x++ {
+			gx := at(x+1, y) - at(x-1, y)
+			gy := at(x, y+1) - at(x, y-1)
+			mag := math.Hypot(gx, gy)
+			if mag == 0 {
+				continue
+			}
+			angle := math.Atan2(gy, gx)
+			if angle < 0 {
+				angle += math.Pi
+			}
+			bin := min(int(angle/math.Pi*hogBins), hogBins-1)
+			desc[((y/hogCell)*cols+x/hogCell)*hogBins+bin] += mag
+		}
+	}
+	for c := 0; c < len(desc); c += hogBins {
+		cell := desc[c : c+hogBins]
+		var sum float64
+		for _, v := range cell {
+			sum += v * v
+		}
+		if sum > 0 {
+			norm := math.Sqrt(sum)
+			for i := range cell {
+				cell[i] /= norm
+			}
+		}
+	}
+	return desc
+}
+
+// cosineSimilarity is the cosine of the angle between a and b, 0 when either is zero.
+func cosineSimilarity(a, b []float64) float64 {
+	var dot, na, nb float64
+	for i := range a {
+		dot += a[i] * b[i]
+		na += a[i] * a[i]
+		nb += b[i] * b[i]
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / math.Sqrt(na*nb)
+}
@@ -0,0 +1,126 @@
+package signature
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"strconv"
+	"strings"
+
+	"gocv.io/x/gocv"
+)
+
+// Default ConfidenceBuckets cut-offs.
+const (
+	DefaultHighConfidence   = 0.75
+	DefaultMediumConfidence = 0.5
+)
+
+// Weights of the ConfidenceFactors in the overall confidence; they sum to 1.
+const (
+	densityWeight         = 0.3
+	strokeVariationWeight = 0.3
+	aspectWeight          = 0.25
+	positionWeight        = 0.15
+)
+
+// ConfidenceFactors are the sub-scores, each in [0, 1], a region's confidence
+// is the weighted mean of, so callers can apply their own thresholds to them.
+type ConfidenceFactors struct {
+	// Density scores the share of the box that is ink: handwriting is sparse
+	// (a few percent to a third), solid logos and paragraphs are dense.
+	Density float64 `json:"density"`
+	// StrokeVariation scores how much the stroke width varies: pens vary,
+	// printed text hardly does. 0.5 when there is too little ink to measure.
+	StrokeVariation float64 `json:"stroke_variation"`
+	// Aspect scores width/height: signatures are wider than tall, but not the
+	// extreme widths of a text line or a rule.
+	Aspect float64 `json:"aspect"`
+	// Position scores where the region sits on the rendered page, rising from
+	// 0.5 at the top to 1 at the bottom, where documents are usually signed.
+	Position float64 `json:"position"`
+}
+
+// Score is the weighted mean of the factors.
+func (f ConfidenceFactors) Score() float64 {
+	return densityWeight*f.Density + strokeVariationWeight*f.StrokeVariation +
+		aspectWeight*f.Aspect + positionWeight*f.Position
+}
+
+// regionConfidence scores how signature-like the ink of bin (ink 255) inside
+// rect is; page is the size of the rendered page bin covers.
+func regionConfidence(bin gocv.Mat, rect image.Rectangle, page image.Point) ConfidenceFactors {
+	if rect.Empty() {
+		return ConfidenceFactors{}
+	}
+	region := bin.Region(rect)
+	defer region.Close()
+	density := float64(gocv.CountNonZero(region)) / float64(rectArea(rect))
+	aspect := float64(rect.Dx()) / float64(rect.Dy())
+
+	f := ConfidenceFactors{
+		Density:         rangeScore(density, 0, 0.03, 0.30, 0.60),
+		StrokeVariation: 0.5,
+		Aspect:          rangeScore(aspect, 0.5, 1.5, 8, 20),
+		Position:        0.5,
+	}
+	if _, variation, ok := strokeWidthStats(region); ok {
+		f.StrokeVariation = rangeScore(variation, 0, printedStrokeVariation*1.5, math.Inf(1), math.Inf(1))
+	}
+	if page.Y > 0 {
+		centre := float64(rect.Min.Y+rect.Max.Y) / 2 / float64(page.Y)
+		f.Position = 0.5 + 0.5*math.Min(math.Max(centre, 0), 1)
+	}
+	return f
+}
+
+// rangeScore is 1 for v in [lo, hi], falling linearly to 0 at zeroLo and zeroHi.
+func rangeScore(v, zeroLo, lo, hi, zeroHi float64) float64 {
+	switch {
+	case v <= zeroLo || v >= zeroHi:
+		return 0
+	case v < lo:
+		return (v - zeroLo) / (lo - zeroLo)
+	case v > hi:
+		return (zeroHi - v) / (zeroHi - hi)
+	default:
+		return 1
+	}
+}
+
+// ConfidenceBuckets are the lower bounds of the high and medium buckets.
+type ConfidenceBuckets struct {
+	High, Medium float64
+}
+
+// ParseConfidenceBuckets parses "high,medium", e.g. "0.75,0.5".
+func ParseConfidenceBuckets(s string) (ConfidenceBuckets, error) {
+	parts := strings.Split(s, ",")
+	if len(parts) != 2 {
+		return ConfidenceBuckets{}, fmt.Errorf("want high,medium thresholds, got %q", s)
+	}
+	var v [2]float64
+	for i, part := range parts {
+		var err error
+		if v[i], err = strconv.ParseFloat(strings.TrimSpace(part), 64); err != nil {
+			return ConfidenceBuckets{}, fmt.Errorf("invalid threshold %q: %v", part, err)
+		}
+	}
+	b := ConfidenceBuckets{High: v[0], Medium: v[1]}
+	if b.Medium < 0 || b.High > 1 || b.Medium > b.High {
+		return ConfidenceBuckets{}, fmt.Errorf("need 0 <= medium <= high <= 1, got %q", s)
+	}
+	return b, nil
+}
+
+// bucket names the folder a result with the given confidence belongs in.
+func (b ConfidenceBuckets) bucket(confidence float64) string {
+	switch {
+	case confidence >= b.High:
+		return "high"
+	case confidence >= b.Medium:
+		return "medium"
+	default:
+		return "low"
+	}
+}
@@ -0,0 +1,51 @@
+package signature
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestConfidenceBucket(t *testing.T) {
+	b, err := ParseConfidenceBuckets("0.75, 0.5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for confidence, want := range map[float64]string{1: "high", 0.75: "high", 0.74: "medium", 0.5: "medium", 0.49: "low", 0: "low"} {
+		if got := b.bucket(confidence); got != want {
+			t.Errorf("bucket(%g) = %s, want %s", confidence, got, want)
+		}
+	}
+	for _, s := range []string{"0.5", "0.5,0.75", "1.5,0.5", "0.5,-1", "high,low"} {
+		if _, err := ParseConfidenceBuckets(s); err == nil {
+			t.Errorf("ParseConfidenceBuckets(%q) accepted", s)
+		}
+	}
+}
+
+func TestConfidenceBucketDirectories(t *testing.T) {
+	page := signaturePage(t, t.TempDir())
+	// Thresholds around any score below 1 that send the one signature to each folder
+	for want, buckets := range map[string]ConfidenceBuckets{
+		"high":   {High: 0, Medium: 0},
+		"medium": {High: 1, Medium: 0},
+		"low":    {High: 1, Medium: 1},
+	} {
+		opts := fixtureOptions(t)
+		opts.Buckets = &buckets
+		results := extractFixture(t, opts, page)
+		if len(results) != 1 {
+			t.Fatalf("got %d results, want 1", len(results))
+		}
+		res := results[0]
+		if res.Confidence >= 1 {
+			t.Fatalf("confidence %g: the fixture needs a score below 1", res.Confidence)
+		}
+		if dir := filepath.Dir(res.OutputPath); dir != filepath.Join(opts.OutputDir, want) {
+			t.Errorf("confidence %.2f with %+v: output in %s, want the %s folder", res.Confidence, buckets, dir, want)
+		}
+		if _, err := os.Stat(res.OutputPath); err != nil {
+			t.Error(err)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package signature
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"path/filepath"
+
+	"gocv.io/x/gocv"
+)
+
+// Stages of the pipeline dumped by Options.DebugDir, in the order they run. The
+// number leads each file name so a directory listing follows the pipeline.
+const (
+	debugStagePage = iota + 1
+	debugStageGray
+	debugStageBinary
+	debugStageContours
+	debugStageCrop
+)
+
+// Colors of the boxes in the contours image.
+var (
+	debugAreaColor     = color.RGBA{0, 0, 255, 0} // anchor search areas
+	debugRejectedColor = color.RGBA{255, 0, 0, 0} // candidates not kept
+	debugSelectedColor = color.RGBA{0, 200, 0, 0} // regions extracted
+)
+
+// debugDump writes intermediate images of one page to Options.DebugDir. A nil
+// *debugDump writes nothing, so the pipeline calls it unconditionally.
+type debugDump struct {
+	dir    string
+	prefix string
+	logf   func(format string, args ...any)
+}
+
+// write saves img as {prefix}_{stage}_{name}.png, e.g. p2_03_binary.png.
+func (d *debugDump) write(stage int, name string, img gocv.Mat) error {
+	if d == nil {
+		return nil
+	}
+	path := filepath.Join(d.dir, outputName(d.prefix, fmt.Sprintf("%02d_%s.png", stage, name)))
+	if !gocv.IMWrite(path, img) {
+		return fmt.Errorf("unable to write debug image: %s", path)
+	}
+	d.logf("Debug image saved to %s", path)
+	return nil
+}
+
+// contours draws the candidate boxes that passed the size filter over a copy
+// of page: the anchor search areas in blue, rejected candidates in red and the
+// selected regions in green.
+func (d *debugDump) contours(page gocv.Mat, candidates, areas, selected []image.Rectangle) error {
+	if d == nil {
+		return nil
+	}
+	canvas := page.Clone()
+	defer canvas.Close()
+
+	thickness := max(1, page.Cols()/800)
+	for _, area := range areas {
+		gocv.Rectangle(&canvas, area, debugAreaColor, thickness)
+	}
+	for _, rect := range candidates {
+		gocv.Rectangle(&canvas, rect, debugRejectedColor, thickness)
+	}
+	for _, rect := range selected {
+		gocv.Rectangle(&canvas, rect, debugSelectedColor, 2*thickness)
+	}
+	return d.write(debugStageContours, "contours", canvas)
+}
@@ -0,0 +1,144 @@
+package signature
+
+import (
+	"image"
+	"image/color"
+	"math"
+
+	"gocv.io/x/gocv"
+)
+
+// minCoverage keeps unmixing stable for pixels that are almost pure background.
+const minCoverage = 0.05
+
+// estimateBackground averages the crop pixels that background removal treats as
+// paper (every channel above threshold), falling back to white.
+func estimateBackground(crop gocv.Mat, threshold uint8) color.RGBA {
+	var sum [3]float64
+	var n float64
+	for y := 0; y < crop.Rows(); y++ {
+		for x := 0; x < crop.Cols(); x++ {
+			v := crop.GetVecbAt(y, x)
+			if v[0] > threshold && v[1] > threshold && v[2] > threshold {
+				sum[0] += float64(v[2])
+				sum[1] += float64(v[1])
+				sum[2] += float64(v[0])
+				n++
+			}
+		}
+	}
+	if n == 0 {
+		return color.RGBA{R: 255, G: 255, B: 255, A: 255}
+	}
+	return color.RGBA{R: uint8(sum[0] / n), G: uint8(sum[1] / n), B: uint8(sum[2] / n), A: 255}
+}
+
+// decontaminateEdges removes the background's contribution from the colors of
+// edge pixels, the anti-aliased fringe that otherwise shows as a light halo over
+// dark backgrounds. Each edge pixel C is modelled as a mix of the ink color K and
+// the background B with coverage a; a is taken from partial alpha when present or
+// estimated by projecting C onto the B-K line, then the pixel is unmixed to
+// F = (C - (1-a)B) / a with alpha a.
+func decontaminateEdges(img *image.RGBA, bg color.RGBA) {
+	ink, ok := interiorInkColor(img)
+	if !ok {
+		return
+	}
+
+	b := img.Bounds()
+	B := [3]float64{float64(bg.R), float64(bg.G), float64(bg.B)}
+	K := [3]float64{float64(ink.R), float64(ink.G), float64(ink.B)}
+	var bk [3]float64
+	var bkLen2 float64
+	for i := range bk {
+		bk[i] = B[i] - K[i]
+		bkLen2 += bk[i] * bk[i]
+	}
+	if bkLen2 == 0 {
+		return
+	}
+
+	// Decide every pixel from the original image before modifying any of them
+	type fix struct {
+		x, y int
+		c    color.RGBA
+	}
+	var fixes []fix
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			c := img.RGBAAt(x, y)
+			if c.A == 0 || (c.A == 255 && !bordersTransparency(img, x, y)) {
+				continue
+			}
+
+			C := [3]float64{float64(c.R), float64(c.G), float64(c.B)}
+			a := float64(c.A) / 255
+			if c.A == 255 {
+				var dot float64
+				for i := range C {
+					dot += (B[i] - C[i]) * bk[i]
+				}
+				a = dot / bkLen2
+			}
+			a = math.Min(math.Max(a, minCoverage), 1)
+
+			var F [3]uint8
+			for i := range C {
+				F[i] = uint8(math.Round(math.Min(math.Max((C[i]-(1-a)*B[i])/a, 0), 255)))
+			}
+			fixes = append(fixes, fix{x, y, color.RGBA{R: F[0], G: F[1], B: F[2], A: uint8(math.Round(a * 255))}})
+		}
+	}
+	for _, f := range fixes {
+		img.SetRGBA(f.x, f.y, f.c)
+	}
+}
+
+// interiorInkColor averages opaque pixels that are fully surrounded by ink, which
+// are free of background contamination. It falls back to the darkest opaque pixel.
+func interiorInkColor(img *image.RGBA) (color.RGBA, bool) {
+	var sum [3]float64
+	var n float64
+	darkest, darkestLum, found := color.RGBA{}, math.MaxFloat64, false
+
+	b := img.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			c := img.RGBAAt(x, y)
+			if c.A != 255 {
+				continue
+			}
+			if lum := float64(c.R) + float64(c.G) + float64(c.B); lum < darkestLum {
+				darkest, darkestLum, found = c, lum, true
+			}
+			if bordersTransparency(img, x, y) {
+				continue
+			}
+			sum[0] += float64(c.R)
+			sum[1] += float64(c.G)
+			sum[2] += float64(c.B)
+			n++
+		}
+	}
+	if n == 0 {
+		return darkest, found
+	}
+	return color.RGBA{R: uint8(sum[0] / n), G: uint8(sum[1] / n), B: uint8(sum[2] / n), A: 255}, true
+}
+
+// bordersTransparency reports whether any 8-neighbour of (x, y) is not fully opaque.
+// Pixels on the image border count as bordering transparency.
+func bordersTransparency(img *image.RGBA, x, y int) bool {
+	for dy := -1; dy <= 1; dy++ {
+		for dx := -1; dx <= 1; dx++ {
+			if dx == 0 && dy == 0 {
+				continue
+			}
+			p := image.Pt(x+dx, y+dy)
+			if !p.In(img.Bounds()) || img.RGBAAt(p.X, p.Y).A != 255 {
+				return true
+			}
+		}
+	}
+	return false
+}
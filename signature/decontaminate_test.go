@@ -0,0 +1,48 @@
+package signature
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+// colorDistance is the squared RGB distance between a and b.
+func colorDistance(a, b color.RGBA) int {
+	dr, dg, db := int(a.R)-int(b.R), int(a.G)-int(b.G), int(a.B)-int(b.B)
+	return dr*dr + dg*dg + db*db
+}
+
+func TestDecontaminateEdgesShiftsTowardInk(t *testing.T) {
+	white := color.RGBA{R: 255, G: 255, B: 255, A: 255}
+	ink := color.RGBA{R: 20, G: 30, B: 110, A: 255}
+	// Half ink, half paper, as anti-aliasing leaves it
+	mixed := color.RGBA{R: 138, G: 143, B: 183, A: 255}
+	img := image.NewRGBA(image.Rect(0, 0, 12, 12))
+	for y := 2; y < 10; y++ {
+		for x := 2; x < 10; x++ {
+			img.SetRGBA(x, y, ink)
+		}
+	}
+	// A fringe with soft alpha on the left and an opaque one on the right
+	for y := 2; y < 10; y++ {
+		img.SetRGBA(1, y, color.RGBA{R: mixed.R, G: mixed.G, B: mixed.B, A: 128})
+		img.SetRGBA(10, y, mixed)
+	}
+
+	decontaminateEdges(img, white)
+	for _, x := range []int{1, 10} {
+		got := img.RGBAAt(x, 5)
+		if colorDistance(got, ink) >= colorDistance(mixed, ink)/4 {
+			t.Errorf("edge pixel (%d,5) = %v, want its color close to the ink %v", x, got, ink)
+		}
+		if got.A == 0 || got.A == 255 {
+			t.Errorf("edge pixel (%d,5) has alpha %d, want partial coverage", x, got.A)
+		}
+	}
+	if got := img.RGBAAt(5, 5); got != ink {
+		t.Errorf("interior pixel = %v, want the ink left alone", got)
+	}
+	if got := img.RGBAAt(0, 0); got.A != 0 {
+		t.Errorf("background pixel = %v, want it left transparent", got)
+	}
+}
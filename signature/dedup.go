@@ -0,0 +1,141 @@
+package signature
+
+import (
+	"fmt"
+	"image"
+	"math/bits"
+	"os"
+	"sync"
+)
+
+// Supported values for Options.Dedup.
+const (
+	// DedupOff keeps near-identical signatures as they are; each still has its
+	// Result.Hash. It is the default, also when Options.Dedup is "".
+	DedupOff = "off"
+	// DedupLink keeps a near-identical signature but records in
+	// Result.DuplicateOf the output it repeats.
+	DedupLink = "link"
+	// DedupSkip removes the output of a near-identical signature and drops its
+	// result, so no metadata or OnResult delivery is made for it.
+	DedupSkip = "skip"
+)
+
+// Deduplication parameters.
+const (
+	// DefaultDedupDistance is how many of the 64 bits of two ink hashes may
+	// differ for the signatures to count as the same (see Options.DedupDistance).
+	// Rescans of one page stay within a few bits; signatures of the same person
+	// on different pages usually differ by more than ten.
+	DefaultDedupDistance = 6
+	// maxDedupAspect is how much wider, relative to their height, one of two
+	// signatures may be for them to count as the same, since the hash is blind
+	// to proportions.
+	maxDedupAspect = 1.25
+)
+
+// ValidDedup reports whether mode is a known Options.Dedup value.
+func ValidDedup(mode string) bool {
+	switch mode {
+	case "", DedupOff, DedupLink, DedupSkip:
+		return true
+	}
+	return false
+}
+
+// InkHash is the perceptual hash of a transparent signature in 16 hex digits:
+// the difference hash (as for Fingerprint.HeaderHash) of its ink drawn dark on
+// white. Rescans of one signature hash within a few bits of each other however
+// they were cropped or scaled, so it matches repeats that a checksum can't.
+func InkHash(img *image.RGBA) string {
+	return fmt.Sprintf("%016x", inkHash(img))
+}
+
+// inkHash is InkHash as a number.
+func inkHash(img *image.RGBA) uint64 {
+	b := img.Bounds()
+	ink := image.NewGray(b)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			ink.Pix[ink.PixOffset(x, y)] = 255 - img.Pix[img.PixOffset(x, y)+3]
+		}
+	}
+	return differenceHash(ink)
+}
+
+// dedupIndex holds the signatures delivered so far by an Extractor, which
+// later ones are compared against; a batch shares it across its documents.
+type dedupIndex struct {
+	mu   sync.Mutex
+	seen []dedupEntry
+}
+
+// dedupEntry is one signature of a dedupIndex.
+type dedupEntry struct {
+	hash   uint64
+	aspect float64
+	path   string
+}
+
+// firstOf returns the output of the first signature delivered whose hash is
+// within maxDistance bits of hash and whose proportions are close to
+// aspect's, or records the signature at path as a new one and returns "".
+func (d *dedupIndex) firstOf(hash uint64, aspect float64, maxDistance int, path string) string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, s := range d.seen {
+		ratio := max(s.aspect, aspect) / min(s.aspect, aspect)
+		if bits.OnesCount64(s.hash^hash) <= maxDistance && ratio <= maxDedupAspect {
+			return s.path
+		}
+	}
+	d.seen = append(d.seen, dedupEntry{hash: hash, aspect: aspect, path: path})
+	return ""
+}
+
+// dedup sets the Hash of res and, with Options.Dedup, its DuplicateOf when an
+// earlier signature of the run looks the same; with DedupSkip the output of a
+// duplicate is removed. It reports whether res is to be delivered. Seals and
+// marks are hashed but never deduplicated: empty boxes all look alike.
+func (e *Extractor) dedup(res *Result) (bool, error) {
+	if res.Image == nil || res.Image.Rect.Empty() {
+		return true, nil
+	}
+	hash := inkHash(res.Image)
+	res.Hash = fmt.Sprintf("%016x", hash)
+	if (e.opts.Dedup != DedupLink && e.opts.Dedup != DedupSkip) || e.seen == nil || res.Seal != 0 || res.Mark != "" {
+		return true, nil
+	}
+	maxDistance := e.opts.DedupDistance
+	if maxDistance == 0 {
+		maxDistance = DefaultDedupDistance
+	}
+	aspect := float64(res.Image.Rect.Dx()) / float64(res.Image.Rect.Dy())
+	res.DuplicateOf = e.seen.firstOf(hash, aspect, maxDistance, res.OutputPath)
+	if res.DuplicateOf == "" {
+		return true, nil
+	}
+	if e.opts.Dedup == DedupLink {
+		e.logf("%s looks like %s", res.OutputPath, res.DuplicateOf)
+		return true, nil
+	}
+	e.logf("Skipping %s: it looks like %s", res.OutputPath, res.DuplicateOf)
+	if err := os.Remove(res.OutputPath); err != nil {
+		return false, fmt.Errorf("failed to remove duplicate: %v", err)
+	}
+	return false, nil
+}
+
+// withoutDuplicates drops from results those DedupSkip didn't deliver.
+func (e *Extractor) withoutDuplicates(results []*Result) []*Result {
+	if e.opts.Dedup != DedupSkip {
+		return results
+	}
+	kept := results[:0]
+	for _, res := range results {
+		if res.DuplicateOf == "" {
+			kept = append(kept, res)
+		}
+	}
+	return kept
+}
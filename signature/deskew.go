@@ -0,0 +1,159 @@
+package signature
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"sort"
+
+	"gocv.io/x/gocv"
+)
+
+// Deskew parameters; lengths are in pixels at thresholdReferenceDPI.
+const (
+	// maxSkewDegrees is the largest tilt corrected; anything steeper is not a
+	// scanning error but a layout (or a 90° turn, which this does not handle).
+	maxSkewDegrees = 10
+	// minSkewDegrees is the smallest tilt worth resampling the page for.
+	minSkewDegrees = 0.1
+	// skewSmear closes the gaps between words so a text line becomes one blob.
+	skewSmear = 15
+	// minSkewLineWidth is the narrowest blob measured as a text line (about 17mm);
+	// minSkewLines of them are needed to trust the estimate.
+	minSkewLineWidth = 100
+	minSkewLines     = 3
+)
+
+// skewAngle estimates how far the page at imgPath is tilted, in degrees;
+// positive when lines run down to the right. Words are smeared into line blobs
+// and the median angle of their minimum-area rectangles is taken, so a few
+// diagonal strokes or a slanted signature don't sway it. ok is false when the
+// page has too few text lines to tell.
+func skewAngle(imgPath string, dpi float64) (angle float64, ok bool, err error) {
+	gray, err := readImage(imgPath, gocv.IMReadGrayScale)
+	if err != nil {
+		return 0, false, err
+	}
+	defer gray.Close()
+	bin := gocv.NewMat()
+	defer bin.Close()
+	gocv.Threshold(gray, &bin, inkThreshold, 255, gocv.ThresholdBinaryInv)
+
+	kernel := gocv.GetStructuringElement(gocv.MorphRect, image.Pt(scaleLength(skewSmear, dpi), 1))
+	defer kernel.Close()
+	gocv.MorphologyEx(bin, &bin, gocv.MorphClose, kernel)
+
+	contours := gocv.FindContours(bin, gocv.RetrievalExternal, gocv.ChainApproxSimple)
+	defer contours.Close()
+	minWidth := scaleLength(minSkewLineWidth, dpi)
+	var angles []float64
+	for i := 0; i < contours.Size(); i++ {
+		c := contours.At(i)
+		box := gocv.BoundingRect(c)
+		if box.Dx() < minWidth || box.Dx() < 5*box.Dy() {
+			continue
+		}
+		a := longEdgeAngle(gocv.MinAreaRect2f(c))
+		if math.Abs(a) <= maxSkewDegrees {
+			angles = append(angles, a)
+		}
+	}
+	if len(angles) < minSkewLines {
+		return 0, false, nil
+	}
+	sort.Float64s(angles)
+	return angles[len(angles)/2], true, nil
+}
+
+// longEdgeAngle returns the angle of the longer side of r against the x axis,
+// in (-90, 90] degrees, positive pointing down-right.
+func longEdgeAngle(r gocv.RotatedRect2f) float64 {
+	p := r.Points
+	if len(p) != 4 {
+		return 0
+	}
+	dx1, dy1 := float64(p[1].X-p[0].X), float64(p[1].Y-p[0].Y)
+	dx2, dy2 := float64(p[2].X-p[1].X), float64(p[2].Y-p[1].Y)
+	dx, dy := dx1, dy1
+	if math.Hypot(dx2, dy2) > math.Hypot(dx1, dy1) {
+		dx, dy = dx2, dy2
+	}
+	a := math.Atan2(dy, dx) * 180 / math.Pi
+	switch {
+	case a > 90:
+		a -= 180
+	case a <= -90:
+		a += 180
+	}
+	return a
+}
+
+// deskewImageFile rotates the image at path by angle degrees about its centre
+// (counter-clockwise as OpenCV defines it, which levels lines tilted down-right
+// by angle), keeping its size and filling the uncovered corners with white.
+func deskewImageFile(path string, angle float64) error {
+	img, err := readImage(path, gocv.IMReadUnchanged)
+	if err != nil {
+		return err
+	}
+	defer img.Close()
+
+	m := gocv.GetRotationMatrix2D(image.Pt(img.Cols()/2, img.Rows()/2), angle, 1)
+	defer m.Close()
+	rotated := gocv.NewMat()
+	defer rotated.Close()
+	white := color.RGBA{R: 255, G: 255, B: 255, A: 255}
+	gocv.WarpAffineWithParams(img, &rotated, m, image.Pt(img.Cols(), img.Rows()), gocv.InterpolationCubic, gocv.BorderConstant, white)
+	if !gocv.IMWrite(path, rotated) {
+		return fmt.Errorf("unable to write image: %s", path)
+	}
+	return nil
+}
+
+// unskewRect maps a rectangle of an image deskewed by angle (see
+// deskewImageFile) back onto the image as rendered: the bounding box of its
+// corners turned back about the centre, clipped to size.
+func unskewRect(r image.Rectangle, size image.Point, angle float64) image.Rectangle {
+	rad := angle * math.Pi / 180
+	cos, sin := math.Cos(rad), math.Sin(rad)
+	cx, cy := float64(size.X/2), float64(size.Y/2)
+
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	for _, p := range []image.Point{r.Min, {r.Max.X, r.Min.Y}, {r.Min.X, r.Max.Y}, r.Max} {
+		// Inverse of OpenCV's rotation: x = cos*x' - sin*y', y = sin*x' + cos*y'
+		dx, dy := float64(p.X)-cx, float64(p.Y)-cy
+		x, y := cx+cos*dx-sin*dy, cy+sin*dx+cos*dy
+		minX, maxX = math.Min(minX, x), math.Max(maxX, x)
+		minY, maxY = math.Min(minY, y), math.Max(maxY, y)
+	}
+	return image.Rect(int(math.Floor(minX)), int(math.Floor(minY)), int(math.Ceil(maxX)), int(math.Ceil(maxY))).
+		Intersect(image.Rectangle{Max: size})
+}
+
+// straightenInk rotates a BGR crop so the long side of the minimum-area
+// rectangle around its ink is horizontal, growing the canvas like
+// alignToPrincipalAxis. It returns the rotated crop (owned by the caller) and
+// the applied rotation in degrees, counter-clockwise.
+func straightenInk(crop gocv.Mat) (gocv.Mat, float64) {
+	mask := inkMask(crop)
+	defer mask.Close()
+	contours := gocv.FindContours(mask, gocv.RetrievalExternal, gocv.ChainApproxSimple)
+	defer contours.Close()
+	var points []image.Point
+	for i := 0; i < contours.Size(); i++ {
+		points = append(points, contours.At(i).ToPoints()...)
+	}
+	if len(points) < 3 {
+		return crop.Clone(), 0
+	}
+
+	pv := gocv.NewPointVectorFromPoints(points)
+	defer pv.Close()
+	angle := longEdgeAngle(gocv.MinAreaRect2f(pv))
+	if math.Abs(angle) < minSkewDegrees {
+		return crop.Clone(), 0
+	}
+	return rotateExpanded(crop, angle), angle
+}
@@ -0,0 +1,70 @@
+package signature
+
+import (
+	"image"
+
+	"gocv.io/x/gocv"
+)
+
+// medianDenoise smooths gray in place with a ksize x ksize median filter (ksize
+// odd), which wipes out isolated dust and JPEG ringing before thresholding while
+// keeping stroke edges sharp. A ksize below 3 leaves gray unchanged.
+func medianDenoise(gray *gocv.Mat, ksize int) {
+	if ksize < 3 {
+		return
+	}
+	gocv.MedianBlur(*gray, gray, ksize)
+}
+
+// despeckle makes every 8-connected group of visible pixels in img smaller than
+// minArea pixels fully transparent, so dust surviving the background removal
+// doesn't show as opaque specks. It returns how many groups were removed.
+func despeckle(img *image.RGBA, minArea int) int {
+	if minArea <= 1 {
+		return 0
+	}
+	w, h := img.Rect.Dx(), img.Rect.Dy()
+	alphaAt := func(x, y int) uint8 { return img.Pix[y*img.Stride+x*4+3] }
+
+	seen := make([]bool, w*h)
+	var removed int
+	var component, stack []int
+	for start := range seen {
+		if seen[start] || alphaAt(start%w, start/w) == 0 {
+			continue
+		}
+
+		// Flood-fill the component, remembering its pixels in case it is a speck
+		component = component[:0]
+		stack = append(stack[:0], start)
+		seen[start] = true
+		for len(stack) > 0 {
+			p := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			component = append(component, p)
+			x, y := p%w, p/w
+			for dy := -1; dy <= 1; dy++ {
+				for dx := -1; dx <= 1; dx++ {
+					nx, ny := x+dx, y+dy
+					if nx < 0 || ny < 0 || nx >= w || ny >= h {
+						continue
+					}
+					n := ny*w + nx
+					if !seen[n] && alphaAt(nx, ny) != 0 {
+						seen[n] = true
+						stack = append(stack, n)
+					}
+				}
+			}
+		}
+		if len(component) >= minArea {
+			continue
+		}
+		for _, p := range component {
+			i := (p/w)*img.Stride + (p%w)*4
+			img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] = 0, 0, 0, 0
+		}
+		removed++
+	}
+	return removed
+}
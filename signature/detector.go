@@ -0,0 +1,231 @@
+package signature
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"sort"
+	"sync"
+
+	"gocv.io/x/gocv"
+)
+
+// Supported values for Options.Detector.
+const (
+	// DetectorContours finds the signature with the classical pipeline:
+	// binarization, contours and shape filters. It is the default.
+	DetectorContours = "contours"
+	// DetectorONNX runs an object-detection model (Options.DetectorModel) trained
+	// for handwritten signatures through OpenCV's DNN module, for noisy scans the
+	// contour heuristics break on.
+	DetectorONNX = "onnx"
+)
+
+// DefaultMinDetectorScore is the model score below which DetectorONNX boxes are discarded.
+const DefaultMinDetectorScore = 0.25
+
+// Model input handling, fixed by how YOLO-family detectors are exported.
+const (
+	// detectorInputSize is the square input the page is letterboxed into.
+	detectorInputSize = 640
+	// detectorNMS is the IoU above which overlapping boxes are merged.
+	detectorNMS = 0.45
+)
+
+// detectorPadColor fills the letterbox bars, the gray YOLO models are trained with.
+var detectorPadColor = color.RGBA{114, 114, 114, 0}
+
+// ValidDetector reports whether name is a known Options.Detector value.
+func ValidDetector(name string) bool {
+	switch name {
+	case "", DetectorContours, DetectorONNX:
+		return true
+	}
+	return false
+}
+
+// onnxDetector is a loaded detection model. OpenCV networks are not safe for
+// concurrent inference, so detect serializes calls.
+type onnxDetector struct {
+	mu  sync.Mutex
+	net gocv.Net
+}
+
+// scoredBox is one detection in page pixels.
+type scoredBox struct {
+	rect  image.Rectangle
+	score float64
+}
+
+// loadDetector reads the ONNX model at path.
+func loadDetector(path string) (*onnxDetector, error) {
+	net := gocv.ReadNetFromONNX(path)
+	if net.Empty() {
+		return nil, fmt.Errorf("unable to load ONNX model: %s", path)
+	}
+	return &onnxDetector{net: net}, nil
+}
+
+// detector returns the extractor's model, loading it on first use so that
+// NewExtractor stays cheap and the contour pipeline never touches the DNN module.
+func (e *Extractor) detector() (*onnxDetector, error) {
+	e.detectorOnce.Do(func() {
+		e.onnx, e.onnxErr = loadDetector(e.opts.DetectorModel)
+	})
+	return e.onnx, e.onnxErr
+}
+
+// detect runs the model on a BGR page and returns the boxes scoring at least
+// minScore after non-maximum suppression, best first. The output is read as
+// YOLOv8 lays it out, [1, 4+classes, boxes] with centre, size and one score per
+// class, or transposed as [1, boxes, 4+classes]; the best class score is used.
+func (d *onnxDetector) detect(page gocv.Mat, minScore float64) ([]scoredBox, error) {
+	// Letterbox: scale the page into the square input keeping its aspect
+	scale := min(float64(detectorInputSize)/float64(page.Cols()), float64(detectorInputSize)/float64(page.Rows()))
+	resized := gocv.NewMat()
+	defer resized.Close()
+	gocv.Resize(page, &resized, image.Pt(int(float64(page.Cols())*scale), int(float64(page.Rows())*scale)), 0, 0, gocv.InterpolationArea)
+	input := gocv.NewMat()
+	defer input.Close()
+	gocv.CopyMakeBorder(resized, &input, 0, detectorInputSize-resized.Rows(), 0, detectorInputSize-resized.Cols(), gocv.BorderConstant, detectorPadColor)
+
+	blob := gocv.BlobFromImage(input, 1.0/255, image.Pt(detectorInputSize, detectorInputSize), gocv.NewScalar(0, 0, 0, 0), true, false)
+	defer blob.Close()
+
+	d.mu.Lock()
+	d.net.SetInput(blob, "")
+	out := d.net.Forward("")
+	d.mu.Unlock()
+	defer out.Close()
+
+	dims := out.Size()
+	if len(dims) != 3 || dims[0] != 1 {
+		return nil, fmt.Errorf("unexpected model output shape %v, want [1, 4+classes, boxes]", dims)
+	}
+	data, err := out.DataPtrFloat32()
+	if err != nil {
+		return nil, err
+	}
+	attrs, boxes := dims[1], dims[2]
+	at := func(box, attr int) float32 { return data[attr*boxes+box] }
+	if attrs > boxes {
+		// Exported transposed: one row per box
+		attrs, boxes = boxes, attrs
+		at = func(box, attr int) float32 { return data[box*attrs+attr] }
+	}
+	if attrs < 5 {
+		return nil, errors.New("model output has no class scores")
+	}
+
+	var rects []image.Rectangle
+	var scores []float32
+	for i := 0; i < boxes; i++ {
+		var best float32
+		for c := 4; c < attrs; c++ {
+			best = max(best, at(i, c))
+		}
+		if float64(best) < minScore {
+			continue
+		}
+		// Centre and size in input pixels, back to page pixels
+		cx, cy, w, h := at(i, 0), at(i, 1), at(i, 2), at(i, 3)
+		rect := image.Rect(
+			int(float64(cx-w/2)/scale), int(float64(cy-h/2)/scale),
+			int(float64(cx+w/2)/scale), int(float64(cy+h/2)/scale),
+		).Intersect(image.Rect(0, 0, page.Cols(), page.Rows()))
+		if rect.Empty() {
+			continue
+		}
+		rects = append(rects, rect)
+		scores = append(scores, best)
+	}
+	if len(rects) == 0 {
+		return nil, nil
+	}
+
+	keep := gocv.NMSBoxes(rects, scores, float32(minScore), detectorNMS)
+	found := make([]scoredBox, 0, len(keep))
+	for _, i := range keep {
+		found = append(found, scoredBox{rect: rects[i], score: float64(scores[i])})
+	}
+	sort.SliceStable(found, func(i, j int) bool { return found[i].score > found[j].score })
+	return found, nil
+}
+
+// modelRegions is the DetectorONNX counterpart of extractSignature: it crops the
+// boxes the model finds on the page at imgPath, each scored by the model.
+// Without params.all only the best box is returned, otherwise all of them in
+// reading order. params.areas, params.classes and params.padding apply as for
+// detection.
+func (e *Extractor) modelRegions(imgPath string, params detectParams) ([]SignatureRegion, error) {
+	det, err := e.detector()
+	if err != nil {
+		return nil, err
+	}
+	img, err := readImage(imgPath, gocv.IMReadColor)
+	if err != nil {
+		return nil, err
+	}
+	defer img.Close()
+	if err := params.debug.write(debugStagePage, "page", img); err != nil {
+		return nil, err
+	}
+	if params.key != nil {
+		params.key.whiten(&img)
+	}
+	whitenSeals(&img, params.seals)
+
+	boxes, err := det.detect(img, e.minDetectorScore())
+	if err != nil {
+		return nil, fmt.Errorf("model inference failed: %v", err)
+	}
+	imgRect := image.Rect(0, 0, img.Cols(), img.Rows())
+	var rects []image.Rectangle
+	scores := map[image.Rectangle]float64{}
+	classes := map[image.Rectangle]string{}
+	for _, b := range boxes {
+		if params.areas != nil && !inSearchAreas(b.rect, params.areas, params.labels) {
+			continue
+		}
+		crop := img.Region(b.rect.Inset(-params.padding).Intersect(imgRect))
+		classes[b.rect] = classifyRegion(crop)
+		crop.Close()
+		if !keepClass(params.classes, classes[b.rect]) {
+			continue
+		}
+		rects = append(rects, b.rect)
+		scores[b.rect] = b.score
+	}
+	if len(rects) > 0 {
+		if params.all {
+			rects = allRegions(rects)
+		} else {
+			rects = rects[:1]
+		}
+	}
+	if err := params.debug.contours(img, nil, params.areas, rects); err != nil {
+		return nil, err
+	}
+	if len(rects) == 0 {
+		return nil, ErrNoSignature
+	}
+
+	regions := make([]SignatureRegion, len(rects))
+	for i, rect := range rects {
+		padded := rect.Inset(-params.padding).Intersect(imgRect)
+		crop := img.Region(padded)
+		regions[i] = SignatureRegion{Bounds: padded, Image: crop.Clone(), Ink: rect, Confidence: scores[rect], Class: classes[rect]}
+		crop.Close()
+	}
+	e.logf("Model found %d signature boxes (best score %.2f)", len(boxes), boxes[0].score)
+	return regions, nil
+}
+
+// minDetectorScore returns Options.MinDetectorScore, defaulting when zero.
+func (e *Extractor) minDetectorScore() float64 {
+	if e.opts.MinDetectorScore == 0 {
+		return DefaultMinDetectorScore
+	}
+	return e.opts.MinDetectorScore
+}
@@ -0,0 +1,517 @@
+package signature
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	_ "crypto/sha1" // digests of adbe.pkcs7.sha1 and older signers
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"time"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// DigitalSignature is a cryptographic signature of a PDF, the value of one of
+// its signature fields (see DigitalSignatures). Intact checks the signature
+// against the certificate it carries; whether that certificate is trusted,
+// expired or revoked is not checked.
+type DigitalSignature struct {
+	// Field is the fully qualified name of the signature field.
+	Field string `json:"field"`
+	// Page is the page the field's widget is on and Rect the widget in PDF
+	// points; Page is 0 for an invisible signature.
+	Page int     `json:"page,omitempty"`
+	Rect PDFRect `json:"rect_pt,omitzero"`
+	// SubFilter is the encoding of the signature, e.g. "adbe.pkcs7.detached"
+	// or "ETSI.CAdES.detached"; "ETSI.RFC3161" is a document timestamp.
+	SubFilter string `json:"sub_filter,omitempty"`
+	// SignerName is the signature's /Name, or else the common name of the
+	// signer's certificate.
+	SignerName string `json:"signer_name,omitempty"`
+	// SigningTime is the signature's /M, or else the signing time the signer
+	// signed, or the time of a document timestamp.
+	SigningTime time.Time `json:"signing_time,omitzero"`
+	Reason      string    `json:"reason,omitempty"`
+	Location    string    `json:"location,omitempty"`
+	// Intact reports that the /ByteRange covers the file from its start up to
+	// where it ends, except for the signature itself, that those bytes hash to
+	// the digest that was signed, and that the signer's certificate verifies
+	// the signature.
+	Intact bool `json:"intact"`
+	// CoversDocument reports that the /ByteRange runs to the end of the file.
+	// When it doesn't, the PDF was saved again after signing, by a later
+	// signature or by edits, which Intact doesn't vouch for.
+	CoversDocument bool `json:"covers_document"`
+	// Problem is why the signature isn't Intact.
+	Problem string `json:"problem,omitempty"`
+}
+
+// signedField is a signature field with a value, found by collectSignedFields.
+type signedField struct {
+	name  string
+	value types.Dict
+	// widgets are the object numbers of the field's widgets, and rects their
+	// rectangles, zero for those without an area.
+	widgets []int
+	rects   []PDFRect
+}
+
+// DigitalSignatures reads the digital signatures of the PDF at pdfPath, one
+// per signed signature field, in the order of the form. A PDF without signed
+// fields has none. A signature that can't be checked is still returned, with
+// Intact false and the reason in Problem.
+func DigitalSignatures(pdfPath, password string) ([]DigitalSignature, error) {
+	ctx, err := readPDFStructure(pdfPath, password)
+	if err != nil {
+		return nil, err
+	}
+	xref := ctx.XRefTable
+	roots, err := acroFormFields(xref)
+	if err != nil || roots == nil {
+		return nil, err
+	}
+	var fields []*signedField
+	visited := map[int]bool{}
+	for _, root := range roots {
+		collectSignedFields(xref, root, "", "", nil, 0, visited, &fields)
+	}
+	if len(fields) == 0 {
+		return nil, nil
+	}
+
+	// The signatures sign the bytes of the file, not its parsed objects
+	data, err := os.ReadFile(pdfPath)
+	if err != nil {
+		return nil, err
+	}
+	annots, err := pageAnnotations(xref)
+	if err != nil {
+		return nil, err
+	}
+	pageOf := map[int]int{}
+	for _, a := range annots {
+		if _, ok := pageOf[a.objNr]; !ok {
+			pageOf[a.objNr] = a.page
+		}
+	}
+
+	sigs := make([]DigitalSignature, 0, len(fields))
+	for _, f := range fields {
+		sig := DigitalSignature{Field: f.name}
+		for i, objNr := range f.widgets {
+			if page, ok := pageOf[objNr]; ok && f.rects[i] != (PDFRect{}) {
+				sig.Page, sig.Rect = page, f.rects[i]
+				break
+			}
+		}
+		readDigitalSignature(xref, f.value, data, &sig)
+		sigs = append(sigs, sig)
+	}
+	return sigs, nil
+}
+
+// collectSignedFields walks the field (or widget) obj and its kids like
+// collectSignatureWidgets, adding every signature field with a value to
+// fields and its widgets to it. field is the field obj belongs to when obj is
+// one of its widgets.
+func collectSignedFields(xref *model.XRefTable, obj types.Object, name, ft string, field *signedField, depth int, visited map[int]bool, fields *[]*signedField) {
+	if depth > maxFieldDepth {
+		return
+	}
+	ref, isRef := obj.(types.IndirectRef)
+	if isRef {
+		if visited[ref.ObjectNumber.Value()] {
+			return
+		}
+		visited[ref.ObjectNumber.Value()] = true
+	}
+	d, err := xref.DereferenceDict(obj)
+	if err != nil || d == nil {
+		return
+	}
+
+	if own := d.NameEntry("FT"); own != nil {
+		ft = *own
+	}
+	// A dictionary with a name is a field of its own; one without is a widget
+	if t, ok := d.Find("T"); ok {
+		if s, err := types.StringOrHexLiteral(t); err == nil && s != nil {
+			if name != "" {
+				name += "."
+			}
+			name += *s
+		}
+		field = nil
+		if v, err := xref.DereferenceDict(d["V"]); ft == "Sig" && err == nil && v != nil {
+			field = &signedField{name: name, value: v}
+			*fields = append(*fields, field)
+		}
+	}
+	if _, ok := d.Find("Rect"); ok && field != nil && isRef {
+		rect, _ := fieldRect(xref, d)
+		field.widgets = append(field.widgets, ref.ObjectNumber.Value())
+		field.rects = append(field.rects, rect)
+	}
+
+	kids, err := xref.DereferenceArray(d["Kids"])
+	if err != nil {
+		return
+	}
+	for _, kid := range kids {
+		collectSignedFields(xref, kid, name, ft, field, depth+1, visited, fields)
+	}
+}
+
+// readDigitalSignature fills sig from the signature dictionary v of the PDF
+// whose bytes are data.
+func readDigitalSignature(xref *model.XRefTable, v types.Dict, data []byte, sig *DigitalSignature) {
+	if s := v.NameEntry("SubFilter"); s != nil {
+		sig.SubFilter = *s
+	}
+	sig.SignerName = dictText(xref, v, "Name")
+	sig.Reason = dictText(xref, v, "Reason")
+	sig.Location = dictText(xref, v, "Location")
+	if m := dictText(xref, v, "M"); m != "" {
+		if t, ok := types.DateTime(m, true); ok {
+			sig.SigningTime = t
+		}
+	}
+
+	signed, contents, end, err := byteRange(xref, v, data)
+	if err != nil {
+		sig.Problem = err.Error()
+		return
+	}
+	sig.CoversDocument = true
+	for _, c := range data[end:] {
+		if !isPDFSpace(c) {
+			sig.CoversDocument = false
+			break
+		}
+	}
+
+	signer, signingTime, err := checkCMS(sig.SubFilter, contents, signed)
+	if signer != nil && sig.SignerName == "" {
+		sig.SignerName = signer.Subject.CommonName
+	}
+	if sig.SigningTime.IsZero() {
+		sig.SigningTime = signingTime
+	}
+	if err != nil {
+		sig.Problem = err.Error()
+		return
+	}
+	sig.Intact = true
+}
+
+// dictText returns the text string under key in d, empty when there is none.
+func dictText(xref *model.XRefTable, d types.Dict, key string) string {
+	o, err := xref.Dereference(d[key])
+	if err != nil || o == nil {
+		return ""
+	}
+	s, err := types.StringOrHexLiteral(o)
+	if err != nil || s == nil {
+		return ""
+	}
+	return *s
+}
+
+// byteRange reads the /ByteRange of the signature dictionary v and returns
+// the bytes of data it covers, the signature stored in the gap it leaves,
+// which must be exactly the /Contents hex string, and where the range ends.
+func byteRange(xref *model.XRefTable, v types.Dict, data []byte) (signed, contents []byte, end int, err error) {
+	a, err := xref.DereferenceArray(v["ByteRange"])
+	if err != nil || len(a) != 4 {
+		return nil, nil, 0, errors.New("signature has no valid /ByteRange")
+	}
+	var r [4]int
+	for i, o := range a {
+		n, ok := o.(types.Integer)
+		if !ok || n < 0 {
+			return nil, nil, 0, errors.New("signature has no valid /ByteRange")
+		}
+		r[i] = n.Value()
+	}
+	// Two runs around the signature: [0, r1) and [r2, r2+r3)
+	if r[0] != 0 || r[1] >= r[2] || r[2]+r[3] > len(data) {
+		return nil, nil, 0, fmt.Errorf("/ByteRange %v doesn't cover the file from its start", r)
+	}
+	gap := data[r[1]:r[2]]
+	if len(gap) < 2 || gap[0] != '<' || gap[len(gap)-1] != '>' {
+		return nil, nil, 0, fmt.Errorf("/ByteRange %v leaves out more than the signature", r)
+	}
+	contents, err = hex.DecodeString(string(gap[1 : len(gap)-1]))
+	if err != nil {
+		return nil, nil, 0, fmt.Errorf("/ByteRange %v leaves out more than the signature", r)
+	}
+	end = r[2] + r[3]
+	signed = append(data[:r[1]:r[1]], data[r[2]:end]...)
+	return signed, contents, end, nil
+}
+
+// Object identifiers of the CMS (PKCS#7) structures checkCMS reads.
+var (
+	oidSignedData    = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+	oidMessageDigest = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 4}
+	oidSigningTime   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 5}
+	oidRSAPSS        = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 10}
+)
+
+// digestHashes maps the digest algorithms of signatures to their hash.
+var digestHashes = map[string]crypto.Hash{
+	"1.3.14.3.2.26":          crypto.SHA1,
+	"2.16.840.1.101.3.4.2.1": crypto.SHA256,
+	"2.16.840.1.101.3.4.2.2": crypto.SHA384,
+	"2.16.840.1.101.3.4.2.3": crypto.SHA512,
+}
+
+type cmsContentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"tag:0"`
+}
+
+type cmsSignedData struct {
+	Version          int
+	DigestAlgorithms asn1.RawValue
+	EncapContentInfo struct {
+		ContentType asn1.ObjectIdentifier
+		Content     asn1.RawValue `asn1:"optional,tag:0"`
+	}
+	Certificates asn1.RawValue   `asn1:"optional,tag:0"`
+	CRLs         asn1.RawValue   `asn1:"optional,tag:1"`
+	SignerInfos  []cmsSignerInfo `asn1:"set"`
+}
+
+type cmsSignerInfo struct {
+	Version            int
+	SID                asn1.RawValue
+	DigestAlgorithm    pkix.AlgorithmIdentifier
+	SignedAttrs        asn1.RawValue `asn1:"optional,tag:0"`
+	SignatureAlgorithm pkix.AlgorithmIdentifier
+	Signature          []byte
+}
+
+type cmsAttribute struct {
+	Type   asn1.ObjectIdentifier
+	Values asn1.RawValue
+}
+
+type cmsIssuerAndSerial struct {
+	Issuer asn1.RawValue
+	Serial *big.Int
+}
+
+// tstInfo is the head of an RFC 3161 timestamp token's content.
+type tstInfo struct {
+	Version        int
+	Policy         asn1.ObjectIdentifier
+	MessageImprint struct {
+		HashAlgorithm pkix.AlgorithmIdentifier
+		HashedMessage []byte
+	}
+	SerialNumber *big.Int
+	GenTime      time.Time `asn1:"generalized"`
+}
+
+// checkCMS checks that the DER CMS signature der, of the given SubFilter,
+// signs signed, and returns the signer's certificate and the signing time it
+// carries, when it could read them, also with an error.
+func checkCMS(subFilter string, der, signed []byte) (*x509.Certificate, time.Time, error) {
+	var signingTime time.Time
+	switch subFilter {
+	case "adbe.pkcs7.detached", "ETSI.CAdES.detached", "adbe.pkcs7.sha1", "ETSI.RFC3161":
+	default:
+		return nil, signingTime, fmt.Errorf("SubFilter %q is not supported", subFilter)
+	}
+	var ci cmsContentInfo
+	if _, err := asn1.Unmarshal(der, &ci); err != nil {
+		return nil, signingTime, fmt.Errorf("unreadable PKCS#7 signature: %v", err)
+	}
+	if !ci.ContentType.Equal(oidSignedData) {
+		return nil, signingTime, errors.New("signature is not PKCS#7 signed data")
+	}
+	var sd cmsSignedData
+	if _, err := asn1.Unmarshal(ci.Content.Bytes, &sd); err != nil {
+		return nil, signingTime, fmt.Errorf("unreadable PKCS#7 signature: %v", err)
+	}
+	if len(sd.SignerInfos) == 0 {
+		return nil, signingTime, errors.New("signature has no signer")
+	}
+	si := sd.SignerInfos[0]
+	h, ok := digestHashes[si.DigestAlgorithm.Algorithm.String()]
+	if !ok {
+		return nil, signingTime, fmt.Errorf("digest algorithm %v is not supported", si.DigestAlgorithm.Algorithm)
+	}
+	certs, err := x509.ParseCertificates(sd.Certificates.Bytes)
+	if err != nil {
+		return nil, signingTime, fmt.Errorf("unreadable signer certificate: %v", err)
+	}
+	signer := signerCertificate(certs, si.SID)
+	if signer == nil {
+		return nil, signingTime, errors.New("signature doesn't carry the signer's certificate")
+	}
+
+	// The signer signs the file's bytes, or for these SubFilters a content
+	// holding their digest
+	content := signed
+	var eContent []byte
+	if c := sd.EncapContentInfo.Content.Bytes; len(c) > 0 {
+		if _, err := asn1.Unmarshal(c, &eContent); err != nil {
+			return signer, signingTime, fmt.Errorf("unreadable signed content: %v", err)
+		}
+	}
+	switch subFilter {
+	case "adbe.pkcs7.sha1":
+		if !bytes.Equal(eContent, digest(crypto.SHA1, signed)) {
+			return signer, signingTime, errors.New("the signed bytes don't match the signature's digest")
+		}
+		content = eContent
+	case "ETSI.RFC3161":
+		var tst tstInfo
+		if _, err := asn1.Unmarshal(eContent, &tst); err != nil {
+			return signer, signingTime, fmt.Errorf("unreadable timestamp token: %v", err)
+		}
+		signingTime = tst.GenTime
+		th, ok := digestHashes[tst.MessageImprint.HashAlgorithm.Algorithm.String()]
+		if !ok {
+			return signer, signingTime, fmt.Errorf("digest algorithm %v is not supported", tst.MessageImprint.HashAlgorithm.Algorithm)
+		}
+		if !bytes.Equal(tst.MessageImprint.HashedMessage, digest(th, signed)) {
+			return signer, signingTime, errors.New("the signed bytes don't match the timestamp's digest")
+		}
+		content = eContent
+	default:
+		if len(eContent) > 0 {
+			return signer, signingTime, errors.New("detached signature embeds content")
+		}
+	}
+
+	// With signed attributes, the signature covers them and they the content
+	toVerify := digest(h, content)
+	if len(si.SignedAttrs.FullBytes) > 0 {
+		var messageDigest []byte
+		for rest := si.SignedAttrs.Bytes; len(rest) > 0; {
+			var attr cmsAttribute
+			if rest, err = asn1.Unmarshal(rest, &attr); err != nil {
+				return signer, signingTime, fmt.Errorf("unreadable signed attributes: %v", err)
+			}
+			switch {
+			case attr.Type.Equal(oidMessageDigest):
+				asn1.Unmarshal(attr.Values.Bytes, &messageDigest)
+			case attr.Type.Equal(oidSigningTime) && signingTime.IsZero():
+				asn1.Unmarshal(attr.Values.Bytes, &signingTime)
+			}
+		}
+		if !bytes.Equal(messageDigest, toVerify) {
+			return signer, signingTime, errors.New("the signed bytes don't match the signature's digest")
+		}
+		// They are signed as the SET they are, not with the implicit tag they carry
+		attrs := bytes.Clone(si.SignedAttrs.FullBytes)
+		attrs[0] = asn1.TagSet | 0x20
+		toVerify = digest(h, attrs)
+	}
+	if err := verifySigner(signer, si.SignatureAlgorithm.Algorithm, h, toVerify, si.Signature); err != nil {
+		return signer, signingTime, fmt.Errorf("the signer's certificate doesn't verify the signature: %v", err)
+	}
+	return signer, signingTime, nil
+}
+
+// signerCertificate returns the certificate among certs that sid, a CMS
+// SignerIdentifier, names, or nil.
+func signerCertificate(certs []*x509.Certificate, sid asn1.RawValue) *x509.Certificate {
+	// subjectKeyIdentifier is [0], issuerAndSerialNumber a SEQUENCE
+	if sid.Class == asn1.ClassContextSpecific && sid.Tag == 0 {
+		for _, c := range certs {
+			if bytes.Equal(c.SubjectKeyId, sid.Bytes) {
+				return c
+			}
+		}
+		return nil
+	}
+	var ias cmsIssuerAndSerial
+	if _, err := asn1.Unmarshal(sid.FullBytes, &ias); err != nil || ias.Serial == nil {
+		return nil
+	}
+	for _, c := range certs {
+		if bytes.Equal(c.RawIssuer, ias.Issuer.FullBytes) && c.SerialNumber.Cmp(ias.Serial) == 0 {
+			return c
+		}
+	}
+	return nil
+}
+
+// verifySigner checks sig, made with the algorithm alg over a digest by h,
+// with the public key of cert.
+func verifySigner(cert *x509.Certificate, alg asn1.ObjectIdentifier, h crypto.Hash, digest, sig []byte) error {
+	switch pub := cert.PublicKey.(type) {
+	case *rsa.PublicKey:
+		if alg.Equal(oidRSAPSS) {
+			return rsa.VerifyPSS(pub, h, digest, sig, nil)
+		}
+		return rsa.VerifyPKCS1v15(pub, h, digest, sig)
+	case *ecdsa.PublicKey:
+		if !ecdsa.VerifyASN1(pub, digest, sig) {
+			return errors.New("ecdsa: verification error")
+		}
+		return nil
+	}
+	return fmt.Errorf("%T keys are not supported", cert.PublicKey)
+}
+
+// digest hashes data with h.
+func digest(h crypto.Hash, data []byte) []byte {
+	w := h.New()
+	w.Write(data)
+	return w.Sum(nil)
+}
+
+// digitalSignatureFor returns the signature among sigs, those of res's page,
+// of the form field res was cropped from, or else the one whose widget
+// overlaps res the most; nil when none does.
+func digitalSignatureFor(sigs []DigitalSignature, res *Result) *DigitalSignature {
+	var best *DigitalSignature
+	bestArea := 0.0
+	for i := range sigs {
+		s := sigs[i]
+		if res.FormField != "" && s.Field == res.FormField {
+			return &s
+		}
+		w := min(s.Rect.URX, res.PDFBounds.URX) - max(s.Rect.LLX, res.PDFBounds.LLX)
+		h := min(s.Rect.URY, res.PDFBounds.URY) - max(s.Rect.LLY, res.PDFBounds.LLY)
+		if w > 0 && h > 0 && w*h > bestArea {
+			best, bestArea = &s, w*h
+		}
+	}
+	return best
+}
+
+// logDigitalSignature logs sig, as a warning when it isn't intact.
+func (e *Extractor) logDigitalSignature(sig DigitalSignature) {
+	who, when := sig.SignerName, "an unknown time"
+	if who == "" {
+		who = "an unknown signer"
+	}
+	if !sig.SigningTime.IsZero() {
+		when = sig.SigningTime.Format(time.RFC3339)
+	}
+	switch {
+	case !sig.Intact:
+		e.warnf("Digital signature %q by %s at %s is not intact: %s", sig.Field, who, when, sig.Problem)
+	case !sig.CoversDocument:
+		e.warnf("Digital signature %q by %s at %s is intact, but the PDF was changed after signing", sig.Field, who, when)
+	default:
+		e.logf("Digital signature %q by %s at %s is intact and covers the whole document", sig.Field, who, when)
+	}
+}
@@ -0,0 +1,35 @@
+package signature
+
+import (
+	"image"
+	_ "image/png" // register the decoder for imageSize
+	"os"
+)
+
+// DefaultEdgeMargin is how close, in pixels, a detection may come to the page
+// border before it is considered to touch it.
+const DefaultEdgeMargin = 2
+
+// touchesEdge reports whether rect comes within margin pixels of any border of
+// an image with the given bounds. Such detections were often cut off by the scanner.
+func touchesEdge(rect, page image.Rectangle, margin int) bool {
+	return rect.Min.X-page.Min.X <= margin ||
+		rect.Min.Y-page.Min.Y <= margin ||
+		page.Max.X-rect.Max.X <= margin ||
+		page.Max.Y-rect.Max.Y <= margin
+}
+
+// imageBounds reads only the header of an image file to get its dimensions.
+func imageBounds(path string) (image.Rectangle, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return image.Rectangle{}, err
+	}
+	defer f.Close()
+
+	cfg, _, err := image.DecodeConfig(f)
+	if err != nil {
+		return image.Rectangle{}, err
+	}
+	return image.Rect(0, 0, cfg.Width, cfg.Height), nil
+}
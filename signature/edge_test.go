@@ -0,0 +1,61 @@
+package signature
+
+import (
+	"context"
+	"errors"
+	"image"
+	"testing"
+)
+
+func TestSignatureOffThePageTouchesEdge(t *testing.T) {
+	dir := t.TempDir()
+	img := newPage(850, 1100)
+	// Starts 120px from the right border and runs off it
+	drawSignature(img, 730, 850, 0, 1, blueInk)
+	cut := writeFixture(t, dir, "cut.png", img)
+
+	results := extractFixture(t, fixtureOptions(t), cut)
+	if len(results) != 1 || !results[0].EdgeTouch {
+		t.Fatalf("signature running off the page: results %v, want one with EdgeTouch", results)
+	}
+	if results := extractFixture(t, fixtureOptions(t), signaturePage(t, dir)); len(results) != 1 || results[0].EdgeTouch {
+		t.Errorf("signature inside the page: results %v, want one without EdgeTouch", results)
+	}
+
+	opts := fixtureOptions(t)
+	opts.Strict = true
+	_, err := NewExtractor(opts).ExtractFromPDF(context.Background(), cut)
+	if !errors.Is(err, ErrNoSignature) {
+		t.Errorf("strict extraction = %v, want ErrNoSignature once the cut-off region is rejected", err)
+	}
+
+	// Under -strict only the cut-off region goes; the one beside it is kept
+	drawSignature(img, 150, 300, 0, 1, blueInk)
+	both := writeFixture(t, dir, "both.png", img)
+	opts = fixtureOptions(t)
+	opts.Strict, opts.AllRegions = true, true
+	results = extractFixture(t, opts, both)
+	if len(results) != 1 || results[0].EdgeTouch || results[0].Bounds.Max.Y > 500 {
+		t.Errorf("strict extraction of two regions: results %v, want only the one inside the page", results)
+	}
+}
+
+func TestTouchesEdge(t *testing.T) {
+	page := image.Rect(0, 0, 100, 50)
+	tests := []struct {
+		rect image.Rectangle
+		want bool
+	}{
+		{image.Rect(10, 10, 90, 40), false},
+		{image.Rect(3, 3, 97, 47), false},
+		{image.Rect(2, 10, 90, 40), true},
+		{image.Rect(10, 10, 98, 40), true},
+		{image.Rect(10, 10, 90, 48), true},
+		{image.Rect(-5, 10, 90, 40), true},
+	}
+	for _, tt := range tests {
+		if got := touchesEdge(tt.rect, page, DefaultEdgeMargin); got != tt.want {
+			t.Errorf("touchesEdge(%v, %v) = %v, want %v", tt.rect, page, got, tt.want)
+		}
+	}
+}
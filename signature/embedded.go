@@ -0,0 +1,334 @@
+package signature
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"io"
+	"math"
+	"os"
+	"strconv"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// scanCoverTolerance is how far, as a fraction of the page's width or height,
+// an embedded image may miss the page's edges and still count as covering it.
+const scanCoverTolerance = 0.01
+
+// embeddedScan is a page that is nothing but one scanned image, which can be
+// decoded straight from the PDF instead of being rasterized (see
+// Options.EmbeddedScans).
+type embeddedScan struct {
+	pdf   *model.Context
+	sd    *types.StreamDict
+	objNr int
+	name  string
+	// Size is the width and height of the image in pixels.
+	Size image.Point
+	// DPI is the resolution the image is drawn at on the page.
+	DPI float64
+}
+
+// embeddedScans returns the pages among pages that are one embedded scan,
+// keyed by page number. Pages drawing anything else (text other than an
+// invisible OCR layer, vector graphics, several images) or an image that
+// doesn't cover the page are left out, to be rasterized.
+func embeddedScans(pdfPath, password string, pages []int) (map[int]*embeddedScan, error) {
+	pdf, err := readPDFStructure(pdfPath, password)
+	if err != nil {
+		return nil, err
+	}
+	scans := map[int]*embeddedScan{}
+	for _, page := range pages {
+		if scan := pageScan(pdf, page); scan != nil {
+			scans[page] = scan
+		}
+	}
+	return scans, nil
+}
+
+// pageScan returns the embedded scan page is, or nil.
+func pageScan(pdf *model.Context, page int) *embeddedScan {
+	xref := pdf.XRefTable
+	pageDict, _, inherited, err := xref.PageDict(page, false)
+	if err != nil || pageDict == nil || inherited == nil {
+		return nil
+	}
+	crop := inherited.CropBox
+	if crop == nil {
+		crop = inherited.MediaBox
+	}
+	if crop == nil || normalizeRotate(inherited.Rotate) != 0 {
+		return nil
+	}
+	content, err := xref.PageContent(pageDict, page)
+	if err != nil {
+		return nil
+	}
+	name, ctm, ok := soleImageDraw(content)
+	if !ok {
+		return nil
+	}
+
+	// The image must be drawn upright over the whole page
+	w, h := crop.Width(), crop.Height()
+	if math.Abs(ctm[1]) > 1e-6 || math.Abs(ctm[2]) > 1e-6 || ctm[0] <= 0 || ctm[3] <= 0 ||
+		math.Abs(ctm[4]-crop.LL.X) > scanCoverTolerance*w || math.Abs(ctm[5]-crop.LL.Y) > scanCoverTolerance*h ||
+		math.Abs(ctm[4]+ctm[0]-crop.UR.X) > scanCoverTolerance*w || math.Abs(ctm[5]+ctm[3]-crop.UR.Y) > scanCoverTolerance*h {
+		return nil
+	}
+
+	resources, err := xref.DereferenceDict(pageDict["Resources"])
+	if err != nil || resources == nil {
+		resources = inherited.Resources
+	}
+	xobjects, err := xref.DereferenceDict(resources["XObject"])
+	if err != nil || xobjects == nil {
+		return nil
+	}
+	ref, ok := xobjects[name].(types.IndirectRef)
+	if !ok {
+		return nil
+	}
+	sd, _, err := xref.DereferenceStreamDict(ref)
+	if err != nil || sd == nil {
+		return nil
+	}
+	if st := sd.Subtype(); st == nil || *st != "Image" {
+		return nil
+	}
+	// Masks and decode arrays change what the pixels look like on the page
+	for _, key := range []string{"ImageMask", "Mask", "SMask", "Decode"} {
+		if _, found := sd.Find(key); found {
+			return nil
+		}
+	}
+	width, height := sd.IntEntry("Width"), sd.IntEntry("Height")
+	if width == nil || height == nil || *width <= 0 || *height <= 0 {
+		return nil
+	}
+
+	// Square pixels only, so one DPI describes the image
+	dpiX, dpiY := float64(*width)*pointsPerInch/w, float64(*height)*pointsPerInch/h
+	if math.Abs(dpiX-dpiY) > scanCoverTolerance*dpiX {
+		return nil
+	}
+	dpi := dpiX
+	if r := math.Round(dpi); math.Abs(dpi-r) < 0.05 {
+		dpi = r
+	}
+	return &embeddedScan{
+		pdf:   pdf,
+		sd:    sd,
+		objNr: ref.ObjectNumber.Value(),
+		name:  name,
+		Size:  image.Pt(*width, *height),
+		DPI:   dpi,
+	}
+}
+
+// write extracts the image into {prefix}.jpg or {prefix}.png, as stored in the
+// PDF (a JPEG keeps its bytes) or as pdfcpu decodes it, and returns its path.
+// Images that Go can't decode, or that decode to another size, fail, so the
+// page is rasterized instead.
+func (s *embeddedScan) write(prefix string) (string, error) {
+	img, err := pdfcpu.ExtractImage(s.pdf, s.sd, false, s.name, s.objNr, false)
+	if err != nil {
+		return "", fmt.Errorf("failed to extract image %s: %v", s.name, err)
+	}
+	if img == nil || img.Reader == nil {
+		return "", fmt.Errorf("image %s has an unsupported format", s.name)
+	}
+	if img.FileType != "jpg" && img.FileType != "png" {
+		return "", fmt.Errorf("image %s is %s, which can't be decoded", s.name, img.FileType)
+	}
+	data, err := io.ReadAll(img.Reader)
+	if err != nil {
+		return "", err
+	}
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return "", fmt.Errorf("failed to decode image %s: %v", s.name, err)
+	}
+	if cfg.Width != s.Size.X || cfg.Height != s.Size.Y {
+		return "", fmt.Errorf("image %s decodes to %dx%d, not %dx%d", s.name, cfg.Width, cfg.Height, s.Size.X, s.Size.Y)
+	}
+	path := prefix + "." + img.FileType
+	return path, os.WriteFile(path, data, 0o644)
+}
+
+// soleImageDraw reads a page's content stream and reports whether it paints
+// exactly one XObject and nothing else visible, returning the XObject's
+// resource name and the transformation matrix it is drawn with. Text in
+// render mode 3 (invisible), as OCR layers of searchable scans use, is allowed.
+func soleImageDraw(content []byte) (name string, ctm [6]float64, ok bool) {
+	type state struct {
+		ctm        [6]float64
+		renderMode string
+	}
+	cur := state{ctm: [6]float64{1, 0, 0, 1, 0, 0}, renderMode: "0"}
+	var stack []state
+	var operands []string
+	draws := 0
+
+	lex := contentLexer{data: content}
+	for {
+		tok, isOperator, more := lex.next()
+		if !more {
+			break
+		}
+		if !isOperator {
+			operands = append(operands, tok)
+			continue
+		}
+		switch tok {
+		case "q":
+			stack = append(stack, cur)
+		case "Q":
+			if len(stack) == 0 {
+				return "", ctm, false
+			}
+			cur, stack = stack[len(stack)-1], stack[:len(stack)-1]
+		case "cm":
+			if len(operands) != 6 {
+				return "", ctm, false
+			}
+			var m [6]float64
+			for i, o := range operands {
+				v, err := strconv.ParseFloat(o, 64)
+				if err != nil {
+					return "", ctm, false
+				}
+				m[i] = v
+			}
+			cur.ctm = multiplyMatrix(m, cur.ctm)
+		case "Tr":
+			if len(operands) == 1 {
+				cur.renderMode = operands[0]
+			}
+		case "Tj", "TJ", "'", "\"":
+			if cur.renderMode != "3" {
+				return "", ctm, false
+			}
+		case "Do":
+			if len(operands) != 1 || len(operands[0]) < 2 || operands[0][0] != '/' {
+				return "", ctm, false
+			}
+			draws++
+			name, ctm = operands[0][1:], cur.ctm
+		case "S", "s", "f", "F", "f*", "B", "B*", "b", "b*", "sh", "BI", "d0", "d1":
+			// Painted paths, shadings, inline images and Type 3 glyphs
+			return "", ctm, false
+		}
+		operands = operands[:0]
+	}
+	return name, ctm, draws == 1
+}
+
+// multiplyMatrix returns the PDF matrix product m × n: a transform by m
+// followed by one by n, as "m cm" applies to the current matrix n.
+func multiplyMatrix(m, n [6]float64) [6]float64 {
+	return [6]float64{
+		m[0]*n[0] + m[1]*n[2],
+		m[0]*n[1] + m[1]*n[3],
+		m[2]*n[0] + m[3]*n[2],
+		m[2]*n[1] + m[3]*n[3],
+		m[4]*n[0] + m[5]*n[2] + n[4],
+		m[4]*n[1] + m[5]*n[3] + n[5],
+	}
+}
+
+// contentLexer splits a PDF content stream into operands and operators.
+// Strings, arrays and dictionaries come out as opaque operands; only numbers,
+// names and operators are read.
+type contentLexer struct {
+	data []byte
+	pos  int
+}
+
+// next returns the next token and whether it is an operator, or false once
+// the stream ends.
+func (l *contentLexer) next() (tok string, isOperator, more bool) {
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		switch {
+		case isPDFSpace(c):
+			l.pos++
+		case c == '%':
+			for l.pos < len(l.data) && l.data[l.pos] != '\n' && l.data[l.pos] != '\r' {
+				l.pos++
+			}
+		case c == '(':
+			l.skipString()
+			return "()", false, true
+		case c == '<' && l.pos+1 < len(l.data) && l.data[l.pos+1] == '<',
+			c == '>' && l.pos+1 < len(l.data) && l.data[l.pos+1] == '>':
+			l.pos += 2
+			return string(c) + string(c), false, true
+		case c == '<':
+			for l.pos < len(l.data) && l.data[l.pos] != '>' {
+				l.pos++
+			}
+			l.pos++
+			return "<>", false, true
+		case c == '[' || c == ']' || c == '{' || c == '}' || c == ')' || c == '>':
+			l.pos++
+			return string(c), false, true
+		default:
+			start := l.pos
+			l.pos++
+			for l.pos < len(l.data) && !isPDFSpace(l.data[l.pos]) && !isPDFDelimiter(l.data[l.pos]) {
+				l.pos++
+			}
+			tok := string(l.data[start:l.pos])
+			switch {
+			case c == '/', c == '+', c == '-', c == '.', c >= '0' && c <= '9':
+				return tok, false, true
+			case tok == "true", tok == "false", tok == "null":
+				return tok, false, true
+			}
+			return tok, true, true
+		}
+	}
+	return "", false, false
+}
+
+// skipString moves past the literal string starting at l.pos, with its
+// nested parentheses and backslash escapes.
+func (l *contentLexer) skipString() {
+	depth := 0
+	for ; l.pos < len(l.data); l.pos++ {
+		switch l.data[l.pos] {
+		case '\\':
+			l.pos++
+		case '(':
+			depth++
+		case ')':
+			if depth--; depth == 0 {
+				l.pos++
+				return
+			}
+		}
+	}
+}
+
+// isPDFSpace reports whether c is PDF white space.
+func isPDFSpace(c byte) bool {
+	switch c {
+	case 0, '\t', '\n', '\f', '\r', ' ':
+		return true
+	}
+	return false
+}
+
+// isPDFDelimiter reports whether c ends a regular PDF token.
+func isPDFDelimiter(c byte) bool {
+	switch c {
+	case '(', ')', '<', '>', '[', ']', '{', '}', '/', '%':
+		return true
+	}
+	return false
+}
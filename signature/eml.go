@@ -0,0 +1,189 @@
+package signature
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ExtractFromEML extracts every PDF attachment from a MIME email and runs the
+// pipeline on each one, naming outputs after the attachment files. Successful
+// results are returned in attachment order, together with the joined errors of
+// the attachments that failed.
+func (e *Extractor) ExtractFromEML(ctx context.Context, emlPath string) ([]*Result, error) {
+	tmpDir, err := os.MkdirTemp("", "poc-pdf-eml-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	attachments, err := extractPDFAttachments(emlPath, tmpDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read email: %v", err)
+	}
+	if len(attachments) == 0 {
+		return nil, fmt.Errorf("no PDF attachments found in %s", emlPath)
+	}
+
+	paths := make([]string, len(attachments))
+	for i, a := range attachments {
+		paths[i] = a.Path
+	}
+	results, err := e.ExtractBatch(ctx, paths)
+	if err != nil {
+		return nil, err
+	}
+
+	ordered := make([][]*Result, len(paths))
+	var errs []error
+	for r := range results {
+		if r.Err != nil {
+			errs = append(errs, fmt.Errorf("%s: %v", filepath.Base(r.Path), r.Err))
+			continue
+		}
+		ordered[r.Index] = r.Results
+	}
+	var done []*Result
+	for _, res := range ordered {
+		done = append(done, res...)
+	}
+	return done, errors.Join(errs...)
+}
+
+// attachment is a PDF pulled out of an email and saved to disk.
+type attachment struct {
+	// Name is the attachment's file name as given in the email.
+	Name string
+	// Path is where the decoded PDF was written.
+	Path string
+}
+
+// extractPDFAttachments parses a MIME email and writes every PDF attachment into dir.
+// Non-PDF parts are skipped; nested multiparts (e.g. mixed inside alternative) are walked.
+func extractPDFAttachments(emlPath, dir string) ([]attachment, error) {
+	f, err := os.Open(emlPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	msg, err := mail.ReadMessage(f)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &attachmentWriter{dir: dir, used: map[string]bool{}}
+	header := textproto.MIMEHeader(msg.Header)
+	if err := w.walk(header, msg.Body); err != nil {
+		return nil, err
+	}
+	return w.found, nil
+}
+
+// attachmentWriter accumulates PDFs while walking the MIME tree.
+type attachmentWriter struct {
+	dir string
+	// used holds the file names written so far.
+	used  map[string]bool
+	found []attachment
+}
+
+func (w *attachmentWriter) walk(header textproto.MIMEHeader, body io.Reader) error {
+	mediaType, params, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil {
+		// RFC 2045: a missing or broken Content-Type means text/plain
+		mediaType = "text/plain"
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") {
+		mr := multipart.NewReader(body, params["boundary"])
+		for {
+			part, err := mr.NextRawPart()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if err := w.walk(part.Header, part); err != nil {
+				return err
+			}
+		}
+	}
+
+	name := attachmentFileName(header, params)
+	if mediaType != "application/pdf" && !strings.EqualFold(filepath.Ext(name), ".pdf") {
+		return nil
+	}
+	if name == "" {
+		name = "attachment.pdf"
+	}
+	return w.save(name, decodeTransferEncoding(header, body))
+}
+
+func (w *attachmentWriter) save(name string, r io.Reader) error {
+	// Never trust a path from the email, and keep duplicate names apart:
+	// a.pdf, a_2.pdf, a_3.pdf, skipping any taken by an attachment of that name
+	name = filepath.Base(name)
+	ext := filepath.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	for n := 2; w.used[name]; n++ {
+		name = base + "_" + strconv.Itoa(n) + ext
+	}
+	w.used[name] = true
+
+	path := filepath.Join(w.dir, name)
+	out, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	if _, err := io.Copy(out, r); err != nil {
+		return fmt.Errorf("decoding attachment %s: %v", name, err)
+	}
+	w.found = append(w.found, attachment{Name: name, Path: path})
+	return nil
+}
+
+// attachmentFileName returns the decoded file name from Content-Disposition,
+// falling back to the legacy Content-Type name parameter.
+func attachmentFileName(header textproto.MIMEHeader, typeParams map[string]string) string {
+	name := ""
+	if _, params, err := mime.ParseMediaType(header.Get("Content-Disposition")); err == nil {
+		name = params["filename"]
+	}
+	if name == "" {
+		name = typeParams["name"]
+	}
+
+	// Some clients still send RFC 2047 encoded-words instead of RFC 2231
+	dec := new(mime.WordDecoder)
+	if decoded, err := dec.DecodeHeader(name); err == nil {
+		name = decoded
+	}
+	return name
+}
+
+// decodeTransferEncoding wraps body with a decoder for its Content-Transfer-Encoding.
+func decodeTransferEncoding(header textproto.MIMEHeader, body io.Reader) io.Reader {
+	switch strings.ToLower(strings.TrimSpace(header.Get("Content-Transfer-Encoding"))) {
+	case "base64":
+		return base64.NewDecoder(base64.StdEncoding, body)
+	case "quoted-printable":
+		return quotedprintable.NewReader(body)
+	default:
+		return body
+	}
+}
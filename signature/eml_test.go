@@ -0,0 +1,91 @@
+package signature
+
+import (
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// writeEML writes an email with one part per entry of parts, each a file
+// name and content type, with the part's index as its body.
+func writeEML(t *testing.T, parts [][2]string) string {
+	t.Helper()
+	var b strings.Builder
+	b.WriteString("From: intake@example.com\r\nTo: desk@example.com\r\nSubject: Signed forms\r\n")
+	b.WriteString("MIME-Version: 1.0\r\nContent-Type: multipart/mixed; boundary=\"b1\"\r\n\r\n")
+	b.WriteString("--b1\r\nContent-Type: text/plain\r\n\r\nPlease find the forms attached.\r\n")
+	for i, p := range parts {
+		b.WriteString("--b1\r\nContent-Type: " + p[1] + "\r\n")
+		b.WriteString("Content-Disposition: attachment; filename=\"" + p[0] + "\"\r\n")
+		b.WriteString("Content-Transfer-Encoding: base64\r\n\r\n")
+		b.WriteString(base64.StdEncoding.EncodeToString([]byte("%PDF-1.4 part "+strconv.Itoa(i))) + "\r\n")
+	}
+	b.WriteString("--b1--\r\n")
+	path := filepath.Join(t.TempDir(), "mail.eml")
+	if err := os.WriteFile(path, []byte(b.String()), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestExtractPDFAttachments(t *testing.T) {
+	eml := writeEML(t, [][2]string{
+		{"form.pdf", "application/pdf"},
+		{"photo.jpg", "image/jpeg"},
+	})
+	dir := t.TempDir()
+	got, err := extractPDFAttachments(eml, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].Name != "form.pdf" {
+		t.Fatalf("attachments = %+v, want only form.pdf", got)
+	}
+	data, err := os.ReadFile(got[0].Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "%PDF-1.4 part 0" {
+		t.Errorf("form.pdf holds %q, want the decoded attachment", data)
+	}
+}
+
+func TestExtractPDFAttachmentsDuplicateNames(t *testing.T) {
+	eml := writeEML(t, [][2]string{
+		{"a.pdf", "application/pdf"},
+		{"a.pdf", "application/pdf"},
+		{"a.pdf", "application/pdf"},
+		{"../a_2.pdf", "application/pdf"},
+	})
+	dir := t.TempDir()
+	got, err := extractPDFAttachments(eml, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"a.pdf", "a_2.pdf", "a_3.pdf", "a_2_2.pdf"}
+	if len(got) != len(want) {
+		t.Fatalf("got %d attachments, want %d", len(got), len(want))
+	}
+	for i, a := range got {
+		if a.Name != want[i] || a.Path != filepath.Join(dir, want[i]) {
+			t.Errorf("attachment %d = %+v, want %s in %s", i, a, want[i], dir)
+		}
+		data, err := os.ReadFile(a.Path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if body := "%PDF-1.4 part " + strconv.Itoa(i); string(data) != body {
+			t.Errorf("%s holds %q, want %q", a.Name, data, body)
+		}
+	}
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != len(want) {
+		t.Errorf("%d files written, want %d", len(files), len(want))
+	}
+}
@@ -0,0 +1,63 @@
+package signature
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+)
+
+// Supported values for Options.Encode.
+const (
+	// EncodeBase64 is the output file in standard base64.
+	EncodeBase64 = "base64"
+	// EncodeDataURI is the output file as a data: URI, ready for the src of an
+	// <img> tag.
+	EncodeDataURI = "datauri"
+)
+
+// ValidEncoding reports whether encoding is a known Options.Encode value; ""
+// writes output files as usual.
+func ValidEncoding(encoding string) bool {
+	switch encoding {
+	case "", EncodeBase64, EncodeDataURI:
+		return true
+	}
+	return false
+}
+
+// formatMIMEType is the media type of outputs in format, for data URIs.
+func formatMIMEType(format string) string {
+	switch format {
+	case FormatPNG, FormatAVIF, FormatWebP, FormatTIFF, FormatJPEG:
+		return "image/" + format
+	case FormatSVG:
+		return "image/svg+xml"
+	case FormatPSD:
+		return "image/vnd.adobe.photoshop"
+	case FormatStrokes:
+		return "application/json"
+	}
+	return "application/octet-stream"
+}
+
+// encodeOutput replaces the output file of res with Result.Encoded, in
+// Options.Encode, and clears its OutputPath. The file is written first like
+// any other, since some formats are encoded by external tools.
+func (e *Extractor) encodeOutput(res *Result) error {
+	if e.opts.Encode == "" || res.OutputPath == "" {
+		return nil
+	}
+	data, err := os.ReadFile(res.OutputPath)
+	if err != nil {
+		return err
+	}
+	res.Encoded = base64.StdEncoding.EncodeToString(data)
+	if e.opts.Encode == EncodeDataURI {
+		res.Encoded = fmt.Sprintf("data:%s;base64,%s", formatMIMEType(e.opts.Format), res.Encoded)
+	}
+	if err := os.Remove(res.OutputPath); err != nil {
+		return fmt.Errorf("failed to remove encoded output: %v", err)
+	}
+	res.OutputPath = ""
+	return nil
+}
@@ -0,0 +1,1719 @@
+package signature
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"log/slog"
+	"maps"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+	"gocv.io/x/gocv"
+)
+
+// ErrNoSignature reports that no ink region was found on a page, or on any page of a document.
+var ErrNoSignature = errors.New("no contours found - cannot find signature")
+
+// errCutOff rejects a region touching the page edge under Options.Strict;
+// extractPage skips it and keeps the page's other regions.
+var errCutOff = errors.New("touches the page edge and may be cut off")
+
+// DefaultDPI keeps small signatures sharp; pdftoppm's own default of 150 blurs them.
+const DefaultDPI = 300
+
+// inkThreshold is the grayscale level below which a pixel is considered ink.
+const inkThreshold = 200
+
+// minSignatureSide is the shortest a signature's longer side may be, in pixels at
+// thresholdReferenceDPI (about 3mm); smaller ink is dust or a stray mark.
+const minSignatureSide = 18
+
+// DefaultWhiteThreshold is the per-channel level above which a pixel counts as
+// background when the transparent output is made (see Options.WhiteThreshold).
+const DefaultWhiteThreshold = 200
+
+// Result describes one extracted signature and where it was found.
+type Result struct {
+	// Source is the PDF the signature was extracted from.
+	Source string
+	// Page is the 1-based page number within Source.
+	Page int
+	// Region is the 1-based number of the signature on its page with
+	// Options.AllRegions, in reading order, and 0 otherwise.
+	Region int
+	// PagePath is the rendered page image the signature was cropped from. It
+	// lives in the document's temporary directory, which is removed when the
+	// document is done unless Options.KeepTemp is set.
+	PagePath string
+	// DPI is the resolution the page was rendered at for detection.
+	DPI float64
+	// OutputDPI is the resolution of the render the output crop was taken from.
+	OutputDPI float64
+	// Rotation is 180 when the page was detected (or assumed) upside down and
+	// turned over before extraction, 0 otherwise.
+	Rotation int
+	// Skew is the tilt in degrees the page was levelled by with Options.Deskew,
+	// positive when its lines ran down to the right; 0 when it was not.
+	Skew float64
+	// Bounds is the signature region in page pixels (origin top-left) of the
+	// page as rendered, i.e. turned by PageRotate but before any Rotation, Skew
+	// or perspective correction.
+	Bounds image.Rectangle
+	// PDFBounds is Bounds converted to PDF user space (points, origin bottom-left),
+	// the space stamps and annotations are placed in.
+	PDFBounds PDFRect
+	// PageBox is the area of the page that was rendered, in PDF user space: the
+	// CropBox with the poppler rasterizer. PageRotate is the page's /Rotate in
+	// degrees clockwise, which the render (and so Bounds) is turned by but
+	// PDFBounds is not.
+	PageBox    PDFRect
+	PageRotate int
+	// Confidence scores how signature-like the region is, in [0, 1] (see
+	// SignatureRegion.Confidence); ConfidenceFactors are its sub-scores.
+	Confidence        float64
+	ConfidenceFactors ConfidenceFactors
+	// SignatureType is "wet" for wet-ink, "electronic" for a signature typed in a
+	// script font, or "unknown" when the strokes are inconclusive.
+	SignatureType string
+	// Quality rates the capture of the signature (see Quality); nil for seals
+	// and marks.
+	Quality *Quality
+	// Class is ClassHandwritten, ClassStamp or ClassPrintedText; empty for
+	// seals and marks.
+	Class string
+	// SignerName and SignerDate are the printed name and the date read next to
+	// the signature with Options.SignerOCR, empty when none was recognized;
+	// SignerText holds every line of text read there.
+	SignerName string
+	SignerDate string
+	SignerText []string
+	// EdgeTouch is set when Bounds abuts the page border, which usually means the
+	// signature was cut off during scanning.
+	EdgeTouch bool
+	// FormField is the fully qualified name of the AcroForm signature field the
+	// signature was cropped from; empty when it was found by contour detection.
+	FormField string
+	// Zone is the name of the Options.Template zone the signature was cropped
+	// from, which also names its outputs; Template is the Template.Name.
+	Zone     string
+	Template string
+	// Seal is the 1-based number of the seal on its page, in reading order,
+	// when the result is a round seal found with Options.Seals rather than a
+	// signature, and 0 otherwise. SealColor is its ink, SealRed or SealBlue.
+	Seal      int
+	SealColor string
+	// Mark is MarkCheckbox or MarkInitials when the result is the crop of a
+	// marked box, found with Options.Marks or given as a Template zone of that
+	// kind, rather than a signature; Box is its number in the page's
+	// MarkReport. For a mark Confidence is 0.5 at the least ink that counts as
+	// marked and 1 from twice that.
+	Mark string
+	Box  int
+	// AlignAngle is the counter-clockwise rotation in degrees applied by
+	// Options.PCAAlign or Options.Straighten.
+	AlignAngle float64
+	// WidthMM and HeightMM are the physical size of the signature on the page.
+	WidthMM, HeightMM float64
+	// PrintDPI, when non-zero, is the DPI the output was resampled for so that it
+	// prints at WidthMM x HeightMM.
+	PrintDPI float64
+	// Baseline is the y coordinate (in output crop pixels) where the pen rests,
+	// set when Options.DetectBaseline found one; -1 otherwise.
+	Baseline int
+	// OutputPath is where the transparent signature PNG was written, or the
+	// page's preview image with Options.DryRun; empty with Options.Encode.
+	OutputPath string
+	// MetadataPath is where the JSON description was written with
+	// Options.Metadata, or the page's Preview listing with Options.DryRun.
+	MetadataPath string
+	// Image is the final transparent signature; nil with Options.DryRun.
+	Image *image.RGBA
+	// Hash is the InkHash of Image, empty when there is none.
+	Hash string
+	// DuplicateOf is the OutputPath of the earlier signature of the run that
+	// this one looks the same as with Options.Dedup, empty otherwise.
+	DuplicateOf string
+	// Encoded is the output file in Options.Encode, which replaces the file.
+	Encoded string
+	// DigitalSignature is the digital signature of the form field the
+	// signature was cropped from, or whose widget it overlaps, with
+	// Options.DigitalSignatures; nil otherwise.
+	DigitalSignature *DigitalSignature
+}
+
+// convertPDFToPNG uses pdftoppm CLI to convert one page (1-based) of a PDF to a PNG file.
+// Output is saved as {outPrefix}.png, outPrefix being a path without extension.
+// When crop is non-empty only that pixel region of the page is rasterized.
+func convertPDFToPNG(ctx context.Context, limits processLimits, pdfPath string, page int, outPrefix string, dpi float64, crop image.Rectangle, password string) (string, error) {
+	// Example: pdftoppm -png -singlefile -cropbox -f 2 -l 2 -r 300 [-x 10 -y 20 -W 300 -H 100] input.pdf /tmp/dir/output
+	prefix := outPrefix
+	resolution := strconv.FormatFloat(dpi, 'f', -1, 64)
+	p := strconv.Itoa(page)
+	// -cropbox renders what a viewer shows rather than the whole MediaBox
+	args := []string{"-png", "-singlefile", "-cropbox", "-f", p, "-l", p, "-r", resolution}
+	if !crop.Empty() {
+		args = append(args,
+			"-x", strconv.Itoa(crop.Min.X), "-y", strconv.Itoa(crop.Min.Y),
+			"-W", strconv.Itoa(crop.Dx()), "-H", strconv.Itoa(crop.Dy()))
+	}
+	args = append(args, pdfPath, prefix)
+	if _, err := runPoppler(ctx, limits, "pdftoppm", password, args...); err != nil {
+		return "", err
+	}
+
+	// The resulting file will be something like outputPrefix.png
+	outputFile := prefix + ".png"
+	return outputFile, nil
+}
+
+// SignatureRegion is one ink region found on a page.
+type SignatureRegion struct {
+	// Bounds is the region in pixels of the image it was found in.
+	Bounds image.Rectangle
+	// Image is a BGR copy of the region; the caller must Close it.
+	Image gocv.Mat
+	// Ink is the bounding box of the ink itself; Bounds adds the crop padding.
+	Ink image.Rectangle
+	// Field is the AcroForm signature field the region was cropped from, if any.
+	Field string
+	// Zone is the Options.Template zone the region was cropped from, if any.
+	Zone string
+	// Confidence scores how signature-like the region is, in [0, 1]: the
+	// Factors' Score, or the model score with DetectorONNX.
+	Confidence float64
+	// Factors are the sub-scores of Confidence; zero with DetectorONNX.
+	Factors ConfidenceFactors
+	// Class is what made the ink (see classifyRegion), e.g. ClassHandwritten.
+	Class string
+}
+
+// detectParams configures extractSignature.
+type detectParams struct {
+	// key, when non-nil, whitens the keyed paper color first, so tinted forms
+	// behave like white paper.
+	key *chromaKey
+	// seals, when non-nil, are whitened after the key (see whitenSeals), so
+	// they are neither taken for the signature nor merged with it.
+	seals []sealCircle
+	// dpi is the resolution of the image; regions too small to be a signature
+	// at that resolution are ignored.
+	dpi float64
+	// all returns every region that passes the size and aspect filters, in
+	// reading order (see allRegions), instead of only the largest.
+	all bool
+	// binarization is the Options.Binarization method separating ink from paper.
+	binarization string
+	// colorInk adds strongly colored pixels to the mask (see Options.ColorInk).
+	colorInk bool
+	// noShapeFilter keeps candidates that look like printed text or solid graphics.
+	noShapeFilter bool
+	// classes, when non-nil, drops candidates of the other classes before the
+	// signature is picked (see Options.Classes).
+	classes []string
+	// mergeGap, when positive, groups contours within this many pixels of each
+	// other into one region (see mergeNearby) before they are filtered.
+	mergeGap int
+	// removeRules erases long horizontal and vertical lines from the mask (see
+	// removeRules) before contours are found.
+	removeRules bool
+	// medianBlur, when 3 or more, median-filters the grayscale page with this
+	// odd kernel size before thresholding (see medianDenoise).
+	medianBlur int
+	// areas, when non-nil, restricts the search to regions centred in one of
+	// them, excluding the anchor labels themselves (see inSearchAreas).
+	areas, labels []image.Rectangle
+	// debug, when non-nil, receives the page, grayscale, mask and contour images.
+	debug *debugDump
+	// padding grows each crop by this many pixels on every side, within the image.
+	padding int
+	// logger, when non-nil, receives the detection decisions at debug level.
+	logger *slog.Logger
+}
+
+// extractSignature loads an image via gocv, thresholds it, finds the largest contour,
+// crops it, and returns it as a region holding the crop and its bounding box in
+// page pixels (or several regions; see detectParams). It also returns the
+// binarization method used, which auto resolves from the page histogram.
+func extractSignature(imgPath string, params detectParams) ([]SignatureRegion, string, error) {
+	// Read image in color
+	img, err := readImage(imgPath, gocv.IMReadColor)
+	if err != nil {
+		return nil, "", err
+	}
+	defer img.Close()
+	if err := params.debug.write(debugStagePage, "page", img); err != nil {
+		return nil, "", err
+	}
+
+	if params.key != nil {
+		params.key.whiten(&img)
+	}
+	whitenSeals(&img, params.seals)
+
+	// Convert to grayscale
+	gray := pageBuffers.get()
+	defer pageBuffers.put(gray)
+	gocv.CvtColor(img, &gray, gocv.ColorBGRToGray)
+	medianDenoise(&gray, params.medianBlur)
+	if err := params.debug.write(debugStageGray, "gray", gray); err != nil {
+		return nil, "", err
+	}
+
+	// Threshold: convert signature (dark) to white, background (light) to black,
+	// with the cutoff chosen by the binarization method
+	bin, method, cutoff := binarize(gray, params.binarization, params.dpi)
+	defer pageBuffers.put(bin)
+	debugLog(params.logger, "binarized page", "method", method, "cutoff", cutoff)
+	if params.colorInk {
+		if err := addColorInk(img, &bin); err != nil {
+			return nil, method, fmt.Errorf("failed to segment colored ink: %v", err)
+		}
+	}
+	if params.removeRules {
+		// Signing lines and form boxes would otherwise merge with the signature
+		removed := removeRules(&bin, params.dpi)
+		removed.Close()
+	}
+	if err := params.debug.write(debugStageBinary, "binary", bin); err != nil {
+		return nil, method, err
+	}
+
+	// Find external contours
+	contours := gocv.FindContours(bin, gocv.RetrievalExternal, gocv.ChainApproxSimple)
+	defer contours.Close()
+
+	// If there are no contours, we can't find a signature
+	if contours.Size() == 0 {
+		return nil, method, ErrNoSignature
+	}
+
+	// Collect bounding rectangles, ignoring specks
+	var sized, rects []image.Rectangle
+	minSide := scaleLength(minSignatureSide, params.dpi)
+
+	// Iterate over the contours in the PointsVector
+	boxes := make([]image.Rectangle, contours.Size())
+	for i := range boxes {
+		c := contours.At(i)             // c is of type gocv.Points
+		boxes[i] = gocv.BoundingRect(c) // bounding box of this contour
+	}
+	// Fragmented strokes: group them before specks are dropped, since each
+	// fragment alone may be speck-sized
+	if params.mergeGap > 0 {
+		boxes = mergeNearby(boxes, params.mergeGap)
+	}
+	for _, rect := range boxes {
+		if max(rect.Dx(), rect.Dy()) < minSide {
+			continue
+		}
+		sized = append(sized, rect)
+		if params.areas != nil && !inSearchAreas(rect, params.areas, params.labels) {
+			continue
+		}
+		rects = append(rects, rect)
+	}
+
+	// Drop text blocks and solid graphics before ranking by size
+	var rejected int
+	candidates := len(rects)
+	if !params.noShapeFilter {
+		// Classes are judged on every plausible candidate, not only the largest
+		rects = plausibleCandidates(bin, rects, params.dpi, !params.all && params.classes == nil)
+		if len(rects) == 0 {
+			rejected = candidates
+		}
+	}
+	debugLog(params.logger, "filtered contours", "contours", contours.Size(), "merged", len(boxes), "signature_sized", len(sized),
+		"in_search_areas", candidates, "plausible", len(rects))
+
+	// Facsimile stamps and typed names: keep the wanted classes before ranking
+	imgRect := image.Rect(0, 0, img.Cols(), img.Rows())
+	classes := map[image.Rectangle]string{}
+	var unwanted int
+	if params.classes != nil {
+		var kept []image.Rectangle
+		for _, rect := range rects {
+			crop := img.Region(rect.Inset(-params.padding).Intersect(imgRect))
+			classes[rect] = classifyRegion(crop)
+			crop.Close()
+			if keepClass(params.classes, classes[rect]) {
+				kept = append(kept, rect)
+			}
+		}
+		unwanted = len(rects) - len(kept)
+		debugLog(params.logger, "classified candidates", "candidates", len(rects), "kept", len(kept))
+		rects = kept
+	}
+
+	if params.all {
+		rects = allRegions(rects)
+	} else if len(rects) > 0 {
+		rects = []image.Rectangle{largestRect(rects)}
+	}
+	// Drawn before giving up, since a page where nothing was kept is when the boxes help most
+	if err := params.debug.contours(img, sized, params.areas, rects); err != nil {
+		return nil, method, err
+	}
+	if rejected > 0 {
+		return nil, method, fmt.Errorf("%w: all %d candidate regions look like printed text or graphics", ErrNoSignature, rejected)
+	}
+	if unwanted > 0 && len(rects) == 0 {
+		return nil, method, fmt.Errorf("%w: all %d candidate regions are of classes other than %s", ErrNoSignature, unwanted, strings.Join(params.classes, ", "))
+	}
+	if len(rects) == 0 {
+		return nil, method, ErrNoSignature
+	}
+	debugLog(params.logger, "selected regions", "regions", fmt.Sprint(rects))
+
+	// Crop each region from the original color image (img), padded so strokes
+	// ending on the bounding box keep their anti-aliased edge
+	regions := make([]SignatureRegion, len(rects))
+	for i, rect := range rects {
+		padded := rect.Inset(-params.padding).Intersect(imgRect)
+		signature := img.Region(padded)
+		// Keep a copy so we can safely Close() signature
+		factors := regionConfidence(bin, rect, imgRect.Size())
+		class, ok := classes[rect]
+		if !ok {
+			class = classifyRegion(signature)
+		}
+		regions[i] = SignatureRegion{Bounds: padded, Image: signature.Clone(), Ink: rect, Confidence: factors.Score(), Factors: factors, Class: class}
+		signature.Close()
+	}
+	return regions, method, nil
+}
+
+// removeWhiteBackground converts near-white pixels (every channel above threshold)
+// to transparent (alpha=0) and keeps signature pixels opaque. It works on the raw
+// pixel bytes rather than per-pixel Mat accessors, which cross into C twice per
+// pixel and took seconds on full 300 DPI pages.
+func removeWhiteBackground(input gocv.Mat, threshold uint8) (*image.RGBA, error) {
+	// input is a BGR image (3 channels).
+	data, err := bgrPixels(input)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create a new RGBA image in Go
+	output := image.NewRGBA(image.Rect(0, 0, input.Cols(), input.Rows()))
+	pix := output.Pix
+
+	// For each pixel, if it's near white => make alpha=0, else alpha=255
+	for i, j := 0, 0; i < len(data); i, j = i+3, j+4 {
+		// data[i] = Blue, data[i+1] = Green, data[i+2] = Red
+		b, g, r := data[i], data[i+1], data[i+2]
+
+		// Simple "near-white" threshold
+		if r > threshold && g > threshold && b > threshold {
+			// transparent
+			pix[j], pix[j+1], pix[j+2], pix[j+3] = 255, 255, 255, 0
+		} else {
+			// opaque
+			pix[j], pix[j+1], pix[j+2], pix[j+3] = r, g, b, 255
+		}
+	}
+
+	return output, nil
+}
+
+// bgrPixels returns the bytes of an 8-bit BGR Mat, three per pixel, row by row.
+// Continuous Mats are read in place; others (such as ROIs of a larger image)
+// are copied first, so the result is only valid while input is open.
+func bgrPixels(input gocv.Mat) ([]byte, error) {
+	if input.Channels() != 3 || input.Type() != gocv.MatTypeCV8UC3 {
+		return nil, fmt.Errorf("expected 3-channel BGR image")
+	}
+	if !input.IsContinuous() {
+		continuous := input.Clone()
+		defer continuous.Close()
+		return continuous.ToBytes(), nil
+	}
+	return input.DataPtrUint8()
+}
+
+// Options holds the tunables shared by every document an Extractor processes.
+type Options struct {
+	// RenderDPI is the resolution used to render PDF pages for detection.
+	RenderDPI float64
+	// OutputDPI is the resolution of the page render the final crop is taken from.
+	OutputDPI float64
+	// DetectWidth, when non-zero, detects on a copy of each page render shrunk
+	// to at most this many pixels wide, lowering RenderDPI to match, while the
+	// output is still cropped from the render at OutputDPI. Contour detection
+	// at print resolution is slow and memory heavy; the region found on the
+	// copy is scaled back like any other.
+	DetectWidth int
+	// Sweep lists debug thresholds for the animated comparison; nil disables it.
+	Sweep []uint8
+	// EdgeMargin is the distance in pixels from the page border that counts as touching it.
+	EdgeMargin int
+	// Format is the output encoding, one of the Format* constants (see ValidFormat).
+	Format string
+	// Quality is the 0-100 encoder quality for lossy formats.
+	Quality int
+	// MinPagePt and MaxPagePt bound the accepted page width and height in points.
+	MinPagePt, MaxPagePt float64
+	// MaxRenderPx caps the longest side of a rendered page; the DPI is lowered to fit.
+	MaxRenderPx int
+	// MaxRenderBytes, when non-zero, caps the size of each page render file;
+	// a larger one fails the document with ErrResourceLimit. On Linux the
+	// rasterizer subprocess is also stopped as soon as it writes past it.
+	MaxRenderBytes int64
+	// MaxRasterizerMemory, when non-zero, caps the address space of each
+	// rasterizer subprocess in bytes (RLIMIT_AS, Linux only); a page that needs
+	// more fails with ErrResourceLimit. The in-process fitz backend is not capped.
+	MaxRasterizerMemory int64
+	// DocumentTimeout, when non-zero, bounds the processing of one document;
+	// past it the running subprocesses are killed and the document fails with
+	// ErrResourceLimit.
+	DocumentTimeout time.Duration
+	// ChromaKey is "", "auto" or a #RRGGBB paper color removed like white.
+	ChromaKey string
+	// Rasterizer picks the PDF rendering backend (see RasterizerAuto); "" means auto.
+	Rasterizer string
+	// Password opens encrypted PDFs; without it (or with a wrong one) they fail
+	// with ErrEncrypted. Every rasterizer but RasterizerFitz supports it.
+	Password string
+	// Pages selects the pages to process; nil processes every page.
+	Pages PageSelection
+	// ROI, when set, limits rendering to this region of the page in PDF points.
+	ROI *PDFRect
+	// Buckets, when set, sorts outputs into high/medium/low folders by confidence.
+	Buckets *ConfidenceBuckets
+	// Palette, when non-zero, quantizes PNG output to an indexed image of at most
+	// this many colors, one of them transparent.
+	Palette int
+	// OutputDir is where outputs are written; empty means the current directory.
+	OutputDir string
+	// OnResult, when set, is called with every result as soon as its outputs are
+	// written, in the order of the results, from the encoding stage of the
+	// pipeline; an error fails that document.
+	OnResult func(ctx context.Context, res *Result) error `json:"-"`
+	// OnStage, when set, is called as each timed pipeline stage (see
+	// StageRasterize) of a page or region ends, with its duration and error.
+	// It may be called from several goroutines at once, since the stages of a
+	// document overlap (see PipelineDepth) and a batch runs several documents.
+	OnStage func(stage string, d time.Duration, err error) `json:"-"`
+	// Logf, when set, receives progress messages; nil keeps the extractor quiet.
+	Logf func(format string, args ...any) `json:"-"`
+	// Logger, when set, replaces Logf: progress is logged at info level,
+	// warnings at warn level, and stage timings and detection decisions
+	// (binarization cutoff, contour counts, selected region) at debug level.
+	Logger *slog.Logger `json:"-"`
+	// TracerProvider, when set, creates an OpenTelemetry span per document
+	// and per stage (see StageRasterize); nil uses the global provider of
+	// go.opentelemetry.io/otel, a no-op unless the program installs one.
+	TracerProvider trace.TracerProvider `json:"-"`
+	// Workers is how many documents are processed concurrently in a batch.
+	Workers int
+	// Cache, when set, stores the outputs of every document under the SHA-256
+	// of its bytes and of these options, and restores them when the same
+	// document comes again instead of processing it (see DirCache).
+	Cache Cache `json:"-"`
+	// PipelineDepth bounds the stages of one document, which run concurrently:
+	// up to this many pages are rendered ahead of detection, and this many
+	// outputs wait for encoding behind it. 0 means DefaultPipelineDepth; 1
+	// still overlaps the stages, by one page or output each.
+	PipelineDepth int
+	// Strict skips detections that touch the page edge instead of only flagging them.
+	Strict bool
+	// AutoOrient detects upside-down pages and turns them over before extraction.
+	AutoOrient bool
+	// AssumeUpsideDown turns every page over without running detection.
+	AssumeUpsideDown bool
+	// PCAAlign rotates the crop so the principal axis of its ink is horizontal.
+	PCAAlign bool
+	// Straighten rotates the crop so the long side of the minimum-area
+	// rectangle around its ink is horizontal; exclusive with PCAAlign.
+	Straighten bool
+	// Deskew measures the tilt of a scanned page from its text lines and levels
+	// the page before detection. Pages with signature form fields are left as
+	// they are, since the fields are placed in the unrotated page.
+	Deskew bool
+	// Decontaminate unmixes the paper color from anti-aliased edge pixels.
+	Decontaminate bool
+	// PrintDPI, when non-zero, resamples the output so it prints at its physical size at this DPI.
+	PrintDPI float64
+	// DetectBaseline reports the baseline and writes crops above and below it.
+	DetectBaseline bool
+	// Binarization is how ink is separated from paper for detection: auto
+	// (the default for ""), fixed, otsu or adaptive; see BinarizeAuto.
+	Binarization string
+	// ColorInk also counts strongly colored pixels as ink, measured as their
+	// chroma in Lab space, however light they are: in detection, in form
+	// field, zone and box ink checks, and in the output, where they stay
+	// opaque. Blue and purple ballpoint strokes lighter than the gray cutoff
+	// are then kept. Tinted paper needs ChromaKey with it.
+	ColorInk bool
+	// NoShapeFilter disables the filters that reject candidate regions shaped like
+	// printed text or solid graphics (see plausibleSignature).
+	NoShapeFilter bool
+	// Classes, when non-nil, keeps only the regions of these classes (see
+	// ClassHandwritten); with ClassHandwritten alone, facsimile stamps and typed
+	// names are skipped and the signature is picked among the rest. Every
+	// region is classified either way, as Result.Class.
+	Classes []string
+	// Detector finds the signature region: DetectorContours (the default for
+	// "") or DetectorONNX, which needs DetectorModel.
+	Detector string
+	// DetectorModel is the ONNX object-detection model used by DetectorONNX.
+	DetectorModel string
+	// MinDetectorScore is the model score below which DetectorONNX boxes are
+	// discarded; 0 means DefaultMinDetectorScore.
+	MinDetectorScore float64
+	// MergeGapPx groups ink contours within this many pixels (at RenderDPI) of
+	// each other into one region, so a light-pressure signature that binarizes
+	// into separate loops is found whole. 0 keeps every contour separate.
+	MergeGapPx int
+	// RemoveLines erases printed signing lines and form box edges, both from the
+	// mask searched for the signature and from the output, repairing the strokes
+	// that crossed them.
+	RemoveLines bool
+	// FlattenIllumination divides every page render by an estimate of its paper
+	// brightness before anything else looks at it, evening out the shadows and
+	// gradients of phone photos (see flattenIllumination).
+	FlattenIllumination bool
+	// CorrectPerspective finds the outline of a document photographed at an
+	// angle (the largest four-cornered contour covering a good part of the
+	// page) and warps every render flat along it before detection, so the
+	// keystoned page crops like a scan (see documentOutline). Pages with an
+	// ROI, signature form fields or template zones are left as they are,
+	// since those are placed in the unwarped page.
+	CorrectPerspective bool
+	// MedianBlur, when non-zero, median-filters the page with this odd kernel
+	// size (in pixels at RenderDPI) before it is thresholded for detection, so
+	// scanner dust and JPEG artifacts don't form candidate regions.
+	MedianBlur int
+	// DespeckleArea, when non-zero, makes every group of connected opaque pixels
+	// smaller than this many pixels transparent in the outputs.
+	DespeckleArea int
+	// NoFormFields ignores the PDF's AcroForm signature fields. Otherwise a page
+	// with signature fields is cropped to them instead of searched for ink.
+	NoFormFields bool
+	// EmbeddedScans decodes a page that is nothing but one scanned image (a
+	// JPEG or lossless image covering the page, optionally under invisible OCR
+	// text) straight from the PDF instead of rasterizing it, so its pixels are
+	// the scanner's. Such pages are processed at the image's own resolution
+	// rather than RenderDPI and OutputDPI; all other pages are rasterized.
+	EmbeddedScans bool
+	// DigitalSignatures also reads the PDF's digital signatures (see
+	// DigitalSignatures), logs each with its signer, signing time and whether
+	// it is intact, and attaches every one to the result cropped from its
+	// field (see Result.DigitalSignature).
+	DigitalSignatures bool
+	// Template, when set, crops its named zones from every document instead of
+	// detecting signatures or reading form fields. Only the pages the zones are
+	// on are processed (Pages is ignored), blank zones are skipped, and each
+	// output is named after its zone.
+	Template *Template
+	// Seals also extracts the round red and blue company seals of each page,
+	// found by color and the Hough circle transform, as transparent
+	// seal_{n}.png files cut from the detection render; signatures are then
+	// detected with the seals' ink left out. It is ignored with DryRun.
+	Seals bool
+	// Marks also looks for the checkboxes and initials boxes printed on each
+	// page, judges from the ink inside each whether it was marked, and writes
+	// a crop of every marked one named {kind}_{n} and a {prefix}_marks.json
+	// MarkReport listing them all. Pages with Template zones of a mark kind
+	// are judged on those zones instead. It is ignored with DryRun.
+	Marks bool
+	// Templates, when set and Template is not, is a registry of templates
+	// (see LoadTemplates): each document is cropped with the template whose
+	// Match it fits, and a document that fits none is detected as usual.
+	Templates []*Template
+	// SoftAlpha derives each pixel's alpha from its darkness instead of cutting
+	// the background to fully transparent and the ink to fully opaque.
+	SoftAlpha bool
+	// AlphaGamma shapes the soft alpha curve; 0 means DefaultAlphaGamma.
+	AlphaGamma float64
+	// WhiteThreshold is the per-channel level above which a crop pixel becomes
+	// transparent paper; raise it to keep light pencil strokes. 0 means
+	// DefaultWhiteThreshold.
+	WhiteThreshold int
+	// CropPaddingPx grows the crop by this many pixels of the detection render
+	// on every side, so strokes touching the ink's bounding box aren't clipped.
+	CropPaddingPx int
+	// InkColor recolors the opaque pixels of the output while keeping their
+	// alpha: InkOriginal (the default for "") keeps the scanned color, InkBlack
+	// or a #RRGGBB color makes every signature uniform.
+	InkColor string
+	// Anchors lists printed labels (e.g. "Signature:") found by OCR; when any is on
+	// the page, only the area to the right of and below it is searched.
+	Anchors []string
+	// OCRLanguage is the Tesseract language for Anchors, template fingerprints
+	// and SignerOCR; "" means DefaultOCRLanguage.
+	OCRLanguage string
+	// SignerOCR reads the text around each signature with Tesseract and reports
+	// the signer's printed name and the date found in it (see Result.SignerName).
+	SignerOCR bool
+	// SignerArea is the neighbourhood SignerOCR reads; nil means
+	// DefaultSignerArea.
+	SignerArea *SignerArea
+	// Trim cuts the output to the bounding box of its visible pixels, dropping
+	// the paper margin and CropPaddingPx.
+	Trim bool
+	// Canvas, when non-zero, trims the output and scales it to fit a transparent
+	// canvas of this many pixels, keeping CanvasPadding pixels free on every
+	// side, so every output has the same size.
+	Canvas image.Point
+	// CanvasPadding is the margin kept free on the Canvas.
+	CanvasPadding int
+	// CanvasAlign positions the signature on the Canvas: AlignCenter (the
+	// default for ""), an edge such as AlignBottom, or a corner such as
+	// "bottom-left".
+	CanvasAlign string
+	// Metadata writes a {name}.meta.json file next to each output describing
+	// the result (see Result.Metadata).
+	Metadata bool
+	// Dedup is DedupOff, DedupLink or DedupSkip: what to do with a signature
+	// whose Result.Hash is within DedupDistance bits of one delivered before by
+	// the same Extractor, e.g. another scan of the same page in a batch.
+	Dedup string
+	// DedupDistance is how many bits of two hashes may differ for Dedup; 0 means
+	// DefaultDedupDistance.
+	DedupDistance int
+	// Encode, when EncodeBase64 or EncodeDataURI, hands each output to the
+	// caller as Result.Encoded instead of leaving it in OutputDir.
+	Encode string
+	// DebugDir, when set, receives the intermediate images of each page, numbered
+	// by stage: the render, grayscale, binary mask, candidate boxes and the crop
+	// before its background is removed.
+	DebugDir string
+	// KeepTemp leaves each document's temporary directory of page renders in
+	// place instead of removing it when the document is done, and logs where
+	// it is, for inspecting the renders. The directories are created below
+	// os.TempDir, i.e. $TMPDIR on Unix.
+	KeepTemp bool
+	// AllRegions returns every ink region passing the size and aspect filters
+	// instead of only the largest, for forms with several signers on one page.
+	AllRegions bool
+	// DryRun detects the signatures of each page but writes no crops: only a
+	// {prefix}_preview.png of the detection render with every candidate region
+	// boxed by confidence bucket (Buckets, or the defaults): green high, orange
+	// medium, red low; and a {prefix}_preview.json Preview listing them. The results keep the bounds
+	// and confidence of the regions a real run would extract, without images.
+	DryRun bool
+}
+
+// outputName returns name, prefixed with prefix when one is set, so outputs for
+// several documents in one run don't overwrite each other.
+func outputName(prefix, name string) string {
+	if prefix == "" {
+		return name
+	}
+	return prefix + "_" + name
+}
+
+// tempDir creates a directory for the intermediate files of one document,
+// named after kind, below os.TempDir (which honours TMPDIR). The returned
+// cleanup removes it, or with Options.KeepTemp logs where it was kept; call it
+// whether or not the document succeeded.
+func (e *Extractor) tempDir(kind string) (string, func(), error) {
+	dir, err := os.MkdirTemp("", "poc-pdf-"+kind+"-")
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create temporary directory: %v", err)
+	}
+	cleanup := func() {
+		if e.opts.KeepTemp {
+			e.logf("Temporary files kept in %s", dir)
+			return
+		}
+		if err := os.RemoveAll(dir); err != nil {
+			e.warnf("Could not remove temporary files: %v", err)
+		}
+	}
+	return dir, cleanup, nil
+}
+
+// extract runs the pipeline on every selected page of one PDF (see Options.Pages)
+// and returns a result per page with a signature (per region with
+// Options.AllRegions); pages without ink are skipped.
+// Outputs of multi-page documents carry a p{N} page prefix after outPrefix, so
+// signatures on different pages don't overwrite each other. It fails with
+// ErrNoSignature when no page has one, and on the first page that fails for any
+// other reason. What else is learnt about the document goes into stats.
+func (e *Extractor) extract(ctx context.Context, pdfPath, outPrefix string, stats *documentStats) ([]*Result, error) {
+	ctx, span := e.startDocumentSpan(ctx, pdfPath)
+	ctx, cancel := e.documentContext(ctx)
+	defer cancel()
+	results, err := e.cachedDocument(ctx, pdfPath, outPrefix, stats)
+	results, err = e.withoutDuplicates(results), documentError(ctx, err)
+	span.SetAttributes(attrSignatures.Int(len(results)))
+	endSpan(span, err)
+	return results, err
+}
+
+// documentStats is what extract reports about a document besides its results.
+type documentStats struct {
+	// pages is how many pages were run through the pipeline.
+	pages int
+}
+
+// documentContext bounds ctx by Options.DocumentTimeout, if set.
+func (e *Extractor) documentContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if e.opts.DocumentTimeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeoutCause(ctx, e.opts.DocumentTimeout,
+		fmt.Errorf("%w: the document took longer than %v", ErrResourceLimit, e.opts.DocumentTimeout))
+}
+
+// documentError replaces an error caused by the expiry of a documentContext
+// with that cause, so it reads as ErrResourceLimit rather than a deadline.
+func documentError(ctx context.Context, err error) error {
+	if cause := context.Cause(ctx); err != nil && errors.Is(cause, ErrResourceLimit) && !errors.Is(err, ErrResourceLimit) {
+		return cause
+	}
+	return err
+}
+
+// extractDocument is extract within the document's deadline.
+func (e *Extractor) extractDocument(ctx context.Context, pdfPath, outPrefix string, stats *documentStats) ([]*Result, error) {
+	raster, err := e.rasterizerFor(pdfPath)
+	if err != nil {
+		return nil, err
+	}
+	_, isImage := raster.(imageRasterizer)
+	if e.opts.DebugDir != "" {
+		if err := os.MkdirAll(e.opts.DebugDir, 0o755); err != nil {
+			return nil, err
+		}
+	}
+
+	if isImage {
+		e.logf("Reading image: %s", pdfPath)
+	} else {
+		e.logf("Converting PDF: %s", pdfPath)
+	}
+	numPages, err := raster.pageCount(ctx, pdfPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read page count: %w", err)
+	}
+	trace.SpanFromContext(ctx).SetAttributes(attrPages.Int(numPages))
+
+	selected, err := e.opts.Pages.resolve(numPages)
+	if err != nil {
+		return nil, err
+	}
+
+	// Page renders go to a private directory, never next to the input
+	tmpDir, cleanup, err := e.tempDir("pages")
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	// Standard documents: crop each party's zone wherever the template puts it
+	template := e.opts.Template
+	if template == nil && len(e.opts.Templates) > 0 {
+		if template, err = e.identifyTemplate(ctx, raster, pdfPath, tmpDir, numPages); err != nil {
+			return nil, fmt.Errorf("failed to identify template: %w", err)
+		}
+		if template != nil {
+			e.logf("Template %s matches %s", template.Name, pdfPath)
+		} else {
+			e.warnf("No template matches %s, detecting signatures instead", pdfPath)
+		}
+	}
+	var zones map[int][]Zone
+	if template != nil {
+		if zones, err = template.zonesByPage(numPages); err != nil {
+			return nil, err
+		}
+		selected = slices.Sorted(maps.Keys(zones))
+	}
+
+	// Digitally prepared forms say exactly where they are signed
+	var formFields map[int][]Field
+	if !e.opts.NoFormFields && !isImage && zones == nil {
+		if formFields, err = formSignatureFields(pdfPath, e.opts.Password); err != nil {
+			e.warnf("Could not read form fields, detecting signatures instead: %v", err)
+		}
+	}
+
+	// Scanned pages carry their pixels; decoding them beats rendering
+	var scans map[int]*embeddedScan
+	if e.opts.EmbeddedScans && !isImage {
+		if scans, err = embeddedScans(pdfPath, e.opts.Password, selected); err != nil {
+			e.warnf("Could not look for embedded scans, rasterizing every page: %v", err)
+		}
+	}
+
+	// Signed PDFs carry their cryptographic status next to the ink
+	var digital map[int][]DigitalSignature
+	if e.opts.DigitalSignatures && !isImage {
+		sigs, err := DigitalSignatures(pdfPath, e.opts.Password)
+		if err != nil {
+			e.warnf("Could not read digital signatures: %v", err)
+		}
+		digital = map[int][]DigitalSignature{}
+		for _, sig := range sigs {
+			e.logDigitalSignature(sig)
+			if sig.Page > 0 {
+				digital[sig.Page] = append(digital[sig.Page], sig)
+			}
+		}
+	}
+
+	// Pipeline: the next pages render while one is detected, and its outputs
+	// are encoded while detection moves on
+	stats.pages = len(selected)
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	depth := e.pipelineDepth()
+	rendered := renderPages(ctx, selected, depth, func(page int) *renderedPage {
+		pagePrefix := outPrefix
+		// Zone names are unique across pages, so they need no page prefix
+		if numPages > 1 && zones == nil {
+			pagePrefix = outputName(outPrefix, "p"+strconv.Itoa(page))
+		}
+		rp := e.renderPage(ctx, raster, pdfPath, tmpDir, page, pagePrefix, formFields[page], zones[page], scans[page])
+		rp.digital = digital[page]
+		return rp
+	})
+	defer func() {
+		// The renderer writes into tmpDir until it stops
+		cancel(nil)
+		for range rendered {
+		}
+	}()
+	encoder := startEncoder(ctx, cancel, depth)
+
+	results, err := e.extractPages(ctx, rendered, encoder, numPages)
+	// A failed output cancels detection, so its error is the one to report
+	if encodeErr := encoder.wait(); encodeErr != nil {
+		return nil, encodeErr
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(results) == 0 {
+		return nil, fmt.Errorf("failed to extract signature: %w", ErrNoSignature)
+	}
+	return results, nil
+}
+
+// extractPages runs the rest of the pipeline on each page of rendered as it
+// arrives, queuing the outputs on encoder, and returns their results; pages
+// without ink are skipped. The results are complete once encoder is done.
+func (e *Extractor) extractPages(ctx context.Context, rendered <-chan *renderedPage, encoder *encodeQueue, numPages int) ([]*Result, error) {
+	var results []*Result
+	for rp := range rendered {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if numPages > 1 {
+			e.logf("Page %d of %d", rp.pageNum, numPages)
+		}
+		res, err := e.extractPage(ctx, rp, encoder)
+		if errors.Is(err, ErrNoSignature) {
+			e.logf("Page %d: no signature found", rp.pageNum)
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("page %d: %w", rp.pageNum, err)
+		}
+		results = append(results, res...)
+	}
+	// The renderer stops early only when the document is cancelled
+	return results, ctx.Err()
+}
+
+// renderedPage is a page rendered for detection by renderPage, or the error
+// that stopped it, on its way to extractPage.
+type renderedPage struct {
+	// opts are the extractor's options with the DPIs clamped for this page,
+	// and RenderDPI lowered to that of page with Options.DetectWidth.
+	opts      Options
+	pdfPath   string
+	pageNum   int
+	outPrefix string
+	box       pageBox
+	fields    []Field
+	zones     []Zone
+	// digital are the digital signatures with a widget on the page.
+	digital []DigitalSignature
+	pages   *pageCache
+	// page is the detection render.
+	page pageRender
+	err  error
+}
+
+// renderPage is the first stage of the pipeline: it reads the size of page
+// pageNum of pdfPath, checks it and renders the page (or Options.ROI) for
+// detection into tmpDir, named with outPrefix. fields and zones are the page's
+// form fields and template zones (see extractPage), and scan, when non-nil,
+// the embedded scan it is decoded from instead. A failure is returned in the
+// renderedPage, so it reaches extractPage in page order.
+func (e *Extractor) renderPage(ctx context.Context, raster rasterizer, pdfPath, tmpDir string, pageNum int, outPrefix string, fields []Field, zones []Zone, scan *embeddedScan) *renderedPage {
+	rp := &renderedPage{opts: e.opts, pdfPath: pdfPath, pageNum: pageNum, outPrefix: outPrefix, fields: fields, zones: zones}
+	opts := &rp.opts
+	ctx, span := e.startSpan(ctx, StageRasterize, attrPage.Int(pageNum))
+	defer func() { endSpan(span, rp.err) }()
+
+	// The page box ties pixels to PDF points, both for an ROI and for the result
+	rasterStart := time.Now()
+	box, err := raster.box(ctx, pdfPath, pageNum)
+	if err != nil {
+		e.observe(StageRasterize, rasterStart, err)
+		rp.err = fmt.Errorf("failed to read page size: %w", err)
+		return rp
+	}
+	if rp.err = ctx.Err(); rp.err != nil {
+		return rp
+	}
+	rp.box = box
+
+	// Refuse absurd page sizes and keep huge pages from rendering enormous images
+	if rp.err = checkPageSize(box.Rect, opts.MinPagePt, opts.MaxPagePt); rp.err != nil {
+		return rp
+	}
+	if len(zones) > 0 {
+		rp.fields = zoneFields(zones, pageNum, box)
+	}
+
+	// An embedded scan is served at its own resolution from the image itself
+	source := pdfPath
+	if scan != nil {
+		path, err := scan.write(filepath.Join(tmpDir, outputName(outPrefix, "pdf_scan")))
+		if err != nil {
+			e.warnf("Rasterizing page %d instead of decoding its scan: %v", pageNum, err)
+		} else {
+			e.logf("Page %d is a %dx%d scan at %g DPI, decoding it instead of rasterizing", pageNum, scan.Size.X, scan.Size.Y, scan.DPI)
+			raster, source = imageRasterizer{dpi: scan.DPI}, path
+			opts.RenderDPI, opts.OutputDPI = scan.DPI, scan.DPI
+		}
+	}
+	// Only the ROI is rasterized when one is set, so it alone has to fit
+	rendered := box.Rect
+	if opts.ROI != nil {
+		rendered = opts.ROI.intersect(box.Rect)
+	}
+	for _, dpi := range []*float64{&opts.RenderDPI, &opts.OutputDPI} {
+		if clamped := clampDPI(rendered, *dpi, opts.MaxRenderPx); clamped != *dpi {
+			e.warnf("Lowering %g DPI to %g DPI to keep the rendered region under %d px", *dpi, clamped, opts.MaxRenderPx)
+			*dpi = clamped
+		}
+	}
+
+	// Step 1: Convert the page (or just the ROI) of the PDF to PNG
+	rp.pages = newPageCache(raster, source, pageNum, filepath.Join(tmpDir, outputName(outPrefix, "pdf_page")), box, opts.ROI)
+	rp.pages.flatten = opts.FlattenIllumination
+	rp.pages.perspective = opts.CorrectPerspective && opts.ROI == nil && len(rp.fields) == 0
+	rp.pages.maxBytes = opts.MaxRenderBytes
+	rp.page, err = rp.pages.render(ctx, opts.RenderDPI)
+	if err != nil {
+		e.observe(StageRasterize, rasterStart, err)
+		rp.err = fmt.Errorf("failed to convert PDF to PNG: %w", err)
+		return rp
+	}
+	e.logf("PNG generated: %s", rp.page.Path)
+	if rp.pages.warped() {
+		e.logf("Page %d was photographed at an angle, warped flat along the document outline", pageNum)
+	}
+
+	// Optional: detect on a smaller copy; the output is still cropped from the
+	// full render, which becomes the one at OutputDPI when they were equal
+	if dpi := detectionDPI(rp.page.Size.X, opts.RenderDPI, opts.DetectWidth); dpi < opts.RenderDPI {
+		small, err := rp.pages.downscale(rp.page, opts.RenderDPI, dpi)
+		if err != nil {
+			e.observe(StageRasterize, rasterStart, err)
+			rp.err = fmt.Errorf("failed to downscale page for detection: %v", err)
+			return rp
+		}
+		e.logf("Detecting on %s, %d px wide at %.4g DPI", small.Path, small.Size.X, dpi)
+		rp.page, opts.RenderDPI = small, dpi
+	}
+	e.observe(StageRasterize, rasterStart, nil)
+	return rp
+}
+
+// extractPage runs the rest of the pipeline on a page rendered by renderPage
+// and writes the transparent signature (and any debug output) into
+// opts.OutputDir, named with the page's prefix, or into a confidence-bucket
+// subfolder of it when opts.Buckets is set; encoding and delivering the outputs
+// is queued on encoder. It returns one result, or one per region with
+// Options.AllRegions. When fields (the page's AcroForm signature fields) or
+// zones (its Options.Template zones) is non-empty, they are cropped instead of
+// detected; every inked zone gives a result named after it. With Options.Seals
+// the page's seals follow, one result each, and then its marked boxes (see
+// Options.Marks). Cancelling ctx kills any running subprocess and stops
+// between stages.
+func (e *Extractor) extractPage(ctx context.Context, rp *renderedPage, encoder *encodeQueue) ([]*Result, error) {
+	if rp.err != nil {
+		return nil, rp.err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	opts, pdfPath, pageNum, outPrefix := rp.opts, rp.pdfPath, rp.pageNum, rp.outPrefix
+	box, fields, zones, pages, page := rp.box, rp.fields, rp.zones, rp.pages, rp.page
+	pngPath := page.Path
+
+	// Pages fed into the scanner backwards: turn the renders upright before detecting
+	rotation, err := e.orientPage(pages, page)
+	if err != nil {
+		return nil, fmt.Errorf("failed to orient page: %v", err)
+	}
+
+	// Crooked scans: level the page so lines and signatures run horizontally
+	var skew float64
+	if opts.Deskew && len(fields) == 0 && len(zones) == 0 {
+		if skew, err = e.deskewPage(pages, page); err != nil {
+			return nil, fmt.Errorf("failed to deskew page: %v", err)
+		}
+	}
+
+	// Colored safety paper: key out the paper hue (sampled from the page corners on auto)
+	key, err := e.resolveChromaKey(opts.ChromaKey, pngPath)
+	if err != nil {
+		return nil, err
+	}
+
+	// Text-heavy pages: only look next to printed labels such as "Signature:"
+	params := detectParams{
+		key:           key,
+		dpi:           opts.RenderDPI,
+		all:           opts.AllRegions,
+		binarization:  opts.Binarization,
+		colorInk:      opts.ColorInk,
+		noShapeFilter: opts.NoShapeFilter,
+		classes:       opts.Classes,
+		removeRules:   opts.RemoveLines,
+		mergeGap:      opts.MergeGapPx,
+		medianBlur:    opts.MedianBlur,
+		padding:       opts.CropPaddingPx,
+		logger:        e.pageLogger(pageNum),
+	}
+	if opts.DebugDir != "" {
+		params.debug = &debugDump{dir: opts.DebugDir, prefix: outPrefix, logf: e.logf}
+	}
+	// Company seals: find them before the signatures, which are often written across them
+	var seals []sealCircle
+	if opts.Seals && !opts.DryRun {
+		if seals, err = e.pageSeals(page); err != nil {
+			return nil, fmt.Errorf("failed to find seals: %v", err)
+		}
+		e.logf("Found %d seals", len(seals))
+		params.seals = seals
+	}
+	// Initials and consent boxes are judged on their own, whatever detection finds
+	var boxes []markBox
+	if !opts.DryRun {
+		if boxes, err = e.pageMarks(page, box, rotation == 180, markZones(zones)); err != nil {
+			return nil, fmt.Errorf("failed to find marks: %v", err)
+		}
+	}
+	if len(opts.Anchors) > 0 && len(fields) == 0 {
+		if params.areas, params.labels, err = e.anchorAreas(ctx, page); err != nil {
+			return nil, fmt.Errorf("failed to find anchors: %w", err)
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	// Step 2: Extract the signature region(s)
+	detect := func(params detectParams) (regions []SignatureRegion, method string, err error) {
+		if len(fields) > 0 {
+			regions, err = fieldRegions(page, fields, opts.RenderDPI, box, rotation == 180, opts.Binarization, opts.ColorInk, params.all || len(zones) > 0)
+			if err == nil {
+				regions, err = keepClasses(regions, opts.Classes)
+			}
+		} else if len(zones) > 0 {
+			err = fmt.Errorf("%w: the page has only mark zones", ErrNoSignature)
+		} else if opts.Detector == DetectorONNX {
+			regions, err = e.modelRegions(pngPath, params)
+		} else {
+			regions, method, err = extractSignature(pngPath, params)
+		}
+		if len(zones) > 0 {
+			for i := range regions {
+				regions[i].Zone, regions[i].Field = regions[i].Field, ""
+			}
+		}
+		return regions, method, err
+	}
+	if len(zones) > 0 {
+		e.logf("Cropping %d template zones", len(fields))
+	} else if len(fields) > 0 {
+		e.logf("Cropping %d signature form fields", len(fields))
+	}
+	detectStart := time.Now()
+	_, span := e.startSpan(ctx, StageDetect, attrPage.Int(pageNum))
+	regions, method, err := detect(params)
+	span.SetAttributes(attrRegions.Int(len(regions)))
+	endSpan(span, err)
+	e.observe(StageDetect, detectStart, err)
+	// Closed on every return, failures included, should a detector return some
+	defer func() {
+		for i := range regions {
+			regions[i].Image.Close()
+		}
+	}()
+	if method != "" {
+		e.logf("Binarization: %s", method)
+	}
+	// A page may carry a seal or boxes and no signature
+	if errors.Is(err, ErrNoSignature) && (len(seals) > 0 || len(boxes) > 0) {
+		e.logf("No signature found beside the seals and boxes")
+		err = nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to extract signature: %w", err)
+	}
+	if opts.AllRegions {
+		e.logf("Found %d signature regions", len(regions))
+	}
+
+	st := &pageState{
+		opts:      opts,
+		pdfPath:   pdfPath,
+		pageNum:   pageNum,
+		outPrefix: outPrefix,
+		box:       box,
+		pages:     pages,
+		page:      page,
+		rotation:  rotation,
+		skew:      skew,
+		key:       key,
+		debug:     params.debug,
+		encoder:   encoder,
+		digital:   rp.digital,
+	}
+	if len(zones) > 0 {
+		st.template = zones[0].template
+	}
+	if opts.DryRun {
+		// Every candidate goes into the preview, not only those a real run keeps
+		candidates := regions
+		if !opts.AllRegions {
+			params.all, params.debug = true, nil
+			candidates, _, err = detect(params)
+			defer func() {
+				for i := range candidates {
+					candidates[i].Image.Close()
+				}
+			}()
+			if err != nil {
+				return nil, fmt.Errorf("failed to list candidate regions: %w", err)
+			}
+		}
+		return e.previewPage(st, candidates, regions)
+	}
+	var results []*Result
+	cutOff := 0
+	for i, region := range regions {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		n := 0
+		if opts.AllRegions {
+			n = i + 1
+		}
+		res, err := e.extractRegion(ctx, st, region, n)
+		if errors.Is(err, errCutOff) {
+			e.warnf("Skipping %v", err)
+			cutOff++
+			continue
+		}
+		if err != nil {
+			if region.Zone != "" {
+				err = fmt.Errorf("zone %s: %w", region.Zone, err)
+			} else if n > 0 {
+				err = fmt.Errorf("region %d: %w", n, err)
+			}
+			return nil, err
+		}
+		results = append(results, res)
+	}
+	if cutOff > 0 && len(results) == 0 && len(seals) == 0 && len(boxes) == 0 {
+		return nil, fmt.Errorf("failed to extract signature: %w: all %d regions touch the page edge", ErrNoSignature, cutOff)
+	}
+	if len(seals) > 0 {
+		sealResults, err := e.extractSeals(ctx, st, seals)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, sealResults...)
+	}
+	if len(boxes) > 0 {
+		markResults, err := e.extractMarks(ctx, st, boxes)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, markResults...)
+	}
+	return results, nil
+}
+
+// pageState is what extractPage has learned about a page once it is rendered,
+// shared by every region found on it.
+type pageState struct {
+	// opts are the extractor's options with the DPIs for this page (see renderedPage).
+	opts      Options
+	pdfPath   string
+	pageNum   int
+	outPrefix string
+	box       pageBox
+	pages     *pageCache
+	// page is the detection render.
+	page     pageRender
+	rotation int
+	skew     float64
+	key      *chromaKey
+	// template is the Template.Name of the page's zones, if any.
+	template string
+	// words are the page's OCR words once wordsRead (see pageWords).
+	words     []ocrWord
+	wordsRead bool
+	// debug receives the crop before its background is removed; nil disables it.
+	debug *debugDump
+	// encoder runs the encoding stage of the document (see pageState.later).
+	encoder *encodeQueue
+	// digital are the page's digital signatures (see renderedPage).
+	digital []DigitalSignature
+}
+
+// pageBounds expresses a region of the detection render in full-page pixels,
+// undoing the deskew, then the turn and then the perspective correction.
+func (st *pageState) pageBounds(renderBounds image.Rectangle) image.Rectangle {
+	pageRect := renderBounds
+	if st.skew != 0 {
+		pageRect = unskewRect(renderBounds, st.page.Size, st.skew)
+	}
+	if st.rotation == 180 {
+		pageRect = rotateRect180(pageRect, st.page.Size)
+	}
+	pageRect = st.pages.unwarp(pageRect, st.page.Size)
+	return pageRect.Add(st.page.Origin)
+}
+
+// pageEdges returns the borders of the page in pixels of the detection render.
+// They are those of the render itself unless only Options.ROI was rendered,
+// when they lie around it, off the render where the ROI stays inside the page.
+func (st *pageState) pageEdges() image.Rectangle {
+	render := image.Rectangle{Max: st.page.Size}
+	if st.opts.ROI == nil {
+		return render
+	}
+	page := image.Rectangle{Max: st.box.pixels(st.page.DPI)}.Sub(st.page.Origin)
+	if st.rotation == 180 {
+		page = rotateRect180(page, st.page.Size)
+	}
+	return page
+}
+
+// extractRegion finishes the pipeline for one detected region and writes its
+// outputs. n is the region's 1-based number with Options.AllRegions, naming its
+// outputs signature_{n}, and 0 otherwise, keeping the signature_result name.
+// A region of an Options.Template zone is named after the zone instead.
+func (e *Extractor) extractRegion(ctx context.Context, st *pageState, region SignatureRegion, n int) (*Result, error) {
+	opts := st.opts
+	rotation := st.rotation
+	key := st.key
+	pngPath := st.page.Path
+	outDir := opts.OutputDir
+	outPath := func(name string) string { return filepath.Join(outDir, outputName(st.outPrefix, name)) }
+	baseName, resultName := "signature", "signature_result"
+	if n > 0 {
+		baseName = "signature_" + strconv.Itoa(n)
+		resultName = baseName
+	}
+	if region.Zone != "" {
+		baseName, resultName = region.Zone, region.Zone
+	}
+
+	signatureMat, renderBounds := region.Image, region.Bounds
+	bounds := st.pageBounds(renderBounds)
+
+	// Map the pixel region back onto the page so it can be re-stamped in PDF space
+	res := &Result{
+		Source:     st.pdfPath,
+		Page:       st.pageNum,
+		Region:     n,
+		PagePath:   pngPath,
+		Baseline:   -1,
+		DPI:        opts.RenderDPI,
+		OutputDPI:  opts.RenderDPI,
+		Rotation:   rotation,
+		Skew:       st.skew,
+		Bounds:     bounds,
+		PDFBounds:  pixelRectToPDF(bounds, opts.RenderDPI, st.box),
+		PageBox:    st.box.Rect,
+		PageRotate: st.box.Rotate,
+		FormField:  region.Field,
+		Zone:       region.Zone,
+		Class:      region.Class,
+	}
+	if region.Zone != "" {
+		res.Template = st.template
+	}
+	res.DigitalSignature = digitalSignatureFor(st.digital, res)
+	e.logf("Signature region: %v px at %g DPI, %v pt in PDF user space", res.Bounds, res.DPI, res.PDFBounds)
+
+	res.Confidence, res.ConfidenceFactors = region.Confidence, region.Factors
+	mask := inkMask(signatureMat)
+	var wetScore float64
+	res.SignatureType, wetScore = classifySignature(mask)
+	mask.Close()
+	quality := measureQuality(signatureMat)
+	res.Quality = &quality
+	e.logf("Detection confidence: %.2f", res.Confidence)
+	e.logf("Signature type: %s (wet-ink score %.2f), class: %s", res.SignatureType, wetScore, res.Class)
+
+	// Optional: read the printed name and the date under the signing line
+	if opts.SignerOCR {
+		if err := e.readSignerText(ctx, st, region, res); err != nil {
+			return nil, fmt.Errorf("failed to read the signer's name and date: %w", err)
+		}
+	}
+	e.logf("Capture quality: %.2f (resolution %.2f, continuity %.2f, clipping %.2f, contrast %.2f)",
+		quality.Score, quality.Resolution, quality.Continuity, quality.Clipping, quality.Contrast)
+	if l := e.pageLogger(st.pageNum); l != nil {
+		l.Debug("selected region", "region", n, "bounds", res.Bounds.String(), "pdf_bounds", res.PDFBounds.String(),
+			"confidence", res.Confidence, "confidence_factors", res.ConfidenceFactors, "type", res.SignatureType)
+	}
+
+	// Optional: triage outputs into high/medium/low folders so reviewers can focus on the weak ones
+	if opts.Buckets != nil {
+		outDir = filepath.Join(opts.OutputDir, opts.Buckets.bucket(res.Confidence))
+		if err := os.MkdirAll(outDir, 0o755); err != nil {
+			return nil, err
+		}
+	}
+
+	// A signature running into the border was probably cut off, which matters for legal completeness.
+	// The padding is not part of the signature, so judge the ink alone
+	res.EdgeTouch = touchesEdge(region.Ink, st.pageEdges(), opts.EdgeMargin)
+	if res.EdgeTouch {
+		if opts.Strict {
+			return nil, fmt.Errorf("signature region %v %w", bounds, errCutOff)
+		}
+		e.warnf("Signature region touches the page edge and may be cut off")
+	}
+
+	// Optional: crop the output from a second render at a different resolution
+	crop := signatureMat
+	if opts.OutputDPI != opts.RenderDPI {
+		renderStart := time.Now()
+		rctx, span := e.startSpan(ctx, StageRasterize, attrPage.Int(st.pageNum), attrRegion.Int(n))
+		outPage, err := st.pages.render(rctx, opts.OutputDPI)
+		endSpan(span, err)
+		e.observe(StageRasterize, renderStart, err)
+		if err != nil {
+			return nil, fmt.Errorf("failed to render output page: %w", err)
+		}
+		outRect := scaleRect(bounds, opts.RenderDPI, opts.OutputDPI).Sub(outPage.Origin)
+		if st.skew != 0 || st.pages.warped() {
+			// Both renders are levelled and warped alike, so the region maps across directly
+			outRect = scaleRect(renderBounds, opts.RenderDPI, opts.OutputDPI)
+		} else if rotation == 180 {
+			outRect = rotateRect180(outRect, outPage.Size)
+		}
+		outCrop, err := cropImage(outPage.Path, outRect)
+		if err != nil {
+			return nil, fmt.Errorf("failed to crop output page: %v", err)
+		}
+		defer outCrop.Close()
+		if key != nil {
+			key.whiten(&outCrop)
+		}
+		crop = outCrop
+		res.OutputDPI = opts.OutputDPI
+		e.logf("Output cropped from %s at %g DPI", outPage.Path, res.OutputDPI)
+	}
+
+	// Optional: erase the signing line the signature was written over
+	if opts.RemoveLines {
+		cleaned := whitenRules(crop, res.OutputDPI)
+		defer cleaned.Close()
+		crop = cleaned
+	}
+
+	// Optional: animate the crop at several thresholds to help pick one
+	if opts.Sweep != nil {
+		sweepPath := outPath("threshold_sweep.gif")
+		if n > 0 {
+			sweepPath = outPath(baseName + "_threshold_sweep.gif")
+		}
+		if err := writeThresholdSweep(crop, opts.Sweep, sweepPath); err != nil {
+			return nil, fmt.Errorf("failed to write threshold sweep: %v", err)
+		}
+		e.logf("Threshold sweep saved to %s", sweepPath)
+	}
+
+	// Optional: normalize orientation so every signature shares a horizontal baseline
+	if opts.PCAAlign {
+		aligned, angle := alignToPrincipalAxis(crop)
+		defer aligned.Close()
+		crop = aligned
+		res.AlignAngle = angle
+		e.logf("Rotated signature by %.2f degrees to align its principal axis", angle)
+	} else if opts.Straighten {
+		straightened, angle := straightenInk(crop)
+		defer straightened.Close()
+		crop = straightened
+		res.AlignAngle = angle
+		e.logf("Rotated signature by %.2f degrees to straighten its ink", angle)
+	}
+
+	// Physical size of the signature, and optionally resample it for a printer's DPI
+	res.WidthMM, res.HeightMM = physicalSizeMM(image.Pt(crop.Cols(), crop.Rows()), res.OutputDPI)
+	e.logf("Physical size: %.1f x %.1f mm", res.WidthMM, res.HeightMM)
+	if opts.PrintDPI > 0 {
+		scaled := scaleToPrintDPI(crop, res.OutputDPI, opts.PrintDPI)
+		defer scaled.Close()
+		crop = scaled
+		res.PrintDPI = opts.PrintDPI
+		e.logf("Resampled to %dx%d px for printing at %g DPI", crop.Cols(), crop.Rows(), opts.PrintDPI)
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	cropName := "crop"
+	if n > 0 {
+		cropName += "_" + strconv.Itoa(n)
+	}
+	if err := st.debug.write(debugStageCrop, cropName, crop); err != nil {
+		return nil, err
+	}
+
+	// Step 3: Remove white background (convert near-white to transparent, or fade
+	// alpha with the ink darkness for a soft matte)
+	backgroundStart := time.Now()
+	_, span := e.startSpan(ctx, StageBackground, attrPage.Int(st.pageNum), attrRegion.Int(n))
+	signatureImage, err := e.removeBackground(crop)
+	endSpan(span, err)
+	e.observe(StageBackground, backgroundStart, err)
+	if err != nil {
+		return nil, fmt.Errorf("failed to remove background: %v", err)
+	}
+
+	// Optional: strip the light fringe so the signature composites cleanly on dark backgrounds
+	if opts.Decontaminate {
+		decontaminateEdges(signatureImage, estimateBackground(crop, e.whiteThreshold()))
+	}
+
+	// Optional: drop the transparent margin, then fit the ink onto a uniform canvas for display
+	if opts.Trim || opts.Canvas != (image.Point{}) {
+		signatureImage = trimTransparent(signatureImage)
+	}
+	if opts.Canvas != (image.Point{}) {
+		signatureImage = placeOnCanvas(signatureImage, opts.Canvas, opts.CanvasPadding, opts.CanvasAlign)
+		e.logf("Placed on a %dx%d px canvas", opts.Canvas.X, opts.Canvas.Y)
+	}
+
+	// Step 4: Save final image, while detection goes on with the next region
+	res.Image = signatureImage
+	res.OutputPath = outPath(resultName + "." + formatExtension(opts.Format))
+	// The crops above are closed on return, before the encoder gets to them
+	owned := crop.Clone()
+	err = st.later(ctx, func(ctx context.Context) error {
+		defer owned.Close()
+		encodeStart := time.Now()
+		ctx, span := e.startSpan(ctx, StageEncode, attrPage.Int(st.pageNum), attrRegion.Int(n), attrFormat.String(opts.Format))
+		err := e.writeOutput(ctx, owned, signatureImage, res.OutputPath)
+		endSpan(span, err)
+		e.observe(StageEncode, encodeStart, err)
+		if err != nil {
+			return err
+		}
+
+		// Optional: split the signature at its baseline to separate the body from descenders
+		if opts.DetectBaseline {
+			if err := e.writeBaselineSplit(ctx, owned, baseName, outPath, res); err != nil {
+				return fmt.Errorf("failed to split at baseline: %v", err)
+			}
+		}
+
+		e.logf("Signature with transparent background saved to %s", res.OutputPath)
+		if opts.Format == FormatRaw {
+			e.logf("Raw RGBA output is %dx%d px", signatureImage.Bounds().Dx(), signatureImage.Bounds().Dy())
+		}
+
+		// Optional: record where the signature came from and hand it to the caller (e.g. a webhook)
+		return e.deliver(ctx, res)
+	})
+	if err != nil {
+		owned.Close()
+		return nil, err
+	}
+	return res, nil
+}
+
+// writeOutput encodes the finished signature to path in Options.Format; crop
+// is the untouched crop, kept as the bottom layer of a PSD.
+func (e *Extractor) writeOutput(ctx context.Context, crop gocv.Mat, signatureImage *image.RGBA, path string) error {
+	opts := e.opts
+	if opts.Format == FormatPSD {
+		// Keep the untouched crop underneath so designers can refine the extraction by hand
+		original, err := crop.ToImage()
+		if err != nil {
+			return fmt.Errorf("failed to convert crop: %v", err)
+		}
+		layers := []psdLayer{{Name: "Original crop", Image: original}, {Name: "Signature", Image: signatureImage}}
+		if err := writePSD(path, layers); err != nil {
+			return fmt.Errorf("failed to write PSD: %v", err)
+		}
+		return nil
+	}
+	if opts.Palette > 0 {
+		// Indexed PNG with a single transparent entry, for thumbnails where size matters most
+		return writePNG(quantizeMedianCut(signatureImage, opts.Palette), path)
+	}
+	return writeImage(ctx, signatureImage, path, opts.Format, opts.Quality)
+}
+
+// writeBaselineSplit records the crop's baseline in res and writes the parts above
+// and below it next to the main output as {baseName}_above and {baseName}_below;
+// outPath maps a file name to its location.
+func (e *Extractor) writeBaselineSplit(ctx context.Context, crop gocv.Mat, baseName string, outPath func(string) string, res *Result) error {
+	opts := e.opts
+	mask := inkMask(crop)
+	baseline, ok := detectBaseline(mask)
+	mask.Close()
+	if !ok {
+		e.logf("No baseline found: the crop contains no ink")
+		return nil
+	}
+	res.Baseline = baseline
+	e.logf("Baseline at y=%d of %d crop rows", baseline, crop.Rows())
+
+	above, below := splitAtBaseline(crop, baseline)
+	defer above.Close()
+	defer below.Close()
+
+	parts := []struct {
+		name string
+		mat  gocv.Mat
+	}{{baseName + "_above", above}, {baseName + "_below", below}}
+	for _, part := range parts {
+		if part.mat.Empty() {
+			continue
+		}
+		img, err := e.removeBackground(part.mat)
+		if err != nil {
+			return err
+		}
+		path := outPath(part.name + "." + formatExtension(opts.Format))
+		if err := writeImage(ctx, img, path, opts.Format, opts.Quality); err != nil {
+			return err
+		}
+		e.logf("Saved %s", path)
+	}
+	return nil
+}
+
+// ocrLanguage returns Options.OCRLanguage, defaulting when empty.
+func (e *Extractor) ocrLanguage() string {
+	if e.opts.OCRLanguage == "" {
+		return DefaultOCRLanguage
+	}
+	return e.opts.OCRLanguage
+}
+
+// anchorAreas finds the Options.Anchors labels on the rendered page and returns
+// the areas to search for a signature next to them, plus the label boxes. When
+// no label is found both are nil and the whole page is searched.
+func (e *Extractor) anchorAreas(ctx context.Context, page pageRender) (areas, labels []image.Rectangle, err error) {
+	labels, err = findAnchors(ctx, page.Path, e.opts.Anchors, e.ocrLanguage())
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(labels) == 0 {
+		e.logf("No anchor label found, searching the whole page")
+		return nil, nil, nil
+	}
+
+	bounds := image.Rectangle{Max: page.Size}
+	for _, label := range labels {
+		areas = append(areas, anchorSearchArea(label, bounds, page.DPI))
+	}
+	e.logf("Found %d anchor labels, searching next to them: %v", len(labels), areas)
+	return areas, labels, nil
+}
+
+// orientPage decides whether the page is upside down (by detection with
+// Options.AutoOrient, or unconditionally with Options.AssumeUpsideDown) and, if
+// so, turns all renders over. It returns the applied rotation in degrees.
+func (e *Extractor) orientPage(pages *pageCache, page pageRender) (int, error) {
+	opts := e.opts
+	flip := opts.AssumeUpsideDown
+	if !flip && opts.AutoOrient {
+		upsideDown, score, ok, err := detectUpsideDown(page.Path, page.DPI)
+		if err != nil {
+			return 0, err
+		}
+		if !ok {
+			e.logf("Orientation: not enough text to decide, assuming upright")
+			return 0, nil
+		}
+		e.logf("Orientation score: %.2f (negative means upside down)", score)
+		flip = upsideDown
+	}
+	if !flip {
+		return 0, nil
+	}
+	if err := pages.setUpsideDown(); err != nil {
+		return 0, err
+	}
+	e.logf("Page rotated by 180 degrees")
+	return 180, nil
+}
+
+// deskewPage measures the tilt of the page from its text lines and, when it is
+// large enough to matter, levels all renders. It returns the applied
+// correction in degrees, 0 when the page was left alone.
+func (e *Extractor) deskewPage(pages *pageCache, page pageRender) (float64, error) {
+	angle, ok, err := skewAngle(page.Path, page.DPI)
+	if err != nil {
+		return 0, err
+	}
+	if !ok {
+		e.logf("Skew: not enough text lines to measure, leaving the page as is")
+		return 0, nil
+	}
+	if math.Abs(angle) < minSkewDegrees {
+		e.logf("Skew: %.2f degrees, leaving the page as is", angle)
+		return 0, nil
+	}
+	if err := pages.setSkew(angle); err != nil {
+		return 0, err
+	}
+	e.logf("Page deskewed by %.2f degrees", angle)
+	return angle, nil
+}
+
+// resolveChromaKey turns the Options.ChromaKey setting into a key, sampling the paper
+// color from the rendered page for "auto". It returns nil when keying is off.
+func (e *Extractor) resolveChromaKey(setting, pagePath string) (*chromaKey, error) {
+	switch setting {
+	case "":
+		return nil, nil
+	case "auto":
+		page, err := readImage(pagePath, gocv.IMReadColor)
+		if err != nil {
+			return nil, err
+		}
+		defer page.Close()
+		key := sampleChromaKey(page)
+		e.logf("Chroma key sampled from page corners: H=%.0f S=%.2f V=%.2f", key.H, key.S, key.V)
+		return &key, nil
+	default:
+		key, err := parseChromaKeyColor(setting)
+		if err != nil {
+			return nil, err
+		}
+		return &key, nil
+	}
+}
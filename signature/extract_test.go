@@ -0,0 +1,103 @@
+package signature
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"testing"
+
+	"gocv.io/x/gocv"
+)
+
+// removeWhiteBackgroundPerPixel is the original removeWhiteBackground, reading
+// every pixel through the Mat accessors, kept to compare against.
+func removeWhiteBackgroundPerPixel(input gocv.Mat, threshold uint8) *image.RGBA {
+	rows, cols := input.Rows(), input.Cols()
+	output := image.NewRGBA(image.Rect(0, 0, cols, rows))
+	for y := 0; y < rows; y++ {
+		for x := 0; x < cols; x++ {
+			bVec := input.GetVecbAt(y, x)
+			b, g, r := bVec[0], bVec[1], bVec[2]
+			if r > threshold && g > threshold && b > threshold {
+				output.Set(x, y, color.RGBA{R: 255, G: 255, B: 255, A: 0})
+			} else {
+				output.Set(x, y, color.RGBA{R: r, G: g, B: b, A: 255})
+			}
+		}
+	}
+	return output
+}
+
+// a4Page returns a white BGR page the size of A4 at 300 DPI with a few dark
+// blue strokes on it.
+func a4Page() gocv.Mat {
+	page := gocv.NewMatWithSizeFromScalar(gocv.NewScalar(255, 255, 255, 0), 3508, 2480, gocv.MatTypeCV8UC3)
+	ink := color.RGBA{R: 20, G: 30, B: 110}
+	for i := range 5 {
+		y := 2600 + 60*i
+		gocv.Line(&page, image.Pt(900, y), image.Pt(1900, y+120), ink, 3+i)
+	}
+	return page
+}
+
+func TestRemoveWhiteBackgroundMatchesPerPixel(t *testing.T) {
+	page := a4Page()
+	defer page.Close()
+	// A region of a larger image is not continuous in memory
+	region := page.Region(image.Rect(800, 2500, 2000, 3000))
+	defer region.Close()
+	for _, m := range []gocv.Mat{page, region} {
+		got, err := removeWhiteBackground(m, 200)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := removeWhiteBackgroundPerPixel(m, 200); !bytes.Equal(got.Pix, want.Pix) {
+			t.Errorf("%dx%d Mat: output differs from the per-pixel loop", m.Cols(), m.Rows())
+		}
+	}
+}
+
+func TestOutputDPIIndependentOfRenderDPI(t *testing.T) {
+	path := signaturePage(t, t.TempDir())
+	for _, outputDPI := range []float64{200, 50} {
+		opts := fixtureOptions(t)
+		opts.OutputDPI = outputDPI
+		results := extractFixture(t, opts, path)
+		if len(results) != 1 {
+			t.Fatalf("output DPI %g: got %d results, want 1", outputDPI, len(results))
+		}
+		res := results[0]
+		if res.DPI != fixtureDPI || res.OutputDPI != outputDPI {
+			t.Errorf("DPI = %g, OutputDPI = %g, want %d and %g", res.DPI, res.OutputDPI, fixtureDPI, outputDPI)
+		}
+		// The detection box, scaled to the output render
+		want := scaleRect(res.Bounds, fixtureDPI, outputDPI).Size()
+		if got := res.Image.Bounds().Size(); got != want {
+			t.Errorf("output DPI %g: output is %v, want %v (%v at %d DPI)", outputDPI, got, want, res.Bounds, fixtureDPI)
+		}
+		written, err := imageBounds(res.OutputPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if written.Size() != want {
+			t.Errorf("output DPI %g: %s is %v, want %v", outputDPI, res.OutputPath, written.Size(), want)
+		}
+	}
+}
+
+func BenchmarkRemoveWhiteBackground(b *testing.B) {
+	page := a4Page()
+	defer page.Close()
+	b.Run("bytes", func(b *testing.B) {
+		for b.Loop() {
+			if _, err := removeWhiteBackground(page, 200); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("per-pixel", func(b *testing.B) {
+		for b.Loop() {
+			removeWhiteBackgroundPerPixel(page, 200)
+		}
+	})
+}
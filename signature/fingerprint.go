@@ -0,0 +1,349 @@
+package signature
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"image/png"
+	"math"
+	"math/bits"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	xdraw "golang.org/x/image/draw"
+)
+
+// Fingerprint parameters.
+const (
+	// DefaultMaxHashDistance is how many of the 64 bits of a header hash may
+	// differ for a document to still match (see Fingerprint.MaxHashDistance).
+	DefaultMaxHashDistance = 10
+	// DefaultSizeTolerance is how far, in points, a page may be off
+	// Fingerprint.PageSize and still match.
+	DefaultSizeTolerance = 3
+	// fingerprintDPI renders the header: enough for Tesseract to read headings.
+	fingerprintDPI = 150
+	// headerFraction is the top share of the first page that is its header.
+	headerFraction = 0.2
+)
+
+// Fingerprint tells the documents a Template is for apart from the others in a
+// registry (see Options.Templates). Every criterion given must hold; those left
+// out are not checked. All of them look at the first page.
+type Fingerprint struct {
+	// PageSize is the size of the first page as displayed, in points.
+	PageSize *PageSize `yaml:"page_size,omitempty"`
+	// Pages, when non-zero, is the number of pages the document has.
+	Pages int `yaml:"pages,omitempty"`
+	// Text lists phrases that must all appear in the header, read by OCR with
+	// Options.OCRLanguage (needs tesseract). Matching ignores case and punctuation.
+	Text []string `yaml:"text,omitempty"`
+	// HeaderHash is the 64-bit difference hash of the header in hex, as printed
+	// by the fingerprint command.
+	HeaderHash string `yaml:"header_hash,omitempty"`
+	// MaxHashDistance is how many bits of HeaderHash may differ; 0 means
+	// DefaultMaxHashDistance.
+	MaxHashDistance int `yaml:"max_hash_distance,omitempty"`
+}
+
+// PageSize is a page width and height in points.
+type PageSize struct {
+	Width  float64 `yaml:"width"`
+	Height float64 `yaml:"height"`
+	// Tolerance is how far off each side may be; 0 means DefaultSizeTolerance.
+	Tolerance float64 `yaml:"tolerance,omitempty"`
+}
+
+// criteria counts the criteria f checks.
+func (f *Fingerprint) criteria() int {
+	n := len(f.Text)
+	if f.PageSize != nil {
+		n++
+	}
+	if f.Pages != 0 {
+		n++
+	}
+	if f.HeaderHash != "" {
+		n++
+	}
+	return n
+}
+
+// validate checks the values of a fingerprint read from a file.
+func (f *Fingerprint) validate() error {
+	if f.criteria() == 0 {
+		return fmt.Errorf("match checks nothing; give page_size, pages, text or header_hash")
+	}
+	if f.PageSize != nil && (f.PageSize.Width <= 0 || f.PageSize.Height <= 0 || f.PageSize.Tolerance < 0) {
+		return fmt.Errorf("match page_size %+v must have a positive width and height", *f.PageSize)
+	}
+	if f.Pages < 0 {
+		return fmt.Errorf("match pages must not be negative, got %d", f.Pages)
+	}
+	if f.HeaderHash != "" {
+		if _, err := parseHeaderHash(f.HeaderHash); err != nil {
+			return err
+		}
+	}
+	if f.MaxHashDistance < 0 || f.MaxHashDistance > 64 {
+		return fmt.Errorf("match max_hash_distance must be in 0-64, got %d", f.MaxHashDistance)
+	}
+	return nil
+}
+
+// parseHeaderHash parses the 16 hex digits of a header hash.
+func parseHeaderHash(s string) (uint64, error) {
+	h, err := strconv.ParseUint(s, 16, 64)
+	if err != nil || len(s) != 16 {
+		return 0, fmt.Errorf("invalid header_hash %q: want 16 hex digits", s)
+	}
+	return h, nil
+}
+
+// differenceHash is the dHash of img: shrunk to 9x8 gray pixels, one bit per
+// pair of horizontal neighbours, set when the left one is brighter. It survives
+// rescans, resolution changes and small shifts, but not a different layout.
+func differenceHash(img image.Image) uint64 {
+	small := image.NewGray(image.Rect(0, 0, 9, 8))
+	xdraw.BiLinear.Scale(small, small.Bounds(), img, img.Bounds(), xdraw.Src, nil)
+	var h uint64
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			h <<= 1
+			if small.GrayAt(x, y).Y > small.GrayAt(x+1, y).Y {
+				h |= 1
+			}
+		}
+	}
+	return h
+}
+
+// documentPrint is what is known of a document while templates are matched
+// against it; the box, header render and OCR are made on first use.
+type documentPrint struct {
+	e        *Extractor
+	raster   rasterizer
+	pdfPath  string
+	tmpDir   string
+	numPages int
+
+	box    *pageBox
+	header string
+	hash   *uint64
+	words  []ocrWord
+	read   bool
+}
+
+// pageBox returns the box of the first page.
+func (d *documentPrint) pageBox(ctx context.Context) (pageBox, error) {
+	if d.box == nil {
+		box, err := d.raster.box(ctx, d.pdfPath, 1)
+		if err != nil {
+			return pageBox{}, fmt.Errorf("failed to read page size: %w", err)
+		}
+		d.box = &box
+	}
+	return *d.box, nil
+}
+
+// headerPath renders the header of the first page, once.
+func (d *documentPrint) headerPath(ctx context.Context) (string, error) {
+	if d.header != "" {
+		return d.header, nil
+	}
+	box, err := d.pageBox(ctx)
+	if err != nil {
+		return "", err
+	}
+	w, h := box.size()
+	scale := fingerprintDPI / pointsPerInch
+	crop := image.Rect(0, 0, int(math.Ceil(w*scale)), int(math.Ceil(h*headerFraction*scale)))
+	if d.header, err = d.raster.renderPNG(ctx, d.pdfPath, 1, filepath.Join(d.tmpDir, "fingerprint_header"), fingerprintDPI, crop); err != nil {
+		return "", fmt.Errorf("failed to render the header: %w", err)
+	}
+	return d.header, nil
+}
+
+// headerHash returns the difference hash of the header.
+func (d *documentPrint) headerHash(ctx context.Context) (uint64, error) {
+	if d.hash == nil {
+		path, err := d.headerPath(ctx)
+		if err != nil {
+			return 0, err
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return 0, err
+		}
+		img, err := png.Decode(f)
+		f.Close()
+		if err != nil {
+			return 0, fmt.Errorf("failed to decode header render %s: %v", path, err)
+		}
+		h := differenceHash(img)
+		d.hash = &h
+	}
+	return *d.hash, nil
+}
+
+// hasText reports whether the header reads phrase.
+func (d *documentPrint) hasText(ctx context.Context, phrase string) (bool, error) {
+	if !d.read {
+		path, err := d.headerPath(ctx)
+		if err != nil {
+			return false, err
+		}
+		if d.words, err = ocrWords(ctx, path, d.e.ocrLanguage()); err != nil {
+			return false, err
+		}
+		d.read = true
+	}
+	want := strings.Fields(normalizeWord(phrase))
+	for i := range d.words {
+		if _, ok := matchPhrase(d.words[i:], want); ok {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// matches reports whether the document has every trait of f, checking the
+// cheap ones first so that most templates are ruled out without a render.
+func (d *documentPrint) matches(ctx context.Context, f *Fingerprint) (bool, error) {
+	if f.Pages != 0 && f.Pages != d.numPages {
+		return false, nil
+	}
+	if f.PageSize != nil {
+		box, err := d.pageBox(ctx)
+		if err != nil {
+			return false, err
+		}
+		tolerance := f.PageSize.Tolerance
+		if tolerance == 0 {
+			tolerance = DefaultSizeTolerance
+		}
+		w, h := box.size()
+		if math.Abs(w-f.PageSize.Width) > tolerance || math.Abs(h-f.PageSize.Height) > tolerance {
+			return false, nil
+		}
+	}
+	if f.HeaderHash != "" {
+		want, _ := parseHeaderHash(f.HeaderHash)
+		got, err := d.headerHash(ctx)
+		if err != nil {
+			return false, err
+		}
+		maxDistance := f.MaxHashDistance
+		if maxDistance == 0 {
+			maxDistance = DefaultMaxHashDistance
+		}
+		if bits.OnesCount64(want^got) > maxDistance {
+			return false, nil
+		}
+	}
+	for _, phrase := range f.Text {
+		ok, err := d.hasText(ctx, phrase)
+		if err != nil || !ok {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// identifyTemplate returns the template of Options.Templates the document at
+// pdfPath matches, or nil when it matches none. When several match, the one
+// checking the most criteria wins, and among those the first in the registry.
+// Renders go to tmpDir.
+func (e *Extractor) identifyTemplate(ctx context.Context, raster rasterizer, pdfPath, tmpDir string, numPages int) (*Template, error) {
+	d := &documentPrint{e: e, raster: raster, pdfPath: pdfPath, tmpDir: tmpDir, numPages: numPages}
+	var best *Template
+	for _, t := range e.opts.Templates {
+		if best != nil && t.Match.criteria() <= best.Match.criteria() {
+			continue
+		}
+		ok, err := d.matches(ctx, t.Match)
+		if err != nil {
+			return nil, fmt.Errorf("template %s: %w", t.Name, err)
+		}
+		if ok {
+			best = t
+		}
+	}
+	return best, nil
+}
+
+// Fingerprint measures the document at path the way a Template's match
+// section describes it (page size, page count and header hash), for writing
+// templates from a sample document.
+func (e *Extractor) Fingerprint(ctx context.Context, path string) (*Fingerprint, error) {
+	raster, err := e.rasterizerFor(path)
+	if err != nil {
+		return nil, err
+	}
+	numPages, err := raster.pageCount(ctx, path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read page count: %w", err)
+	}
+	tmpDir, cleanup, err := e.tempDir("fingerprint")
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	d := &documentPrint{e: e, raster: raster, pdfPath: path, tmpDir: tmpDir, numPages: numPages}
+	box, err := d.pageBox(ctx)
+	if err != nil {
+		return nil, err
+	}
+	hash, err := d.headerHash(ctx)
+	if err != nil {
+		return nil, err
+	}
+	w, h := box.size()
+	return &Fingerprint{
+		PageSize:   &PageSize{Width: math.Round(w*100) / 100, Height: math.Round(h*100) / 100},
+		Pages:      numPages,
+		HeaderHash: fmt.Sprintf("%016x", hash),
+	}, nil
+}
+
+// LoadTemplates reads the template registry in dir: every .yaml, .yml and
+// .json file, in file name order. Each template must have a match section,
+// and its name defaults to its file name without the extension.
+func LoadTemplates(dir string) ([]*Template, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var templates []*Template
+	names := map[string]string{}
+	for _, entry := range entries {
+		switch strings.ToLower(filepath.Ext(entry.Name())) {
+		case ".yaml", ".yml", ".json":
+		default:
+			continue
+		}
+		if entry.IsDir() {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		t, err := ParseTemplate(path)
+		if err != nil {
+			return nil, err
+		}
+		if t.Match == nil {
+			return nil, fmt.Errorf("template %s has no match section to pick it by", path)
+		}
+		if other, ok := names[t.Name]; ok {
+			return nil, fmt.Errorf("templates %s and %s are both named %q", other, path, t.Name)
+		}
+		names[t.Name] = path
+		templates = append(templates, t)
+	}
+	if len(templates) == 0 {
+		return nil, fmt.Errorf("no .yaml, .yml or .json templates in %s", dir)
+	}
+	return templates, nil
+}
@@ -0,0 +1,152 @@
+package signature
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+// fixtureDPI is the resolution of the pages the fixtures draw: a letter page
+// is 850x1100 pixels.
+const fixtureDPI = 100
+
+// blueInk is the color of a ballpoint signature.
+var blueInk = color.RGBA{R: 20, G: 30, B: 110, A: 255}
+
+// newPage returns a white w x h page.
+func newPage(w, h int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for i := range img.Pix {
+		img.Pix[i] = 255
+	}
+	return img
+}
+
+// dot paints a filled disc of radius r centered on (x, y).
+func dot(img *image.RGBA, x, y, r float64, c color.Color) {
+	for dy := -r; dy <= r; dy++ {
+		for dx := -r; dx <= r; dx++ {
+			if dx*dx+dy*dy <= r*r {
+				img.Set(int(math.Round(x+dx)), int(math.Round(y+dy)), c)
+			}
+		}
+	}
+}
+
+// drawSignature paints one continuous, looping stroke about 300 pixels long
+// whose width varies like pen pressure, starting at (x, y) and running at
+// angle degrees below the horizontal, scaled by scale.
+func drawSignature(img *image.RGBA, x, y, angle, scale float64, c color.Color) {
+	sin, cos := math.Sincos(angle * math.Pi / 180)
+	for u := 0.0; u <= 1; u += 0.0005 {
+		px := 260*u + 18*math.Sin(2*math.Pi*7*u)
+		py := 30*math.Sin(2*math.Pi*3*u) + 18*math.Cos(2*math.Pi*7*u)
+		r := (1.8 + 0.8*math.Sin(2*math.Pi*5*u)) * scale
+		dot(img, x+scale*(px*cos-py*sin), y+scale*(px*sin+py*cos), r, c)
+	}
+}
+
+// writeFixture encodes img as a PNG at dir/name and returns its path.
+func writeFixture(t *testing.T, dir, name string, img image.Image) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := writePNG(img, path); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// signaturePage writes a letter page at fixtureDPI with one signature in its
+// lower half and returns its path.
+func signaturePage(t *testing.T, dir string) string {
+	t.Helper()
+	img := newPage(850, 1100)
+	drawSignature(img, 300, 850, 0, 1, blueInk)
+	return writeFixture(t, dir, "page.png", img)
+}
+
+// fakePDF writes a placeholder PDF with a page for each of pages, images
+// drawn at fixtureDPI on letter paper, and puts a pdfinfo and a pdftoppm on
+// PATH that serve them as its pages. It returns the PDF's path; use it with
+// RasterizerPoppler.
+func fakePDF(t *testing.T, pages ...*image.RGBA) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake poppler tools are shell scripts")
+	}
+	dir := t.TempDir()
+	for i, img := range pages {
+		writeFixture(t, dir, fmt.Sprintf("page%d.png", i+1), img)
+	}
+	tools := map[string]string{
+		"pdfinfo": fmt.Sprintf("#!/bin/sh\nif [ \"$1\" = -box ]; then\n"+
+			"  echo \"Page $3 CropBox: 0.00 0.00 612.00 792.00\"\n  echo \"Page $3 rot: 0\"\n"+
+			"else\n  echo 'Pages: %d'\nfi\n", len(pages)),
+		// The page follows -f and the output prefix comes last
+		"pdftoppm": "#!/bin/sh\nwhile [ $# -gt 1 ]; do [ \"$1\" = -f ] && page=$2; shift; done\n" +
+			"cp \"" + dir + "/page$page.png\" \"$1.png\"\n",
+	}
+	for name, script := range tools {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(script), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	path := filepath.Join(dir, "document.pdf")
+	if err := os.WriteFile(path, []byte("%PDF-1.4\n%%EOF\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// fixtureOptions returns the default options for pages drawn at fixtureDPI,
+// writing into a fresh directory.
+func fixtureOptions(t *testing.T) Options {
+	t.Helper()
+	opts := DefaultOptions()
+	opts.RenderDPI, opts.OutputDPI = fixtureDPI, fixtureDPI
+	opts.OutputDir = t.TempDir()
+	return opts
+}
+
+// extractFixture runs the pipeline over the page image at path and returns
+// its results, failing the test on an error.
+func extractFixture(t *testing.T, opts Options, path string) []*Result {
+	t.Helper()
+	results, err := NewExtractor(opts).ExtractFromPDF(context.Background(), path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return results
+}
+
+// isInk reports whether c is an opaque, dark pixel: ink on a page or in an output.
+func isInk(c color.RGBA) bool {
+	return c.A > 128 && (c.R < inkThreshold || c.G < inkThreshold || c.B < inkThreshold)
+}
+
+// inkAngle returns the angle in degrees between the x axis and the principal
+// axis of the ink of img, positive down-right.
+func inkAngle(img *image.RGBA) float64 {
+	var n, sx, sy, sxx, syy, sxy float64
+	b := img.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if isInk(img.RGBAAt(x, y)) {
+				fx, fy := float64(x), float64(y)
+				n++
+				sx, sy = sx+fx, sy+fy
+				sxx, syy, sxy = sxx+fx*fx, syy+fy*fy, sxy+fx*fy
+			}
+		}
+	}
+	mx, my := sx/n, sy/n
+	cxx, cyy, cxy := sxx/n-mx*mx, syy/n-my*my, sxy/n-mx*my
+	return 0.5 * math.Atan2(2*cxy, cxx-cyy) * 180 / math.Pi
+}
@@ -0,0 +1,79 @@
+package signature
+
+import (
+	"fmt"
+	"image"
+
+	"gocv.io/x/gocv"
+)
+
+// Background estimation parameters, in pixels at thresholdReferenceDPI.
+const (
+	// illumInkWidth is wider than any pen stroke, so a dilation (a local
+	// maximum) of this size replaces the ink with the paper around it.
+	illumInkWidth = 9
+	// illumBlur is the median window smoothing the paper estimate (about 1cm):
+	// larger than text, smaller than a shadow's gradient.
+	illumBlur = 61
+	// illumDownscale is how much the background is shrunk while it is
+	// estimated; it varies slowly, so little is lost and the filters run fast.
+	illumDownscale = 4
+)
+
+// flattenIllumination divides the BGR img by an estimate of its paper
+// brightness, so a phone photo with a shadow across it comes out as ink on an
+// evenly white page. The estimate is the page with its ink dilated away and
+// heavily median-blurred. The caller owns the returned Mat.
+func flattenIllumination(img gocv.Mat, dpi float64) gocv.Mat {
+	small := gocv.NewMat()
+	defer small.Close()
+	smallSize := image.Pt(max(1, img.Cols()/illumDownscale), max(1, img.Rows()/illumDownscale))
+	gocv.Resize(img, &small, smallSize, 0, 0, gocv.InterpolationArea)
+
+	ink := oddAtLeast3(scaleLength(illumInkWidth, dpi) / illumDownscale)
+	kernel := gocv.GetStructuringElement(gocv.MorphRect, image.Pt(ink, ink))
+	defer kernel.Close()
+	gocv.Dilate(small, &small, kernel)
+	gocv.MedianBlur(small, &small, oddAtLeast3(scaleLength(illumBlur, dpi)/illumDownscale))
+
+	background := gocv.NewMat()
+	defer background.Close()
+	gocv.Resize(small, &background, image.Pt(img.Cols(), img.Rows()), 0, 0, gocv.InterpolationLinear)
+
+	// out = img / background * 255, in floats so the ratio isn't truncated;
+	// the conversion back saturates highlights brighter than their paper
+	src, bg := gocv.NewMat(), gocv.NewMat()
+	defer src.Close()
+	defer bg.Close()
+	img.ConvertTo(&src, gocv.MatTypeCV32FC3)
+	background.ConvertToWithParams(&bg, gocv.MatTypeCV32FC3, 1, 1) // +1 avoids dividing by black
+	gocv.Divide(src, bg, &src)
+	out := gocv.NewMat()
+	src.ConvertToWithParams(&out, gocv.MatTypeCV8UC3, 255, 0)
+	return out
+}
+
+// oddAtLeast3 rounds n up to an odd kernel size of at least 3.
+func oddAtLeast3(n int) int {
+	if n < 3 {
+		return 3
+	}
+	return n | 1
+}
+
+// flattenImageFile rewrites the page image at path with its illumination
+// flattened (see flattenIllumination); dpi is its resolution.
+func flattenImageFile(path string, dpi float64) error {
+	img, err := readImage(path, gocv.IMReadColor)
+	if err != nil {
+		return err
+	}
+	defer img.Close()
+
+	flat := flattenIllumination(img, dpi)
+	defer flat.Close()
+	if !gocv.IMWrite(path, flat) {
+		return fmt.Errorf("unable to write image: %s", path)
+	}
+	return nil
+}
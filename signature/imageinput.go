@@ -0,0 +1,119 @@
+package signature
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"image/draw"
+	_ "image/jpeg"
+	"image/png"
+	"io"
+	"math"
+	"net/http"
+	"os"
+
+	xdraw "golang.org/x/image/draw"
+)
+
+// isImageFile reports whether the file at path is a PNG or JPEG image, by its
+// magic bytes, so a photo spooled under a .pdf name is still recognized.
+func isImageFile(path string) (bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	head := make([]byte, 512)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return false, err
+	}
+	switch http.DetectContentType(head[:n]) {
+	case "image/png", "image/jpeg":
+		return true, nil
+	}
+	return false, nil
+}
+
+// imageRasterizer serves a PNG or JPEG photo of a page as a one-page "PDF", so
+// the whole pipeline runs on it without poppler. The image is taken to be at
+// dpi, which gives it a page box in points; renders at other resolutions are
+// resampled from it.
+type imageRasterizer struct {
+	dpi float64
+}
+
+func (r imageRasterizer) pageCount(ctx context.Context, path string) (int, error) {
+	return 1, nil
+}
+
+func (r imageRasterizer) box(ctx context.Context, path string, page int) (pageBox, error) {
+	if page != 1 {
+		return pageBox{}, fmt.Errorf("page %d is out of range: an image has 1 page", page)
+	}
+	bounds, err := imageBounds(path)
+	if err != nil {
+		return pageBox{}, err
+	}
+	scale := pointsPerInch / r.dpi
+	return pageBox{Rect: PDFRect{URX: float64(bounds.Dx()) * scale, URY: float64(bounds.Dy()) * scale}}, nil
+}
+
+func (r imageRasterizer) renderPNG(ctx context.Context, path string, page int, outPrefix string, dpi float64, crop image.Rectangle) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	src, _, err := image.Decode(f)
+	f.Close()
+	if err != nil {
+		return "", fmt.Errorf("failed to decode image %s: %v", path, err)
+	}
+
+	// Resample to the requested resolution, then keep the crop
+	var img image.Image = src
+	if dpi != r.dpi {
+		s := dpi / r.dpi
+		b := src.Bounds()
+		scaled := image.NewRGBA(image.Rect(0, 0, int(math.Ceil(float64(b.Dx())*s)), int(math.Ceil(float64(b.Dy())*s))))
+		xdraw.CatmullRom.Scale(scaled, scaled.Bounds(), src, b, xdraw.Src, nil)
+		img = scaled
+	}
+	if !crop.Empty() {
+		b := img.Bounds()
+		rect := crop.Add(b.Min).Intersect(b)
+		if rect.Empty() {
+			return "", fmt.Errorf("crop %v lies outside the %dx%d image", crop, b.Dx(), b.Dy())
+		}
+		cropped := image.NewRGBA(image.Rect(0, 0, rect.Dx(), rect.Dy()))
+		draw.Draw(cropped, cropped.Bounds(), img, rect.Min, draw.Src)
+		img = cropped
+	}
+
+	outPath := outPrefix + ".png"
+	out, err := os.Create(outPath)
+	if err != nil {
+		return "", err
+	}
+	defer out.Close()
+	if err := png.Encode(out, img); err != nil {
+		return "", fmt.Errorf("failed to encode %s: %v", outPath, err)
+	}
+	return outPath, out.Close()
+}
+
+// rasterizerFor returns the backend for the input at path: imageRasterizer at
+// Options.RenderDPI for a PNG or JPEG, the configured PDF rasterizer otherwise.
+func (e *Extractor) rasterizerFor(path string) (rasterizer, error) {
+	isImage, err := isImageFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if isImage {
+		return imageRasterizer{dpi: e.opts.RenderDPI}, nil
+	}
+	return newRasterizer(e.opts.Rasterizer, e.opts.Password, e.opts.processLimits())
+}
@@ -0,0 +1,201 @@
+package signature
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"slices"
+	"strings"
+
+	"gocv.io/x/gocv"
+)
+
+// Region classes reported as Result.Class and selectable with Options.Classes.
+const (
+	// ClassHandwritten is ink laid down by a pen: a real signature.
+	ClassHandwritten = "handwritten"
+	// ClassStamp is a rubber or facsimile stamp.
+	ClassStamp = "stamp"
+	// ClassPrintedText is a typed name or other machine-printed lettering,
+	// including a signature typed in a script font.
+	ClassPrintedText = "printed-text"
+)
+
+// Classes lists the region classes.
+var Classes = []string{ClassHandwritten, ClassStamp, ClassPrintedText}
+
+// Stamp and print recognition parameters.
+const (
+	// stampMinFrameShare is the share of the ink's bounding box the outline of
+	// a stamp's frame spans.
+	stampMinFrameShare = 0.8
+	// stampMinRoundness is the least share of its enclosing circle a round
+	// frame's outline fills.
+	stampMinRoundness = 0.7
+	// stampMaxFrameInk is the largest inked share of a frame's box: a frame
+	// around lettering is hollow, a blot is not.
+	stampMaxFrameInk = 0.5
+	// stampMinFillGain is how much closing the pinholes grows the ink of a
+	// rubber stamp, whose ink takes unevenly; pen strokes are solid and hardly
+	// grow.
+	stampMinFillGain = 1.25
+	// printedMaxStrokeVariation is the most the stroke width of print varies;
+	// it is looser than printedStrokeVariation, as letter height backs it up.
+	printedMaxStrokeVariation = 0.2
+	// printedMinGlyphs is how many separate letters a line of print has at the
+	// least; handwriting joins its letters.
+	printedMinGlyphs = 6
+	// printedMaxHeightVariation is the most the coefficient of variation of the
+	// letter heights reaches in print.
+	printedMaxHeightVariation = 0.3
+)
+
+// ParseClasses parses a comma-separated list of region classes, e.g.
+// "handwritten,stamp".
+func ParseClasses(s string) ([]string, error) {
+	var classes []string
+	for _, part := range strings.Split(s, ",") {
+		class := strings.TrimSpace(part)
+		if !slices.Contains(Classes, class) {
+			return nil, fmt.Errorf("unknown region class %q: want %s", class, strings.Join(Classes, ", "))
+		}
+		classes = append(classes, class)
+	}
+	return classes, nil
+}
+
+// keepClass reports whether a region of class is among classes; nil keeps
+// every class.
+func keepClass(classes []string, class string) bool {
+	return classes == nil || slices.Contains(classes, class)
+}
+
+// keepClasses classifies each of regions and, when classes is non-nil, drops
+// (and closes) those of the other classes, failing with ErrNoSignature when
+// none is left.
+func keepClasses(regions []SignatureRegion, classes []string) ([]SignatureRegion, error) {
+	var kept []SignatureRegion
+	for _, r := range regions {
+		r.Class = classifyRegion(r.Image)
+		if keepClass(classes, r.Class) {
+			kept = append(kept, r)
+		} else {
+			r.Image.Close()
+		}
+	}
+	if len(kept) == 0 && len(regions) > 0 {
+		return nil, fmt.Errorf("%w: all %d regions are of classes other than %s", ErrNoSignature, len(regions), strings.Join(classes, ", "))
+	}
+	return kept, nil
+}
+
+// classifyRegion labels the ink of the BGR crop of a region as handwritten, a
+// stamp or printed text. A stamp has a frame around its lettering or the
+// pinholed ink of rubber; print has the even strokes and smooth outlines of a
+// font (see classifySignature), or even strokes in a row of separate letters
+// of one height. Ink too sparse to judge counts as handwritten.
+func classifyRegion(crop gocv.Mat) string {
+	mask := inkMask(crop)
+	defer mask.Close()
+	ink := gocv.CountNonZero(mask)
+	if ink == 0 {
+		return ClassHandwritten
+	}
+	if hasStampFrame(mask) || pinholeGain(mask, ink) >= stampMinFillGain {
+		return ClassStamp
+	}
+	if label, _ := classifySignature(mask); label == signatureElectronic {
+		return ClassPrintedText
+	}
+	if variation, ok := strokeWidthVariation(mask); ok && variation < printedMaxStrokeVariation && uniformGlyphs(mask) {
+		return ClassPrintedText
+	}
+	return ClassHandwritten
+}
+
+// hasStampFrame reports whether the outline of the largest piece of ink in
+// mask is a hollow rectangle or circle around the rest of it.
+func hasStampFrame(mask gocv.Mat) bool {
+	contours := gocv.FindContours(mask, gocv.RetrievalExternal, gocv.ChainApproxSimple)
+	defer contours.Close()
+	if contours.Size() == 0 {
+		return false
+	}
+	inkBox := image.Rectangle{}
+	largest, largestArea := 0, 0.0
+	for i := 0; i < contours.Size(); i++ {
+		c := contours.At(i)
+		inkBox = inkBox.Union(gocv.BoundingRect(c))
+		if area := gocv.ContourArea(c); area > largestArea {
+			largest, largestArea = i, area
+		}
+	}
+	c := contours.At(largest)
+	frame := gocv.BoundingRect(c)
+	if float64(rectArea(frame)) < stampMinFrameShare*float64(rectArea(inkBox)) {
+		return false
+	}
+	region := mask.Region(frame)
+	framed := gocv.CountNonZero(region)
+	region.Close()
+	if float64(framed) > stampMaxFrameInk*float64(rectArea(frame)) {
+		return false
+	}
+
+	approx := gocv.ApproxPolyDP(c, boxPolygonEpsilon*gocv.ArcLength(c, true), true)
+	corners := approx.Size()
+	approx.Close()
+	if corners == 4 && largestArea >= boxMinRectangularity*float64(rectArea(frame)) {
+		return true
+	}
+	_, _, radius := gocv.MinEnclosingCircle(c)
+	return largestArea >= stampMinRoundness*math.Pi*float64(radius)*float64(radius)
+}
+
+// pinholeGain is how many times its ink pixels mask holds once the one-pixel
+// holes in its strokes are closed.
+func pinholeGain(mask gocv.Mat, ink int) float64 {
+	kernel := gocv.GetStructuringElement(gocv.MorphRect, image.Pt(3, 3))
+	defer kernel.Close()
+	closed := gocv.NewMat()
+	defer closed.Close()
+	gocv.MorphologyEx(mask, &closed, gocv.MorphClose, kernel)
+	return float64(gocv.CountNonZero(closed)) / float64(ink)
+}
+
+// uniformGlyphs reports whether mask holds a row of at least printedMinGlyphs
+// separate letters of much the same height, as print does. Dots and specks
+// under a third of the tallest letter are left out.
+func uniformGlyphs(mask gocv.Mat) bool {
+	labels := gocv.NewMat()
+	defer labels.Close()
+	stats := gocv.NewMat()
+	defer stats.Close()
+	centroids := gocv.NewMat()
+	defer centroids.Close()
+	n := gocv.ConnectedComponentsWithStats(mask, &labels, &stats, &centroids)
+
+	heights := make([]float64, 0, n)
+	tallest := 0.0
+	// Label 0 is the background
+	for i := 1; i < n; i++ {
+		h := float64(stats.GetIntAt(i, int(gocv.CC_STAT_HEIGHT)))
+		heights = append(heights, h)
+		tallest = max(tallest, h)
+	}
+	var sum, sumSq float64
+	glyphs := 0
+	for _, h := range heights {
+		if h >= tallest/3 {
+			glyphs++
+			sum += h
+			sumSq += h * h
+		}
+	}
+	if glyphs < printedMinGlyphs {
+		return false
+	}
+	mean := sum / float64(glyphs)
+	std := math.Sqrt(max(sumSq/float64(glyphs)-mean*mean, 0))
+	return std/mean <= printedMaxHeightVariation
+}
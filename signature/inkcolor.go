@@ -0,0 +1,65 @@
+package signature
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"strconv"
+	"strings"
+)
+
+// Named values for Options.InkColor; any #RRGGBB color is accepted too.
+const (
+	// InkOriginal keeps the scanned ink color, e.g. blue ballpoint.
+	InkOriginal = "original"
+	// InkBlack normalizes every signature to black.
+	InkBlack = "black"
+)
+
+// ValidateInkColor checks an Options.InkColor setting: "", original, black or a hex color.
+func ValidateInkColor(setting string) error {
+	_, _, err := parseInkColor(setting)
+	return err
+}
+
+// parseInkColor resolves an Options.InkColor setting to the color opaque pixels
+// are painted with; recolor is false when the scanned color is kept.
+func parseInkColor(setting string) (c color.RGBA, recolor bool, err error) {
+	switch setting {
+	case "", InkOriginal:
+		return color.RGBA{}, false, nil
+	case InkBlack:
+		return color.RGBA{A: 255}, true, nil
+	}
+	c, ok := parseHexColor(setting)
+	if !ok {
+		return color.RGBA{}, false, fmt.Errorf("invalid ink color %q: want %s, %s or #RRGGBB", setting, InkOriginal, InkBlack)
+	}
+	return c, true, nil
+}
+
+// parseHexColor parses an opaque hex color such as "#d8ecd0" or "d8ecd0".
+func parseHexColor(s string) (color.RGBA, bool) {
+	hex := strings.TrimPrefix(s, "#")
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil || len(hex) != 6 {
+		return color.RGBA{}, false
+	}
+	return color.RGBA{R: uint8(v >> 16), G: uint8(v >> 8), B: uint8(v), A: 255}, true
+}
+
+// recolorInk paints every visible pixel of img with ink, keeping its alpha, so a
+// soft matte keeps its anti-aliased edges. img is premultiplied, so each channel
+// becomes ink scaled by the pixel's alpha.
+func recolorInk(img *image.RGBA, ink color.RGBA) {
+	pix := img.Pix
+	for i := 0; i < len(pix); i += 4 {
+		a := uint32(pix[i+3])
+		if a == 0 {
+			continue
+		}
+		pix[i] = uint8((uint32(ink.R)*a + 127) / 255)
+		pix[i+1] = uint8((uint32(ink.G)*a + 127) / 255)
+		pix[i+2] = uint8((uint32(ink.B)*a + 127) / 255)
+	}
+}
@@ -0,0 +1,87 @@
+package signature
+
+import (
+	"image"
+
+	"gocv.io/x/gocv"
+)
+
+// Ruled-line removal parameters, in pixels at thresholdReferenceDPI.
+const (
+	// minRuleLength is the shortest straight run of ink (about 10mm) taken for a
+	// printed signing line or form box edge; pen strokes are rarely this straight.
+	minRuleLength = 60
+	// ruleRepairGap is the widest gap a removed rule may leave in a stroke
+	// crossing it that is bridged again.
+	ruleRepairGap = 4
+)
+
+// ruleMask returns the long horizontal and vertical structures of bin (ink 255),
+// found by opening it with a line-shaped kernel in each direction.
+func ruleMask(bin gocv.Mat, dpi float64) gocv.Mat {
+	length := scaleLength(minRuleLength, dpi)
+	rules := openWithLine(bin, image.Pt(length, 1))
+	vertical := openWithLine(bin, image.Pt(1, length))
+	defer vertical.Close()
+	gocv.BitwiseOr(rules, vertical, &rules)
+	return rules
+}
+
+// openWithLine opens bin with a rectangular kernel of size, keeping only ink
+// runs at least that long in its direction.
+func openWithLine(bin gocv.Mat, size image.Point) gocv.Mat {
+	kernel := gocv.GetStructuringElement(gocv.MorphRect, size)
+	defer kernel.Close()
+	dst := gocv.NewMat()
+	gocv.MorphologyEx(bin, &dst, gocv.MorphOpen, kernel)
+	return dst
+}
+
+// removeRules subtracts the ruled lines (see ruleMask) from bin in place and
+// repairs the strokes they crossed. It returns the pixels that were removed
+// for good: rule pixels not restored by the repair.
+func removeRules(bin *gocv.Mat, dpi float64) gocv.Mat {
+	rules := ruleMask(*bin, dpi)
+	defer rules.Close()
+	gocv.Subtract(*bin, rules, bin)
+
+	// A stroke crossing a rule is cut where the rule was; closing bridges the
+	// cut, and only bridges inside the rule are kept so nothing else grows
+	gap := scaleLength(ruleRepairGap, dpi)
+	kernel := gocv.GetStructuringElement(gocv.MorphRect, image.Pt(gap, gap))
+	defer kernel.Close()
+	repaired := gocv.NewMat()
+	defer repaired.Close()
+	gocv.MorphologyEx(*bin, &repaired, gocv.MorphClose, kernel)
+	gocv.BitwiseAnd(repaired, rules, &repaired)
+	gocv.BitwiseOr(*bin, repaired, bin)
+
+	removed := gocv.NewMat()
+	gocv.Subtract(rules, repaired, &removed)
+	return removed
+}
+
+// whitenRules returns a copy of the BGR crop with its ruled lines painted
+// paper-white, so they become transparent along with the background. The
+// line's anti-aliased fringe goes with it, except where a stroke crosses.
+func whitenRules(crop gocv.Mat, dpi float64) gocv.Mat {
+	gray := gocv.NewMat()
+	defer gray.Close()
+	gocv.CvtColor(crop, &gray, gocv.ColorBGRToGray)
+	bin := gocv.NewMat()
+	defer bin.Close()
+	gocv.Threshold(gray, &bin, inkThreshold, 255, gocv.ThresholdBinaryInv)
+
+	removed := removeRules(&bin, dpi)
+	defer removed.Close()
+	fringe := gocv.GetStructuringElement(gocv.MorphRect, image.Pt(3, 3))
+	defer fringe.Close()
+	gocv.Dilate(removed, &removed, fringe)
+	gocv.Subtract(removed, bin, &removed)
+
+	out := crop.Clone()
+	white := gocv.NewMatWithSizeFromScalar(gocv.NewScalar(255, 255, 255, 0), crop.Rows(), crop.Cols(), crop.Type())
+	defer white.Close()
+	white.CopyToWithMask(&out, removed)
+	return out
+}
@@ -0,0 +1,313 @@
+package signature
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"image"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+
+	"gocv.io/x/gocv"
+)
+
+// Kinds of box a mark is looked for in, reported as Result.Mark and usable as
+// a Zone.Kind.
+const (
+	MarkCheckbox = "checkbox"
+	MarkInitials = "initials"
+)
+
+// Printed box sizes, in millimetres, told apart by shape: checkboxes are
+// small squares, initials boxes wider rectangles.
+const (
+	checkboxMinMM, checkboxMaxMM = 2.5, 10
+	// checkboxMaxAspect is how far from square a checkbox may be.
+	checkboxMaxAspect                        = 1.4
+	initialsMinWidthMM, initialsMaxWidthMM   = 8, 40
+	initialsMinHeightMM, initialsMaxHeightMM = 5, 20
+)
+
+// Box recognition and judging.
+const (
+	// boxMinRectangularity is the least share of its bounding box a contour
+	// must fill to be a printed box rather than a loop of handwriting.
+	boxMinRectangularity = 0.8
+	// boxPolygonEpsilon is the ApproxPolyDP tolerance, as a fraction of the
+	// contour's perimeter, at which a box simplifies to 4 corners.
+	boxPolygonEpsilon = 0.04
+	// boxBorderInset is the fraction of the box's shorter side cut from every
+	// edge before its ink is measured, so the printed border doesn't count.
+	boxBorderInset = 0.15
+	// markMinInk is the share of a box's inside that must be inked for it to
+	// count as marked; a specked scan stays below it.
+	markMinInk = 0.03
+)
+
+// MarkReport is the JSON listing of the boxes of one page, written as
+// {prefix}_marks.json with Options.Marks or mark zones, marked or not.
+type MarkReport struct {
+	Source string  `json:"source"`
+	Page   int     `json:"page"`
+	DPI    float64 `json:"dpi"`
+	// Boxes are every box found, in reading order.
+	Boxes []MarkBox `json:"boxes"`
+}
+
+// MarkBox is one box in a MarkReport.
+type MarkBox struct {
+	// Number is the box's 1-based position on the page, which names its crop.
+	Number int `json:"number"`
+	// Kind is MarkCheckbox or MarkInitials.
+	Kind string `json:"kind"`
+	// Zone is the Options.Template zone the box was given as, if any.
+	Zone string `json:"zone,omitempty"`
+	// Bounds is the box in page pixels at DPI, origin top-left, as in Metadata.
+	Bounds PixelBounds `json:"bounds_px"`
+	// PDFBounds is the box in PDF points, origin bottom-left.
+	PDFBounds PDFRect `json:"bounds_pt"`
+	// Ink is the share of the inside of the box that is inked.
+	Ink    float64 `json:"ink"`
+	Marked bool    `json:"marked"`
+	// OutputPath is the crop of a marked box.
+	OutputPath string `json:"output_path,omitempty"`
+}
+
+// ValidMarkKind reports whether kind is MarkCheckbox or MarkInitials.
+func ValidMarkKind(kind string) bool {
+	return kind == MarkCheckbox || kind == MarkInitials
+}
+
+// markBox is a box of a page in pixels of the detection render.
+type markBox struct {
+	rect image.Rectangle
+	kind string
+	zone string
+	ink  float64
+}
+
+// boxKind classifies a rectangle of a page rendered at dpi by its size, or
+// returns "" when it is no box a mark goes in.
+func boxKind(rect image.Rectangle, dpi float64) string {
+	w, h := physicalSizeMM(rect.Size(), dpi)
+	long, short := max(w, h), min(w, h)
+	switch {
+	case short >= checkboxMinMM && long <= checkboxMaxMM && long <= checkboxMaxAspect*short:
+		return MarkCheckbox
+	case w >= initialsMinWidthMM && w <= initialsMaxWidthMM && h >= initialsMinHeightMM && h <= initialsMaxHeightMM:
+		return MarkInitials
+	}
+	return ""
+}
+
+// findBoxes returns the printed checkboxes and initials boxes of the ink mask
+// bin of a page rendered at dpi: contours that simplify to 4 corners, fill
+// their bounding box and have the size of a box. The inner and outer edge of
+// one border give two contours; only the outer one is kept.
+func findBoxes(bin gocv.Mat, dpi float64) []markBox {
+	contours := gocv.FindContours(bin, gocv.RetrievalList, gocv.ChainApproxSimple)
+	defer contours.Close()
+
+	var boxes []markBox
+	for i := 0; i < contours.Size(); i++ {
+		c := contours.At(i)
+		rect := gocv.BoundingRect(c)
+		kind := boxKind(rect, dpi)
+		if kind == "" || gocv.ContourArea(c) < boxMinRectangularity*float64(rectArea(rect)) {
+			continue
+		}
+		approx := gocv.ApproxPolyDP(c, boxPolygonEpsilon*gocv.ArcLength(c, true), true)
+		corners := approx.Size()
+		approx.Close()
+		if corners != 4 {
+			continue
+		}
+		boxes = append(boxes, markBox{rect: rect, kind: kind})
+	}
+
+	sort.SliceStable(boxes, func(i, j int) bool { return rectArea(boxes[i].rect) > rectArea(boxes[j].rect) })
+	var kept []markBox
+	for _, b := range boxes {
+		inner := false
+		for _, k := range kept {
+			if overlap := b.rect.Intersect(k.rect); rectArea(overlap) > rectArea(b.rect)/2 {
+				inner = true
+				break
+			}
+		}
+		if !inner {
+			kept = append(kept, b)
+		}
+	}
+	return kept
+}
+
+// boxInk measures the share of the inside of rect that bin inks.
+func boxInk(bin gocv.Mat, rect image.Rectangle) float64 {
+	inset := max(2, int(boxBorderInset*float64(min(rect.Dx(), rect.Dy()))))
+	inside := rect.Inset(inset)
+	if inside.Empty() {
+		return 0
+	}
+	region := bin.Region(inside)
+	defer region.Close()
+	return float64(gocv.CountNonZero(region)) / float64(rectArea(inside))
+}
+
+// pageMarks returns the boxes of a page and how much ink each holds: the mark
+// zones given, or with none the boxes printed on the page when Options.Marks is
+// set. The zones are placed like signature form fields (see fieldRegions).
+func (e *Extractor) pageMarks(page pageRender, box pageBox, upsideDown bool, zones []Zone) ([]markBox, error) {
+	if len(zones) == 0 && !e.opts.Marks {
+		return nil, nil
+	}
+	img, err := readImage(page.Path, gocv.IMReadColor)
+	if err != nil {
+		return nil, err
+	}
+	defer img.Close()
+	gray := pageBuffers.get()
+	defer pageBuffers.put(gray)
+	gocv.CvtColor(img, &gray, gocv.ColorBGRToGray)
+	bin, _, _ := binarize(gray, e.opts.Binarization, page.DPI)
+	defer pageBuffers.put(bin)
+	if e.opts.ColorInk {
+		if err := addColorInk(img, &bin); err != nil {
+			return nil, fmt.Errorf("failed to segment colored ink: %v", err)
+		}
+	}
+
+	var boxes []markBox
+	if len(zones) > 0 {
+		renderRect := image.Rect(0, 0, page.Size.X, page.Size.Y)
+		for _, z := range zones {
+			rect := pdfRectToPixels(z.Rect.toPDF(box), page.DPI, box).Sub(page.Origin).Intersect(renderRect)
+			if rect.Empty() {
+				continue
+			}
+			if upsideDown {
+				rect = rotateRect180(rect, page.Size)
+			}
+			boxes = append(boxes, markBox{rect: rect, kind: z.Kind, zone: z.Name})
+		}
+	} else {
+		boxes = findBoxes(bin, page.DPI)
+	}
+	for i := range boxes {
+		boxes[i].ink = boxInk(bin, boxes[i].rect)
+	}
+	sort.SliceStable(boxes, func(i, j int) bool { return readingOrder(boxes[i].rect, boxes[j].rect) })
+	return boxes, nil
+}
+
+// extractMarks writes a crop of every marked one of boxes, found on the page of
+// st, named after its zone or as {kind}_{n}, and the page's MarkReport as
+// {prefix}_marks.json. It returns a result for each marked box.
+func (e *Extractor) extractMarks(ctx context.Context, st *pageState, boxes []markBox) ([]*Result, error) {
+	opts := st.opts
+	img, err := readImage(st.page.Path, gocv.IMReadColor)
+	if err != nil {
+		return nil, err
+	}
+	defer img.Close()
+	imgRect := image.Rect(0, 0, img.Cols(), img.Rows())
+
+	report := MarkReport{Source: st.pdfPath, Page: st.pageNum, DPI: opts.RenderDPI, Boxes: make([]MarkBox, len(boxes))}
+	var results []*Result
+	for i, b := range boxes {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		n := i + 1
+		bounds := st.pageBounds(b.rect)
+		entry := MarkBox{
+			Number:    n,
+			Kind:      b.kind,
+			Zone:      b.zone,
+			Bounds:    PixelBounds{X: bounds.Min.X, Y: bounds.Min.Y, Width: bounds.Dx(), Height: bounds.Dy()},
+			PDFBounds: pixelRectToPDF(bounds, opts.RenderDPI, st.box),
+			Ink:       b.ink,
+			Marked:    b.ink >= markMinInk,
+		}
+		if entry.Marked {
+			name := b.kind + "_" + strconv.Itoa(n)
+			if b.zone != "" {
+				name = b.zone
+			}
+			res, err := e.writeMark(ctx, st, img, b.rect.Inset(-opts.CropPaddingPx).Intersect(imgRect), name)
+			if err != nil {
+				return nil, fmt.Errorf("%s %d: %w", b.kind, n, err)
+			}
+			res.Mark, res.Box, res.Zone = b.kind, n, b.zone
+			if b.zone != "" {
+				res.Template = st.template
+			}
+			// Half confidence at the threshold, full from twice its ink
+			res.Confidence = min(1, b.ink/(2*markMinInk))
+			if err := st.later(ctx, func(ctx context.Context) error { return e.deliver(ctx, res) }); err != nil {
+				return nil, err
+			}
+			entry.OutputPath = res.OutputPath
+			results = append(results, res)
+		}
+		e.logf("%s %d: %.1f%% inked, marked: %t", b.kind, n, 100*b.ink, entry.Marked)
+		report.Boxes[i] = entry
+	}
+
+	path := filepath.Join(opts.OutputDir, outputName(st.outPrefix, "marks.json"))
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode mark report: %v", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+		return nil, fmt.Errorf("failed to write mark report: %v", err)
+	}
+	e.logf("Mark report saved to %s", path)
+	return results, nil
+}
+
+// writeMark crops rect of the detection render img, removes its background
+// like a signature's and queues writing it as name in Options.Format.
+func (e *Extractor) writeMark(ctx context.Context, st *pageState, img gocv.Mat, rect image.Rectangle, name string) (*Result, error) {
+	opts := st.opts
+	crop := img.Region(rect)
+	defer crop.Close()
+	markImage, err := e.removeBackground(crop)
+	if err != nil {
+		return nil, fmt.Errorf("failed to remove background: %v", err)
+	}
+	bounds := st.pageBounds(rect)
+	res := &Result{
+		Source:     st.pdfPath,
+		Page:       st.pageNum,
+		PagePath:   st.page.Path,
+		Baseline:   -1,
+		DPI:        opts.RenderDPI,
+		OutputDPI:  opts.RenderDPI,
+		Rotation:   st.rotation,
+		Skew:       st.skew,
+		Bounds:     bounds,
+		PDFBounds:  pixelRectToPDF(bounds, opts.RenderDPI, st.box),
+		PageBox:    st.box.Rect,
+		PageRotate: st.box.Rotate,
+		Image:      markImage,
+		OutputPath: filepath.Join(opts.OutputDir, outputName(st.outPrefix, name+"."+formatExtension(opts.Format))),
+	}
+	res.WidthMM, res.HeightMM = physicalSizeMM(rect.Size(), opts.RenderDPI)
+	owned := crop.Clone()
+	err = st.later(ctx, func(ctx context.Context) error {
+		defer owned.Close()
+		if err := e.writeOutput(ctx, owned, markImage, res.OutputPath); err != nil {
+			return err
+		}
+		e.logf("Mark saved to %s", res.OutputPath)
+		return nil
+	})
+	if err != nil {
+		owned.Close()
+		return nil, err
+	}
+	return res, nil
+}
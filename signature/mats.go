@@ -0,0 +1,94 @@
+package signature
+
+import (
+	"fmt"
+	"sync"
+
+	"gocv.io/x/gocv"
+)
+
+// maxPooledMats is how many page buffers pageBuffers keeps for reuse; any
+// more are closed when returned. A 300 DPI A4 page is about 9 MB a channel.
+const maxPooledMats = 8
+
+// pageBuffers pools the page-sized gray and binary images of detection. OpenCV
+// reallocates the data of a destination Mat only when its size or type
+// changes, so on a run of similar pages a pooled buffer is reused as is and a
+// long-running server doesn't allocate and free a page's worth per step.
+var pageBuffers matPool
+
+// matPool is a bounded free list of Mats. Unlike a sync.Pool it never drops a
+// Mat without closing it, since the garbage collector can't free native memory.
+type matPool struct {
+	mu           sync.Mutex
+	free         []gocv.Mat
+	hits, misses int64
+}
+
+// get returns a pooled Mat, or a new one when the pool is empty. Its contents
+// are stale, so use it only as the destination of an operation, and hand it
+// back with put instead of closing it.
+func (p *matPool) get() gocv.Mat {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if n := len(p.free); n > 0 {
+		m := p.free[n-1]
+		p.free = p.free[:n-1]
+		p.hits++
+		return m
+	}
+	p.misses++
+	return gocv.NewMat()
+}
+
+// put hands m back for reuse, or closes it when the pool is full. m must not
+// be used afterwards, nor be a Region sharing the data of another Mat.
+func (p *matPool) put(m gocv.Mat) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.free) >= maxPooledMats {
+		m.Close()
+		return
+	}
+	p.free = append(p.free, m)
+}
+
+// MatStats counts the native OpenCV images of the process, to tell a leak in
+// a long-running server from a busy one.
+type MatStats struct {
+	// Live is how many Mats are open, pooled ones included, or -1 unless the
+	// program was built with -tags matprofile, which tracks every Mat.
+	Live int `json:"live"`
+	// Pooled is how many page buffers wait in the pool for reuse.
+	Pooled int `json:"pooled"`
+	// PoolHits and PoolMisses count the page buffers taken from the pool and
+	// allocated anew since the start.
+	PoolHits   int64 `json:"pool_hits"`
+	PoolMisses int64 `json:"pool_misses"`
+}
+
+// liveMats counts the open Mats; the matprofile build sets it.
+var liveMats func() int
+
+// ReadMatStats returns the current MatStats.
+func ReadMatStats() MatStats {
+	s := MatStats{Live: -1}
+	if liveMats != nil {
+		s.Live = liveMats()
+	}
+	pageBuffers.mu.Lock()
+	defer pageBuffers.mu.Unlock()
+	s.Pooled, s.PoolHits, s.PoolMisses = len(pageBuffers.free), pageBuffers.hits, pageBuffers.misses
+	return s
+}
+
+// readImage loads the image at path with gocv.IMRead. An unreadable file
+// still gives an (empty) Mat, which is closed here before failing.
+func readImage(path string, flags gocv.IMReadFlag) (gocv.Mat, error) {
+	img := gocv.IMRead(path, flags)
+	if img.Empty() {
+		img.Close()
+		return gocv.Mat{}, fmt.Errorf("unable to read image: %s", path)
+	}
+	return img, nil
+}
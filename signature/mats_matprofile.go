@@ -0,0 +1,9 @@
+//go:build matprofile
+
+package signature
+
+import "gocv.io/x/gocv"
+
+func init() {
+	liveMats = func() int { return gocv.MatProfile.Count() }
+}
@@ -0,0 +1,50 @@
+package signature
+
+import "image"
+
+// mergeNearby groups rects lying within gap pixels of each other, horizontally
+// and vertically, and returns the bounding box of each group. Grouping is
+// transitive: a chain of close fragments, like the separate loops of a
+// light-pressure signature, becomes one region even if its ends are far apart.
+func mergeNearby(rects []image.Rectangle, gap int) []image.Rectangle {
+	// Union-find over the rects, joining every close pair
+	parent := make([]int, len(rects))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+	for i := range rects {
+		for j := i + 1; j < len(rects); j++ {
+			if rectGap(rects[i], rects[j]) <= gap {
+				parent[find(i)] = find(j)
+			}
+		}
+	}
+
+	groups := map[int]int{} // root -> index in merged
+	var merged []image.Rectangle
+	for i, r := range rects {
+		root := find(i)
+		if k, ok := groups[root]; ok {
+			merged[k] = merged[k].Union(r)
+			continue
+		}
+		groups[root] = len(merged)
+		merged = append(merged, r)
+	}
+	return merged
+}
+
+// rectGap is the larger of the horizontal and vertical distances between a and
+// b, 0 when they touch or overlap.
+func rectGap(a, b image.Rectangle) int {
+	dx := max(0, a.Min.X-b.Max.X, b.Min.X-a.Max.X)
+	dy := max(0, a.Min.Y-b.Max.Y, b.Min.Y-a.Max.Y)
+	return max(dx, dy)
+}
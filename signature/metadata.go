@@ -0,0 +1,135 @@
+package signature
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// metadataSuffix replaces the output's extension in the name of its metadata
+// file. It is not plain .json so it can't collide with -format strokes output.
+const metadataSuffix = ".meta.json"
+
+// Metadata is the JSON description of a Result written by Options.Metadata.
+type Metadata struct {
+	Source     string  `json:"source"`
+	Page       int     `json:"page"`
+	Region     int     `json:"region,omitempty"`
+	OutputPath string  `json:"output_path"`
+	DPI        float64 `json:"dpi"`
+	OutputDPI  float64 `json:"output_dpi"`
+	// Bounds is the signature in page pixels at DPI, origin top-left.
+	Bounds PixelBounds `json:"bounds_px"`
+	// PDFBounds is the signature in PDF points, origin bottom-left.
+	PDFBounds PDFRect `json:"bounds_pt"`
+	// PageBox and PageRotate are the rendered page area in PDF points and its
+	// /Rotate (see Result.PageBox).
+	PageBox    PDFRect `json:"page_box_pt"`
+	PageRotate int     `json:"page_rotate"`
+	Confidence float64 `json:"confidence"`
+	// ConfidenceFactors are the sub-scores of Confidence; omitted with -detector onnx.
+	ConfidenceFactors *ConfidenceFactors `json:"confidence_factors,omitempty"`
+	// SignatureType is omitted for seals and marks.
+	SignatureType string  `json:"signature_type,omitempty"`
+	EdgeTouch     bool    `json:"edge_touch"`
+	FormField     string  `json:"form_field,omitempty"`
+	Zone          string  `json:"zone,omitempty"`
+	Template      string  `json:"template,omitempty"`
+	Seal          int     `json:"seal,omitempty"`
+	SealColor     string  `json:"seal_color,omitempty"`
+	Mark          string  `json:"mark,omitempty"`
+	Box           int     `json:"box,omitempty"`
+	Rotation      int     `json:"rotation"`
+	Skew          float64 `json:"skew_deg,omitempty"`
+	WidthMM       float64 `json:"width_mm"`
+	HeightMM      float64 `json:"height_mm"`
+	// Quality rates the capture; omitted for seals and marks.
+	Quality *Quality `json:"quality,omitempty"`
+	// Class is omitted for seals and marks.
+	Class string `json:"class,omitempty"`
+	// SignerName, SignerDate and SignerText are set with -signer-ocr.
+	SignerName string   `json:"signer_name,omitempty"`
+	SignerDate string   `json:"signer_date,omitempty"`
+	SignerText []string `json:"signer_text,omitempty"`
+	// Hash is the perceptual InkHash of the output; DuplicateOf is set with
+	// -dedup link.
+	Hash        string `json:"ink_hash,omitempty"`
+	DuplicateOf string `json:"duplicate_of,omitempty"`
+	// Image is the output itself with -encode, in base64 or as a data URI.
+	Image string `json:"image,omitempty"`
+	// DigitalSignature is set with -digital-signatures for a signature in a
+	// digitally signed field.
+	DigitalSignature *DigitalSignature `json:"digital_signature,omitempty"`
+}
+
+// PixelBounds is a pixel rectangle in Metadata.
+type PixelBounds struct {
+	X      int `json:"x"`
+	Y      int `json:"y"`
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+// Metadata describes r for downstream systems.
+func (r *Result) Metadata() Metadata {
+	m := Metadata{
+		Source:           r.Source,
+		Page:             r.Page,
+		Region:           r.Region,
+		OutputPath:       r.OutputPath,
+		DPI:              r.DPI,
+		OutputDPI:        r.OutputDPI,
+		Bounds:           PixelBounds{X: r.Bounds.Min.X, Y: r.Bounds.Min.Y, Width: r.Bounds.Dx(), Height: r.Bounds.Dy()},
+		PDFBounds:        r.PDFBounds,
+		PageBox:          r.PageBox,
+		PageRotate:       r.PageRotate,
+		Confidence:       r.Confidence,
+		SignatureType:    r.SignatureType,
+		EdgeTouch:        r.EdgeTouch,
+		FormField:        r.FormField,
+		Zone:             r.Zone,
+		Template:         r.Template,
+		Seal:             r.Seal,
+		SealColor:        r.SealColor,
+		Mark:             r.Mark,
+		Box:              r.Box,
+		Rotation:         r.Rotation,
+		Skew:             r.Skew,
+		WidthMM:          r.WidthMM,
+		HeightMM:         r.HeightMM,
+		Quality:          r.Quality,
+		Class:            r.Class,
+		SignerName:       r.SignerName,
+		SignerDate:       r.SignerDate,
+		SignerText:       r.SignerText,
+		Hash:             r.Hash,
+		DuplicateOf:      r.DuplicateOf,
+		Image:            r.Encoded,
+		DigitalSignature: r.DigitalSignature,
+	}
+	if r.ConfidenceFactors != (ConfidenceFactors{}) {
+		factors := r.ConfidenceFactors
+		m.ConfidenceFactors = &factors
+	}
+	return m
+}
+
+// metadataPath names the metadata file of the output at outputPath, e.g.
+// signature_result.png -> signature_result.meta.json.
+func metadataPath(outputPath string) string {
+	return strings.TrimSuffix(outputPath, filepath.Ext(outputPath)) + metadataSuffix
+}
+
+// writeMetadata writes the metadata of res as indented JSON to path.
+func writeMetadata(res *Result, path string) error {
+	data, err := json.MarshalIndent(res.Metadata(), "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode metadata: %v", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("failed to write metadata: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package signature
+
+import (
+	"fmt"
+	"image"
+
+	"gocv.io/x/gocv"
+)
+
+// Orientation heuristic tuning. Pixel values are at thresholdReferenceDPI.
+const (
+	// orientationMinLineHeight ignores projection runs too thin to be text lines.
+	orientationMinLineHeight = 6
+	// orientationMargin is how lopsided the ascender/descender balance must be
+	// (as a fraction of their total) before the page is called upside down.
+	orientationMargin = 0.1
+	// orientationMinInk is the least ascender+descender ink needed to decide.
+	orientationMinInk = 500
+)
+
+// detectUpsideDown decides whether a rendered page is rotated by 180°. In upright
+// Latin text, ascenders (b, d, h, k, l, t, capitals) put clearly more ink above
+// each line's x-height band than descenders (g, j, p, q, y) put below it; turning
+// the page over reverses that balance. The returned score is (above-below)/(above+below)
+// summed over all text lines: positive means upright. ok is false when the page has
+// too little text to tell. dpi is the resolution of the render.
+func detectUpsideDown(pagePath string, dpi float64) (upsideDown bool, score float64, ok bool, err error) {
+	gray, err := readImage(pagePath, gocv.IMReadGrayScale)
+	if err != nil {
+		return false, 0, false, err
+	}
+	defer gray.Close()
+
+	mask := gocv.NewMat()
+	defer mask.Close()
+	gocv.Threshold(gray, &mask, inkThreshold, 255, gocv.ThresholdBinaryInv)
+
+	rows := make([]int, mask.Rows())
+	for y := range rows {
+		for x := 0; x < mask.Cols(); x++ {
+			if mask.GetUCharAt(y, x) != 0 {
+				rows[y]++
+			}
+		}
+	}
+
+	minLineHeight := scaleLength(orientationMinLineHeight, dpi)
+	var above, below float64
+	for y := 0; y < len(rows); {
+		if rows[y] == 0 {
+			y++
+			continue
+		}
+		top := y
+		for y < len(rows) && rows[y] > 0 {
+			y++
+		}
+		if y-top < minLineHeight {
+			continue
+		}
+		a, b := lineAscenderBalance(rows[top:y])
+		above += a
+		below += b
+	}
+
+	total := above + below
+	if total < scaleArea(orientationMinInk, dpi) {
+		return false, 0, false, nil
+	}
+	score = (above - below) / total
+	return score < -orientationMargin, score, true, nil
+}
+
+// lineAscenderBalance splits one text line's row projection into the ink above and
+// below its x-height band (the contiguous dense rows around the densest row).
+func lineAscenderBalance(line []int) (above, below float64) {
+	peak := 0
+	for i, v := range line {
+		if v > line[peak] {
+			peak = i
+		}
+	}
+	limit := float64(line[peak]) * baselineBandRatio
+	coreTop, coreBottom := peak, peak
+	for coreTop > 0 && float64(line[coreTop-1]) >= limit {
+		coreTop--
+	}
+	for coreBottom+1 < len(line) && float64(line[coreBottom+1]) >= limit {
+		coreBottom++
+	}
+
+	for _, v := range line[:coreTop] {
+		above += float64(v)
+	}
+	for _, v := range line[coreBottom+1:] {
+		below += float64(v)
+	}
+	return above, below
+}
+
+// rotateImageFile180 turns an image file upside down in place.
+func rotateImageFile180(path string) error {
+	img, err := readImage(path, gocv.IMReadUnchanged)
+	if err != nil {
+		return err
+	}
+	defer img.Close()
+
+	rotated := gocv.NewMat()
+	defer rotated.Close()
+	gocv.Rotate(img, &rotated, gocv.Rotate180Clockwise)
+	if !gocv.IMWrite(path, rotated) {
+		return fmt.Errorf("unable to write image: %s", path)
+	}
+	return nil
+}
+
+// rotateRect180 maps a rectangle between an image of the given size and the same
+// image rotated by 180°. It is its own inverse.
+func rotateRect180(r image.Rectangle, size image.Point) image.Rectangle {
+	return image.Rect(size.X-r.Max.X, size.Y-r.Max.Y, size.X-r.Min.X, size.Y-r.Min.Y)
+}
@@ -0,0 +1,108 @@
+package signature
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+// drawTextLines prints lines of blocky Latin-looking text between rows top and
+// bottom: glyphs are 2px bars with a 10px x-height, two in five rising into an
+// ascender and one in ten dropping into a descender, like upright English.
+func drawTextLines(img *image.RGBA, top, bottom int) {
+	black := color.RGBA{A: 255}
+	bar := func(x, y0, y1 int) {
+		for y := y0; y < y1; y++ {
+			img.SetRGBA(x, y, black)
+			img.SetRGBA(x+1, y, black)
+		}
+	}
+	for y := top; y+24 < bottom; y += 30 {
+		for i, x := 0, 80; x < 770; i, x = i+1, x+5 {
+			if i%9 == 8 {
+				continue // word space
+			}
+			bar(x, y+7, y+17)
+			switch {
+			case i%5 == 1 || i%5 == 3:
+				bar(x, y, y+7)
+			case i%10 == 4:
+				bar(x, y+17, y+24)
+			}
+		}
+	}
+}
+
+// rotate180 returns img turned upside down.
+func rotate180(img *image.RGBA) *image.RGBA {
+	b := img.Bounds()
+	out := image.NewRGBA(b)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			out.SetRGBA(b.Max.X-1-(x-b.Min.X), b.Max.Y-1-(y-b.Min.Y), img.RGBAAt(x, y))
+		}
+	}
+	return out
+}
+
+// inkOverlap is the intersection over union of the ink of a and b, each
+// taken from the top-left of its own ink bounding box.
+func inkOverlap(a, b *image.RGBA) float64 {
+	inkBounds := func(img *image.RGBA) image.Rectangle {
+		var r image.Rectangle
+		for y := img.Rect.Min.Y; y < img.Rect.Max.Y; y++ {
+			for x := img.Rect.Min.X; x < img.Rect.Max.X; x++ {
+				if isInk(img.RGBAAt(x, y)) {
+					r = r.Union(image.Rect(x, y, x+1, y+1))
+				}
+			}
+		}
+		return r
+	}
+	ra, rb := inkBounds(a), inkBounds(b)
+	var both, either int
+	for y := range max(ra.Dy(), rb.Dy()) {
+		for x := range max(ra.Dx(), rb.Dx()) {
+			ia := isInk(a.RGBAAt(ra.Min.X+x, ra.Min.Y+y)) && x < ra.Dx() && y < ra.Dy()
+			ib := isInk(b.RGBAAt(rb.Min.X+x, rb.Min.Y+y)) && x < rb.Dx() && y < rb.Dy()
+			if ia && ib {
+				both++
+			}
+			if ia || ib {
+				either++
+			}
+		}
+	}
+	if either == 0 {
+		return 0
+	}
+	return float64(both) / float64(either)
+}
+
+func TestAutoOrientTurnsUpsideDownPage(t *testing.T) {
+	dir := t.TempDir()
+	upright := newPage(850, 1100)
+	drawTextLines(upright, 100, 600)
+	drawSignature(upright, 300, 850, 0, 1, blueInk)
+
+	opts := fixtureOptions(t)
+	opts.AutoOrient = true
+	want := extractFixture(t, opts, writeFixture(t, dir, "upright.png", upright))
+	if len(want) != 1 || want[0].Rotation != 0 {
+		t.Fatalf("upright page: results %v, want one with Rotation 0", want)
+	}
+
+	opts.OutputDir = t.TempDir()
+	got := extractFixture(t, opts, writeFixture(t, dir, "upside_down.png", rotate180(upright)))
+	if len(got) != 1 {
+		t.Fatalf("upside-down page: got %d results, want 1", len(got))
+	}
+	if got[0].Rotation != 180 {
+		t.Errorf("Rotation = %d, want 180", got[0].Rotation)
+	}
+	// Upright, the signature matches the one from the upright page and not its turned copy
+	same, turned := inkOverlap(got[0].Image, want[0].Image), inkOverlap(rotate180(got[0].Image), want[0].Image)
+	if same < 0.6 || same <= turned {
+		t.Errorf("ink overlap with the upright signature %.2f, turned over %.2f; want the output upright", same, turned)
+	}
+}
@@ -0,0 +1,220 @@
+package signature
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+
+	"golang.org/x/image/tiff"
+)
+
+// Supported values for Options.Format.
+const (
+	FormatPNG  = "png"
+	FormatAVIF = "avif"
+	FormatPSD  = "psd"
+	// FormatWebP keeps transparency and is encoded by cwebp (libwebp).
+	FormatWebP = "webp"
+	// FormatTIFF is a deflate-compressed RGBA TIFF.
+	FormatTIFF = "tiff"
+	// FormatJPEG composites the signature onto white, for consumers without
+	// transparency support.
+	FormatJPEG = "jpeg"
+	// FormatRaw writes the bare non-premultiplied RGBA bytes, row by row, with
+	// no header; the size comes from Result.Image.
+	FormatRaw = "rgba"
+	// FormatSVG traces the ink outlines into filled curves (see writeSVG), so
+	// the signature scales without pixelating.
+	FormatSVG = "svg"
+	// FormatStrokes writes the skeletonized ink as JSON polylines instead of an image.
+	FormatStrokes = "strokes"
+)
+
+// DefaultQuality is the AVIF, WebP and JPEG quality used by DefaultOptions.
+const DefaultQuality = 60
+
+// ValidFormat reports whether format is a supported output format.
+func ValidFormat(format string) bool {
+	switch format {
+	case FormatPNG, FormatAVIF, FormatPSD, FormatStrokes, FormatWebP, FormatTIFF, FormatJPEG, FormatRaw, FormatSVG:
+		return true
+	}
+	return false
+}
+
+// formatExtension is the file extension used for outputs in format.
+func formatExtension(format string) string {
+	switch format {
+	case FormatStrokes:
+		return "json"
+	case FormatJPEG:
+		return "jpg"
+	}
+	return format
+}
+
+// writeImage encodes img to path in the given format; quality applies to lossy formats.
+// For PSD this writes a single "Signature" layer; strokes vectorizes the opaque pixels.
+func writeImage(ctx context.Context, img image.Image, path, format string, quality int) error {
+	switch format {
+	case FormatPNG:
+		return writePNG(img, path)
+	case FormatAVIF:
+		return writeAVIF(ctx, img, path, quality)
+	case FormatPSD:
+		return writePSD(path, []psdLayer{{Name: "Signature", Image: img}})
+	case FormatStrokes:
+		return writeStrokes(img, path)
+	case FormatWebP:
+		return writeWebP(ctx, img, path, quality)
+	case FormatTIFF:
+		return writeTIFF(img, path)
+	case FormatJPEG:
+		return writeJPEGOnWhite(img, path, quality)
+	case FormatRaw:
+		return writeRawRGBA(img, path)
+	case FormatSVG:
+		return writeSVG(img, path)
+	default:
+		return fmt.Errorf("unsupported output format %q", format)
+	}
+}
+
+// WritePNG encodes the transparent signature image as PNG to w.
+func (r *Result) WritePNG(w io.Writer) error {
+	if r.Image == nil {
+		return fmt.Errorf("result has no image")
+	}
+	if err := png.Encode(w, r.Image); err != nil {
+		return fmt.Errorf("failed to encode PNG: %v", err)
+	}
+	return nil
+}
+
+func writePNG(img image.Image, path string) error {
+	outFile, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create output file: %v", err)
+	}
+	defer outFile.Close()
+
+	if err := png.Encode(outFile, img); err != nil {
+		return fmt.Errorf("failed to encode PNG: %v", err)
+	}
+	return outFile.Close()
+}
+
+// writeAVIF shells out to avifenc (libavif), which keeps the alpha channel.
+// The image is handed over as a temporary PNG since Go has no AVIF encoder.
+func writeAVIF(ctx context.Context, img image.Image, path string, quality int) error {
+	avifenc, err := exec.LookPath("avifenc")
+	if err != nil {
+		return fmt.Errorf("AVIF output needs the avifenc tool from libavif on PATH (e.g. brew install libavif, apt-get install libavif-bin): %v", err)
+	}
+
+	tmpDir, err := os.MkdirTemp("", "poc-pdf-avif-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	src := filepath.Join(tmpDir, "signature.png")
+	if err := writePNG(img, src); err != nil {
+		return err
+	}
+
+	// -q sets color quality and --qalpha the alpha plane; keep the mask at the same quality
+	q := strconv.Itoa(quality)
+	cmd := exec.CommandContext(ctx, avifenc, "-q", q, "--qalpha", q, src, path)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return fmt.Errorf("avifenc error: %v: %s", err, out)
+	}
+	return nil
+}
+
+// writeWebP shells out to cwebp (libwebp), like writeAVIF: Go's x/image only
+// decodes WebP. -exact keeps the color of transparent pixels, which the soft
+// matte relies on when the image is composited.
+func writeWebP(ctx context.Context, img image.Image, path string, quality int) error {
+	cwebp, err := exec.LookPath("cwebp")
+	if err != nil {
+		return fmt.Errorf("WebP output needs the cwebp tool from libwebp on PATH (e.g. brew install webp, apt-get install webp): %v", err)
+	}
+
+	tmpDir, err := os.MkdirTemp("", "poc-pdf-webp-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	src := filepath.Join(tmpDir, "signature.png")
+	if err := writePNG(img, src); err != nil {
+		return err
+	}
+
+	q := strconv.Itoa(quality)
+	cmd := exec.CommandContext(ctx, cwebp, "-quiet", "-q", q, "-alpha_q", q, "-exact", src, "-o", path)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return fmt.Errorf("cwebp error: %v: %s", err, out)
+	}
+	return nil
+}
+
+func writeTIFF(img image.Image, path string) error {
+	outFile, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create output file: %v", err)
+	}
+	defer outFile.Close()
+
+	if err := tiff.Encode(outFile, img, &tiff.Options{Compression: tiff.Deflate, Predictor: true}); err != nil {
+		return fmt.Errorf("failed to encode TIFF: %v", err)
+	}
+	return outFile.Close()
+}
+
+// writeJPEGOnWhite composites img over white paper, since JPEG has no alpha,
+// and encodes it at quality.
+func writeJPEGOnWhite(img image.Image, path string, quality int) error {
+	flat := image.NewRGBA(img.Bounds())
+	draw.Draw(flat, flat.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(flat, flat.Bounds(), img, img.Bounds().Min, draw.Over)
+
+	outFile, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create output file: %v", err)
+	}
+	defer outFile.Close()
+
+	if err := jpeg.Encode(outFile, flat, &jpeg.Options{Quality: max(quality, 1)}); err != nil {
+		return fmt.Errorf("failed to encode JPEG: %v", err)
+	}
+	return outFile.Close()
+}
+
+// writeRawRGBA writes img as width*height*4 bytes of non-premultiplied RGBA,
+// for pipelines that upload pixels straight into a texture or buffer.
+func writeRawRGBA(img image.Image, path string) error {
+	b := img.Bounds()
+	nrgba := image.NewNRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(nrgba, nrgba.Bounds(), img, b.Min, draw.Src)
+	if err := os.WriteFile(path, nrgba.Pix, 0o644); err != nil {
+		return fmt.Errorf("failed to write raw RGBA: %v", err)
+	}
+	return nil
+}
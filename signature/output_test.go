@@ -0,0 +1,77 @@
+package signature
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// transparentSignature returns a 200x80 output-like image: a stroke of
+// opaque ink with a soft edge on a transparent background.
+func transparentSignature() *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, 200, 80))
+	for y := 30; y < 50; y++ {
+		for x := 20; x < 180; x++ {
+			a := uint8(255)
+			if y == 30 || y == 49 {
+				a = 128
+			}
+			img.SetRGBA(x, y, color.RGBA{R: blueInk.R, G: blueInk.G, B: blueInk.B, A: a})
+		}
+	}
+	return img
+}
+
+func TestAVIFKeepsAlpha(t *testing.T) {
+	for _, tool := range []string{"avifenc", "avifdec"} {
+		if _, err := exec.LookPath(tool); err != nil {
+			t.Skipf("%s not installed", tool)
+		}
+	}
+	dir := t.TempDir()
+	src := transparentSignature()
+	avif := filepath.Join(dir, "signature.avif")
+	if err := writeAVIF(context.Background(), src, avif, 90); err != nil {
+		t.Fatal(err)
+	}
+	back := filepath.Join(dir, "decoded.png")
+	if out, err := exec.Command("avifdec", avif, back).CombinedOutput(); err != nil {
+		t.Fatalf("avifdec: %v: %s", err, out)
+	}
+	f, err := os.Open(back)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	decoded, _, err := image.Decode(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Bounds() != src.Bounds() {
+		t.Fatalf("decoded %v, want %v", decoded.Bounds(), src.Bounds())
+	}
+	// Lossy, but the background must stay transparent and the ink opaque
+	for _, p := range []image.Point{{5, 5}, {190, 70}, {100, 10}} {
+		if _, _, _, a := decoded.At(p.X, p.Y).RGBA(); a>>8 > 16 {
+			t.Errorf("background pixel %v has alpha %d, want transparent", p, a>>8)
+		}
+	}
+	for _, p := range []image.Point{{100, 40}, {25, 35}, {175, 45}} {
+		if _, _, _, a := decoded.At(p.X, p.Y).RGBA(); a>>8 < 239 {
+			t.Errorf("ink pixel %v has alpha %d, want opaque", p, a>>8)
+		}
+	}
+}
+
+func TestAVIFWithoutEncoder(t *testing.T) {
+	t.Setenv("PATH", t.TempDir())
+	err := writeAVIF(context.Background(), transparentSignature(), filepath.Join(t.TempDir(), "s.avif"), 90)
+	if err == nil || !strings.Contains(err.Error(), "avifenc") {
+		t.Errorf("writeAVIF without avifenc = %v, want an error naming it", err)
+	}
+}
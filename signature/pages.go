@@ -0,0 +1,93 @@
+package signature
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// lastPage stands for the document's final page in a PageSelection.
+const lastPage = -1
+
+// PageSelection lists the pages to process as inclusive ranges of 1-based page
+// numbers; the end of a range may be lastPage. A nil selection means every page.
+type PageSelection []pageRange
+
+// pageRange is an inclusive range of pages.
+type pageRange struct {
+	From, To int
+}
+
+// ParsePageSelection parses a comma-separated list of pages and ranges such as
+// "1,3,5-7,last" or "2-last".
+func ParsePageSelection(s string) (PageSelection, error) {
+	var sel PageSelection
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		from, to, isRange := strings.Cut(part, "-")
+		first, err := parsePageNumber(from)
+		if err != nil {
+			return nil, err
+		}
+		last := first
+		if isRange {
+			if last, err = parsePageNumber(to); err != nil {
+				return nil, err
+			}
+			if first == lastPage || (last != lastPage && last < first) {
+				return nil, fmt.Errorf("invalid page range %q", part)
+			}
+		}
+		sel = append(sel, pageRange{From: first, To: last})
+	}
+	return sel, nil
+}
+
+// parsePageNumber parses a 1-based page number or the keyword "last".
+func parsePageNumber(s string) (int, error) {
+	s = strings.TrimSpace(s)
+	if strings.EqualFold(s, "last") {
+		return lastPage, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("invalid page %q: want a number from 1 or \"last\"", s)
+	}
+	return n, nil
+}
+
+// resolve returns the selected pages of a document with numPages pages, sorted
+// and without duplicates. Pages past the end of the document are an error.
+func (sel PageSelection) resolve(numPages int) ([]int, error) {
+	if sel == nil {
+		pages := make([]int, numPages)
+		for i := range pages {
+			pages[i] = i + 1
+		}
+		return pages, nil
+	}
+
+	seen := map[int]bool{}
+	var pages []int
+	for _, r := range sel {
+		from, to := r.From, r.To
+		if from == lastPage {
+			from = numPages
+		}
+		if to == lastPage {
+			to = numPages
+		}
+		if from > numPages || to > numPages {
+			return nil, fmt.Errorf("page %d is out of range: the document has %d pages", max(from, to), numPages)
+		}
+		for p := from; p <= to; p++ {
+			if !seen[p] {
+				seen[p] = true
+				pages = append(pages, p)
+			}
+		}
+	}
+	sort.Ints(pages)
+	return pages, nil
+}
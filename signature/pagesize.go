@@ -0,0 +1,33 @@
+package signature
+
+import (
+	"fmt"
+	"math"
+)
+
+// Page-size limits. PDF itself caps pages at 14400pt (200in); anything outside
+// these bounds is almost certainly a corrupt or crafted document.
+const (
+	DefaultMinPagePt   = 36    // 0.5in
+	DefaultMaxPagePt   = 14400 // 200in
+	DefaultMaxRenderPx = 20000 // longest side of a rendered page
+)
+
+// checkPageSize rejects pages whose width or height falls outside [minPt, maxPt].
+func checkPageSize(box PDFRect, minPt, maxPt float64) error {
+	w, h := box.Width(), box.Height()
+	if w < minPt || h < minPt || w > maxPt || h > maxPt {
+		return fmt.Errorf("page size %.2f x %.2f pt is outside the accepted range %g-%g pt", w, h, minPt, maxPt)
+	}
+	return nil
+}
+
+// clampDPI lowers dpi so the longest rendered side of box, the page or the
+// part of it rasterized, stays within maxPx.
+func clampDPI(box PDFRect, dpi float64, maxPx int) float64 {
+	longest := math.Max(box.Width(), box.Height())
+	if limit := float64(maxPx) * pointsPerInch / longest; dpi > limit {
+		return math.Floor(limit)
+	}
+	return dpi
+}
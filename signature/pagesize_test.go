@@ -0,0 +1,88 @@
+package signature
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestClampDPI(t *testing.T) {
+	tests := []struct {
+		name  string
+		box   PDFRect
+		dpi   float64
+		maxPx int
+		want  float64
+	}{
+		{"letter fits", PDFRect{URX: 612, URY: 792}, 300, DefaultMaxRenderPx, 300},
+		{"letter at 2000 DPI", PDFRect{URX: 612, URY: 792}, 2000, DefaultMaxRenderPx, 1818},
+		{"largest PDF page", PDFRect{URX: 14400, URY: 14400}, 300, DefaultMaxRenderPx, 100},
+		{"landscape", PDFRect{URX: 1000, URY: 500}, 2000, DefaultMaxRenderPx, 1440},
+		{"offset box", PDFRect{LLX: 500, LLY: 500, URX: 1500, URY: 1000}, 2000, DefaultMaxRenderPx, 1440},
+		{"small region", PDFRect{LLX: 300, LLY: 50, URX: 580, URY: 150}, 2000, DefaultMaxRenderPx, 2000},
+		{"exact limit", PDFRect{URX: 720, URY: 720}, 100, 1000, 100},
+	}
+	for _, tt := range tests {
+		if got := clampDPI(tt.box, tt.dpi, tt.maxPx); got != tt.want {
+			t.Errorf("%s: clampDPI(%v, %g, %d) = %g, want %g", tt.name, tt.box, tt.dpi, tt.maxPx, got, tt.want)
+		}
+	}
+}
+
+func TestCheckPageSize(t *testing.T) {
+	tests := []struct {
+		box PDFRect
+		ok  bool
+	}{
+		{PDFRect{URX: 612, URY: 792}, true},
+		{PDFRect{LLX: 100, LLY: 100, URX: 136, URY: 14500}, true},
+		{PDFRect{URX: 100000, URY: 100000}, false},
+		{PDFRect{URX: 612, URY: 14401}, false},
+		{PDFRect{URX: 20, URY: 792}, false},
+		{PDFRect{}, false},
+	}
+	for _, tt := range tests {
+		err := checkPageSize(tt.box, DefaultMinPagePt, DefaultMaxPagePt)
+		if (err == nil) != tt.ok {
+			t.Errorf("checkPageSize(%v) = %v, want ok %v", tt.box, err, tt.ok)
+		}
+	}
+}
+
+func TestAbsurdPageSizeRejectedBeforeRendering(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake poppler tools are shell scripts")
+	}
+	// A pdfinfo reporting a 100000pt page, and a pdftoppm recording that it ran
+	dir := t.TempDir()
+	rendered := filepath.Join(dir, "rendered")
+	tools := map[string]string{
+		"pdfinfo": "#!/bin/sh\necho 'Pages:          1'\n" +
+			"echo 'Page    1 CropBox:     0.00     0.00 100000.00 100000.00'\necho 'Page    1 rot:  0'\n",
+		"pdftoppm": "#!/bin/sh\ntouch " + rendered + "\nexit 1\n",
+	}
+	for name, script := range tools {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(script), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	pdf := filepath.Join(dir, "crafted.pdf")
+	if err := os.WriteFile(pdf, []byte("%PDF-1.4\n%%EOF\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := DefaultOptions()
+	opts.Rasterizer = RasterizerPoppler
+	opts.OutputDir = t.TempDir()
+	_, err := NewExtractor(opts).ExtractFromPDF(context.Background(), pdf)
+	if err == nil || !strings.Contains(err.Error(), "100000.00 x 100000.00 pt") {
+		t.Errorf("ExtractFromPDF = %v, want the page size named in the error", err)
+	}
+	if _, err := os.Stat(rendered); err == nil {
+		t.Error("pdftoppm ran for a page outside the accepted size range")
+	}
+}
@@ -0,0 +1,148 @@
+package signature
+
+import (
+	"image"
+	"image/color"
+	"sort"
+)
+
+const (
+	// MinPaletteSize leaves room for the transparent entry plus one ink color.
+	MinPaletteSize = 2
+	// MaxPaletteSize is the most colors an indexed PNG can hold.
+	MaxPaletteSize = 256
+	// paletteAlphaCutoff is the alpha below which a pixel becomes the transparent entry.
+	paletteAlphaCutoff = 128
+)
+
+// quantizeMedianCut reduces img to an indexed image of at most size colors.
+// Entry 0 is fully transparent and takes every pixel with alpha below
+// paletteAlphaCutoff; the remaining entries come from a median cut over the
+// opaque pixels. The PNG encoder picks the smallest bit depth for the palette.
+func quantizeMedianCut(img *image.RGBA, size int) *image.Paletted {
+	b := img.Bounds()
+	var opaque []color.RGBA
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if c := img.RGBAAt(x, y); c.A >= paletteAlphaCutoff {
+				opaque = append(opaque, opaqueColor(c))
+			}
+		}
+	}
+
+	palette := color.Palette{color.RGBA{}}
+	for _, box := range medianCut(opaque, size-1) {
+		palette = append(palette, box.mean())
+	}
+
+	out := image.NewPaletted(b, palette)
+	lookup := make(map[color.RGBA]uint8)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			c := img.RGBAAt(x, y)
+			if c.A < paletteAlphaCutoff {
+				continue // index 0 is already transparent
+			}
+			c = opaqueColor(c)
+			idx, ok := lookup[c]
+			if !ok {
+				idx = uint8(palette[1:].Index(c) + 1)
+				lookup[c] = idx
+			}
+			out.SetColorIndex(x, y, idx)
+		}
+	}
+	return out
+}
+
+// opaqueColor un-premultiplies c and makes it fully opaque, since the indexed
+// output only distinguishes transparent from opaque.
+func opaqueColor(c color.RGBA) color.RGBA {
+	if c.A == 0 || c.A == 255 {
+		return color.RGBA{R: c.R, G: c.G, B: c.B, A: 255}
+	}
+	a := uint32(c.A)
+	return color.RGBA{
+		R: uint8(min(uint32(c.R)*255/a, 255)),
+		G: uint8(min(uint32(c.G)*255/a, 255)),
+		B: uint8(min(uint32(c.B)*255/a, 255)),
+		A: 255,
+	}
+}
+
+// colorBox is a set of colors in median cut.
+type colorBox []color.RGBA
+
+// medianCut splits colors into at most n boxes, repeatedly halving the box
+// with the widest channel range at the median of that channel.
+func medianCut(colors []color.RGBA, n int) []colorBox {
+	if len(colors) == 0 || n < 1 {
+		return nil
+	}
+	boxes := []colorBox{colors}
+	for len(boxes) < n {
+		widest, channel, spread := -1, 0, 0
+		for i, box := range boxes {
+			if len(box) < 2 {
+				continue
+			}
+			if ch, s := box.widestChannel(); s > spread {
+				widest, channel, spread = i, ch, s
+			}
+		}
+		if widest < 0 {
+			break // every box holds a single color
+		}
+
+		box := boxes[widest]
+		sort.Slice(box, func(i, j int) bool { return channelValue(box[i], channel) < channelValue(box[j], channel) })
+		mid := len(box) / 2
+		boxes[widest] = box[:mid]
+		boxes = append(boxes, box[mid:])
+	}
+	return boxes
+}
+
+// widestChannel returns the channel (0=R, 1=G, 2=B) with the largest range and that range.
+func (b colorBox) widestChannel() (int, int) {
+	lo := [3]int{255, 255, 255}
+	var hi [3]int
+	for _, c := range b {
+		for ch := 0; ch < 3; ch++ {
+			v := channelValue(c, ch)
+			lo[ch] = min(lo[ch], v)
+			hi[ch] = max(hi[ch], v)
+		}
+	}
+	best := 0
+	for ch := 1; ch < 3; ch++ {
+		if hi[ch]-lo[ch] > hi[best]-lo[best] {
+			best = ch
+		}
+	}
+	return best, hi[best] - lo[best]
+}
+
+// mean is the average color of the box.
+func (b colorBox) mean() color.RGBA {
+	var sum [3]int
+	for _, c := range b {
+		for ch := 0; ch < 3; ch++ {
+			sum[ch] += channelValue(c, ch)
+		}
+	}
+	n := len(b)
+	return color.RGBA{R: uint8(sum[0] / n), G: uint8(sum[1] / n), B: uint8(sum[2] / n), A: 255}
+}
+
+// channelValue returns the R, G or B component of c.
+func channelValue(c color.RGBA, ch int) int {
+	switch ch {
+	case 0:
+		return int(c.R)
+	case 1:
+		return int(c.G)
+	default:
+		return int(c.B)
+	}
+}
@@ -0,0 +1,77 @@
+package signature
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"testing"
+)
+
+// shadedSignature returns a transparent output-like signature whose ink
+// shades from blue to violet along the stroke, with the soft alpha edge of
+// an anti-aliased scan.
+func shadedSignature() *image.RGBA {
+	page := newPage(340, 140)
+	drawSignature(page, 30, 70, 0, 1, blueInk)
+	out := image.NewRGBA(page.Bounds())
+	for y := range 140 {
+		for x := range 340 {
+			c := page.RGBAAt(x, y)
+			if c != blueInk {
+				continue
+			}
+			out.SetRGBA(x, y, color.RGBA{R: uint8(20 + x/4), G: uint8(30 + y/10), B: 110, A: 255})
+			// A half-covered fringe above each ink pixel
+			if y > 0 && page.RGBAAt(x, y-1) != blueInk {
+				out.SetRGBA(x, y-1, color.RGBA{R: 70, G: 75, B: 120, A: 140})
+			}
+		}
+	}
+	return out
+}
+
+func TestPaletteSmallerAndClose(t *testing.T) {
+	src := shadedSignature()
+	var full, indexed bytes.Buffer
+	if err := png.Encode(&full, src); err != nil {
+		t.Fatal(err)
+	}
+	if err := png.Encode(&indexed, quantizeMedianCut(src, 16)); err != nil {
+		t.Fatal(err)
+	}
+	if indexed.Len() >= full.Len() {
+		t.Errorf("indexed PNG is %d bytes, RGBA PNG %d; want it smaller", indexed.Len(), full.Len())
+	}
+
+	back, err := png.Decode(&indexed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := back.(*image.Paletted); !ok {
+		t.Errorf("decoded %T, want an indexed image", back)
+	}
+	var sum float64
+	var n int
+	for y := range 140 {
+		for x := range 340 {
+			want := src.RGBAAt(x, y)
+			got := color.NRGBAModel.Convert(back.At(x, y)).(color.NRGBA)
+			if (want.A >= paletteAlphaCutoff) != (got.A == 255) || (got.A != 0 && got.A != 255) {
+				t.Fatalf("pixel (%d,%d): alpha %d from %d, want only the transparent entry below %d", x, y, got.A, want.A, paletteAlphaCutoff)
+			}
+			if want.A < paletteAlphaCutoff {
+				continue
+			}
+			o := opaqueColor(want)
+			for _, d := range []int{int(o.R) - int(got.R), int(o.G) - int(got.G), int(o.B) - int(got.B)} {
+				sum += float64(d * d)
+			}
+			n += 3
+		}
+	}
+	if rms := math.Sqrt(sum / float64(n)); rms > 8 {
+		t.Errorf("ink differs from the original by %.1f RMS per channel, want at most 8", rms)
+	}
+}
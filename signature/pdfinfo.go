@@ -0,0 +1,261 @@
+package signature
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"math"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// pointsPerInch is the size of the PDF user-space unit: 1pt = 1/72 inch.
+const pointsPerInch = 72.0
+
+// PDFRect is a rectangle in PDF user space (points), stored the way PDF does:
+// lower-left and upper-right corners, with the origin at the bottom-left.
+type PDFRect struct {
+	LLX float64 `json:"llx"`
+	LLY float64 `json:"lly"`
+	URX float64 `json:"urx"`
+	URY float64 `json:"ury"`
+}
+
+// Width returns the horizontal extent of the rectangle in points.
+func (r PDFRect) Width() float64 { return r.URX - r.LLX }
+
+// Height returns the vertical extent of the rectangle in points.
+func (r PDFRect) Height() float64 { return r.URY - r.LLY }
+
+// intersect returns the part of r inside other, empty (zero width or height)
+// when they don't overlap.
+func (r PDFRect) intersect(other PDFRect) PDFRect {
+	out := PDFRect{
+		LLX: math.Max(r.LLX, other.LLX),
+		LLY: math.Max(r.LLY, other.LLY),
+		URX: math.Min(r.URX, other.URX),
+		URY: math.Min(r.URY, other.URY),
+	}
+	out.URX, out.URY = math.Max(out.URX, out.LLX), math.Max(out.URY, out.LLY)
+	return out
+}
+
+func (r PDFRect) String() string {
+	return fmt.Sprintf("[%.2f %.2f %.2f %.2f]", r.LLX, r.LLY, r.URX, r.URY)
+}
+
+// runPoppler runs a poppler tool with args under limits, inserting -upw
+// password before them when one is set, and returns its standard output. A
+// missing tool fails with ErrRasterizerNotFound, an encrypted PDF opened
+// without the right password with ErrEncrypted, a PDF the tool can't parse
+// with ErrCorruptPDF, and a tool stopped by limits with ErrResourceLimit.
+func runPoppler(ctx context.Context, limits processLimits, tool, password string, args ...string) ([]byte, error) {
+	if password != "" {
+		args = append([]string{"-upw", password}, args...)
+	}
+	out, stderr, err := runLimited(ctx, limits, tool, args...)
+	if errors.Is(err, exec.ErrNotFound) {
+		return nil, fmt.Errorf("%w: %s not found on PATH (install poppler-utils)", ErrRasterizerNotFound, tool)
+	}
+	if err != nil {
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) {
+			return nil, err
+		}
+		// Both tools report "Command Line Error: Incorrect password" for a missing or wrong password
+		if bytes.Contains(stderr, []byte("Incorrect password")) {
+			return nil, ErrEncrypted
+		}
+		// Exit status 1 is "error opening a PDF file"; a file that isn't there says so
+		if exitErr.ExitCode() == 1 && !bytes.Contains(stderr, []byte("Couldn't open file")) {
+			return nil, fmt.Errorf("%w: %s: %s", ErrCorruptPDF, tool, bytes.TrimSpace(stderr))
+		}
+		return nil, fmt.Errorf("%s error: %v: %s", tool, err, bytes.TrimSpace(stderr))
+	}
+	return out, nil
+}
+
+// pageCount uses the pdfinfo CLI to read the number of pages of a PDF.
+func pageCount(ctx context.Context, limits processLimits, pdfPath, password string) (int, error) {
+	out, err := runPoppler(ctx, limits, "pdfinfo", password, pdfPath)
+	if err != nil {
+		return 0, err
+	}
+
+	// The line looks like: "Pages:          3"
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 || fields[0] != "Pages:" {
+			continue
+		}
+		n, err := strconv.Atoi(fields[1])
+		if err != nil || n < 1 {
+			return 0, fmt.Errorf("invalid page count %q", fields[1])
+		}
+		return n, nil
+	}
+	return 0, fmt.Errorf("no page count reported")
+}
+
+// pageBox ties a rendered page to PDF user space: Rect is the box the
+// rasterizer draws, in default user space, and Rotate the page's /Rotate, the
+// clockwise turn in degrees (0, 90, 180 or 270) the render applies to it.
+type pageBox struct {
+	Rect   PDFRect
+	Rotate int
+}
+
+// size returns the width and height of the rendered page in points, which are
+// those of Rect swapped on pages turned sideways.
+func (b pageBox) size() (w, h float64) {
+	if b.Rotate == 90 || b.Rotate == 270 {
+		return b.Rect.Height(), b.Rect.Width()
+	}
+	return b.Rect.Width(), b.Rect.Height()
+}
+
+// pixels returns the width and height of the page rendered at dpi, rounded up
+// to whole pixels like the rasterizers do.
+func (b pageBox) pixels(dpi float64) image.Point {
+	w, h := b.size()
+	return image.Pt(int(math.Ceil(w*dpi/pointsPerInch)), int(math.Ceil(h*dpi/pointsPerInch)))
+}
+
+// toPDF converts a point of the render, in points from its top-left corner, to
+// default user space.
+func (b pageBox) toPDF(u, v float64) (x, y float64) {
+	r := b.Rect
+	switch b.Rotate {
+	case 90:
+		return r.LLX + v, r.LLY + u
+	case 180:
+		return r.URX - u, r.LLY + v
+	case 270:
+		return r.URX - v, r.URY - u
+	default:
+		return r.LLX + u, r.URY - v
+	}
+}
+
+// fromPDF is the inverse of toPDF.
+func (b pageBox) fromPDF(x, y float64) (u, v float64) {
+	r := b.Rect
+	switch b.Rotate {
+	case 90:
+		return y - r.LLY, x - r.LLX
+	case 180:
+		return r.URX - x, y - r.LLY
+	case 270:
+		return r.URY - y, r.URX - x
+	default:
+		return x - r.LLX, r.URY - y
+	}
+}
+
+// normalizeRotate brings a /Rotate value, a multiple of 90 that may be negative
+// or above 360, into 0-270.
+func normalizeRotate(rotate int) int {
+	return (rotate%360 + 360) % 360
+}
+
+// pagePopplerBox uses the pdfinfo CLI to read the CropBox and rotation of the
+// given page. pdftoppm renders the CropBox (with -cropbox) turned by /Rotate,
+// so this is the box the raster maps onto.
+func pagePopplerBox(ctx context.Context, limits processLimits, pdfPath string, page int, password string) (pageBox, error) {
+	p := strconv.Itoa(page)
+	out, err := runPoppler(ctx, limits, "pdfinfo", password, "-box", "-f", p, "-l", p, pdfPath)
+	if err != nil {
+		return pageBox{}, err
+	}
+
+	// Lines look like: "Page    1 CropBox:     0.00     0.00   612.00   792.00"
+	// and "Page    1 rot:  90"
+	var box pageBox
+	var found bool
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 4 || fields[0] != "Page" || fields[1] != p {
+			continue
+		}
+		switch {
+		case fields[2] == "rot:" && len(fields) == 4:
+			rotate, err := strconv.Atoi(fields[3])
+			if err != nil || rotate%90 != 0 {
+				return pageBox{}, fmt.Errorf("invalid page rotation %q", fields[3])
+			}
+			box.Rotate = normalizeRotate(rotate)
+		case fields[2] == "CropBox:" && len(fields) == 7:
+			var v [4]float64
+			for i := range v {
+				v[i], err = strconv.ParseFloat(fields[3+i], 64)
+				if err != nil {
+					return pageBox{}, fmt.Errorf("invalid CropBox value %q: %v", fields[3+i], err)
+				}
+			}
+			box.Rect = PDFRect{LLX: v[0], LLY: v[1], URX: v[2], URY: v[3]}
+			found = true
+		}
+	}
+	if !found {
+		return pageBox{}, fmt.Errorf("no CropBox reported for page %d", page)
+	}
+	return box, nil
+}
+
+// pixelRectToPDF converts a rectangle in raster pixels (origin top-left, y down)
+// of a page rendered at dpi to PDF user space (origin bottom-left, y up),
+// undoing the page's rotation.
+func pixelRectToPDF(rect image.Rectangle, dpi float64, box pageBox) PDFRect {
+	scale := pointsPerInch / dpi
+	x0, y0 := box.toPDF(float64(rect.Min.X)*scale, float64(rect.Min.Y)*scale)
+	x1, y1 := box.toPDF(float64(rect.Max.X)*scale, float64(rect.Max.Y)*scale)
+	return PDFRect{
+		LLX: math.Min(x0, x1),
+		LLY: math.Min(y0, y1),
+		URX: math.Max(x0, x1),
+		URY: math.Max(y0, y1),
+	}
+}
+
+// pdfRectToPixels is the inverse of pixelRectToPDF: it converts a PDF user-space
+// rectangle to raster pixels at dpi, rounding outwards and clipping to the page.
+func pdfRectToPixels(r PDFRect, dpi float64, box pageBox) image.Rectangle {
+	// Multiplying before dividing keeps whole pixels whole: 792pt at 150 DPI
+	// is 1650px, where 792 * (150/72) is a hair above and would round up
+	px := func(pt float64) float64 { return pt * dpi / pointsPerInch }
+	u0, v0 := box.fromPDF(r.LLX, r.LLY)
+	u1, v1 := box.fromPDF(r.URX, r.URY)
+	return image.Rect(
+		int(math.Floor(px(math.Min(u0, u1)))),
+		int(math.Floor(px(math.Min(v0, v1)))),
+		int(math.Ceil(px(math.Max(u0, u1)))),
+		int(math.Ceil(px(math.Max(v0, v1)))),
+	).Intersect(image.Rectangle{Max: box.pixels(dpi)})
+}
+
+// ParsePDFRect parses "llx,lly,urx,ury" in points.
+func ParsePDFRect(s string) (PDFRect, error) {
+	parts := strings.Split(s, ",")
+	if len(parts) != 4 {
+		return PDFRect{}, fmt.Errorf("want llx,lly,urx,ury, got %q", s)
+	}
+	var v [4]float64
+	for i, part := range parts {
+		var err error
+		v[i], err = strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return PDFRect{}, fmt.Errorf("invalid coordinate %q: %v", part, err)
+		}
+	}
+	r := PDFRect{LLX: v[0], LLY: v[1], URX: v[2], URY: v[3]}
+	if r.Width() <= 0 || r.Height() <= 0 {
+		return PDFRect{}, fmt.Errorf("region %v has no area", r)
+	}
+	return r, nil
+}
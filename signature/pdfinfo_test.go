@@ -0,0 +1,54 @@
+package signature
+
+import (
+	"image"
+	"testing"
+)
+
+func TestPixelRectToPDF(t *testing.T) {
+	letter := PDFRect{URX: 612, URY: 792}
+	// At 144 DPI a pixel is half a point: (200,300)-(400,500) spans
+	// 100-200pt across and 150-250pt down from the top of the render
+	rect := image.Rect(200, 300, 400, 500)
+	tests := []struct {
+		name string
+		box  pageBox
+		want PDFRect
+	}{
+		{"letter", pageBox{Rect: letter}, PDFRect{LLX: 100, LLY: 542, URX: 200, URY: 642}},
+		{"offset crop box", pageBox{Rect: PDFRect{LLX: 10, LLY: 20, URX: 622, URY: 812}}, PDFRect{LLX: 110, LLY: 562, URX: 210, URY: 662}},
+		{"rotated 90", pageBox{Rect: letter, Rotate: 90}, PDFRect{LLX: 150, LLY: 100, URX: 250, URY: 200}},
+		{"rotated 180", pageBox{Rect: letter, Rotate: 180}, PDFRect{LLX: 412, LLY: 150, URX: 512, URY: 250}},
+		{"rotated 270", pageBox{Rect: letter, Rotate: 270}, PDFRect{LLX: 362, LLY: 592, URX: 462, URY: 692}},
+	}
+	for _, tt := range tests {
+		if got := pixelRectToPDF(rect, 144, tt.box); got != tt.want {
+			t.Errorf("%s: pixelRectToPDF(%v) = %v, want %v", tt.name, rect, got, tt.want)
+		}
+		if got := pdfRectToPixels(tt.want, 144, tt.box); got != rect {
+			t.Errorf("%s: pdfRectToPixels(%v) = %v, want %v", tt.name, tt.want, got, rect)
+		}
+	}
+}
+
+func TestPDFRectToPixelsRoundsOutAndClips(t *testing.T) {
+	box := pageBox{Rect: PDFRect{URX: 612, URY: 792}}
+	tests := []struct {
+		r    PDFRect
+		dpi  float64
+		want image.Rectangle
+	}{
+		// 150/72 px per point: 100.2pt is pixel 208.75, rounded outwards
+		{PDFRect{LLX: 100.2, LLY: 691.8, URX: 200.2, URY: 791.8}, 150, image.Rect(208, 0, 418, 209)},
+		// The whole page is exactly 1275x1650
+		{PDFRect{URX: 612, URY: 792}, 150, image.Rect(0, 0, 1275, 1650)},
+		// Clipped to the 1275x1650 page
+		{PDFRect{LLX: -50, LLY: -50, URX: 100, URY: 100}, 150, image.Rect(0, 1441, 209, 1650)},
+		{PDFRect{LLX: 700, LLY: 0, URX: 800, URY: 100}, 150, image.Rectangle{}},
+	}
+	for _, tt := range tests {
+		if got := pdfRectToPixels(tt.r, tt.dpi, box); got != tt.want && !(got.Empty() && tt.want.Empty()) {
+			t.Errorf("pdfRectToPixels(%v, %g) = %v, want %v", tt.r, tt.dpi, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,256 @@
+package signature
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+
+	"gocv.io/x/gocv"
+)
+
+// Perspective correction parameters.
+const (
+	// outlineWidth is the width the page is shrunk to while the outline of the
+	// document is searched for; the outline is coarse, so little is lost.
+	outlineWidth = 800
+	// outlineMinArea is the smallest share of the image the outline must
+	// cover, so a form box or a photo on the page isn't taken for the page.
+	outlineMinArea = 0.25
+	// outlineEpsilon is the ApproxPolyDP tolerance, as a fraction of the
+	// contour's perimeter, within which it must be a quadrilateral.
+	outlineEpsilon = 0.02
+	// outlineMinKeystone is how far, as a fraction of the image's width or
+	// height, the sides of the outline must be from level and plumb for the
+	// page to be warped; an upright rectangle is left alone, as it is the
+	// page of a born-digital PDF or a photo taken square on.
+	outlineMinKeystone = 0.01
+)
+
+// outline is the quadrilateral of a photographed document, its corners as
+// fractions of the image's width and height, in the order top-left,
+// top-right, bottom-right, bottom-left. Fractions apply alike to every
+// render of the page, whatever its resolution.
+type outline [4][2]float64
+
+// documentOutline finds the photographed document on the page image at
+// imgPath: the largest convex four-cornered contour of its edges or of its
+// bright paper covering outlineMinArea of the image. It returns nil when there
+// is none, or when the outline is already an upright rectangle.
+func documentOutline(imgPath string) (*outline, error) {
+	img, err := readImage(imgPath, gocv.IMReadGrayScale)
+	if err != nil {
+		return nil, err
+	}
+	defer img.Close()
+
+	small := gocv.NewMat()
+	defer small.Close()
+	scale := min(1, float64(outlineWidth)/float64(img.Cols()))
+	size := image.Pt(max(1, int(float64(img.Cols())*scale)), max(1, int(float64(img.Rows())*scale)))
+	gocv.Resize(img, &small, size, 0, 0, gocv.InterpolationArea)
+	gocv.GaussianBlur(small, &small, image.Pt(5, 5), 0, 0, gocv.BorderDefault)
+
+	// The paper's edge against the table, and the paper itself when the
+	// edge is too soft to trace all the way round
+	edges, paper := gocv.NewMat(), gocv.NewMat()
+	defer edges.Close()
+	defer paper.Close()
+	gocv.Canny(small, &edges, 50, 150)
+	kernel := gocv.GetStructuringElement(gocv.MorphRect, image.Pt(3, 3))
+	defer kernel.Close()
+	gocv.Dilate(edges, &edges, kernel)
+	gocv.Threshold(small, &paper, 0, 255, gocv.ThresholdBinary|gocv.ThresholdOtsu)
+
+	var best []image.Point
+	bestArea := outlineMinArea * float64(size.X*size.Y)
+	for _, bin := range []gocv.Mat{edges, paper} {
+		contours := gocv.FindContours(bin, gocv.RetrievalExternal, gocv.ChainApproxSimple)
+		for i := 0; i < contours.Size(); i++ {
+			c := contours.At(i)
+			if gocv.ContourArea(c) < bestArea {
+				continue
+			}
+			approx := gocv.ApproxPolyDP(c, outlineEpsilon*gocv.ArcLength(c, true), true)
+			if area := gocv.ContourArea(approx); approx.Size() == 4 && area >= bestArea {
+				if pts := approx.ToPoints(); convexQuad(pts) {
+					best, bestArea = pts, area
+				}
+			}
+			approx.Close()
+		}
+		contours.Close()
+	}
+	if best == nil {
+		return nil, nil
+	}
+
+	o := orderCorners(best, size)
+	if o.upright() {
+		return nil, nil
+	}
+	return &o, nil
+}
+
+// convexQuad reports whether the four points, in contour order, make a convex
+// quadrilateral: its edges all turn the same way.
+func convexQuad(pts []image.Point) bool {
+	if len(pts) != 4 {
+		return false
+	}
+	var sign int
+	for i := range pts {
+		a, b, c := pts[i], pts[(i+1)%4], pts[(i+2)%4]
+		cross := (b.X-a.X)*(c.Y-b.Y) - (b.Y-a.Y)*(c.X-b.X)
+		switch {
+		case cross == 0:
+			return false
+		case sign == 0:
+			sign = cross
+		case (cross > 0) != (sign > 0):
+			return false
+		}
+	}
+	return true
+}
+
+// orderCorners returns the four corners of a quadrilateral in an image of the
+// given size as an outline: the top-left corner has the smallest x+y, the
+// bottom-right the largest, the top-right the smallest y-x and the
+// bottom-left the largest.
+func orderCorners(pts []image.Point, size image.Point) outline {
+	tl, tr, br, bl := pts[0], pts[0], pts[0], pts[0]
+	for _, p := range pts[1:] {
+		if p.X+p.Y < tl.X+tl.Y {
+			tl = p
+		}
+		if p.X+p.Y > br.X+br.Y {
+			br = p
+		}
+		if p.Y-p.X < tr.Y-tr.X {
+			tr = p
+		}
+		if p.Y-p.X > bl.Y-bl.X {
+			bl = p
+		}
+	}
+	var o outline
+	for i, p := range []image.Point{tl, tr, br, bl} {
+		o[i] = [2]float64{float64(p.X) / float64(size.X), float64(p.Y) / float64(size.Y)}
+	}
+	return o
+}
+
+// upright reports whether the top and bottom of o are level and its sides
+// plumb, within outlineMinKeystone.
+func (o *outline) upright() bool {
+	return math.Abs(o[0][1]-o[1][1]) < outlineMinKeystone && math.Abs(o[3][1]-o[2][1]) < outlineMinKeystone &&
+		math.Abs(o[0][0]-o[3][0]) < outlineMinKeystone && math.Abs(o[1][0]-o[2][0]) < outlineMinKeystone
+}
+
+// corners returns the outline in the pixels of an image of the given size,
+// and the upright rectangle it is warped onto: as wide and tall as the
+// outline's longer sides, scaled to fit the image and centred in it.
+func (o *outline) corners(size image.Point) (src, dst [4][2]float64) {
+	for i, p := range o {
+		src[i] = [2]float64{p[0] * float64(size.X), p[1] * float64(size.Y)}
+	}
+	side := func(a, b int) float64 { return math.Hypot(src[b][0]-src[a][0], src[b][1]-src[a][1]) }
+	w, h := max(side(0, 1), side(3, 2)), max(side(0, 3), side(1, 2))
+	s := min(float64(size.X)/w, float64(size.Y)/h)
+	w, h = w*s, h*s
+	x0, y0 := (float64(size.X)-w)/2, (float64(size.Y)-h)/2
+	dst = [4][2]float64{{x0, y0}, {x0 + w, y0}, {x0 + w, y0 + h}, {x0, y0 + h}}
+	return src, dst
+}
+
+// warpImageFile rewrites the image at path with the document of outline o
+// warped flat, keeping the image's size and filling the margins around the
+// document with white.
+func (o *outline) warpImageFile(path string) error {
+	img, err := readImage(path, gocv.IMReadUnchanged)
+	if err != nil {
+		return err
+	}
+	defer img.Close()
+
+	size := image.Pt(img.Cols(), img.Rows())
+	h, ok := homography(o.corners(size))
+	if !ok {
+		return fmt.Errorf("document outline %v is degenerate", *o)
+	}
+	m := gocv.NewMatWithSize(3, 3, gocv.MatTypeCV64F)
+	defer m.Close()
+	for i, v := range h {
+		m.SetDoubleAt(i/3, i%3, v)
+	}
+	warped := gocv.NewMat()
+	defer warped.Close()
+	white := color.RGBA{R: 255, G: 255, B: 255, A: 255}
+	gocv.WarpPerspectiveWithParams(img, &warped, m, size, gocv.InterpolationCubic, gocv.BorderConstant, white)
+	if !gocv.IMWrite(path, warped) {
+		return fmt.Errorf("unable to write image: %s", path)
+	}
+	return nil
+}
+
+// unwarpRect maps a rectangle of an image of the given size warped with o
+// (see warpImageFile) back onto the image as rendered: the bounding box of
+// its corners under the inverse warp, clipped to size.
+func (o *outline) unwarpRect(r image.Rectangle, size image.Point) image.Rectangle {
+	src, dst := o.corners(size)
+	h, ok := homography(dst, src)
+	if !ok {
+		return r
+	}
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	for _, p := range []image.Point{r.Min, {r.Max.X, r.Min.Y}, {r.Min.X, r.Max.Y}, r.Max} {
+		x, y := float64(p.X), float64(p.Y)
+		w := h[6]*x + h[7]*y + h[8]
+		x, y = (h[0]*x+h[1]*y+h[2])/w, (h[3]*x+h[4]*y+h[5])/w
+		minX, maxX = math.Min(minX, x), math.Max(maxX, x)
+		minY, maxY = math.Min(minY, y), math.Max(maxY, y)
+	}
+	return image.Rect(int(math.Floor(minX)), int(math.Floor(minY)), int(math.Ceil(maxX)), int(math.Ceil(maxY))).
+		Intersect(image.Rectangle{Max: size})
+}
+
+// homography returns the row-major 3x3 perspective transform taking the four
+// points src onto dst, as OpenCV's getPerspectiveTransform does, by solving
+// its eight unknowns (the ninth is 1) with Gaussian elimination. ok is false
+// when three of the points are collinear.
+func homography(src, dst [4][2]float64) (h [9]float64, ok bool) {
+	var a [8][9]float64
+	for i := range src {
+		x, y, u, v := src[i][0], src[i][1], dst[i][0], dst[i][1]
+		a[2*i] = [9]float64{x, y, 1, 0, 0, 0, -x * u, -y * u, u}
+		a[2*i+1] = [9]float64{0, 0, 0, x, y, 1, -x * v, -y * v, v}
+	}
+	for col := range 8 {
+		pivot := col
+		for row := col + 1; row < 8; row++ {
+			if math.Abs(a[row][col]) > math.Abs(a[pivot][col]) {
+				pivot = row
+			}
+		}
+		if math.Abs(a[pivot][col]) < 1e-12 {
+			return h, false
+		}
+		a[col], a[pivot] = a[pivot], a[col]
+		for row := range 8 {
+			if row == col {
+				continue
+			}
+			f := a[row][col] / a[col][col]
+			for k := col; k < 9; k++ {
+				a[row][k] -= f * a[col][k]
+			}
+		}
+	}
+	for i := range 8 {
+		h[i] = a[i][8] / a[i][i]
+	}
+	h[8] = 1
+	return h, true
+}
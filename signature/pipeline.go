@@ -0,0 +1,124 @@
+package signature
+
+import (
+	"context"
+	"fmt"
+)
+
+// DefaultPipelineDepth is how many rendered pages may wait for detection, and
+// how many outputs for encoding, when Options.PipelineDepth is 0.
+const DefaultPipelineDepth = 2
+
+// pipelineDepth returns Options.PipelineDepth, defaulting when below 1.
+func (e *Extractor) pipelineDepth() int {
+	if e.opts.PipelineDepth < 1 {
+		return DefaultPipelineDepth
+	}
+	return e.opts.PipelineDepth
+}
+
+// renderPages renders each of pages in turn with render, ahead of the caller,
+// and sends the results on the returned channel, holding at most depth of them
+// unread. It stops when ctx is done and closes the channel once it has; the
+// caller drains the channel before removing the renders' directory.
+func renderPages(ctx context.Context, pages []int, depth int, render func(page int) *renderedPage) <-chan *renderedPage {
+	rendered := make(chan *renderedPage, depth)
+	go func() {
+		defer close(rendered)
+		for _, page := range pages {
+			if ctx.Err() != nil {
+				return
+			}
+			select {
+			case rendered <- render(page):
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return rendered
+}
+
+// encodeQueue is the last stage of a document's pipeline: it runs the tasks
+// that encode and deliver each result, in the order they were added, while
+// detection moves on to the next region or page.
+type encodeQueue struct {
+	tasks chan func(context.Context) error
+	done  chan struct{}
+	// err is the first task error; read it only once done is closed.
+	err error
+}
+
+// startEncoder starts an encodeQueue holding at most depth tasks that haven't
+// started. The first task that fails cancels ctx with its error, and the tasks
+// after it are skipped.
+func startEncoder(ctx context.Context, cancel context.CancelCauseFunc, depth int) *encodeQueue {
+	q := &encodeQueue{tasks: make(chan func(context.Context) error, depth), done: make(chan struct{})}
+	go func() {
+		defer close(q.done)
+		for task := range q.tasks {
+			if q.err != nil {
+				continue
+			}
+			if err := task(ctx); err != nil {
+				q.err = err
+				cancel(err)
+			}
+		}
+	}()
+	return q
+}
+
+// add queues task, waiting while the queue is full. It fails with the cause of
+// ctx when the document is cancelled meanwhile, e.g. by a failed task.
+func (q *encodeQueue) add(ctx context.Context, task func(context.Context) error) error {
+	select {
+	case q.tasks <- task:
+		return nil
+	case <-ctx.Done():
+		return context.Cause(ctx)
+	}
+}
+
+// wait lets the queued tasks finish and returns the first error of any.
+func (q *encodeQueue) wait() error {
+	close(q.tasks)
+	<-q.done
+	return q.err
+}
+
+// later runs task in the encoding stage of the document of st, once the
+// outputs before it are written; errors are labelled with the page.
+func (st *pageState) later(ctx context.Context, task func(context.Context) error) error {
+	return st.encoder.add(ctx, func(ctx context.Context) error {
+		if err := task(ctx); err != nil {
+			return fmt.Errorf("page %d: %w", st.pageNum, err)
+		}
+		return nil
+	})
+}
+
+// deliver hashes res against the signatures before it (see Options.Dedup),
+// encodes its output with Options.Encode, writes its metadata with
+// Options.Metadata and hands it to Options.OnResult; it runs in the encoding
+// stage once the outputs of res are written.
+func (e *Extractor) deliver(ctx context.Context, res *Result) error {
+	if ok, err := e.dedup(res); !ok {
+		return err
+	}
+	outputPath := res.OutputPath
+	if err := e.encodeOutput(res); err != nil {
+		return err
+	}
+	if e.opts.Metadata {
+		res.MetadataPath = metadataPath(outputPath)
+		if err := writeMetadata(res, res.MetadataPath); err != nil {
+			return err
+		}
+		e.logf("Metadata saved to %s", res.MetadataPath)
+	}
+	if e.opts.OnResult != nil {
+		return e.opts.OnResult(ctx, res)
+	}
+	return nil
+}
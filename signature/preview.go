@@ -0,0 +1,200 @@
+package signature
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gocv.io/x/gocv"
+)
+
+// Colors of the candidate boxes in a dry-run preview, by confidence bucket.
+var previewColors = map[string]color.RGBA{
+	"high":   {0, 200, 0, 0},
+	"medium": {255, 160, 0, 0},
+	"low":    {255, 0, 0, 0},
+}
+
+// Preview is the JSON listing written next to the preview image of a page
+// with Options.DryRun.
+type Preview struct {
+	Source      string  `json:"source"`
+	Page        int     `json:"page"`
+	PreviewPath string  `json:"preview_path"`
+	DPI         float64 `json:"dpi"`
+	// PageBox and PageRotate are the rendered page area in PDF points and its
+	// /Rotate (see Result.PageBox).
+	PageBox    PDFRect `json:"page_box_pt"`
+	PageRotate int     `json:"page_rotate"`
+	Rotation   int     `json:"rotation"`
+	Skew       float64 `json:"skew_deg,omitempty"`
+	// Candidates are every region detection found, in reading order.
+	Candidates []PreviewCandidate `json:"candidates"`
+}
+
+// PreviewCandidate is one region in a Preview.
+type PreviewCandidate struct {
+	// Number labels the region's box in the preview image, from 1.
+	Number int `json:"number"`
+	// Bounds is the region in page pixels at DPI, origin top-left, as in Metadata.
+	Bounds PixelBounds `json:"bounds_px"`
+	// PDFBounds is the region in PDF points, origin bottom-left.
+	PDFBounds  PDFRect `json:"bounds_pt"`
+	Confidence float64 `json:"confidence"`
+	// ConfidenceFactors are the sub-scores of Confidence; omitted with -detector onnx.
+	ConfidenceFactors *ConfidenceFactors `json:"confidence_factors,omitempty"`
+	// Bucket is the confidence bucket (high, medium or low) that colors the box.
+	Bucket    string `json:"bucket"`
+	FormField string `json:"form_field,omitempty"`
+	Zone      string `json:"zone,omitempty"`
+	// Selected marks the regions a run without Options.DryRun would extract.
+	Selected bool `json:"selected"`
+}
+
+// previewPage finishes a dry run of one page: it draws every candidate region
+// over a copy of the detection render, writes it as {prefix}_preview.png with
+// the Preview listing as {prefix}_preview.json, and returns a result without
+// an image for each selected region, so callers see what a real run would
+// extract. No crops are made.
+func (e *Extractor) previewPage(st *pageState, candidates, selected []SignatureRegion) ([]*Result, error) {
+	opts := st.opts
+	buckets := ConfidenceBuckets{High: DefaultHighConfidence, Medium: DefaultMediumConfidence}
+	if opts.Buckets != nil {
+		buckets = *opts.Buckets
+	}
+	isSelected := make(map[image.Rectangle]bool, len(selected))
+	for _, region := range selected {
+		isSelected[region.Bounds] = true
+	}
+	// The search for every region drops shapes the single-region search keeps
+	listed := make(map[image.Rectangle]bool, len(candidates))
+	for _, region := range candidates {
+		listed[region.Bounds] = true
+	}
+	for _, region := range selected {
+		if !listed[region.Bounds] {
+			candidates = append(candidates, region)
+		}
+	}
+
+	preview := Preview{
+		Source:      st.pdfPath,
+		Page:        st.pageNum,
+		PreviewPath: filepath.Join(opts.OutputDir, outputName(st.outPrefix, "preview.png")),
+		DPI:         opts.RenderDPI,
+		PageBox:     st.box.Rect,
+		PageRotate:  st.box.Rotate,
+		Rotation:    st.rotation,
+		Skew:        st.skew,
+		Candidates:  make([]PreviewCandidate, len(candidates)),
+	}
+	var results []*Result
+	for i, region := range candidates {
+		bounds := st.pageBounds(region.Bounds)
+		c := PreviewCandidate{
+			Number:     i + 1,
+			Bounds:     PixelBounds{X: bounds.Min.X, Y: bounds.Min.Y, Width: bounds.Dx(), Height: bounds.Dy()},
+			PDFBounds:  pixelRectToPDF(bounds, opts.RenderDPI, st.box),
+			Confidence: region.Confidence,
+			Bucket:     buckets.bucket(region.Confidence),
+			FormField:  region.Field,
+			Zone:       region.Zone,
+			Selected:   isSelected[region.Bounds],
+		}
+		if region.Factors != (ConfidenceFactors{}) {
+			factors := region.Factors
+			c.ConfidenceFactors = &factors
+		}
+		preview.Candidates[i] = c
+		if !c.Selected {
+			continue
+		}
+		n := 0
+		if opts.AllRegions {
+			n = len(results) + 1
+		}
+		results = append(results, &Result{
+			Source:            st.pdfPath,
+			Page:              st.pageNum,
+			Region:            n,
+			PagePath:          st.page.Path,
+			Baseline:          -1,
+			DPI:               opts.RenderDPI,
+			OutputDPI:         opts.RenderDPI,
+			Rotation:          st.rotation,
+			Skew:              st.skew,
+			Bounds:            bounds,
+			PDFBounds:         c.PDFBounds,
+			PageBox:           st.box.Rect,
+			PageRotate:        st.box.Rotate,
+			Confidence:        region.Confidence,
+			ConfidenceFactors: region.Factors,
+			FormField:         region.Field,
+			Zone:              region.Zone,
+			Template:          st.template,
+			OutputPath:        preview.PreviewPath,
+		})
+	}
+
+	if err := writePreviewImage(st.page.Path, preview.PreviewPath, candidates, preview.Candidates); err != nil {
+		return nil, err
+	}
+	e.logf("Dry run preview saved to %s", preview.PreviewPath)
+	listingPath := previewListingPath(preview.PreviewPath)
+	data, err := json.MarshalIndent(preview, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode preview listing: %v", err)
+	}
+	if err := os.WriteFile(listingPath, append(data, '\n'), 0o644); err != nil {
+		return nil, fmt.Errorf("failed to write preview listing: %v", err)
+	}
+	e.logf("Dry run listing of %d candidate regions saved to %s", len(candidates), listingPath)
+	for _, res := range results {
+		res.MetadataPath = listingPath
+	}
+	return results, nil
+}
+
+// writePreviewImage draws the box of each candidate (in pixels of the render at
+// pagePath) in the color of its bucket, thicker when it is selected, labeled
+// with its number and confidence, and writes the result to path.
+func writePreviewImage(pagePath, path string, candidates []SignatureRegion, listed []PreviewCandidate) error {
+	canvas, err := readImage(pagePath, gocv.IMReadColor)
+	if err != nil {
+		return err
+	}
+	defer canvas.Close()
+
+	thickness := max(1, canvas.Cols()/800)
+	scale := max(0.5, float64(canvas.Cols())/2000)
+	for i, region := range candidates {
+		c := listed[i]
+		boxColor := previewColors[c.Bucket]
+		width := thickness
+		if c.Selected {
+			width *= 3
+		}
+		gocv.Rectangle(&canvas, region.Bounds, boxColor, width)
+		label := fmt.Sprintf("#%d %.2f", c.Number, c.Confidence)
+		// Above the box, or inside its top edge when it starts at the top of the page
+		at := image.Pt(region.Bounds.Min.X, region.Bounds.Min.Y-2*width)
+		if at.Y < int(20*scale) {
+			at.Y = region.Bounds.Min.Y + int(20*scale)
+		}
+		gocv.PutText(&canvas, label, at, gocv.FontHersheySimplex, scale, boxColor, thickness)
+	}
+	if !gocv.IMWrite(path, canvas) {
+		return fmt.Errorf("unable to write preview image: %s", path)
+	}
+	return nil
+}
+
+// previewListingPath names the JSON listing of a preview image, e.g.
+// p2_preview.png -> p2_preview.json.
+func previewListingPath(previewPath string) string {
+	return strings.TrimSuffix(previewPath, filepath.Ext(previewPath)) + ".json"
+}
@@ -0,0 +1,40 @@
+package signature
+
+import (
+	"image"
+	"math"
+
+	"gocv.io/x/gocv"
+)
+
+const mmPerInch = 25.4
+
+// physicalSizeMM returns the real-world size of an image of size px rendered at dpi.
+func physicalSizeMM(px image.Point, dpi float64) (width, height float64) {
+	return float64(px.X) / dpi * mmPerInch, float64(px.Y) / dpi * mmPerInch
+}
+
+// printPixelSize is the pixel size that, printed at printDPI, measures exactly the
+// physical size of an image of size px rendered at sourceDPI.
+func printPixelSize(px image.Point, sourceDPI, printDPI float64) image.Point {
+	s := printDPI / sourceDPI
+	return image.Pt(
+		max(int(math.Round(float64(px.X)*s)), 1),
+		max(int(math.Round(float64(px.Y)*s)), 1),
+	)
+}
+
+// scaleToPrintDPI resamples a crop taken at sourceDPI so it keeps its physical size
+// when printed at printDPI. The caller owns the returned Mat.
+func scaleToPrintDPI(crop gocv.Mat, sourceDPI, printDPI float64) gocv.Mat {
+	size := printPixelSize(image.Pt(crop.Cols(), crop.Rows()), sourceDPI, printDPI)
+
+	// Area averaging avoids aliasing when shrinking; cubic keeps strokes smooth when enlarging
+	interp := gocv.InterpolationCubic
+	if printDPI < sourceDPI {
+		interp = gocv.InterpolationArea
+	}
+	scaled := gocv.NewMat()
+	gocv.Resize(crop, &scaled, size, 0, 0, interp)
+	return scaled
+}
@@ -0,0 +1,50 @@
+package signature
+
+import (
+	"image"
+	"math"
+	"testing"
+)
+
+func TestPrintPixelSize(t *testing.T) {
+	tests := []struct {
+		px                  image.Point
+		sourceDPI, printDPI float64
+		wantMM              [2]float64
+		want                image.Point
+	}{
+		// 2in x 0.5in
+		{image.Pt(300, 75), 150, 600, [2]float64{50.8, 12.7}, image.Pt(1200, 300)},
+		{image.Pt(300, 75), 150, 72, [2]float64{50.8, 12.7}, image.Pt(144, 36)},
+		// 1in x 0.25in
+		{image.Pt(200, 50), 200, 300, [2]float64{25.4, 6.35}, image.Pt(300, 75)},
+		{image.Pt(1, 1), 600, 72, [2]float64{25.4 / 600, 25.4 / 600}, image.Pt(1, 1)},
+	}
+	for _, tt := range tests {
+		w, h := physicalSizeMM(tt.px, tt.sourceDPI)
+		if math.Abs(w-tt.wantMM[0]) > 1e-9 || math.Abs(h-tt.wantMM[1]) > 1e-9 {
+			t.Errorf("physicalSizeMM(%v, %g) = %.2f x %.2f mm, want %.2f x %.2f", tt.px, tt.sourceDPI, w, h, tt.wantMM[0], tt.wantMM[1])
+		}
+		if got := printPixelSize(tt.px, tt.sourceDPI, tt.printDPI); got != tt.want {
+			t.Errorf("printPixelSize(%v, %g, %g) = %v, want %v", tt.px, tt.sourceDPI, tt.printDPI, got, tt.want)
+		}
+	}
+}
+
+func TestPrintDPIOutputSize(t *testing.T) {
+	opts := fixtureOptions(t)
+	opts.PrintDPI = 300
+	results := extractFixture(t, opts, signaturePage(t, t.TempDir()))
+	if len(results) != 1 {
+		t.Fatalf("got %d results, want 1", len(results))
+	}
+	res := results[0]
+	// The reported physical size, in pixels at the print DPI
+	want := image.Pt(int(math.Round(res.WidthMM/mmPerInch*300)), int(math.Round(res.HeightMM/mmPerInch*300)))
+	if got := res.Image.Bounds().Size(); got != want {
+		t.Errorf("output %v for %.1f x %.1f mm at 300 DPI, want %v", got, res.WidthMM, res.HeightMM, want)
+	}
+	if res.PrintDPI != 300 {
+		t.Errorf("PrintDPI = %g, want 300", res.PrintDPI)
+	}
+}
@@ -0,0 +1,150 @@
+package signature
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"os"
+)
+
+// psdLayer is one layer of a PSD document. All layers share the canvas size of the first.
+type psdLayer struct {
+	Name  string
+	Image image.Image
+}
+
+// psdChannelIDs are the per-layer channels written: transparency mask, then R, G, B.
+var psdChannelIDs = []int16{-1, 0, 1, 2}
+
+// writePSD writes an 8-bit RGB Photoshop document with the given layers, the first
+// being the bottom one, plus a composite flattened onto white for viewers that only
+// read the merged image. Only the uncompressed (raw) encoding is used.
+func writePSD(path string, layers []psdLayer) error {
+	if len(layers) == 0 {
+		return fmt.Errorf("PSD needs at least one layer")
+	}
+	canvas := layers[0].Image.Bounds()
+	w, h := canvas.Dx(), canvas.Dy()
+
+	var buf bytes.Buffer
+	be := func(v any) { binary.Write(&buf, binary.BigEndian, v) }
+
+	// File header: signature, version 1, 6 reserved bytes, 3 channels, size, depth 8, RGB mode
+	buf.WriteString("8BPS")
+	be(uint16(1))
+	buf.Write(make([]byte, 6))
+	be(uint16(3))
+	be(uint32(h))
+	be(uint32(w))
+	be(uint16(8))
+	be(uint16(3))
+
+	// Empty color mode data and image resources sections
+	be(uint32(0))
+	be(uint32(0))
+
+	// Layer and mask information section
+	layerInfo := psdLayerInfo(layers, canvas)
+	be(uint32(4 + len(layerInfo) + 4))
+	be(uint32(len(layerInfo)))
+	buf.Write(layerInfo)
+	be(uint32(0)) // no global layer mask
+
+	// Composite image data: raw, planar R, G, B
+	composite := image.NewRGBA(canvas)
+	draw.Draw(composite, canvas, image.NewUniform(color.White), image.Point{}, draw.Src)
+	for _, l := range layers {
+		draw.Draw(composite, canvas, l.Image, l.Image.Bounds().Min, draw.Over)
+	}
+	be(uint16(0))
+	for ch := 0; ch < 3; ch++ {
+		buf.Write(psdPlane(composite, canvas, ch))
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	bw := bufio.NewWriter(f)
+	if _, err := bw.Write(buf.Bytes()); err != nil {
+		return err
+	}
+	if err := bw.Flush(); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// psdLayerInfo encodes the layer count, layer records and channel image data,
+// padded to an even length as the format requires.
+func psdLayerInfo(layers []psdLayer, canvas image.Rectangle) []byte {
+	var buf bytes.Buffer
+	be := func(v any) { binary.Write(&buf, binary.BigEndian, v) }
+	w, h := canvas.Dx(), canvas.Dy()
+	channelLen := uint32(2 + w*h)
+
+	be(int16(len(layers)))
+	for _, l := range layers {
+		be(int32(0))
+		be(int32(0))
+		be(int32(h))
+		be(int32(w))
+		be(uint16(len(psdChannelIDs)))
+		for _, id := range psdChannelIDs {
+			be(id)
+			be(channelLen)
+		}
+		buf.WriteString("8BIMnorm")
+		buf.Write([]byte{255, 0, 0, 0}) // opacity, clipping, flags, filler
+
+		// Pascal string name, padded (with its length byte) to a multiple of 4
+		name := []byte(l.Name)
+		if len(name) > 255 {
+			name = name[:255]
+		}
+		padded := append([]byte{byte(len(name))}, name...)
+		for len(padded)%4 != 0 {
+			padded = append(padded, 0)
+		}
+		be(uint32(4 + 4 + len(padded)))
+		be(uint32(0)) // no layer mask
+		be(uint32(0)) // no blending ranges
+		buf.Write(padded)
+	}
+
+	for _, l := range layers {
+		for _, id := range psdChannelIDs {
+			be(uint16(0)) // raw
+			ch := 3       // alpha
+			if id >= 0 {
+				ch = int(id)
+			}
+			buf.Write(psdPlane(l.Image, canvas, ch))
+		}
+	}
+
+	if buf.Len()%2 != 0 {
+		buf.WriteByte(0)
+	}
+	return buf.Bytes()
+}
+
+// psdPlane extracts one non-premultiplied channel (0=R, 1=G, 2=B, 3=A) of img
+// over the canvas in row-major order.
+func psdPlane(img image.Image, canvas image.Rectangle, ch int) []byte {
+	plane := make([]byte, 0, canvas.Dx()*canvas.Dy())
+	origin := img.Bounds().Min
+	for y := 0; y < canvas.Dy(); y++ {
+		for x := 0; x < canvas.Dx(); x++ {
+			c := color.NRGBAModel.Convert(img.At(origin.X+x, origin.Y+y)).(color.NRGBA)
+			plane = append(plane, [4]uint8{c.R, c.G, c.B, c.A}[ch])
+		}
+	}
+	return plane
+}
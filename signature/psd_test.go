@@ -0,0 +1,174 @@
+package signature
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/draw"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// psdLayerRecord is what readPSD learns about one layer.
+type psdLayerRecord struct {
+	name   string
+	bounds image.Rectangle
+	// planes are the layer's channels by ID: -1 transparency, 0-2 R, G, B.
+	planes map[int16][]byte
+}
+
+// readPSD parses an uncompressed 8-bit RGB PSD as an application opening it
+// would, checking every section length, and returns its size and layers.
+func readPSD(t *testing.T, data []byte) (image.Point, []psdLayerRecord) {
+	t.Helper()
+	r := bytes.NewReader(data)
+	read := func(v any) {
+		t.Helper()
+		if err := binary.Read(r, binary.BigEndian, v); err != nil {
+			t.Fatalf("truncated PSD: %v", err)
+		}
+	}
+	skip := func(n int64) {
+		t.Helper()
+		if n < 0 || n > int64(r.Len()) {
+			t.Fatalf("PSD section length runs %d bytes past its end", n-int64(r.Len()))
+		}
+		if _, err := r.Seek(n, io.SeekCurrent); err != nil {
+			t.Fatalf("truncated PSD skipping %d bytes", n)
+		}
+	}
+
+	var header struct {
+		Sig      [4]byte
+		Version  uint16
+		Reserved [6]byte
+		Channels uint16
+		H, W     uint32
+		Depth    uint16
+		Mode     uint16
+	}
+	read(&header)
+	if string(header.Sig[:]) != "8BPS" || header.Version != 1 || header.Depth != 8 || header.Mode != 3 {
+		t.Fatalf("not an 8-bit RGB PSD: %+v", header)
+	}
+	var n uint32
+	read(&n) // color mode data
+	skip(int64(n))
+	read(&n) // image resources
+	skip(int64(n))
+
+	var sectionLen, layerInfoLen uint32
+	read(&sectionLen)
+	sectionEnd := int64(len(data)-r.Len()) + int64(sectionLen)
+	read(&layerInfoLen)
+	layerInfoEnd := int64(len(data)-r.Len()) + int64(layerInfoLen)
+	var count int16
+	read(&count)
+	if count < 0 {
+		count = -count
+	}
+
+	layers := make([]psdLayerRecord, count)
+	type channel struct {
+		id  int16
+		len uint32
+	}
+	channels := make([][]channel, count)
+	for i := range layers {
+		var top, left, bottom, right int32
+		read(&top)
+		read(&left)
+		read(&bottom)
+		read(&right)
+		layers[i].bounds = image.Rect(int(left), int(top), int(right), int(bottom))
+		var nch uint16
+		read(&nch)
+		for range nch {
+			var c channel
+			read(&c.id)
+			read(&c.len)
+			channels[i] = append(channels[i], c)
+		}
+		var blend [12]byte
+		read(&blend)
+		if string(blend[:4]) != "8BIM" {
+			t.Fatalf("layer %d: bad blend mode signature %q", i, blend[:4])
+		}
+		var extraLen, maskLen, rangesLen uint32
+		read(&extraLen)
+		extraEnd := int64(len(data)-r.Len()) + int64(extraLen)
+		read(&maskLen)
+		skip(int64(maskLen))
+		read(&rangesLen)
+		skip(int64(rangesLen))
+		var nameLen uint8
+		read(&nameLen)
+		name := make([]byte, nameLen)
+		read(name)
+		layers[i].name = string(name)
+		skip(extraEnd - int64(len(data)-r.Len()))
+	}
+	for i := range layers {
+		layers[i].planes = map[int16][]byte{}
+		size := layers[i].bounds.Dx() * layers[i].bounds.Dy()
+		for _, c := range channels[i] {
+			var compression uint16
+			read(&compression)
+			if compression != 0 || int(c.len) != 2+size {
+				t.Fatalf("layer %d channel %d: compression %d, length %d for %d pixels", i, c.id, compression, c.len, size)
+			}
+			plane := make([]byte, size)
+			read(plane)
+			layers[i].planes[c.id] = plane
+		}
+	}
+	if pos := int64(len(data) - r.Len()); pos > layerInfoEnd || layerInfoEnd > sectionEnd {
+		t.Fatalf("layer info overruns its section: at %d, layer info ends %d, section %d", pos, layerInfoEnd, sectionEnd)
+	}
+	skip(sectionEnd - int64(len(data)-r.Len()))
+
+	// The merged image: compression, then one plane per channel
+	var compression uint16
+	read(&compression)
+	if want := int(header.Channels) * int(header.W) * int(header.H); compression != 0 || r.Len() != want {
+		t.Fatalf("composite: compression %d, %d bytes, want raw %d", compression, r.Len(), want)
+	}
+	return image.Pt(int(header.W), int(header.H)), layers
+}
+
+func TestPSDHasTwoLayers(t *testing.T) {
+	original := image.NewRGBA(image.Rect(0, 0, 40, 20))
+	draw.Draw(original, original.Bounds(), image.NewUniform(color.RGBA{R: 240, G: 240, B: 240, A: 255}), image.Point{}, draw.Src)
+	sig := image.NewRGBA(image.Rect(0, 0, 40, 20))
+	sig.SetRGBA(10, 5, blueInk)
+	original.SetRGBA(10, 5, blueInk)
+
+	path := filepath.Join(t.TempDir(), "signature.psd")
+	if err := writePSD(path, []psdLayer{{Name: "Original crop", Image: original}, {Name: "Signature", Image: sig}}); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	size, layers := readPSD(t, data)
+	if size != image.Pt(40, 20) {
+		t.Errorf("canvas %v, want 40x20", size)
+	}
+	if len(layers) != 2 || layers[0].name != "Original crop" || layers[1].name != "Signature" {
+		t.Fatalf("layers %v, want Original crop and Signature", layers)
+	}
+	alpha := layers[1].planes[-1]
+	if alpha[5*40+10] != 255 || alpha[0] != 0 {
+		t.Errorf("signature layer alpha: ink %d, background %d, want 255 and 0", alpha[5*40+10], alpha[0])
+	}
+	if got := (color.RGBA{R: layers[1].planes[0][5*40+10], G: layers[1].planes[1][5*40+10], B: layers[1].planes[2][5*40+10], A: 255}); got != blueInk {
+		t.Errorf("signature layer ink %v, want %v", got, blueInk)
+	}
+	if layers[0].planes[-1][0] != 255 {
+		t.Error("original crop layer is not opaque")
+	}
+}
@@ -0,0 +1,135 @@
+package signature
+
+import (
+	"gocv.io/x/gocv"
+)
+
+// Capture quality parameters. Each factor ramps from 0 at the first value to 1
+// at the second.
+const (
+	// qualityMinInkPx and qualityGoodInkPx bound the ink pixel count: too few
+	// pixels, from a low DPI or a tiny signature, can't be verified.
+	qualityMinInkPx, qualityGoodInkPx = 500, 4000
+	// qualityFragmentShare is the share of the ink under which a connected
+	// piece of it is a fragment rather than a stroke.
+	qualityFragmentShare = 0.01
+	// qualityMinContinuity and qualityGoodContinuity bound the share of the
+	// ink that lies in strokes rather than fragments.
+	qualityMinContinuity, qualityGoodContinuity = 0.6, 0.95
+	// qualityMaxBorderInk is the inked share of the crop's border at which the
+	// clipping factor reaches 0: ink there runs off the crop.
+	qualityMaxBorderInk = 0.02
+	// qualityMinContrast and qualityGoodContrast bound the gray level gap
+	// between the median ink and the median paper pixel.
+	qualityMinContrast, qualityGoodContrast = 50, 140
+)
+
+// Quality rates how well a signature was captured, so a caller can ask for it
+// to be signed again when it is too poor to verify. Unlike the confidence, which
+// asks whether the region is a signature, it asks whether it is a usable one.
+// The factors are each in [0, 1] and Score is their mean.
+type Quality struct {
+	Score float64 `json:"score"`
+	// Resolution scores InkPixels, the number of ink pixels of the crop.
+	Resolution float64 `json:"resolution"`
+	InkPixels  int     `json:"ink_pixels"`
+	// Continuity scores how much of the ink lies in whole strokes; a faint or
+	// badly scanned pen breaks up into specks.
+	Continuity float64 `json:"continuity"`
+	// Clipping is 1 when no ink touches the edge of the crop and falls as more
+	// does, since the signature then runs past it.
+	Clipping float64 `json:"clipping"`
+	// Contrast scores how much darker the ink is than the paper.
+	Contrast float64 `json:"contrast"`
+}
+
+// measureQuality rates the capture of the signature in the BGR crop, padding
+// included.
+func measureQuality(crop gocv.Mat) Quality {
+	gray := gocv.NewMat()
+	defer gray.Close()
+	gocv.CvtColor(crop, &gray, gocv.ColorBGRToGray)
+	mask := gocv.NewMat()
+	defer mask.Close()
+	gocv.Threshold(gray, &mask, inkThreshold, 255, gocv.ThresholdBinaryInv)
+
+	q := Quality{InkPixels: gocv.CountNonZero(mask)}
+	if q.InkPixels == 0 {
+		return q
+	}
+	q.Resolution = ramp(float64(q.InkPixels), qualityMinInkPx, qualityGoodInkPx)
+	q.Continuity = ramp(strokeShare(mask, q.InkPixels), qualityMinContinuity, qualityGoodContinuity)
+	q.Clipping = 1 - ramp(borderInk(mask), 0, qualityMaxBorderInk)
+	q.Contrast = ramp(inkContrast(gray.ToBytes(), mask.ToBytes()), qualityMinContrast, qualityGoodContrast)
+	q.Score = (q.Resolution + q.Continuity + q.Clipping + q.Contrast) / 4
+	return q
+}
+
+// strokeShare is the share of the ink pixels of mask that lie in connected
+// pieces of at least qualityFragmentShare of the ink.
+func strokeShare(mask gocv.Mat, ink int) float64 {
+	labels := gocv.NewMat()
+	defer labels.Close()
+	stats := gocv.NewMat()
+	defer stats.Close()
+	centroids := gocv.NewMat()
+	defer centroids.Close()
+	n := gocv.ConnectedComponentsWithStats(mask, &labels, &stats, &centroids)
+
+	minArea := qualityFragmentShare * float64(ink)
+	strokes := 0
+	// Label 0 is the background
+	for i := 1; i < n; i++ {
+		if area := int(stats.GetIntAt(i, int(gocv.CC_STAT_AREA))); float64(area) >= minArea {
+			strokes += area
+		}
+	}
+	return float64(strokes) / float64(ink)
+}
+
+// borderInk is the inked share of the pixels along the four edges of mask.
+func borderInk(mask gocv.Mat) float64 {
+	rows, cols := mask.Rows(), mask.Cols()
+	if rows < 2 || cols < 2 {
+		return 1
+	}
+	inked := 0
+	for x := 0; x < cols; x++ {
+		if mask.GetUCharAt(0, x) != 0 {
+			inked++
+		}
+		if mask.GetUCharAt(rows-1, x) != 0 {
+			inked++
+		}
+	}
+	for y := 1; y < rows-1; y++ {
+		if mask.GetUCharAt(y, 0) != 0 {
+			inked++
+		}
+		if mask.GetUCharAt(y, cols-1) != 0 {
+			inked++
+		}
+	}
+	return float64(inked) / float64(2*(rows+cols)-4)
+}
+
+// inkContrast is the gap between the median gray level of the paper and of the
+// ink, told apart by mask (ink 255) over the same pixels as gray.
+func inkContrast(gray, mask []byte) float64 {
+	var ink, paper [256]int
+	var inkN, paperN int
+	for i, v := range gray {
+		if mask[i] != 0 {
+			ink[v]++
+			inkN++
+		} else {
+			paper[v]++
+			paperN++
+		}
+	}
+	if paperN == 0 {
+		// All ink: nothing to stand out from
+		return 0
+	}
+	return float64(histogramPercentile(paper, paperN, 0.5) - histogramPercentile(ink, inkN, 0.5))
+}
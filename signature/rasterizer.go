@@ -0,0 +1,107 @@
+package signature
+
+import (
+	"context"
+	"errors"
+	"image"
+)
+
+// Errors of the rasterizers, wrapped by the pipeline errors so callers can
+// branch with errors.Is.
+var (
+	// ErrEncrypted reports a password-protected PDF opened without its password,
+	// or with a wrong one (see Options.Password).
+	ErrEncrypted = errors.New("PDF is encrypted: missing or incorrect password")
+	// ErrRasterizerNotFound reports that the backend picked by Options.Rasterizer
+	// is unavailable: its tools are not on PATH, MuPDF is not compiled in, or
+	// with RasterizerAuto no backend at all is installed. The message says what
+	// to install.
+	ErrRasterizerNotFound = errors.New("rasterizer not available")
+	// ErrCorruptPDF reports a file the rasterizer could not parse as a PDF.
+	ErrCorruptPDF = errors.New("PDF is corrupt or not a PDF")
+	// ErrResourceLimit reports a document that exceeded a resource ceiling:
+	// Options.DocumentTimeout, Options.MaxRenderBytes or
+	// Options.MaxRasterizerMemory. It fails the same way on every retry.
+	ErrResourceLimit = errors.New("resource limit exceeded")
+)
+
+// Supported values for Options.Rasterizer.
+const (
+	// RasterizerAuto uses the built-in MuPDF backend when the binary was built
+	// with it (-tags fitz), and otherwise the first of poppler, mutool and
+	// Ghostscript found on PATH (see ResolveRasterizer).
+	RasterizerAuto = "auto"
+	// RasterizerPoppler shells out to pdftoppm and pdfinfo.
+	RasterizerPoppler = "poppler"
+	// RasterizerMutool shells out to MuPDF's mutool draw.
+	RasterizerMutool = "mutool"
+	// RasterizerGhostscript shells out to Ghostscript's gs.
+	RasterizerGhostscript = "ghostscript"
+	// RasterizerFitz renders in-process with MuPDF via go-fitz; needs -tags fitz.
+	RasterizerFitz = "fitz"
+)
+
+// rasterizer turns PDF pages into PNG files.
+type rasterizer interface {
+	// pageCount returns the number of pages in the PDF.
+	pageCount(ctx context.Context, pdfPath string) (int, error)
+	// box returns the area of page (1-based) that is rendered, in PDF points, and
+	// the rotation the render applies to it.
+	box(ctx context.Context, pdfPath string, page int) (pageBox, error)
+	// renderPNG rasterizes page at dpi to {outPrefix}.png, where outPrefix is a
+	// path without extension, and returns its path. When crop is non-empty only
+	// that pixel region is kept.
+	renderPNG(ctx context.Context, pdfPath string, page int, outPrefix string, dpi float64, crop image.Rectangle) (string, error)
+}
+
+// newFitzRasterizer returns the MuPDF backend; nil unless built with -tags fitz.
+var newFitzRasterizer func(password string) (rasterizer, error)
+
+// ValidRasterizer reports whether name is a known Options.Rasterizer value. A
+// valid name may still be unavailable in this build; see newRasterizer.
+func ValidRasterizer(name string) bool {
+	switch name {
+	case "", RasterizerAuto, RasterizerPoppler, RasterizerMutool, RasterizerGhostscript, RasterizerFitz:
+		return true
+	}
+	return false
+}
+
+// newRasterizer returns the backend called name, resolving "" and
+// RasterizerAuto with ResolveRasterizer. The password, when set, opens
+// encrypted PDFs; limits apply to the subprocesses of the command-line backends.
+func newRasterizer(name, password string, limits processLimits) (rasterizer, error) {
+	name, err := ResolveRasterizer(name, password)
+	if err != nil {
+		return nil, err
+	}
+	switch name {
+	case RasterizerFitz:
+		return newFitzRasterizer(password)
+	case RasterizerMutool:
+		return mutoolRasterizer{password: password, limits: limits}, nil
+	case RasterizerGhostscript:
+		return ghostscriptRasterizer{password: password, limits: limits}, nil
+	default:
+		return popplerRasterizer{password: password, limits: limits}, nil
+	}
+}
+
+// popplerRasterizer renders with the pdftoppm and pdfinfo command-line tools.
+type popplerRasterizer struct {
+	// password is passed as -upw to open encrypted PDFs.
+	password string
+	limits   processLimits
+}
+
+func (r popplerRasterizer) pageCount(ctx context.Context, pdfPath string) (int, error) {
+	return pageCount(ctx, r.limits, pdfPath, r.password)
+}
+
+func (r popplerRasterizer) box(ctx context.Context, pdfPath string, page int) (pageBox, error) {
+	return pagePopplerBox(ctx, r.limits, pdfPath, page, r.password)
+}
+
+func (r popplerRasterizer) renderPNG(ctx context.Context, pdfPath string, page int, outPrefix string, dpi float64, crop image.Rectangle) (string, error) {
+	return convertPDFToPNG(ctx, r.limits, pdfPath, page, outPrefix, dpi, crop, r.password)
+}
@@ -0,0 +1,104 @@
+//go:build fitz
+
+package signature
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+
+	"github.com/gen2brain/go-fitz"
+)
+
+func init() {
+	newFitzRasterizer = func(password string) (rasterizer, error) {
+		if password != "" {
+			return nil, fmt.Errorf("the %s rasterizer can't open password-protected PDFs; use -rasterizer %s", RasterizerFitz, RasterizerPoppler)
+		}
+		return mupdfRasterizer{}, nil
+	}
+}
+
+// mupdfRasterizer renders in-process with MuPDF, so no poppler install is needed.
+// MuPDF calls can't be interrupted; ctx is checked before and after each one.
+type mupdfRasterizer struct{}
+
+func (mupdfRasterizer) pageCount(ctx context.Context, pdfPath string) (int, error) {
+	doc, err := openFitz(ctx, pdfPath)
+	if err != nil {
+		return 0, err
+	}
+	defer doc.Close()
+	return doc.NumPage(), nil
+}
+
+// box reports the page bounds MuPDF renders: the visible page already turned
+// by its rotation, starting at the origin and rounded to whole points. go-fitz
+// exposes neither the CropBox offset nor /Rotate, so the box is that of the
+// page as displayed.
+func (mupdfRasterizer) box(ctx context.Context, pdfPath string, page int) (pageBox, error) {
+	doc, err := openFitz(ctx, pdfPath)
+	if err != nil {
+		return pageBox{}, err
+	}
+	defer doc.Close()
+
+	b, err := doc.Bound(page - 1)
+	if err != nil {
+		return pageBox{}, fmt.Errorf("mupdf error: %v", err)
+	}
+	return pageBox{Rect: PDFRect{URX: float64(b.Dx()), URY: float64(b.Dy())}}, nil
+}
+
+func (mupdfRasterizer) renderPNG(ctx context.Context, pdfPath string, page int, outPrefix string, dpi float64, crop image.Rectangle) (string, error) {
+	doc, err := openFitz(ctx, pdfPath)
+	if err != nil {
+		return "", err
+	}
+	defer doc.Close()
+
+	img, err := doc.ImageDPI(page-1, dpi)
+	if err != nil {
+		return "", fmt.Errorf("mupdf error: %v", err)
+	}
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
+	var out image.Image = img
+	if !crop.Empty() {
+		// Match pdftoppm -x/-y/-W/-H: the region, clipped to the page, at the origin
+		region := crop.Intersect(img.Bounds())
+		cropped := image.NewRGBA(image.Rect(0, 0, region.Dx(), region.Dy()))
+		draw.Draw(cropped, cropped.Bounds(), img, region.Min, draw.Src)
+		out = cropped
+	}
+
+	path := outPrefix + ".png"
+	if err := writePNG(out, path); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// openFitz opens pdfPath with MuPDF unless ctx is already done.
+func openFitz(ctx context.Context, pdfPath string) (*fitz.Document, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	doc, err := fitz.New(pdfPath)
+	if errors.Is(err, fitz.ErrNeedsPassword) {
+		// The document is open at this point, just locked
+		doc.Close()
+		return nil, ErrEncrypted
+	}
+	if errors.Is(err, fitz.ErrOpenDocument) {
+		return nil, fmt.Errorf("%w: mupdf error: %v", ErrCorruptPDF, err)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("mupdf error: %v", err)
+	}
+	return doc, nil
+}
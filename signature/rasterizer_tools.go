@@ -0,0 +1,327 @@
+package signature
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/png"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// toolBackend is a rasterizer that shells out to command-line tools.
+type toolBackend struct {
+	name string
+	// tools are the executables it needs on PATH.
+	tools []string
+	// install names the package that provides them.
+	install string
+}
+
+// toolBackends are the command-line rasterizers in the order RasterizerAuto
+// tries them: poppler renders closest to what viewers show, Ghostscript is the
+// slowest but the most widely installed.
+var toolBackends = []toolBackend{
+	{RasterizerPoppler, []string{"pdftoppm", "pdfinfo"}, "poppler-utils"},
+	{RasterizerMutool, []string{"mutool"}, "mupdf-tools"},
+	{RasterizerGhostscript, []string{"gs"}, "ghostscript"},
+}
+
+// onPath records which of the tools of toolBackends are installed, looked up
+// once on first use.
+var onPath = sync.OnceValue(func() map[string]bool {
+	found := make(map[string]bool)
+	for _, b := range toolBackends {
+		for _, tool := range b.tools {
+			_, err := exec.LookPath(tool)
+			found[tool] = err == nil
+		}
+	}
+	return found
+})
+
+// missingTool returns the first tool of b that is not on PATH, or "" when all are.
+func (b toolBackend) missingTool() string {
+	for _, tool := range b.tools {
+		if !onPath()[tool] {
+			return tool
+		}
+	}
+	return ""
+}
+
+// toolBackendNamed returns the command-line backend called name.
+func toolBackendNamed(name string) (toolBackend, bool) {
+	for _, b := range toolBackends {
+		if b.name == name {
+			return b, true
+		}
+	}
+	return toolBackend{}, false
+}
+
+// noRasterizerError explains that auto found no backend and how to install one.
+func noRasterizerError(password string) error {
+	var options []string
+	for _, b := range toolBackends {
+		options = append(options, fmt.Sprintf("%s (%s)", strings.Join(b.tools, " and "), b.install))
+	}
+	fitz := "or build with -tags fitz for the built-in MuPDF renderer"
+	if newFitzRasterizer != nil && password != "" {
+		fitz = "the built-in MuPDF renderer can't open password-protected PDFs"
+	}
+	return fmt.Errorf("%w: no PDF renderer found; install one of %s, e.g. apt-get install poppler-utils or brew install poppler, %s",
+		ErrRasterizerNotFound, strings.Join(options, ", "), fitz)
+}
+
+// ResolveRasterizer returns the backend that Options.Rasterizer name selects
+// for a PDF opened with password: for RasterizerAuto the first available of
+// RasterizerFitz (when compiled in and no password is needed),
+// RasterizerPoppler, RasterizerMutool and RasterizerGhostscript. It fails with
+// ErrRasterizerNotFound, saying what to install, when the backend (or, for
+// auto, every backend) is unavailable.
+func ResolveRasterizer(name, password string) (string, error) {
+	switch name {
+	case "", RasterizerAuto:
+		// MuPDF as bound by go-fitz can't take a password
+		if newFitzRasterizer != nil && password == "" {
+			return RasterizerFitz, nil
+		}
+		for _, b := range toolBackends {
+			if b.missingTool() == "" {
+				return b.name, nil
+			}
+		}
+		return "", noRasterizerError(password)
+	case RasterizerFitz:
+		if newFitzRasterizer == nil {
+			return "", fmt.Errorf("%w: the %s rasterizer is not compiled in (build with -tags fitz)", ErrRasterizerNotFound, RasterizerFitz)
+		}
+		return name, nil
+	}
+	b, ok := toolBackendNamed(name)
+	if !ok {
+		return "", fmt.Errorf("unknown rasterizer %q", name)
+	}
+	if tool := b.missingTool(); tool != "" {
+		return "", fmt.Errorf("%w: %s not found on PATH (install %s)", ErrRasterizerNotFound, tool, b.install)
+	}
+	return name, nil
+}
+
+// runTool runs a rasterizer's command-line tool, provided by the package
+// install, under limits and returns its standard output. A missing tool fails
+// with ErrRasterizerNotFound and one stopped by limits with ErrResourceLimit.
+func runTool(ctx context.Context, limits processLimits, tool, install string, args ...string) ([]byte, error) {
+	out, stderr, err := runLimited(ctx, limits, tool, args...)
+	if errors.Is(err, exec.ErrNotFound) {
+		return nil, fmt.Errorf("%w: %s not found on PATH (install %s)", ErrRasterizerNotFound, tool, install)
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return nil, fmt.Errorf("%s error: %v: %s", tool, err, bytes.TrimSpace(stderr))
+	}
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// readPDFStructure parses the PDF at pdfPath with pdfcpu, which reads the page
+// tree and form without rendering anything. A wrong or missing password fails
+// with ErrEncrypted and an unparseable file with ErrCorruptPDF.
+func readPDFStructure(pdfPath, password string) (*model.Context, error) {
+	f, err := os.Open(pdfPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	conf := model.NewDefaultConfiguration()
+	conf.UserPW = password
+	ctx, err := api.ReadContext(f, conf)
+	if errors.Is(err, pdfcpu.ErrWrongPassword) {
+		return nil, ErrEncrypted
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to read the PDF structure: %v", ErrCorruptPDF, err)
+	}
+	if err := ctx.EnsurePageCount(); err != nil {
+		return nil, fmt.Errorf("%w: failed to count pages: %v", ErrCorruptPDF, err)
+	}
+	return ctx, nil
+}
+
+// structurePageCount is pageCount for the backends whose tools don't report it.
+func structurePageCount(ctx context.Context, pdfPath, password string) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	pdf, err := readPDFStructure(pdfPath, password)
+	if err != nil {
+		return 0, err
+	}
+	if pdf.PageCount < 1 {
+		return 0, fmt.Errorf("invalid page count %d", pdf.PageCount)
+	}
+	return pdf.PageCount, nil
+}
+
+// structurePageBox reads the CropBox and effective /Rotate of page from the
+// page tree, for the backends that render the CropBox turned by /Rotate as
+// pdftoppm -cropbox does.
+func structurePageBox(ctx context.Context, pdfPath string, page int, password string) (pageBox, error) {
+	if err := ctx.Err(); err != nil {
+		return pageBox{}, err
+	}
+	pdf, err := readPDFStructure(pdfPath, password)
+	if err != nil {
+		return pageBox{}, err
+	}
+	if page < 1 || page > pdf.PageCount {
+		return pageBox{}, fmt.Errorf("page %d is out of range: the document has %d pages", page, pdf.PageCount)
+	}
+	boundaries, err := pdf.PageBoundaries(types.IntSet{page: true})
+	if err != nil {
+		return pageBox{}, fmt.Errorf("failed to read page boundaries: %v", err)
+	}
+	b := boundaries[page-1]
+	crop := b.CropBox()
+	if crop == nil {
+		return pageBox{}, fmt.Errorf("no CropBox or MediaBox for page %d", page)
+	}
+	if b.Rot%90 != 0 {
+		return pageBox{}, fmt.Errorf("invalid page rotation %d", b.Rot)
+	}
+	return pageBox{
+		Rect:   PDFRect{LLX: crop.LL.X, LLY: crop.LL.Y, URX: crop.UR.X, URY: crop.UR.Y},
+		Rotate: normalizeRotate(b.Rot),
+	}, nil
+}
+
+// cropPNGFile keeps only crop of the PNG at path, clipped to the image, matching
+// pdftoppm -x/-y/-W/-H for the tools that can only render whole pages.
+func cropPNGFile(path string, crop image.Rectangle) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	img, err := png.Decode(f)
+	f.Close()
+	if err != nil {
+		return fmt.Errorf("failed to decode render %s: %v", path, err)
+	}
+	region := crop.Add(img.Bounds().Min).Intersect(img.Bounds())
+	if region.Empty() {
+		return fmt.Errorf("crop %v lies outside the %dx%d render", crop, img.Bounds().Dx(), img.Bounds().Dy())
+	}
+	cropped := image.NewRGBA(image.Rect(0, 0, region.Dx(), region.Dy()))
+	draw.Draw(cropped, cropped.Bounds(), img, region.Min, draw.Src)
+	return writePNG(cropped, path)
+}
+
+// mutoolRasterizer renders with MuPDF's mutool command-line tool; the page tree
+// is read with pdfcpu.
+type mutoolRasterizer struct {
+	// password is passed as -p to open encrypted PDFs.
+	password string
+	limits   processLimits
+}
+
+func (r mutoolRasterizer) pageCount(ctx context.Context, pdfPath string) (int, error) {
+	return structurePageCount(ctx, pdfPath, r.password)
+}
+
+func (r mutoolRasterizer) box(ctx context.Context, pdfPath string, page int) (pageBox, error) {
+	return structurePageBox(ctx, pdfPath, page, r.password)
+}
+
+func (r mutoolRasterizer) renderPNG(ctx context.Context, pdfPath string, page int, outPrefix string, dpi float64, crop image.Rectangle) (string, error) {
+	// Example: mutool draw -o output.png -r 300 -c rgb [-p password] input.pdf 2
+	path := outPrefix + ".png"
+	// mutool expands %d in the output name to the page number and has no
+	// escape for it, so render under a name without % and move the page there
+	dir := filepath.Dir(path)
+	if strings.Contains(dir, "%") {
+		dir = ""
+	}
+	tmp, err := os.MkdirTemp(dir, "mutool-")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(tmp)
+	rendered := filepath.Join(tmp, "page.png")
+	args := []string{"draw", "-o", rendered, "-r", strconv.FormatFloat(dpi, 'f', -1, 64), "-c", "rgb"}
+	if r.password != "" {
+		args = append(args, "-p", r.password)
+	}
+	args = append(args, pdfPath, strconv.Itoa(page))
+	if _, err := runTool(ctx, r.limits, "mutool", "mupdf-tools", args...); err != nil {
+		return "", err
+	}
+	if err := os.Rename(rendered, path); err != nil {
+		return "", err
+	}
+	if !crop.Empty() {
+		if err := cropPNGFile(path, crop); err != nil {
+			return "", err
+		}
+	}
+	return path, nil
+}
+
+// ghostscriptRasterizer renders with Ghostscript's gs; the page tree is read
+// with pdfcpu.
+type ghostscriptRasterizer struct {
+	// password is passed as -sPDFPassword to open encrypted PDFs.
+	password string
+	limits   processLimits
+}
+
+func (r ghostscriptRasterizer) pageCount(ctx context.Context, pdfPath string) (int, error) {
+	return structurePageCount(ctx, pdfPath, r.password)
+}
+
+func (r ghostscriptRasterizer) box(ctx context.Context, pdfPath string, page int) (pageBox, error) {
+	return structurePageBox(ctx, pdfPath, page, r.password)
+}
+
+func (r ghostscriptRasterizer) renderPNG(ctx context.Context, pdfPath string, page int, outPrefix string, dpi float64, crop image.Rectangle) (string, error) {
+	// Example: gs -q -dSAFER -dBATCH -dNOPAUSE -sDEVICE=png16m -dUseCropBox -r300
+	//   -dTextAlphaBits=4 -dGraphicsAlphaBits=4 -dFirstPage=2 -dLastPage=2 -sOutputFile=output.png input.pdf
+	path := outPrefix + ".png"
+	p := strconv.Itoa(page)
+	args := []string{
+		"-q", "-dSAFER", "-dBATCH", "-dNOPAUSE", "-sDEVICE=png16m", "-dUseCropBox",
+		"-r" + strconv.FormatFloat(dpi, 'f', -1, 64),
+		// Anti-alias like pdftoppm, so stroke edges look the same to detection
+		"-dTextAlphaBits=4", "-dGraphicsAlphaBits=4",
+		"-dFirstPage=" + p, "-dLastPage=" + p,
+		// gs expands %d in the output name to the page number
+		"-sOutputFile=" + strings.ReplaceAll(path, "%", "%%"),
+	}
+	if r.password != "" {
+		args = append(args, "-sPDFPassword="+r.password)
+	}
+	args = append(args, pdfPath)
+	if _, err := runTool(ctx, r.limits, "gs", "ghostscript", args...); err != nil {
+		return "", err
+	}
+	if !crop.Empty() {
+		if err := cropPNGFile(path, crop); err != nil {
+			return "", err
+		}
+	}
+	return path, nil
+}
@@ -0,0 +1,49 @@
+package signature
+
+import (
+	"context"
+	"image"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+// fakeMutool puts a mutool on PATH that writes a blank page wherever -o
+// points, expanding %d to the page number as mutool draw does.
+func fakeMutool(t *testing.T) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake mutool is a shell script")
+	}
+	dir := t.TempDir()
+	blank := writeFixture(t, dir, "blank.png", newPage(85, 110))
+	// The output follows -o and the page comes last
+	script := "#!/bin/sh\nwhile [ $# -gt 1 ]; do [ \"$1\" = -o ] && out=$2; shift; done\n" +
+		"cp \"" + blank + "\" \"$(printf '%s' \"$out\" | sed \"s/%d/$1/g\")\"\n"
+	if err := os.WriteFile(filepath.Join(dir, "mutool"), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+func TestMutoolRendersToRequestedPath(t *testing.T) {
+	fakeMutool(t)
+	for _, name := range []string{"contract", "100%_signed_%d"} {
+		dir := t.TempDir()
+		path, err := mutoolRasterizer{}.renderPNG(context.Background(), "document.pdf", 3, filepath.Join(dir, name), fixtureDPI, image.Rectangle{})
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if want := filepath.Join(dir, name+".png"); path != want {
+			t.Errorf("%s: rendered to %s, want %s", name, path, want)
+		}
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+		// Nothing is left beside it, such as the page under an expanded name
+		if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+			t.Errorf("%s: output directory has %d entries, want 1", name, len(entries))
+		}
+	}
+}
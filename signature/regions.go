@@ -0,0 +1,67 @@
+package signature
+
+import (
+	"image"
+	"sort"
+)
+
+// Filters applied by Options.AllRegions on top of the minimum size.
+const (
+	// maxRegionAspect drops regions more than this many times longer than they are
+	// thick, such as the printed lines people sign on.
+	maxRegionAspect = 15
+	// minRegionAreaRatio drops regions smaller than this fraction of the largest
+	// one, so stray letters and initials don't count as extra signers.
+	minRegionAreaRatio = 0.2
+)
+
+// largestRect returns the rectangle with the largest area; rects must not be empty.
+func largestRect(rects []image.Rectangle) image.Rectangle {
+	best := rects[0]
+	for _, r := range rects[1:] {
+		if rectArea(r) > rectArea(best) {
+			best = r
+		}
+	}
+	return best
+}
+
+// allRegions keeps the rectangles that look like separate signatures and sorts
+// them in reading order: regions sharing a row left to right, rows top to bottom.
+func allRegions(rects []image.Rectangle) []image.Rectangle {
+	var shaped []image.Rectangle
+	for _, r := range rects {
+		long, short := max(r.Dx(), r.Dy()), min(r.Dx(), r.Dy())
+		if short > 0 && long <= maxRegionAspect*short {
+			shaped = append(shaped, r)
+		}
+	}
+	if len(shaped) == 0 {
+		return nil
+	}
+
+	minArea := float64(rectArea(largestRect(shaped))) * minRegionAreaRatio
+	var kept []image.Rectangle
+	for _, r := range shaped {
+		if float64(rectArea(r)) >= minArea {
+			kept = append(kept, r)
+		}
+	}
+
+	sort.SliceStable(kept, func(i, j int) bool { return readingOrder(kept[i], kept[j]) })
+	return kept
+}
+
+// readingOrder reports whether a comes before b when read: left to right when
+// they share a row, top to bottom otherwise.
+func readingOrder(a, b image.Rectangle) bool {
+	if a.Min.Y < b.Max.Y && b.Min.Y < a.Max.Y {
+		return a.Min.X < b.Min.X
+	}
+	return a.Min.Y < b.Min.Y
+}
+
+// rectArea returns the area of r in pixels.
+func rectArea(r image.Rectangle) int {
+	return r.Dx() * r.Dy()
+}
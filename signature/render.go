@@ -0,0 +1,239 @@
+package signature
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"math"
+	"strconv"
+
+	"gocv.io/x/gocv"
+)
+
+// pageCache renders a PDF page at most once per resolution, so detecting at one DPI
+// and cropping at another costs exactly two rasterizer runs.
+type pageCache struct {
+	raster     rasterizer
+	pdfPath    string
+	page       int
+	prefix     string
+	box        pageBox
+	roi        *PDFRect
+	upsideDown bool
+	// flatten evens out the illumination of every render as it is made (see
+	// flattenIllumination); set it before the first render.
+	flatten bool
+	// perspective warps every render flat along the outline of the
+	// photographed document found on the first one (see documentOutline);
+	// set it before the first render.
+	perspective bool
+	// outline is the document outline the renders are warped along, nil when
+	// none was found or perspective is off.
+	outline *outline
+	// maxBytes, when non-zero, fails renders larger than this many bytes with
+	// ErrResourceLimit (see Options.MaxRenderBytes).
+	maxBytes int64
+	// skew is the rotation in degrees applied after the 180° turn to level the
+	// page (see setSkew); 0 leaves it as rendered.
+	skew    float64
+	renders map[float64]pageRender
+}
+
+// pageRender is one rasterization of the page.
+type pageRender struct {
+	// Path is the PNG file.
+	Path string
+	// Origin is where the PNG's top-left pixel sits in full-page pixels at the
+	// same DPI; non-zero when only an ROI was rendered.
+	Origin image.Point
+	// Size is the width and height of the PNG.
+	Size image.Point
+	// DPI is the resolution the PNG was rendered or resampled at.
+	DPI float64
+}
+
+// newPageCache prepares renders of page (1-based) of pdfPath made by raster and
+// named after prefix, a path without extension (e.g. /tmp/poc-pdf-pages-1/pdf_page).
+// When roi is non-nil only that region (in PDF points) is rasterized.
+func newPageCache(raster rasterizer, pdfPath string, page int, prefix string, box pageBox, roi *PDFRect) *pageCache {
+	return &pageCache{
+		raster:  raster,
+		pdfPath: pdfPath,
+		page:    page,
+		prefix:  prefix,
+		box:     box,
+		roi:     roi,
+		renders: map[float64]pageRender{},
+	}
+}
+
+// render returns the PNG of the page at dpi, rasterizing it on first use.
+// The first render is named {prefix}.png; later ones carry their DPI.
+func (c *pageCache) render(ctx context.Context, dpi float64) (pageRender, error) {
+	if r, ok := c.renders[dpi]; ok {
+		return r, nil
+	}
+
+	var crop image.Rectangle
+	if c.roi != nil {
+		crop = pdfRectToPixels(*c.roi, dpi, c.box)
+		if crop.Empty() {
+			return pageRender{}, fmt.Errorf("region %v does not overlap the page %v", *c.roi, c.box.Rect)
+		}
+	}
+
+	prefix := c.prefix
+	if len(c.renders) > 0 {
+		prefix += "_" + strconv.FormatFloat(dpi, 'f', -1, 64) + "dpi"
+	}
+	path, err := c.raster.renderPNG(ctx, c.pdfPath, c.page, prefix, dpi, crop)
+	if err != nil {
+		return pageRender{}, err
+	}
+	if err := checkRenderSize(path, c.maxBytes); err != nil {
+		return pageRender{}, err
+	}
+	if c.perspective && len(c.renders) == 0 {
+		if c.outline, err = documentOutline(path); err != nil {
+			return pageRender{}, err
+		}
+	}
+	if c.outline != nil {
+		if err := c.outline.warpImageFile(path); err != nil {
+			return pageRender{}, err
+		}
+	}
+	if c.flatten {
+		if err := flattenImageFile(path, dpi); err != nil {
+			return pageRender{}, err
+		}
+	}
+	if c.upsideDown {
+		if err := rotateImageFile180(path); err != nil {
+			return pageRender{}, err
+		}
+	}
+	if c.skew != 0 {
+		if err := deskewImageFile(path, c.skew); err != nil {
+			return pageRender{}, err
+		}
+	}
+	bounds, err := imageBounds(path)
+	if err != nil {
+		return pageRender{}, err
+	}
+	r := pageRender{Path: path, Origin: crop.Min, Size: bounds.Size(), DPI: dpi}
+	c.renders[dpi] = r
+	return r, nil
+}
+
+// detectionDPI is the resolution at which a render width pixels wide at dpi
+// comes out maxWidth pixels wide, or dpi when it is no wider or maxWidth is 0.
+func detectionDPI(width int, dpi float64, maxWidth int) float64 {
+	if maxWidth <= 0 || width <= maxWidth {
+		return dpi
+	}
+	return dpi * float64(maxWidth) / float64(width)
+}
+
+// downscale shrinks the render r, made at fromDPI, to dpi for detection and
+// caches the copy as the render at dpi, named {prefix}_detect.png. Resampling
+// the render costs far less than a second rasterizer run.
+func (c *pageCache) downscale(r pageRender, fromDPI, dpi float64) (pageRender, error) {
+	if small, ok := c.renders[dpi]; ok {
+		return small, nil
+	}
+	img, err := readImage(r.Path, gocv.IMReadColor)
+	if err != nil {
+		return pageRender{}, err
+	}
+	defer img.Close()
+
+	s := dpi / fromDPI
+	size := image.Pt(max(1, int(math.Round(float64(img.Cols())*s))), max(1, int(math.Round(float64(img.Rows())*s))))
+	small := gocv.NewMat()
+	defer small.Close()
+	gocv.Resize(img, &small, size, 0, 0, gocv.InterpolationArea)
+	path := c.prefix + "_detect.png"
+	if !gocv.IMWrite(path, small) {
+		return pageRender{}, fmt.Errorf("unable to write image: %s", path)
+	}
+	origin := scaleRect(image.Rectangle{Min: r.Origin, Max: r.Origin}, fromDPI, dpi).Min
+	down := pageRender{Path: path, Origin: origin, Size: size, DPI: dpi}
+	c.renders[dpi] = down
+	return down, nil
+}
+
+// setUpsideDown turns every existing render over and makes later renders come out
+// rotated by 180° too, so all of them show the page upright.
+func (c *pageCache) setUpsideDown() error {
+	if c.upsideDown {
+		return nil
+	}
+	for _, r := range c.renders {
+		if err := rotateImageFile180(r.Path); err != nil {
+			return err
+		}
+	}
+	c.upsideDown = true
+	return nil
+}
+
+// setSkew levels every existing render by rotating it angle degrees about its
+// centre (see deskewImageFile) and makes later renders come out level too. It
+// must be called once, after any setUpsideDown.
+func (c *pageCache) setSkew(angle float64) error {
+	for _, r := range c.renders {
+		if err := deskewImageFile(r.Path, angle); err != nil {
+			return err
+		}
+	}
+	c.skew = angle
+	return nil
+}
+
+// warped reports whether the renders are warped flat along a document
+// outline.
+func (c *pageCache) warped() bool {
+	return c.outline != nil
+}
+
+// unwarp maps a rectangle of a warped render of the given size back onto the
+// page as rasterized; it returns r as it is when the renders aren't warped.
+func (c *pageCache) unwarp(r image.Rectangle, size image.Point) image.Rectangle {
+	if c.outline == nil {
+		return r
+	}
+	return c.outline.unwarpRect(r, size)
+}
+
+// scaleRect maps a rectangle between two renders of the same page, rounding
+// outwards so no ink on the boundary is lost.
+func scaleRect(rect image.Rectangle, fromDPI, toDPI float64) image.Rectangle {
+	s := toDPI / fromDPI
+	return image.Rect(
+		int(math.Floor(float64(rect.Min.X)*s)),
+		int(math.Floor(float64(rect.Min.Y)*s)),
+		int(math.Ceil(float64(rect.Max.X)*s)),
+		int(math.Ceil(float64(rect.Max.Y)*s)),
+	)
+}
+
+// cropImage loads imgPath and returns a copy of rect (clipped to the image) as a
+// BGR Mat owned by the caller; there is none to close on error.
+func cropImage(imgPath string, rect image.Rectangle) (gocv.Mat, error) {
+	img, err := readImage(imgPath, gocv.IMReadColor)
+	if err != nil {
+		return gocv.Mat{}, err
+	}
+	defer img.Close()
+
+	rect = rect.Intersect(image.Rect(0, 0, img.Cols(), img.Rows()))
+	if rect.Empty() {
+		return gocv.Mat{}, fmt.Errorf("crop %v lies outside %s", rect, imgPath)
+	}
+
+	region := img.Region(rect)
+	defer region.Close()
+	return region.Clone(), nil
+}
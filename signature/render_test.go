@@ -0,0 +1,133 @@
+package signature
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+// writePatternPNG writes a w x h image whose every pixel differs from its
+// neighbours, so a crop taken from the wrong place can't match.
+func writePatternPNG(t *testing.T, path string, w, h int) {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := range h {
+		for x := range w {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: uint8(x/256 + 4*(y/256)), A: 255})
+		}
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := png.Encode(f, img); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func decodePNG(t *testing.T, path string) image.Image {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	img, err := png.Decode(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return img
+}
+
+// checkROIRender renders page 1 of path whole and with roi at dpi and checks
+// the ROI render is the requested crop of the whole one, to within tolerance
+// per channel.
+func checkROIRender(t *testing.T, raster rasterizer, path string, roi PDFRect, dpi float64, tolerance int) {
+	t.Helper()
+	ctx := context.Background()
+	box, err := raster.box(ctx, path, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	full, err := newPageCache(raster, path, 1, filepath.Join(dir, "full"), box, nil).render(ctx, dpi)
+	if err != nil {
+		t.Fatal(err)
+	}
+	part, err := newPageCache(raster, path, 1, filepath.Join(dir, "roi"), box, &roi).render(ctx, dpi)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := pdfRectToPixels(roi, dpi, box)
+	if got := (image.Rectangle{Min: part.Origin, Max: part.Origin.Add(part.Size)}); got != want {
+		t.Fatalf("ROI %v rendered as %v, want %v", roi, got, want)
+	}
+	fullImg, partImg := decodePNG(t, full.Path), decodePNG(t, part.Path)
+	for y := range part.Size.Y {
+		for x := range part.Size.X {
+			r0, g0, b0, _ := fullImg.At(want.Min.X+x, want.Min.Y+y).RGBA()
+			r1, g1, b1, _ := partImg.At(x, y).RGBA()
+			for _, d := range []int{int(r0>>8) - int(r1>>8), int(g0>>8) - int(g1>>8), int(b0>>8) - int(b1>>8)} {
+				if d > tolerance || d < -tolerance {
+					t.Fatalf("ROI pixel (%d,%d) differs from page pixel %v", x, y, want.Min.Add(image.Pt(x, y)))
+				}
+			}
+		}
+	}
+}
+
+func TestRenderROIMatchesCrop(t *testing.T) {
+	t.Run("image", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "page.png")
+		writePatternPNG(t, path, 612, 792)
+		// At the image's own resolution the crop is exact
+		checkROIRender(t, imageRasterizer{dpi: 72}, path, PDFRect{LLX: 100, LLY: 200, URX: 300.5, URY: 410}, 72, 0)
+	})
+	t.Run("poppler", func(t *testing.T) {
+		for _, tool := range []string{"pdfinfo", "pdftoppm"} {
+			if _, err := exec.LookPath(tool); err != nil {
+				t.Skipf("%s not installed", tool)
+			}
+		}
+		// The signature area of the sample letter page, rendered by pdftoppm -x -y -W -H
+		checkROIRender(t, popplerRasterizer{}, "../test.pdf", PDFRect{LLX: 300, LLY: 50, URX: 580, URY: 150}, 150, 8)
+	})
+}
+
+func TestPageEdges(t *testing.T) {
+	box := pageBox{Rect: PDFRect{URX: 612, URY: 792}}
+	roi := PDFRect{LLX: 100, LLY: 100, URX: 300, URY: 200}
+	tests := []struct {
+		name     string
+		roi      *PDFRect
+		rotation int
+		origin   image.Point
+		size     image.Point
+		want     image.Rectangle
+	}{
+		{"whole page", nil, 0, image.Point{}, image.Pt(612, 792), image.Rect(0, 0, 612, 792)},
+		{"whole page turned", nil, 180, image.Point{}, image.Pt(612, 792), image.Rect(0, 0, 612, 792)},
+		// The ROI [100 100 300 200] is at (100,592)-(300,692) on the page
+		{"roi", &roi, 0, image.Pt(100, 592), image.Pt(200, 100), image.Rect(-100, -592, 512, 200)},
+		{"roi turned", &roi, 180, image.Pt(100, 592), image.Pt(200, 100), image.Rect(-312, -100, 300, 692)},
+	}
+	for _, tt := range tests {
+		st := &pageState{box: box, rotation: tt.rotation, page: pageRender{Origin: tt.origin, Size: tt.size, DPI: 72}}
+		st.opts.ROI = tt.roi
+		got := st.pageEdges()
+		if got != tt.want {
+			t.Errorf("%s: pageEdges() = %v, want %v", tt.name, got, tt.want)
+		}
+		// Ink along the ROI's border is not at the page's edge
+		if tt.roi != nil && touchesEdge(image.Rect(0, 0, 50, 20), got, 5) {
+			t.Errorf("%s: ink at the ROI's corner counted as touching the page edge", tt.name)
+		}
+	}
+}
@@ -0,0 +1,19 @@
+package signature
+
+import "math"
+
+// thresholdReferenceDPI is the resolution the pixel-based detection thresholds
+// were tuned at. They are rescaled to the actual render so that the same page
+// is judged alike at any DPI.
+const thresholdReferenceDPI = 150.0
+
+// scaleLength converts a length tuned at thresholdReferenceDPI to pixels at dpi.
+func scaleLength(px int, dpi float64) int {
+	return max(int(math.Round(float64(px)*dpi/thresholdReferenceDPI)), 1)
+}
+
+// scaleArea converts an area tuned at thresholdReferenceDPI to pixels at dpi.
+func scaleArea(px float64, dpi float64) float64 {
+	s := dpi / thresholdReferenceDPI
+	return px * s * s
+}
@@ -0,0 +1,316 @@
+package signature
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"math"
+	"path/filepath"
+	"sort"
+	"strconv"
+
+	"gocv.io/x/gocv"
+)
+
+// Seal ink colors, reported as Result.SealColor.
+const (
+	SealRed  = "red"
+	SealBlue = "blue"
+)
+
+// Seal detection parameters. Seals are stamped in saturated ink, so they are
+// segmented by hue rather than by darkness, then confirmed as circles.
+const (
+	// sealMinSaturation and sealMinValue keep gray paper and black ink out of
+	// the seal masks.
+	sealMinSaturation = 0.35
+	sealMinValue      = 0.3
+	// sealMinDiameterMM and sealMaxDiameterMM bound the size of a round seal.
+	sealMinDiameterMM = 15
+	sealMaxDiameterMM = 60
+	// sealMinCoverage is the share of a circle's rim that must be inked for it
+	// to count as a seal rather than a circle Hough found in the lettering.
+	sealMinCoverage = 0.5
+	// sealRimSamples is how many points around a circle are checked for ink.
+	sealRimSamples = 90
+	// sealRimBand is how far, as a fraction of the radius, ink may lie inside
+	// or outside the circle and still count as its rim.
+	sealRimBand = 0.06
+	// sealMargin grows the circle by this fraction of its radius when the seal
+	// is cut out, so the outer edge of its rim is kept.
+	sealMargin = 0.08
+)
+
+// sealHues are the hue ranges, in degrees, of each seal ink color; red wraps
+// around 0.
+var sealHues = []struct {
+	color  string
+	lo, hi float64
+}{
+	{SealRed, 330, 20},
+	{SealBlue, 190, 260},
+}
+
+// sealColor returns the seal ink color of a pixel, or "" for paper and other ink.
+func sealColor(r, g, b uint8) string {
+	h, s, v := rgbToHSV(r, g, b)
+	if s < sealMinSaturation || v < sealMinValue {
+		return ""
+	}
+	for _, hue := range sealHues {
+		if hue.lo <= hue.hi && h >= hue.lo && h <= hue.hi || hue.lo > hue.hi && (h >= hue.lo || h <= hue.hi) {
+			return hue.color
+		}
+	}
+	return ""
+}
+
+// sealCircle is one seal found on a page, in pixels of the image it was found in.
+type sealCircle struct {
+	center image.Point
+	radius int
+	color  string
+	// coverage is the inked share of the rim; it is the seal's confidence.
+	coverage float64
+}
+
+// bounds is the square the seal is cut out of, before clipping to the image.
+func (c sealCircle) bounds() image.Rectangle {
+	r := int(math.Ceil(float64(c.radius) * (1 + sealMargin)))
+	return image.Rect(c.center.X-r, c.center.Y-r, c.center.X+r+1, c.center.Y+r+1)
+}
+
+// contains reports whether the pixel at x, y lies within the seal's margin.
+func (c sealCircle) contains(x, y int) bool {
+	dx, dy := float64(x-c.center.X), float64(y-c.center.Y)
+	r := float64(c.radius) * (1 + sealMargin)
+	return dx*dx+dy*dy <= r*r
+}
+
+// findSeals returns the round seals on a BGR page rendered at dpi, in reading
+// order. Each seal color is masked on its own, circles of a seal's size are
+// found in the mask with the Hough transform, and only those whose rim is
+// mostly inked are kept.
+func findSeals(img gocv.Mat, dpi float64) ([]sealCircle, error) {
+	data, err := bgrPixels(img)
+	if err != nil {
+		return nil, err
+	}
+	cols, rows := img.Cols(), img.Rows()
+	masks := map[string][]byte{}
+	for i := 0; i < rows*cols; i++ {
+		c := sealColor(data[3*i+2], data[3*i+1], data[3*i])
+		if c == "" {
+			continue
+		}
+		if masks[c] == nil {
+			masks[c] = make([]byte, rows*cols)
+		}
+		masks[c][i] = 255
+	}
+
+	minRadius := int(sealMinDiameterMM / 2 / mmPerInch * dpi)
+	maxRadius := int(sealMaxDiameterMM / 2 / mmPerInch * dpi)
+	var found []sealCircle
+	for _, hue := range sealHues {
+		mask := masks[hue.color]
+		if mask == nil {
+			continue
+		}
+		circles, err := houghCircles(mask, rows, cols, minRadius, maxRadius)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range circles {
+			c.color = hue.color
+			c.coverage = rimCoverage(mask, cols, rows, c)
+			if c.coverage >= sealMinCoverage {
+				found = append(found, c)
+			}
+		}
+	}
+
+	// Rings and lettering of one seal give several concentric circles; keep
+	// the best-inked of each group
+	sort.SliceStable(found, func(i, j int) bool { return found[i].coverage > found[j].coverage })
+	var seals []sealCircle
+	for _, c := range found {
+		overlaps := false
+		for _, kept := range seals {
+			d, r := c.center.Sub(kept.center), max(c.radius, kept.radius)
+			if d.X*d.X+d.Y*d.Y < r*r {
+				overlaps = true
+				break
+			}
+		}
+		if !overlaps {
+			seals = append(seals, c)
+		}
+	}
+	sort.SliceStable(seals, func(i, j int) bool { return readingOrder(seals[i].bounds(), seals[j].bounds()) })
+	return seals, nil
+}
+
+// houghCircles runs the Hough circle transform on a rows x cols seal mask.
+// The mask is blurred first, since the gradient method needs soft edges.
+func houghCircles(mask []byte, rows, cols, minRadius, maxRadius int) ([]sealCircle, error) {
+	m, err := gocv.NewMatFromBytes(rows, cols, gocv.MatTypeCV8U, mask)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build seal mask: %v", err)
+	}
+	defer m.Close()
+	blurred := gocv.NewMat()
+	defer blurred.Close()
+	gocv.GaussianBlur(m, &blurred, image.Pt(9, 9), 2, 2, gocv.BorderDefault)
+
+	circles := gocv.NewMat()
+	defer circles.Close()
+	// Seals of one page rarely overlap, so centres lie at least a radius apart
+	gocv.HoughCirclesWithParams(blurred, &circles, gocv.HoughGradient, 1, float64(minRadius), 100, 30, minRadius, maxRadius)
+	found := make([]sealCircle, 0, circles.Cols())
+	for i := 0; i < circles.Cols(); i++ {
+		v := circles.GetVecfAt(0, i)
+		found = append(found, sealCircle{
+			center: image.Pt(int(math.Round(float64(v[0]))), int(math.Round(float64(v[1])))),
+			radius: int(math.Round(float64(v[2]))),
+		})
+	}
+	return found, nil
+}
+
+// rimCoverage is the share of sealRimSamples directions from the centre of c
+// in which the mask has ink within sealRimBand of its radius.
+func rimCoverage(mask []byte, cols, rows int, c sealCircle) float64 {
+	band := max(1, int(float64(c.radius)*sealRimBand))
+	hits := 0
+	for k := 0; k < sealRimSamples; k++ {
+		angle := 2 * math.Pi * float64(k) / sealRimSamples
+		cos, sin := math.Cos(angle), math.Sin(angle)
+		for r := c.radius - band; r <= c.radius+band; r++ {
+			x := c.center.X + int(math.Round(float64(r)*cos))
+			y := c.center.Y + int(math.Round(float64(r)*sin))
+			if x >= 0 && y >= 0 && x < cols && y < rows && mask[y*cols+x] != 0 {
+				hits++
+				break
+			}
+		}
+	}
+	return float64(hits) / sealRimSamples
+}
+
+// whitenSeals paints the seal-colored pixels inside each of seals white in
+// place, so a signature written across a seal is detected without it. Ink of
+// another color, such as a blue signature over a red seal, is kept.
+func whitenSeals(img *gocv.Mat, seals []sealCircle) {
+	imgRect := image.Rect(0, 0, img.Cols(), img.Rows())
+	for _, seal := range seals {
+		rect := seal.bounds().Intersect(imgRect)
+		for y := rect.Min.Y; y < rect.Max.Y; y++ {
+			for x := rect.Min.X; x < rect.Max.X; x++ {
+				if !seal.contains(x, y) {
+					continue
+				}
+				v := img.GetVecbAt(y, x)
+				if sealColor(v[2], v[1], v[0]) == seal.color {
+					for ch := 0; ch < 3; ch++ {
+						img.SetUCharAt(y, x*3+ch, 255)
+					}
+				}
+			}
+		}
+	}
+}
+
+// cutOutSeal returns the seal as a transparent image: the pixels of its ink
+// color within its circle keep their color, everything else is cleared. It
+// also returns the clipped rectangle of img the image covers.
+func cutOutSeal(img gocv.Mat, seal sealCircle) (*image.RGBA, image.Rectangle, error) {
+	rect := seal.bounds().Intersect(image.Rect(0, 0, img.Cols(), img.Rows()))
+	region := img.Region(rect)
+	defer region.Close()
+	data, err := bgrPixels(region)
+	if err != nil {
+		return nil, rect, err
+	}
+	out := image.NewRGBA(image.Rect(0, 0, rect.Dx(), rect.Dy()))
+	for y := 0; y < rect.Dy(); y++ {
+		for x := 0; x < rect.Dx(); x++ {
+			i, j := 3*(y*rect.Dx()+x), out.PixOffset(x, y)
+			b, g, r := data[i], data[i+1], data[i+2]
+			if seal.contains(rect.Min.X+x, rect.Min.Y+y) && sealColor(r, g, b) == seal.color {
+				out.Pix[j], out.Pix[j+1], out.Pix[j+2], out.Pix[j+3] = r, g, b, 255
+			}
+		}
+	}
+	return out, rect, nil
+}
+
+// pageSeals finds the seals on the detection render page.
+func (e *Extractor) pageSeals(page pageRender) ([]sealCircle, error) {
+	img, err := readImage(page.Path, gocv.IMReadColor)
+	if err != nil {
+		return nil, err
+	}
+	defer img.Close()
+	return findSeals(img, page.DPI)
+}
+
+// extractSeals writes each of seals, found on the page of st, as a transparent
+// seal_{n}.png cut from the detection render, with its own metadata file
+// when Options.Metadata is set, and returns a result for each.
+func (e *Extractor) extractSeals(ctx context.Context, st *pageState, seals []sealCircle) ([]*Result, error) {
+	opts := st.opts
+	img, err := readImage(st.page.Path, gocv.IMReadColor)
+	if err != nil {
+		return nil, err
+	}
+	defer img.Close()
+	pageRect := st.pageEdges()
+
+	var results []*Result
+	for i, seal := range seals {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		n := i + 1
+		sealImage, renderBounds, err := cutOutSeal(img, seal)
+		if err != nil {
+			return nil, fmt.Errorf("seal %d: %v", n, err)
+		}
+		bounds := st.pageBounds(renderBounds)
+		res := &Result{
+			Source:     st.pdfPath,
+			Page:       st.pageNum,
+			PagePath:   st.page.Path,
+			Baseline:   -1,
+			DPI:        opts.RenderDPI,
+			OutputDPI:  opts.RenderDPI,
+			Rotation:   st.rotation,
+			Skew:       st.skew,
+			Bounds:     bounds,
+			PDFBounds:  pixelRectToPDF(bounds, opts.RenderDPI, st.box),
+			PageBox:    st.box.Rect,
+			PageRotate: st.box.Rotate,
+			Confidence: seal.coverage,
+			EdgeTouch:  touchesEdge(renderBounds, pageRect, opts.EdgeMargin),
+			Seal:       n,
+			SealColor:  seal.color,
+			Image:      sealImage,
+			OutputPath: filepath.Join(opts.OutputDir, outputName(st.outPrefix, "seal_"+strconv.Itoa(n)+".png")),
+		}
+		res.WidthMM, res.HeightMM = physicalSizeMM(renderBounds.Size(), opts.RenderDPI)
+		e.logf("Seal %d: %s, %v px at %g DPI, rim %.0f%% inked", n, seal.color, res.Bounds, res.DPI, 100*seal.coverage)
+		err = st.later(ctx, func(ctx context.Context) error {
+			if err := writePNG(sealImage, res.OutputPath); err != nil {
+				return fmt.Errorf("seal %d: %v", n, err)
+			}
+			e.logf("Seal saved to %s", res.OutputPath)
+			return e.deliver(ctx, res)
+		})
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, res)
+	}
+	return results, nil
+}
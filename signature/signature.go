@@ -0,0 +1,190 @@
+// Package signature extracts handwritten signatures from PDF pages into images
+// with a transparent background.
+//
+// The pipeline renders a page with pdftoppm (poppler), finds the largest ink
+// region with OpenCV, crops it and makes the paper transparent:
+//
+//	ex := signature.NewExtractor(signature.DefaultOptions())
+//	results, err := ex.ExtractFromPDF(ctx, "contract.pdf")
+//	if err != nil {
+//		return err
+//	}
+//	for _, res := range results {
+//		fmt.Println(res.Page, res.OutputPath, res.Bounds, res.Confidence)
+//	}
+package signature
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// Extractor runs the extraction pipeline with a fixed set of options. It is safe
+// for concurrent use; each call writes its own output files.
+type Extractor struct {
+	opts Options
+	// seen holds the signatures delivered so far, for Options.Dedup
+	seen *dedupIndex
+
+	// The Options.DetectorModel network, loaded on first use (see detector)
+	detectorOnce sync.Once
+	onnx         *onnxDetector
+	onnxErr      error
+}
+
+// NewExtractor returns an Extractor using opts. Start from DefaultOptions and
+// override what you need; zero values are not valid defaults for every field.
+func NewExtractor(opts Options) *Extractor {
+	return &Extractor{opts: opts, seen: &dedupIndex{}}
+}
+
+// DefaultOptions returns the options the command-line tool uses when no flags are given.
+func DefaultOptions() Options {
+	return Options{
+		RenderDPI:      DefaultDPI,
+		OutputDPI:      DefaultDPI,
+		EdgeMargin:     DefaultEdgeMargin,
+		Format:         FormatPNG,
+		Quality:        DefaultQuality,
+		MinPagePt:      DefaultMinPagePt,
+		MaxPagePt:      DefaultMaxPagePt,
+		MaxRenderPx:    DefaultMaxRenderPx,
+		MaxRenderBytes: DefaultMaxRenderBytes,
+		Binarization:   BinarizeAuto,
+		AlphaGamma:     DefaultAlphaGamma,
+		WhiteThreshold: DefaultWhiteThreshold,
+		Workers:        runtime.NumCPU(),
+		PipelineDepth:  DefaultPipelineDepth,
+		DedupDistance:  DefaultDedupDistance,
+	}
+}
+
+// ExtractFromPDF extracts the signature from every page of the PDF at path and
+// writes the transparent images into Options.OutputDir. A PNG or JPEG photo of
+// a page works too, as a one-page document at Options.RenderDPI. Pages without a
+// signature are skipped; when none has one the error wraps ErrNoSignature.
+//
+// Cancelling ctx, or reaching its deadline, kills any running subprocess
+// (pdftoppm, pdfinfo, tesseract, avifenc) and stops the pipeline before its
+// next stage; the error then wraps ctx.Err(). Running past
+// Options.DocumentTimeout does the same but fails with ErrResourceLimit.
+func (e *Extractor) ExtractFromPDF(ctx context.Context, path string) ([]*Result, error) {
+	return e.extract(ctx, path, "", &documentStats{})
+}
+
+// Extract reads a PDF (or a PNG or JPEG image) from r and returns the first signature found in it, for
+// callers that receive documents over the network. The PDF is spooled to a
+// temporary directory that is removed on return. When opts.OutputDir is empty
+// the outputs are written there too and not kept: the result's Image is all
+// there is, and its path fields are cleared. Otherwise they are written to
+// opts.OutputDir as ExtractFromPDF does.
+func Extract(ctx context.Context, r io.Reader, opts Options) (*Result, error) {
+	dir, err := os.MkdirTemp("", "poc-pdf-extract-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	pdfPath := filepath.Join(dir, "input.pdf")
+	if err := spool(r, pdfPath); err != nil {
+		return nil, err
+	}
+
+	keep := opts.OutputDir != ""
+	if !keep {
+		opts.OutputDir = dir
+	}
+	results, err := NewExtractor(opts).ExtractFromPDF(ctx, pdfPath)
+	if err != nil {
+		return nil, err
+	}
+
+	res := results[0]
+	// The spooled PDF and the rendered pages go away with dir
+	res.Source, res.PagePath = "", ""
+	if !keep {
+		res.OutputPath, res.MetadataPath = "", ""
+	}
+	return res, nil
+}
+
+// spool copies r to a new file at path.
+func spool(r io.Reader, path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err := io.Copy(f, r); err != nil {
+		return fmt.Errorf("failed to read PDF: %w", err)
+	}
+	return f.Close()
+}
+
+// Timed pipeline stages reported to Options.OnStage.
+const (
+	// StageRasterize reads a page's size and renders it, for detection or output.
+	StageRasterize = "rasterize"
+	// StageDetect finds the signature regions of a rendered page.
+	StageDetect = "detect"
+	// StageBackground removes the paper around one signature crop.
+	StageBackground = "background"
+	// StageEncode writes one output image in Options.Format.
+	StageEncode = "encode"
+)
+
+// observe reports a stage that began at start and ended with err to
+// Options.OnStage, if set, and logs its timing at debug level.
+func (e *Extractor) observe(stage string, start time.Time, err error) {
+	d := time.Since(start)
+	if e.opts.OnStage != nil {
+		e.opts.OnStage(stage, d, err)
+	}
+	if e.opts.Logger != nil {
+		e.opts.Logger.Debug("stage finished", "stage", stage, "duration", d, "error", err)
+	}
+}
+
+// logf forwards a progress message to Options.Logger at info level, or to
+// Options.Logf, if either is set.
+func (e *Extractor) logf(format string, args ...any) {
+	switch {
+	case e.opts.Logger != nil:
+		e.opts.Logger.Info(fmt.Sprintf(format, args...))
+	case e.opts.Logf != nil:
+		e.opts.Logf(format, args...)
+	}
+}
+
+// warnf is logf for problems the pipeline works around; Logf sees them with
+// a "Warning: " prefix.
+func (e *Extractor) warnf(format string, args ...any) {
+	switch {
+	case e.opts.Logger != nil:
+		e.opts.Logger.Warn(fmt.Sprintf(format, args...))
+	case e.opts.Logf != nil:
+		e.opts.Logf("Warning: "+format, args...)
+	}
+}
+
+// pageLogger returns Options.Logger tagged with the page number, or nil.
+func (e *Extractor) pageLogger(page int) *slog.Logger {
+	if e.opts.Logger == nil {
+		return nil
+	}
+	return e.opts.Logger.With("page", page)
+}
+
+// debugLog logs msg at debug level on l, which may be nil.
+func debugLog(l *slog.Logger, msg string, args ...any) {
+	if l != nil {
+		l.Debug(msg, args...)
+	}
+}
@@ -0,0 +1,127 @@
+package signature
+
+import (
+	"math"
+
+	"gocv.io/x/gocv"
+)
+
+// Signature type labels reported in Result.SignatureType.
+const (
+	signatureWet        = "wet"
+	signatureElectronic = "electronic"
+	signatureUnknown    = "unknown"
+)
+
+const (
+	// minStrokeSamples is how many stroke-centre pixels are needed to judge the
+	// stroke width; smaller crops are reported as unknown.
+	minStrokeSamples = 50
+	// minContourPoints skips contours too short to say anything about smoothness,
+	// such as dots and specks.
+	minContourPoints = 20
+	// edgeSmoothingEpsilon is the ApproxPolyDP tolerance, in pixels, used as the
+	// smooth reference outline when measuring edge roughness.
+	edgeSmoothingEpsilon = 1.0
+)
+
+// classifySignature labels the ink in mask as a wet-ink or an electronic
+// (typed in a script font) signature. Pen pressure makes wet-ink strokes vary
+// in width and scanning leaves ragged edges, while rendered glyphs have a
+// near-constant stroke and clean outlines. The returned score is the wet-ink
+// likelihood in [0, 1]; it is meaningless when the label is unknown.
+func classifySignature(mask gocv.Mat) (string, float64) {
+	variation, ok := strokeWidthVariation(mask)
+	if !ok {
+		return signatureUnknown, 0
+	}
+	roughness := edgeRoughness(mask)
+
+	score := (ramp(variation, 0.2, 0.4) + ramp(roughness, 1.08, 1.2)) / 2
+	switch {
+	case score >= 0.6:
+		return signatureWet, score
+	case score <= 0.4:
+		return signatureElectronic, score
+	default:
+		return signatureUnknown, score
+	}
+}
+
+// strokeWidthVariation returns the coefficient of variation of the stroke
+// half-width, sampled on the stroke centres (ridges of the distance transform).
+func strokeWidthVariation(mask gocv.Mat) (float64, bool) {
+	_, variation, ok := strokeWidthStats(mask)
+	return variation, ok
+}
+
+// strokeWidthStats returns the mean stroke half-width in pixels and its
+// coefficient of variation, sampled on the stroke centres (ridges of the
+// distance transform). ok is false when there are too few samples to tell.
+func strokeWidthStats(mask gocv.Mat) (mean, variation float64, ok bool) {
+	dist := gocv.NewMat()
+	defer dist.Close()
+	labels := gocv.NewMat()
+	defer labels.Close()
+	gocv.DistanceTransform(mask, &dist, &labels, gocv.DistL2, gocv.DistanceMask5, gocv.DistanceLabelCComp)
+
+	var n int
+	var sum, sumSq float64
+	for y := 1; y < dist.Rows()-1; y++ {
+		for x := 1; x < dist.Cols()-1; x++ {
+			d := dist.GetFloatAt(y, x)
+			if d == 0 || !isRidge(dist, x, y, d) {
+				continue
+			}
+			n++
+			sum += float64(d)
+			sumSq += float64(d) * float64(d)
+		}
+	}
+	if n < minStrokeSamples {
+		return 0, 0, false
+	}
+	mean = sum / float64(n)
+	std := math.Sqrt(max(sumSq/float64(n)-mean*mean, 0))
+	return mean, std / mean, true
+}
+
+// isRidge reports whether no 8-neighbour of (x, y) is further from the background.
+func isRidge(dist gocv.Mat, x, y int, d float32) bool {
+	for dy := -1; dy <= 1; dy++ {
+		for dx := -1; dx <= 1; dx++ {
+			if dist.GetFloatAt(y+dy, x+dx) > d {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// edgeRoughness compares the length of the ink outlines with that of their
+// simplified polygons: 1 for perfectly smooth edges, higher for ragged ones.
+func edgeRoughness(mask gocv.Mat) float64 {
+	contours := gocv.FindContours(mask, gocv.RetrievalList, gocv.ChainApproxNone)
+	defer contours.Close()
+
+	var raw, smooth float64
+	for i := 0; i < contours.Size(); i++ {
+		c := contours.At(i)
+		if c.Size() < minContourPoints {
+			continue
+		}
+		approx := gocv.ApproxPolyDP(c, edgeSmoothingEpsilon, true)
+		raw += gocv.ArcLength(c, true)
+		smooth += gocv.ArcLength(approx, true)
+		approx.Close()
+	}
+	if smooth == 0 {
+		return 1
+	}
+	return raw / smooth
+}
+
+// ramp is 0 below lo, 1 above hi and linear in between.
+func ramp(v, lo, hi float64) float64 {
+	return math.Min(math.Max((v-lo)/(hi-lo), 0), 1)
+}
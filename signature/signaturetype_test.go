@@ -0,0 +1,70 @@
+package signature
+
+import (
+	"image"
+	"math"
+	"testing"
+
+	"gocv.io/x/gocv"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/goitalic"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+)
+
+// typedSignature renders a name in an italic font, as e-signing tools do.
+func typedSignature(t *testing.T) *image.RGBA {
+	t.Helper()
+	f, err := opentype.Parse(goitalic.TTF)
+	if err != nil {
+		t.Fatal(err)
+	}
+	face, err := opentype.NewFace(f, &opentype.FaceOptions{Size: 64, DPI: 72, Hinting: font.HintingNone})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer face.Close()
+	img := newPage(700, 140)
+	d := font.Drawer{Dst: img, Src: image.NewUniform(blueInk), Face: face, Dot: fixed.P(20, 95)}
+	d.DrawString("Jonathan Smith")
+	return img
+}
+
+// wetSignature draws a looping ballpoint stroke whose width swings with pen
+// pressure and whose edge is ragged where the ink skips on the paper grain.
+func wetSignature() *image.RGBA {
+	img := newPage(700, 140)
+	for u := 0.0; u <= 1; u += 0.0003 {
+		x := 40 + 600*u + 25*math.Sin(2*math.Pi*9*u)
+		y := 70 + 25*math.Sin(2*math.Pi*3*u) + 25*math.Cos(2*math.Pi*9*u)
+		r := 1.2 + 2.4*math.Abs(math.Sin(2*math.Pi*4*u))
+		// A deterministic grain: offsets that change every few dots
+		jitter := 0.8 * math.Sin(u*9973)
+		dot(img, x+jitter, y-jitter, r+0.5*math.Sin(u*7919), blueInk)
+	}
+	return img
+}
+
+func TestClassifySignatureTypedVsHandwritten(t *testing.T) {
+	tests := []struct {
+		name string
+		img  *image.RGBA
+		want string
+	}{
+		{"font-rendered", typedSignature(t), signatureElectronic},
+		{"handwritten", wetSignature(), signatureWet},
+	}
+	for _, tt := range tests {
+		crop, err := gocv.ImageToMatRGB(tt.img)
+		if err != nil {
+			t.Fatal(err)
+		}
+		mask := inkMask(crop)
+		got, score := classifySignature(mask)
+		mask.Close()
+		crop.Close()
+		if got != tt.want {
+			t.Errorf("%s signature: type %s (wet-ink score %.2f), want %s", tt.name, got, score, tt.want)
+		}
+	}
+}
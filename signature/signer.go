@@ -0,0 +1,155 @@
+package signature
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// DefaultSignerArea is the neighbourhood read with Options.SignerOCR: a little
+// above the signature, a printed name and date line or two under it, and some
+// room on either side.
+var DefaultSignerArea = SignerArea{Top: 5, Right: 10, Bottom: 25, Left: 10}
+
+// SignerArea is how far, in millimetres, the text read around a signature may
+// lie from each side of its ink.
+type SignerArea struct {
+	Top, Right, Bottom, Left float64
+}
+
+// ParseSignerArea parses one distance for every side, or "TOP,RIGHT,BOTTOM,LEFT"
+// as in CSS, in millimetres, e.g. "5,10,25,10".
+func ParseSignerArea(s string) (SignerArea, error) {
+	parts := strings.Split(s, ",")
+	if len(parts) != 1 && len(parts) != 4 {
+		return SignerArea{}, fmt.Errorf("want MM or TOP,RIGHT,BOTTOM,LEFT millimetres, got %q", s)
+	}
+	v := make([]float64, len(parts))
+	for i, part := range parts {
+		var err error
+		if v[i], err = strconv.ParseFloat(strings.TrimSpace(part), 64); err != nil || v[i] < 0 {
+			return SignerArea{}, fmt.Errorf("invalid distance %q: want millimetres, 0 or more", part)
+		}
+	}
+	if len(v) == 1 {
+		return SignerArea{Top: v[0], Right: v[0], Bottom: v[0], Left: v[0]}, nil
+	}
+	return SignerArea{Top: v[0], Right: v[1], Bottom: v[2], Left: v[3]}, nil
+}
+
+// around returns the area around ink on a page rendered at dpi, clipped to page.
+func (a SignerArea) around(ink, page image.Rectangle, dpi float64) image.Rectangle {
+	px := func(mm float64) int { return int(mm / mmPerInch * dpi) }
+	return image.Rect(ink.Min.X-px(a.Left), ink.Min.Y-px(a.Top), ink.Max.X+px(a.Right), ink.Max.Y+px(a.Bottom)).Intersect(page)
+}
+
+// dateText matches the dates written next to signatures: 12/03/2024, 12.03.24,
+// 2024-03-12, 12 March 2024 and March 12, 2024.
+var dateText = regexp.MustCompile(`(?i)\b(\d{1,2}[./-]\d{1,2}[./-]\d{2,4}|\d{4}-\d{1,2}-\d{1,2}|\d{1,2}\.?\s+(jan|feb|mar|apr|may|jun|jul|aug|sep|oct|nov|dec)[a-z]*\.?,?\s+\d{4}|(jan|feb|mar|apr|may|jun|jul|aug|sep|oct|nov|dec)[a-z]*\.?\s+\d{1,2}(st|nd|rd|th)?,?\s+\d{4})\b`)
+
+// signerLabels are captions printed by the signing line that are no name.
+var signerLabels = map[string]bool{
+	"signature": true, "signed": true, "name": true, "printed name": true, "date": true, "witness": true,
+	"assinatura": true, "nome": true, "data": true, "testemunha": true,
+}
+
+// signerText is what was read around one signature.
+type signerText struct {
+	// lines are the text lines in the area, in reading order.
+	lines []string
+	name  string
+	date  string
+}
+
+// readSigner picks the lines of words that lie within area but outside the
+// signature's ink itself, and finds the signer's printed name and the date in
+// them. The date is the first date in the lines; the name is the first line
+// left with letters only once labels such as "Name:" are cut off.
+func readSigner(words []ocrWord, ink, area image.Rectangle) signerText {
+	var t signerText
+	var line [3]int
+	for _, w := range words {
+		centre := image.Pt((w.Box.Min.X+w.Box.Max.X)/2, (w.Box.Min.Y+w.Box.Max.Y)/2)
+		if !centre.In(area) || centre.In(ink) {
+			continue
+		}
+		if len(t.lines) == 0 || w.line != line {
+			t.lines = append(t.lines, w.Text)
+			line = w.line
+		} else {
+			t.lines[len(t.lines)-1] += " " + w.Text
+		}
+	}
+	for _, l := range t.lines {
+		if t.date == "" {
+			if d := dateText.FindString(l); d != "" {
+				t.date = d
+				continue
+			}
+		}
+		if v := labelValue(l); t.name == "" && isName(v) && !signerLabels[normalizeWord(v)] {
+			t.name = v
+		}
+	}
+	return t
+}
+
+// labelValue cuts a leading label ending in a colon, such as "Printed name:",
+// and the underscores of a blank line off a line of text.
+func labelValue(line string) string {
+	if i := strings.LastIndex(line, ":"); i >= 0 {
+		line = line[i+1:]
+	}
+	return strings.Join(strings.Fields(strings.Trim(line, "_ ")), " ")
+}
+
+// isName reports whether s could be a person's name: letters, spaces, dots,
+// hyphens and apostrophes, with at least two letters.
+func isName(s string) bool {
+	letters := 0
+	for _, r := range s {
+		switch {
+		case unicode.IsLetter(r):
+			letters++
+		case r == ' ' || r == '.' || r == '-' || r == '\'':
+		default:
+			return false
+		}
+	}
+	return letters >= 2
+}
+
+// readSignerText reads the text within Options.SignerArea of the ink of region,
+// found on the page of st, into the Signer fields of res.
+func (e *Extractor) readSignerText(ctx context.Context, st *pageState, region SignatureRegion, res *Result) error {
+	words, err := st.pageWords(ctx, e.ocrLanguage())
+	if err != nil {
+		return err
+	}
+	signerArea := DefaultSignerArea
+	if st.opts.SignerArea != nil {
+		signerArea = *st.opts.SignerArea
+	}
+	area := signerArea.around(region.Ink, image.Rectangle{Max: st.page.Size}, st.opts.RenderDPI)
+	t := readSigner(words, region.Ink, area)
+	res.SignerName, res.SignerDate, res.SignerText = t.name, t.date, t.lines
+	e.logf("Signer: name %q, date %q, from %d lines of text nearby", t.name, t.date, len(t.lines))
+	return nil
+}
+
+// pageWords returns the words Tesseract reads on the detection render of st,
+// running it once per page.
+func (st *pageState) pageWords(ctx context.Context, lang string) ([]ocrWord, error) {
+	if !st.wordsRead {
+		words, err := ocrWords(ctx, st.page.Path, lang)
+		if err != nil {
+			return nil, err
+		}
+		st.words, st.wordsRead = words, true
+	}
+	return st.words, nil
+}
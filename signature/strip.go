@@ -0,0 +1,45 @@
+package signature
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+	"path/filepath"
+)
+
+// DefaultStripSpacing is the transparent gap between strip entries, in pixels.
+const DefaultStripSpacing = 16
+
+// ComposeStrip stacks the signatures of results vertically into one transparent
+// image, each preceded by a label naming its source document and page (and region
+// number with Options.AllRegions).
+func ComposeStrip(results []*Result, spacing int) *image.RGBA {
+	labels := make([]string, len(results))
+	width, height := 0, 0
+	for i, r := range results {
+		labels[i] = fmt.Sprintf("%s p.%d", filepath.Base(r.Source), r.Page)
+		if r.Region > 0 {
+			labels[i] += fmt.Sprintf(" #%d", r.Region)
+		}
+		width = max(width, r.Image.Bounds().Dx(), labelWidth(labels[i]))
+		height += labelHeight + r.Image.Bounds().Dy()
+		if i > 0 {
+			height += spacing
+		}
+	}
+
+	strip := image.NewRGBA(image.Rect(0, 0, width, height))
+	y := 0
+	for i, r := range results {
+		if i > 0 {
+			y += spacing
+		}
+		drawLabelAt(strip, image.Pt(0, y), labels[i])
+		y += labelHeight
+
+		b := r.Image.Bounds()
+		draw.Draw(strip, image.Rect(0, y, b.Dx(), y+b.Dy()), r.Image, b.Min, draw.Over)
+		y += b.Dy()
+	}
+	return strip
+}
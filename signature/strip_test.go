@@ -0,0 +1,64 @@
+package signature
+
+import (
+	"image"
+	"testing"
+)
+
+// opaqueRows reports for each row of img whether it has a visible pixel.
+func opaqueRows(img *image.RGBA) []bool {
+	rows := make([]bool, img.Rect.Dy())
+	for y := range rows {
+		for x := img.Rect.Min.X; x < img.Rect.Max.X; x++ {
+			if img.RGBAAt(x, img.Rect.Min.Y+y).A > 0 {
+				rows[y] = true
+				break
+			}
+		}
+	}
+	return rows
+}
+
+func TestComposeStripTwoPages(t *testing.T) {
+	first, second := newPage(850, 1100), newPage(850, 1100)
+	drawSignature(first, 300, 850, 0, 1, blueInk)
+	drawSignature(second, 100, 300, 10, 1.5, blueInk)
+	opts := fixtureOptions(t)
+	opts.Rasterizer = RasterizerPoppler
+	results := extractFixture(t, opts, fakePDF(t, first, second))
+	if len(results) != 2 || results[0].Page != 1 || results[1].Page != 2 {
+		t.Fatalf("results %v, want one for each of the two pages", results)
+	}
+
+	strip := ComposeStrip(results, DefaultStripSpacing)
+	a, b := results[0].Image.Bounds(), results[1].Image.Bounds()
+	want := image.Pt(max(a.Dx(), b.Dx(), labelWidth("document.pdf p.1")), 2*labelHeight+a.Dy()+DefaultStripSpacing+b.Dy())
+	if got := strip.Bounds().Size(); got != want {
+		t.Fatalf("strip is %v, want %v", got, want)
+	}
+	// Label, signature, gap, label, signature, each where the layout puts it
+	rows := opaqueRows(strip)
+	entries := []struct {
+		name       string
+		top, limit int
+	}{
+		{"page 1 label", 0, labelHeight},
+		{"page 1 signature", labelHeight, labelHeight + a.Dy()},
+		{"page 2 label", labelHeight + a.Dy() + DefaultStripSpacing, 2*labelHeight + a.Dy() + DefaultStripSpacing},
+		{"page 2 signature", 2*labelHeight + a.Dy() + DefaultStripSpacing, want.Y},
+	}
+	for _, e := range entries {
+		found := false
+		for _, opaque := range rows[e.top:e.limit] {
+			found = found || opaque
+		}
+		if !found {
+			t.Errorf("%s: rows %d-%d are empty", e.name, e.top, e.limit)
+		}
+	}
+	for y, opaque := range rows[labelHeight+a.Dy() : labelHeight+a.Dy()+DefaultStripSpacing] {
+		if opaque {
+			t.Errorf("gap row %d is not transparent", labelHeight+a.Dy()+y)
+		}
+	}
+}
@@ -0,0 +1,217 @@
+package signature
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"math"
+	"os"
+)
+
+// strokeSimplifyEpsilon is the Douglas-Peucker tolerance, in pixels, applied to
+// traced strokes; it removes the staircase of the pixel skeleton.
+const strokeSimplifyEpsilon = 1.0
+
+// strokeSet is the JSON written by FormatStrokes. Points are [x, y] with the
+// origin at the top-left, scaled so the longer side of the crop spans 0..1;
+// Width and Height give the crop size in pixels for re-rendering at scale.
+type strokeSet struct {
+	Width   int            `json:"width"`
+	Height  int            `json:"height"`
+	Strokes [][][2]float64 `json:"strokes"`
+}
+
+// writeStrokes vectorizes the opaque pixels of img and writes them to path as JSON.
+func writeStrokes(img image.Image, path string) error {
+	b := img.Bounds()
+	set := strokeSet{Width: b.Dx(), Height: b.Dy(), Strokes: [][][2]float64{}}
+	scale := float64(max(b.Dx(), b.Dy()))
+	for _, stroke := range traceStrokes(thin(alphaMask(img))) {
+		simplified := simplifyPolyline(stroke, strokeSimplifyEpsilon)
+		points := make([][2]float64, len(simplified))
+		for i, p := range simplified {
+			points[i] = [2]float64{roundTo(float64(p.X)/scale, 4), roundTo(float64(p.Y)/scale, 4)}
+		}
+		set.Strokes = append(set.Strokes, points)
+	}
+
+	data, err := json.Marshal(set)
+	if err != nil {
+		return fmt.Errorf("failed to encode strokes: %v", err)
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// bitmap is a binary image indexed [y][x].
+type bitmap [][]bool
+
+// alphaMask marks the pixels of img that are not fully transparent.
+func alphaMask(img image.Image) bitmap {
+	b := img.Bounds()
+	m := make(bitmap, b.Dy())
+	for y := range m {
+		m[y] = make([]bool, b.Dx())
+		for x := range m[y] {
+			_, _, _, a := img.At(b.Min.X+x, b.Min.Y+y).RGBA()
+			m[y][x] = a != 0
+		}
+	}
+	return m
+}
+
+// at reports whether (x, y) is set, treating everything outside as unset.
+func (m bitmap) at(x, y int) bool {
+	return y >= 0 && y < len(m) && x >= 0 && x < len(m[y]) && m[y][x]
+}
+
+// neighbours returns P2..P9 of the Zhang-Suen paper: the 8 neighbours of
+// (x, y) clockwise starting from the one above.
+func (m bitmap) neighbours(x, y int) [8]bool {
+	return [8]bool{
+		m.at(x, y-1), m.at(x+1, y-1), m.at(x+1, y), m.at(x+1, y+1),
+		m.at(x, y+1), m.at(x-1, y+1), m.at(x-1, y), m.at(x-1, y-1),
+	}
+}
+
+// thin reduces m to a one-pixel-wide skeleton in place with Zhang-Suen thinning.
+func thin(m bitmap) bitmap {
+	for changed := true; changed; {
+		changed = false
+		for pass := 0; pass < 2; pass++ {
+			var remove []image.Point
+			for y := range m {
+				for x := range m[y] {
+					if m[y][x] && removable(m.neighbours(x, y), pass) {
+						remove = append(remove, image.Pt(x, y))
+					}
+				}
+			}
+			for _, p := range remove {
+				m[p.Y][p.X] = false
+			}
+			changed = changed || len(remove) > 0
+		}
+	}
+	return m
+}
+
+// removable applies the Zhang-Suen deletion test for the given sub-iteration.
+func removable(p [8]bool, pass int) bool {
+	count, transitions := 0, 0
+	for i := range p {
+		if p[i] {
+			count++
+		}
+		if !p[i] && p[(i+1)%8] {
+			transitions++
+		}
+	}
+	if count < 2 || count > 6 || transitions != 1 {
+		return false
+	}
+	// P2, P4, P6, P8 are p[0], p[2], p[4], p[6]
+	if pass == 0 {
+		return !(p[0] && p[2] && p[4]) && !(p[2] && p[4] && p[6])
+	}
+	return !(p[0] && p[2] && p[6]) && !(p[0] && p[4] && p[6])
+}
+
+// neighbourOffsets lists the 4-connected neighbours before the diagonal ones so
+// tracing follows the skeleton rather than cutting its corners.
+var neighbourOffsets = [8]image.Point{
+	{0, -1}, {1, 0}, {0, 1}, {-1, 0},
+	{1, -1}, {1, 1}, {-1, 1}, {-1, -1},
+}
+
+// traceStrokes walks the skeleton into ordered polylines. Strokes start at
+// endpoints so open pen paths come out in one piece; closed loops left over are
+// traced from an arbitrary pixel. Single isolated pixels are dropped.
+func traceStrokes(skel bitmap) [][]image.Point {
+	visited := make(bitmap, len(skel))
+	for y := range skel {
+		visited[y] = make([]bool, len(skel[y]))
+	}
+	degree := func(x, y int) int {
+		n := 0
+		for _, p := range skel.neighbours(x, y) {
+			if p {
+				n++
+			}
+		}
+		return n
+	}
+	walk := func(x, y int) []image.Point {
+		stroke := []image.Point{{x, y}}
+		visited[y][x] = true
+		for {
+			next, ok := image.Point{}, false
+			for _, d := range neighbourOffsets {
+				nx, ny := x+d.X, y+d.Y
+				if skel.at(nx, ny) && !visited[ny][nx] {
+					next, ok = image.Pt(nx, ny), true
+					break
+				}
+			}
+			if !ok {
+				return stroke
+			}
+			x, y = next.X, next.Y
+			visited[y][x] = true
+			stroke = append(stroke, next)
+		}
+	}
+
+	var strokes [][]image.Point
+	for _, endpointsOnly := range []bool{true, false} {
+		for y := range skel {
+			for x := range skel[y] {
+				if !skel[y][x] || visited[y][x] || (endpointsOnly && degree(x, y) != 1) {
+					continue
+				}
+				if stroke := walk(x, y); len(stroke) > 1 {
+					strokes = append(strokes, stroke)
+				}
+			}
+		}
+	}
+	return strokes
+}
+
+// simplifyPolyline drops points closer than epsilon to the line through their
+// neighbours (Ramer-Douglas-Peucker), always keeping both ends.
+func simplifyPolyline(points []image.Point, epsilon float64) []image.Point {
+	if len(points) < 3 {
+		return points
+	}
+	first, last := points[0], points[len(points)-1]
+	split, farthest := 0, 0.0
+	for i := 1; i < len(points)-1; i++ {
+		if d := pointLineDistance(points[i], first, last); d > farthest {
+			split, farthest = i, d
+		}
+	}
+	if farthest <= epsilon {
+		return []image.Point{first, last}
+	}
+	left := simplifyPolyline(points[:split+1], epsilon)
+	right := simplifyPolyline(points[split:], epsilon)
+	return append(left[:len(left)-1:len(left)-1], right...)
+}
+
+// pointLineDistance is the distance from p to the line through a and b, or to a
+// when a and b coincide.
+func pointLineDistance(p, a, b image.Point) float64 {
+	dx, dy := float64(b.X-a.X), float64(b.Y-a.Y)
+	px, py := float64(p.X-a.X), float64(p.Y-a.Y)
+	length := math.Hypot(dx, dy)
+	if length == 0 {
+		return math.Hypot(px, py)
+	}
+	return math.Abs(dx*py-dy*px) / length
+}
+
+// roundTo rounds v to the given number of decimals to keep the JSON compact.
+func roundTo(v float64, decimals int) float64 {
+	f := math.Pow(10, float64(decimals))
+	return math.Round(v*f) / f
+}
@@ -0,0 +1,50 @@
+package signature
+
+import (
+	"encoding/json"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStrokesFromSignature(t *testing.T) {
+	img := shadedSignature()
+	path := filepath.Join(t.TempDir(), "signature.json")
+	if err := writeStrokes(img, path); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var set strokeSet
+	if err := json.Unmarshal(data, &set); err != nil {
+		t.Fatal(err)
+	}
+	if set.Width != img.Rect.Dx() || set.Height != img.Rect.Dy() {
+		t.Errorf("size %dx%d, want %v", set.Width, set.Height, img.Rect.Size())
+	}
+
+	// The fixture is about 300px of one continuous stroke
+	scale := float64(max(set.Width, set.Height))
+	var length float64
+	longest := 0
+	for _, stroke := range set.Strokes {
+		longest = max(longest, len(stroke))
+		for i, p := range stroke {
+			if p[0] < 0 || p[0] > 1 || p[1] < 0 || p[1] > 1 {
+				t.Fatalf("point %v outside the normalized 0..1 range", p)
+			}
+			if i > 0 {
+				length += math.Hypot(p[0]-stroke[i-1][0], p[1]-stroke[i-1][1]) * scale
+			}
+		}
+	}
+	if longest < 2 {
+		t.Fatalf("%d strokes, none with two points or more", len(set.Strokes))
+	}
+	if length < 200 {
+		t.Errorf("strokes run %.0f px in total, want most of the signature's length", length)
+	}
+}
@@ -0,0 +1,112 @@
+package signature
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"time"
+)
+
+// DefaultMaxRenderBytes is the default Options.MaxRenderBytes: room for a
+// DefaultMaxRenderPx page of incompressible color.
+const DefaultMaxRenderBytes = 1 << 30
+
+// killGrace is how long a cancelled subprocess's output pipes may stay open,
+// held by children that outlived the kill, before Wait gives up on them.
+const killGrace = 2 * time.Second
+
+// processLimits are the ceilings put on every rasterizer subprocess.
+type processLimits struct {
+	// memory caps the address space of the process in bytes (Linux only); 0 is unlimited.
+	memory int64
+	// fileSize caps each file the process writes, in bytes; 0 is unlimited.
+	fileSize int64
+}
+
+// processLimits returns the subprocess ceilings set by opts.
+func (opts Options) processLimits() processLimits {
+	return processLimits{memory: opts.MaxRasterizerMemory, fileSize: opts.MaxRenderBytes}
+}
+
+// runLimited runs the command-line tool name with args in a process group of
+// its own, under limits, and returns its standard output and error. Cancelling
+// ctx kills the whole group, so helpers the tool spawned die with it, and the
+// error is the cause of the cancellation (see Options.DocumentTimeout). A tool
+// stopped by a limit fails with ErrResourceLimit.
+func runLimited(ctx context.Context, limits processLimits, name string, args ...string) ([]byte, []byte, error) {
+	cmd := exec.CommandContext(ctx, name, args...)
+	setProcessGroup(cmd)
+	cmd.WaitDelay = killGrace
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Start(); err != nil {
+		return nil, nil, err
+	}
+	if err := applyLimits(cmd.Process.Pid, limits); err != nil {
+		cmd.Cancel()
+		cmd.Wait()
+		return nil, nil, fmt.Errorf("failed to limit %s: %v", name, err)
+	}
+	err := cmd.Wait()
+	if err != nil && ctx.Err() != nil {
+		// A killed subprocess reports "signal: killed"; say why it was killed instead
+		return nil, nil, context.Cause(ctx)
+	}
+	if err != nil {
+		if reason := limits.exceeded(err, stderr.Bytes()); reason != "" {
+			return nil, nil, fmt.Errorf("%w: %s %s", ErrResourceLimit, name, reason)
+		}
+	}
+	return stdout.Bytes(), stderr.Bytes(), err
+}
+
+// exceeded explains how a tool that failed with err and wrote stderr ran into
+// limits, or returns "" when it failed for another reason.
+func (l processLimits) exceeded(err error, stderr []byte) string {
+	if l.fileSize > 0 && fileSizeSignal(err) {
+		return fmt.Sprintf("wrote more than the %d-byte render limit", l.fileSize)
+	}
+	if l.memory > 0 && (crashSignal(err) || outOfMemory(stderr)) {
+		return fmt.Sprintf("ran out of its %d-byte memory limit", l.memory)
+	}
+	return ""
+}
+
+// outOfMemoryMessages are how pdftoppm, mutool and gs report a failed allocation.
+var outOfMemoryMessages = [][]byte{
+	[]byte("out of memory"),
+	[]byte("bad_alloc"),
+	[]byte("malloc"),
+	[]byte("cannot allocate"),
+	[]byte("vmerror"),
+}
+
+// outOfMemory reports whether a tool's stderr says an allocation failed.
+func outOfMemory(stderr []byte) bool {
+	stderr = bytes.ToLower(stderr)
+	for _, msg := range outOfMemoryMessages {
+		if bytes.Contains(stderr, msg) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkRenderSize fails with ErrResourceLimit when the render at path is
+// larger than maxBytes (0 is unlimited). It catches the backends whose writes
+// can't be capped while they run.
+func checkRenderSize(path string, maxBytes int64) error {
+	if maxBytes <= 0 {
+		return nil
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if info.Size() > maxBytes {
+		return fmt.Errorf("%w: the %d-byte render of %s is larger than the %d-byte limit", ErrResourceLimit, info.Size(), path, maxBytes)
+	}
+	return nil
+}
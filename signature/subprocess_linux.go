@@ -0,0 +1,21 @@
+package signature
+
+import "golang.org/x/sys/unix"
+
+// applyLimits sets the RLIMIT_AS and RLIMIT_FSIZE of the running process pid.
+// They take effect as soon as it has started, before it has opened the PDF.
+func applyLimits(pid int, limits processLimits) error {
+	if limits.memory > 0 {
+		lim := unix.Rlimit{Cur: uint64(limits.memory), Max: uint64(limits.memory)}
+		if err := unix.Prlimit(pid, unix.RLIMIT_AS, &lim, nil); err != nil {
+			return err
+		}
+	}
+	if limits.fileSize > 0 {
+		lim := unix.Rlimit{Cur: uint64(limits.fileSize), Max: uint64(limits.fileSize)}
+		if err := unix.Prlimit(pid, unix.RLIMIT_FSIZE, &lim, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
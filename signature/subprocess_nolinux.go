@@ -0,0 +1,7 @@
+//go:build !linux
+
+package signature
+
+// applyLimits does nothing where a running process's limits can't be set;
+// render sizes are still checked afterwards (see checkRenderSize).
+func applyLimits(pid int, limits processLimits) error { return nil }
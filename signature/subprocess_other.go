@@ -0,0 +1,13 @@
+//go:build !unix
+
+package signature
+
+import "os/exec"
+
+// setProcessGroup is a no-op where process groups don't exist; cancellation
+// kills only cmd.
+func setProcessGroup(cmd *exec.Cmd) {}
+
+func fileSizeSignal(err error) bool { return false }
+
+func crashSignal(err error) bool { return false }
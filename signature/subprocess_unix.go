@@ -0,0 +1,45 @@
+//go:build unix
+
+package signature
+
+import (
+	"errors"
+	"os/exec"
+	"syscall"
+)
+
+// setProcessGroup starts cmd as the leader of a new process group and makes
+// cancellation kill the whole group, not just cmd.
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+}
+
+// exitSignal returns the signal that terminated the process of err, if any.
+func exitSignal(err error) (syscall.Signal, bool) {
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		return 0, false
+	}
+	status, ok := exitErr.Sys().(syscall.WaitStatus)
+	if !ok || !status.Signaled() {
+		return 0, false
+	}
+	return status.Signal(), true
+}
+
+// fileSizeSignal reports whether the process of err was killed for writing
+// past its RLIMIT_FSIZE.
+func fileSizeSignal(err error) bool {
+	sig, ok := exitSignal(err)
+	return ok && sig == syscall.SIGXFSZ
+}
+
+// crashSignal reports whether the process of err aborted or crashed, which is
+// how the C++ tools end when an allocation under RLIMIT_AS fails.
+func crashSignal(err error) bool {
+	sig, ok := exitSignal(err)
+	return ok && (sig == syscall.SIGABRT || sig == syscall.SIGSEGV || sig == syscall.SIGBUS)
+}
@@ -0,0 +1,199 @@
+package signature
+
+import (
+	"bufio"
+	"fmt"
+	"image"
+	"math"
+	"os"
+	"strconv"
+
+	"gocv.io/x/gocv"
+)
+
+// SVG tracing parameters, in output pixels.
+const (
+	// svgSimplifyEpsilon is the Douglas-Peucker tolerance of the traced
+	// outlines; it removes the pixel staircase before curves are fitted.
+	svgSimplifyEpsilon = 0.8
+	// svgCornerDegrees is how sharply an outline must turn at a vertex for it
+	// to be kept as a corner instead of being rounded into a curve.
+	svgCornerDegrees = 100
+	// svgAlphaCutoff is the alpha from which a pixel belongs to the traced
+	// shape; half-transparent fringes of a soft matte are split down the middle.
+	svgAlphaCutoff = 128
+)
+
+// writeSVG traces the opaque pixels of img into filled SVG paths and writes
+// them to path. Every outline, holes included, is found with FindContours,
+// simplified, and fitted with quadratic curves through the midpoints of its
+// edges, keeping sharp turns as corners, potrace-style; the even-odd fill rule
+// cuts the holes out. The fill is the average ink color.
+func writeSVG(img image.Image, path string) error {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	mask := make([]byte, w*h)
+	var sumR, sumG, sumB, sumA float64
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			r, g, bl, a := img.At(b.Min.X+x, b.Min.Y+y).RGBA()
+			if a>>8 >= svgAlphaCutoff {
+				mask[y*w+x] = 255
+			}
+			// Premultiplied sums weighted by alpha average to the ink color
+			sumR, sumG, sumB, sumA = sumR+float64(r), sumG+float64(g), sumB+float64(bl), sumA+float64(a)
+		}
+	}
+	fill := "#000000"
+	if sumA > 0 {
+		c := func(v float64) int { return int(math.Round(v / sumA * 255)) }
+		fill = fmt.Sprintf("#%02x%02x%02x", c(sumR), c(sumG), c(sumB))
+	}
+
+	var paths []string
+	if w > 0 && h > 0 {
+		mat, err := gocv.NewMatFromBytes(h, w, gocv.MatTypeCV8U, mask)
+		if err != nil {
+			return fmt.Errorf("failed to build the ink mask: %v", err)
+		}
+		defer mat.Close()
+		hierarchy := gocv.NewMat()
+		defer hierarchy.Close()
+		contours := gocv.FindContoursWithParams(mat, &hierarchy, gocv.RetrievalCComp, gocv.ChainApproxNone)
+		defer contours.Close()
+		for i := 0; i < contours.Size(); i++ {
+			// Contours run through the centres of the edge pixels; push them half a
+			// pixel off the ink, outwards for outer boundaries and into the hole
+			// for holes, so one-pixel strokes keep their width
+			grow := 0.5
+			if hierarchy.GetVeciAt(0, i)[3] >= 0 {
+				grow = -0.5
+			}
+			simplified := gocv.ApproxPolyDP(contours.At(i), svgSimplifyEpsilon, true)
+			if d := svgOutline(offsetOutline(simplified.ToPoints(), grow)); d != "" {
+				paths = append(paths, d)
+			}
+			simplified.Close()
+		}
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create output file: %v", err)
+	}
+	defer f.Close()
+	out := bufio.NewWriter(f)
+	fmt.Fprintf(out, "<svg xmlns=\"http://www.w3.org/2000/svg\" width=\"%d\" height=\"%d\" viewBox=\"0 0 %d %d\">\n", w, h, w, h)
+	fmt.Fprintf(out, "<path fill=\"%s\" fill-rule=\"evenodd\" d=\"", fill)
+	for i, d := range paths {
+		if i > 0 {
+			out.WriteByte(' ')
+		}
+		out.WriteString(d)
+	}
+	out.WriteString("\"/>\n</svg>\n")
+	if err := out.Flush(); err != nil {
+		return fmt.Errorf("failed to write SVG: %v", err)
+	}
+	return f.Close()
+}
+
+// offsetOutline moves every vertex of the closed polygon points (pixel
+// indices) by grow pixels along its outward normal, shrinking it when grow is
+// negative, and returns pixel-space coordinates. Polygons of fewer than 3
+// vertices are returned empty.
+func offsetOutline(points []image.Point, grow float64) [][2]float64 {
+	n := len(points)
+	if n < 3 {
+		return nil
+	}
+	// The sign of the shoelace area tells which side of the edges is outside
+	var area float64
+	for i, p := range points {
+		q := points[(i+1)%n]
+		area += float64(p.X*q.Y - q.X*p.Y)
+	}
+	side := 1.0
+	if area < 0 {
+		side = -1
+	}
+	normal := func(a, b image.Point) (float64, float64) {
+		dx, dy := float64(b.X-a.X), float64(b.Y-a.Y)
+		l := math.Hypot(dx, dy)
+		if l == 0 {
+			return 0, 0
+		}
+		return side * dy / l, -side * dx / l
+	}
+
+	out := make([][2]float64, n)
+	for i, p := range points {
+		ax, ay := normal(points[(i+n-1)%n], p)
+		bx, by := normal(p, points[(i+1)%n])
+		nx, ny := ax+bx, ay+by
+		if l := math.Hypot(nx, ny); l > 0 {
+			nx, ny = nx/l, ny/l
+		}
+		// Pixel centres sit half a pixel into the pixel's square
+		out[i] = [2]float64{float64(p.X) + 0.5 + grow*nx, float64(p.Y) + 0.5 + grow*ny}
+	}
+	return out
+}
+
+// svgOutline turns a closed polygon into SVG path data: it starts at the
+// midpoint of the last edge and, at every vertex, either bends through it with
+// a quadratic curve to the next edge's midpoint or, at a sharp corner, runs
+// straight into it. Empty polygons give "".
+func svgOutline(points [][2]float64) string {
+	n := len(points)
+	if n < 3 {
+		return ""
+	}
+	pt := func(i int) (float64, float64) {
+		p := points[(i%n+n)%n]
+		return p[0], p[1]
+	}
+	mid := func(i int) (float64, float64) {
+		x1, y1 := pt(i)
+		x2, y2 := pt(i + 1)
+		return (x1 + x2) / 2, (y1 + y2) / 2
+	}
+
+	var d []byte
+	mx, my := mid(-1)
+	d = append(d, 'M')
+	d = appendCoords(d, mx, my)
+	for i := 0; i < n; i++ {
+		px, py := pt(i - 1)
+		x, y := pt(i)
+		nx, ny := pt(i + 1)
+		ex, ey := mid(i)
+		if turnDegrees(x-px, y-py, nx-x, ny-y) > svgCornerDegrees {
+			d = append(d, 'L')
+			d = appendCoords(d, x, y)
+			d = append(d, 'L')
+			d = appendCoords(d, ex, ey)
+			continue
+		}
+		d = append(d, 'Q')
+		d = appendCoords(d, x, y)
+		d = append(d, ' ')
+		d = appendCoords(d, ex, ey)
+	}
+	return string(append(d, 'Z'))
+}
+
+// turnDegrees is the change of direction between two consecutive edges, 0
+// when they continue straight on and 180 when the second doubles back.
+func turnDegrees(ax, ay, bx, by float64) float64 {
+	cross := ax*by - ay*bx
+	dot := ax*bx + ay*by
+	return math.Abs(math.Atan2(cross, dot)) * 180 / math.Pi
+}
+
+// appendCoords appends "x,y" with at most two decimals.
+func appendCoords(d []byte, x, y float64) []byte {
+	d = strconv.AppendFloat(d, roundTo(x, 2), 'f', -1, 64)
+	d = append(d, ',')
+	return strconv.AppendFloat(d, roundTo(y, 2), 'f', -1, 64)
+}
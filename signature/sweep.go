@@ -0,0 +1,119 @@
+package signature
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/draw"
+	"image/gif"
+	"os"
+	"strconv"
+	"strings"
+
+	"gocv.io/x/gocv"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+// sweepFrameDelay is how long each threshold frame is shown, in 1/100s.
+const sweepFrameDelay = 100
+
+// ParseThresholds parses a comma-separated list of 0-255 threshold values.
+func ParseThresholds(list string) ([]uint8, error) {
+	var thresholds []uint8
+	for _, part := range strings.Split(list, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		v, err := strconv.ParseUint(part, 10, 8)
+		if err != nil {
+			return nil, fmt.Errorf("invalid threshold %q: must be 0-255", part)
+		}
+		thresholds = append(thresholds, uint8(v))
+	}
+	if len(thresholds) == 0 {
+		return nil, fmt.Errorf("no thresholds given")
+	}
+	return thresholds, nil
+}
+
+// writeThresholdSweep renders the same crop with each threshold applied as the
+// white cutoff and writes the results as a labeled, looping animated GIF.
+func writeThresholdSweep(crop gocv.Mat, thresholds []uint8, outPath string) error {
+	anim := &gif.GIF{}
+	for _, t := range thresholds {
+		extracted, err := removeWhiteBackground(crop, t)
+		if err != nil {
+			return err
+		}
+
+		// Composite over a checkerboard so transparency is visible in the GIF
+		bounds := extracted.Bounds()
+		frame := image.NewRGBA(bounds)
+		drawCheckerboard(frame)
+		draw.Draw(frame, bounds, extracted, bounds.Min, draw.Over)
+		drawLabel(frame, fmt.Sprintf("threshold %d", t))
+
+		paletted := image.NewPaletted(bounds, palette.Plan9)
+		draw.FloydSteinberg.Draw(paletted, bounds, frame, bounds.Min)
+		anim.Image = append(anim.Image, paletted)
+		anim.Delay = append(anim.Delay, sweepFrameDelay)
+	}
+
+	f, err := os.Create(outPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return gif.EncodeAll(f, anim)
+}
+
+// drawCheckerboard fills img with the usual light-gray transparency pattern.
+func drawCheckerboard(img *image.RGBA) {
+	const cell = 8
+	light := color.RGBA{R: 255, G: 255, B: 255, A: 255}
+	dark := color.RGBA{R: 204, G: 204, B: 204, A: 255}
+	b := img.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if ((x/cell)+(y/cell))%2 == 0 {
+				img.Set(x, y, light)
+			} else {
+				img.Set(x, y, dark)
+			}
+		}
+	}
+}
+
+// labelHeight is the height of the box drawLabelAt paints.
+var labelHeight = basicfont.Face7x13.Height + 4
+
+// drawLabel writes text in the top-left corner of img on a solid backing box
+// so it stays readable over any content.
+func drawLabel(img draw.Image, text string) {
+	drawLabelAt(img, img.Bounds().Min, text)
+}
+
+// labelWidth is the width of the box drawLabelAt paints for text.
+func labelWidth(text string) int {
+	return font.MeasureString(basicfont.Face7x13, text).Ceil() + 6
+}
+
+// drawLabelAt is drawLabel with the box's top-left corner at pt.
+func drawLabelAt(img draw.Image, pt image.Point, text string) {
+	face := basicfont.Face7x13
+	box := image.Rect(0, 0, labelWidth(text), labelHeight).Add(pt)
+	draw.Draw(img, box, image.NewUniform(color.RGBA{A: 255}), image.Point{}, draw.Src)
+
+	d := &font.Drawer{
+		Dst:  img,
+		Src:  image.NewUniform(color.RGBA{R: 255, G: 255, B: 255, A: 255}),
+		Face: face,
+		Dot:  fixed.P(box.Min.X+3, box.Min.Y+2+face.Ascent),
+	}
+	d.DrawString(text)
+}
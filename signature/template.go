@@ -0,0 +1,179 @@
+package signature
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"go.yaml.in/yaml/v3"
+)
+
+// Template names the zones of a standard document where each party signs, so
+// they are cropped where they are instead of detected (see Options.Template).
+type Template struct {
+	// Name identifies the template in logs and as Result.Template; it
+	// defaults to the file name without its extension.
+	Name string `yaml:"name"`
+	// Match fingerprints the documents the template is for, so a registry
+	// (see Options.Templates) can pick it; a single -template needs none.
+	Match *Fingerprint `yaml:"match"`
+	Zones []Zone       `yaml:"zones"`
+}
+
+// Zone is one named signing area of a Template.
+type Zone struct {
+	// Name labels the zone's outputs, e.g. buyer gives buyer.png.
+	Name string `yaml:"name"`
+	// Page is the 1-based page the zone is on; "last" in the file stands for
+	// the document's final page.
+	Page ZonePage `yaml:"page"`
+	// Rect is the zone as fractions of the page as displayed.
+	Rect RelativeRect `yaml:"rect"`
+	// Kind is MarkCheckbox or MarkInitials for a box that is only checked for a
+	// mark (see Options.Marks), and "" or "signature" for a signing zone.
+	Kind string `yaml:"kind"`
+	// template is the Name of the Template the zone belongs to.
+	template string
+}
+
+// ZonePage is a Zone's page number, or lastPage for the final page.
+type ZonePage int
+
+// UnmarshalYAML accepts a page number or "last".
+func (p *ZonePage) UnmarshalYAML(node *yaml.Node) error {
+	n, err := parsePageNumber(node.Value)
+	if err != nil {
+		return err
+	}
+	*p = ZonePage(n)
+	return nil
+}
+
+// resolve returns the page of a document with numPages pages.
+func (p ZonePage) resolve(numPages int) int {
+	if p == lastPage {
+		return numPages
+	}
+	return int(p)
+}
+
+// RelativeRect is a rectangle given as fractions (0-1) of the width and height
+// of the page as a viewer shows it, with the origin at the top-left corner:
+// {x: 0.5, y: 0.75, width: 0.5, height: 0.25} is the bottom-right quarter.
+type RelativeRect struct {
+	X      float64 `yaml:"x"`
+	Y      float64 `yaml:"y"`
+	Width  float64 `yaml:"width"`
+	Height float64 `yaml:"height"`
+}
+
+// toPDF returns the rectangle on the page with box in PDF points.
+func (r RelativeRect) toPDF(box pageBox) PDFRect {
+	w, h := box.size()
+	x0, y0 := box.toPDF(r.X*w, r.Y*h)
+	x1, y1 := box.toPDF((r.X+r.Width)*w, (r.Y+r.Height)*h)
+	return PDFRect{LLX: min(x0, x1), LLY: min(y0, y1), URX: max(x0, x1), URY: max(y0, y1)}
+}
+
+// zoneName is what a Zone.Name may be: it becomes a file name.
+var zoneName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// ParseTemplate reads a Template from the YAML or JSON file at path, e.g.
+//
+//	zones:
+//	  - {name: buyer, page: last, rect: {x: 0.05, y: 0.8, width: 0.4, height: 0.12}}
+//	  - {name: seller, page: last, rect: {x: 0.55, y: 0.8, width: 0.4, height: 0.12}}
+func ParseTemplate(path string) (*Template, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml", ".json":
+	default:
+		return nil, fmt.Errorf("%s: want a .yaml, .yml or .json file", path)
+	}
+	// JSON is valid YAML, so one decoder reads both
+	var t Template
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&t); err != nil {
+		return nil, fmt.Errorf("invalid template %s: %v", path, err)
+	}
+	if len(t.Zones) == 0 {
+		return nil, fmt.Errorf("template %s lists no zones", path)
+	}
+	if t.Name == "" {
+		t.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	if t.Match != nil {
+		if err := t.Match.validate(); err != nil {
+			return nil, fmt.Errorf("template %s: %v", path, err)
+		}
+	}
+	seen := map[string]bool{}
+	for i, z := range t.Zones {
+		if !zoneName.MatchString(z.Name) {
+			return nil, fmt.Errorf("zone %d: name %q must start with a letter or digit and hold only letters, digits, '.', '_' and '-'", i+1, z.Name)
+		}
+		if seen[z.Name] {
+			return nil, fmt.Errorf("zone %d: name %q is used twice", i+1, z.Name)
+		}
+		seen[z.Name] = true
+		if z.Page == 0 {
+			return nil, fmt.Errorf("zone %q: no page given", z.Name)
+		}
+		switch z.Kind {
+		case "", "signature", MarkCheckbox, MarkInitials:
+		default:
+			return nil, fmt.Errorf("zone %q: kind %q must be signature, %s or %s", z.Name, z.Kind, MarkCheckbox, MarkInitials)
+		}
+		r := z.Rect
+		if r.X < 0 || r.Y < 0 || r.Width <= 0 || r.Height <= 0 || r.X+r.Width > 1 || r.Y+r.Height > 1 {
+			return nil, fmt.Errorf("zone %q: rect %+v must have an area and lie within the page (0-1)", z.Name, r)
+		}
+	}
+	return &t, nil
+}
+
+// zonesByPage groups the zones of t by the page of a document with numPages
+// pages they fall on, failing when one names a page past the end.
+func (t *Template) zonesByPage(numPages int) (map[int][]Zone, error) {
+	byPage := map[int][]Zone{}
+	for _, z := range t.Zones {
+		z.template = t.Name
+		page := z.Page.resolve(numPages)
+		if page > numPages {
+			return nil, fmt.Errorf("zone %q is on page %d, but the document has %d pages", z.Name, page, numPages)
+		}
+		byPage[page] = append(byPage[page], z)
+	}
+	return byPage, nil
+}
+
+// zoneFields returns the signing zones of zones as Fields on the page with
+// box, so they are cropped like AcroForm signature fields. Mark zones are left
+// out; they are judged by pageMarks.
+func zoneFields(zones []Zone, page int, box pageBox) []Field {
+	var fields []Field
+	for _, z := range zones {
+		if !ValidMarkKind(z.Kind) {
+			fields = append(fields, Field{Name: z.Name, Page: page, Rect: z.Rect.toPDF(box)})
+		}
+	}
+	return fields
+}
+
+// markZones returns the zones of zones that are checkboxes or initials boxes.
+func markZones(zones []Zone) []Zone {
+	var marks []Zone
+	for _, z := range zones {
+		if ValidMarkKind(z.Kind) {
+			marks = append(marks, z)
+		}
+	}
+	return marks
+}
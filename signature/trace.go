@@ -0,0 +1,55 @@
+package signature
+
+import (
+	"context"
+	"path/filepath"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName is the instrumentation scope of the spans of the pipeline.
+const tracerName = "poc-pdf/signature"
+
+// Span names and attributes. Stage spans are named after the stage (see
+// StageRasterize); spanDocument covers one document, cache lookups included.
+const (
+	spanDocument = "document"
+
+	attrSource     = attribute.Key("document.source")
+	attrPages      = attribute.Key("document.pages")
+	attrSignatures = attribute.Key("document.signatures")
+	attrPage       = attribute.Key("document.page")
+	attrRegions    = attribute.Key("signature.regions")
+	attrRegion     = attribute.Key("signature.region")
+	attrFormat     = attribute.Key("signature.format")
+)
+
+// tracer returns the tracer of Options.TracerProvider, or of the global one.
+func (e *Extractor) tracer() trace.Tracer {
+	if e.opts.TracerProvider != nil {
+		return e.opts.TracerProvider.Tracer(tracerName)
+	}
+	return otel.Tracer(tracerName)
+}
+
+// startSpan starts the span called name as a child of any span in ctx.
+func (e *Extractor) startSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return e.tracer().Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// startDocumentSpan starts the span of the document at path.
+func (e *Extractor) startDocumentSpan(ctx context.Context, path string) (context.Context, trace.Span) {
+	return e.startSpan(ctx, spanDocument, attrSource.String(filepath.Base(path)))
+}
+
+// endSpan ends span, marking it failed with err, if any.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
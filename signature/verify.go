@@ -0,0 +1,229 @@
+package signature
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"os"
+	"path/filepath"
+
+	"gocv.io/x/gocv"
+)
+
+// DefaultMinInkCoverage is the smallest share of a checked area that must be
+// ink for Verify to call it signed, on top of a signature-shaped region being
+// found there. It keeps a stray mark that passes the shape filters from counting.
+const DefaultMinInkCoverage = 0.002
+
+// Field is a named area of a form where a signature is expected.
+type Field struct {
+	Name string `json:"name"`
+	// Page is the 1-based page the field is on.
+	Page int `json:"page"`
+	// Rect is the field in PDF points.
+	Rect PDFRect `json:"rect"`
+}
+
+// VerifyOptions configures Extractor.Verify.
+type VerifyOptions struct {
+	// Fields, when set, are the areas checked; otherwise every selected page
+	// (see Options.Pages) is checked as a whole.
+	Fields []Field
+	// MinInkCoverage is the share of the area that must be ink; 0 means
+	// DefaultMinInkCoverage.
+	MinInkCoverage float64
+}
+
+// FieldCheck is the verdict for one page or field.
+type FieldCheck struct {
+	Page int `json:"page"`
+	// Field is the Field.Name; empty when the whole page was checked.
+	Field string `json:"field,omitempty"`
+	// Bounds is the checked area in PDF points.
+	Bounds PDFRect `json:"bounds_pt"`
+	// InkCoverage is the share of the area's pixels that are ink, in [0, 1].
+	InkCoverage float64 `json:"ink_coverage"`
+	// Confidence is the detection confidence of the signature found; 0 when none was.
+	Confidence float64 `json:"confidence"`
+	// Signed reports that a signature-shaped ink region was found and the area
+	// has at least MinInkCoverage ink.
+	Signed bool `json:"signed"`
+}
+
+// VerifyReport is the outcome of Extractor.Verify for one document.
+type VerifyReport struct {
+	Source string `json:"source"`
+	// Signed is true when every field is signed, or without fields when at
+	// least one page is.
+	Signed bool         `json:"signed"`
+	Checks []FieldCheck `json:"checks"`
+}
+
+// ParseFields reads a JSON array of Field from path, e.g.
+// [{"name": "buyer", "page": 2, "rect": {"llx": 72, "lly": 90, "urx": 270, "ury": 140}}].
+func ParseFields(path string) ([]Field, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var fields []Field
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, fmt.Errorf("invalid fields file %s: %v", path, err)
+	}
+	for i, f := range fields {
+		if f.Page < 1 {
+			return nil, fmt.Errorf("field %d (%q): page must be at least 1, got %d", i+1, f.Name, f.Page)
+		}
+		if f.Rect.Width() <= 0 || f.Rect.Height() <= 0 {
+			return nil, fmt.Errorf("field %d (%q): rect %v has no area", i+1, f.Name, f.Rect)
+		}
+	}
+	return fields, nil
+}
+
+// Verify reports, per page or per field, whether the PDF at path carries a
+// handwritten signature, for auditing returned contracts. It runs detection
+// like ExtractFromPDF but writes no outputs, and removes its page renders
+// (unless Options.KeepTemp is set).
+func (e *Extractor) Verify(ctx context.Context, path string, vopts VerifyOptions) (*VerifyReport, error) {
+	ctx, cancel := e.documentContext(ctx)
+	defer cancel()
+	report, err := e.verify(ctx, path, vopts)
+	return report, documentError(ctx, err)
+}
+
+// verify is Verify within the document's deadline.
+func (e *Extractor) verify(ctx context.Context, path string, vopts VerifyOptions) (*VerifyReport, error) {
+	raster, err := e.rasterizerFor(path)
+	if err != nil {
+		return nil, err
+	}
+	numPages, err := raster.pageCount(ctx, path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read page count: %w", err)
+	}
+	minCoverage := vopts.MinInkCoverage
+	if minCoverage == 0 {
+		minCoverage = DefaultMinInkCoverage
+	}
+
+	// Group the areas to check by page, in page order
+	var pages []int
+	byPage := map[int][]Field{}
+	if len(vopts.Fields) > 0 {
+		for _, f := range vopts.Fields {
+			if f.Page > numPages {
+				return nil, fmt.Errorf("field %q is on page %d, but the document has %d pages", f.Name, f.Page, numPages)
+			}
+			if _, ok := byPage[f.Page]; !ok {
+				pages = append(pages, f.Page)
+			}
+			byPage[f.Page] = append(byPage[f.Page], f)
+		}
+	} else if pages, err = e.opts.Pages.resolve(numPages); err != nil {
+		return nil, err
+	}
+
+	tmpDir, cleanup, err := e.tempDir("verify")
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	report := &VerifyReport{Source: path, Signed: len(vopts.Fields) > 0}
+	for _, page := range pages {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		checks, err := e.verifyPage(ctx, raster, path, tmpDir, page, byPage[page], minCoverage)
+		if err != nil {
+			return nil, fmt.Errorf("page %d: %w", page, err)
+		}
+		for _, c := range checks {
+			if len(vopts.Fields) > 0 {
+				report.Signed = report.Signed && c.Signed
+			} else {
+				report.Signed = report.Signed || c.Signed
+			}
+		}
+		report.Checks = append(report.Checks, checks...)
+	}
+	return report, nil
+}
+
+// verifyPage checks fields on one page, or the whole page when fields is empty,
+// rendering it into tmpDir.
+func (e *Extractor) verifyPage(ctx context.Context, raster rasterizer, path, tmpDir string, page int, fields []Field, minCoverage float64) ([]FieldCheck, error) {
+	box, err := raster.box(ctx, path, page)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read page size: %w", err)
+	}
+	if err := checkPageSize(box.Rect, e.opts.MinPagePt, e.opts.MaxPagePt); err != nil {
+		return nil, err
+	}
+	dpi := clampDPI(box.Rect, e.opts.RenderDPI, e.opts.MaxRenderPx)
+
+	pages := newPageCache(raster, path, page, filepath.Join(tmpDir, "verify_page"), box, nil)
+	pages.flatten = e.opts.FlattenIllumination
+	pages.maxBytes = e.opts.MaxRenderBytes
+	render, err := pages.render(ctx, dpi)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert PDF to PNG: %w", err)
+	}
+
+	img, err := readImage(render.Path, gocv.IMReadGrayScale)
+	if err != nil {
+		return nil, err
+	}
+	defer img.Close()
+	bin, _, _ := binarize(img, e.opts.Binarization, dpi)
+	defer pageBuffers.put(bin)
+
+	pageRect := image.Rect(0, 0, bin.Cols(), bin.Rows())
+	wholePage := len(fields) == 0
+	if wholePage {
+		fields = []Field{{Page: page, Rect: box.Rect}}
+	}
+	checks := make([]FieldCheck, 0, len(fields))
+	for _, f := range fields {
+		area := pdfRectToPixels(f.Rect, dpi, box).Intersect(pageRect)
+		if area.Empty() {
+			return nil, fmt.Errorf("field %q %v does not overlap the page %v", f.Name, f.Rect, box.Rect)
+		}
+
+		check := FieldCheck{Page: page, Field: f.Name, Bounds: f.Rect}
+		region := bin.Region(area)
+		check.InkCoverage = float64(gocv.CountNonZero(region)) / float64(rectArea(area))
+		region.Close()
+
+		// Same detection as extraction, restricted to the field
+		params := detectParams{dpi: dpi, binarization: e.opts.Binarization, noShapeFilter: e.opts.NoShapeFilter, removeRules: e.opts.RemoveLines, mergeGap: e.opts.MergeGapPx, medianBlur: e.opts.MedianBlur}
+		if !wholePage {
+			params.areas = []image.Rectangle{area}
+		}
+		regions, _, err := extractSignature(render.Path, params)
+		if err != nil && !errors.Is(err, ErrNoSignature) {
+			return nil, err
+		}
+		for i, r := range regions {
+			if i == 0 {
+				check.Confidence = r.Confidence
+			}
+			r.Image.Close()
+		}
+		check.Signed = len(regions) > 0 && check.InkCoverage >= minCoverage
+		e.logf("Page %d%s: ink coverage %.4f, signed: %v", page, fieldLabel(f.Name), check.InkCoverage, check.Signed)
+		checks = append(checks, check)
+	}
+	return checks, nil
+}
+
+// fieldLabel formats a field name for progress messages.
+func fieldLabel(name string) string {
+	if name == "" {
+		return ""
+	}
+	return fmt.Sprintf(" field %q", name)
+}
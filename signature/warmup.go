@@ -0,0 +1,113 @@
+package signature
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"os"
+	"path/filepath"
+
+	"gocv.io/x/gocv"
+)
+
+// warmupDPI keeps the validation render tiny: the 1in test page becomes 18x18 px.
+const warmupDPI = 18
+
+// WarmupRasterizer checks the environment once at startup: the backend called
+// name (see Options.Rasterizer) must be available, read a known-good PDF, and
+// produce a decodable page image. For poppler this means pdftoppm and pdfinfo are
+// on PATH; for auto, that some backend is (see ResolveRasterizer). Running it up front turns a broken install into an immediate failure
+// instead of an error on the first real document, and pays the first-exec cost
+// in advance.
+func WarmupRasterizer(ctx context.Context, name string) error {
+	raster, err := newRasterizer(name, "", processLimits{})
+	if err != nil {
+		return err
+	}
+	tmpDir, err := os.MkdirTemp("", "poc-pdf-warmup-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	pdfPath := filepath.Join(tmpDir, "warmup.pdf")
+	if err := os.WriteFile(pdfPath, minimalPDF(), 0o600); err != nil {
+		return err
+	}
+
+	box, err := raster.box(ctx, pdfPath, 1)
+	if err != nil {
+		return fmt.Errorf("reading the page size of a known-good PDF failed: %w", err)
+	}
+	if box.Rect.Width() != pointsPerInch || box.Rect.Height() != pointsPerInch {
+		return fmt.Errorf("got page box %v for a 72x72pt page", box.Rect)
+	}
+
+	pngPath, err := raster.renderPNG(ctx, pdfPath, 1, filepath.Join(tmpDir, "warmup"), warmupDPI, image.Rectangle{})
+	if err != nil {
+		return fmt.Errorf("rendering a known-good PDF failed: %w", err)
+	}
+	if _, err := imageBounds(pngPath); err != nil {
+		return fmt.Errorf("the rasterizer produced an unreadable image: %v", err)
+	}
+	return nil
+}
+
+// WarmupOpenCV checks that OpenCV works: it thresholds a small gray image
+// with a dark square on it and must find that square as the one contour, as
+// detection does on a page. It catches a gocv build that links but whose
+// native library is missing pieces or mismatched.
+func WarmupOpenCV() error {
+	const size = 16
+	square := image.Rect(4, 4, 12, 12)
+	data := make([]byte, size*size)
+	for i := range data {
+		if !image.Pt(i%size, i/size).In(square) {
+			data[i] = 255
+		}
+	}
+	gray, err := gocv.NewMatFromBytes(size, size, gocv.MatTypeCV8U, data)
+	if err != nil {
+		return fmt.Errorf("OpenCV could not hold a gray image: %v", err)
+	}
+	defer gray.Close()
+	bin := gocv.NewMat()
+	defer bin.Close()
+	gocv.Threshold(gray, &bin, 128, 255, gocv.ThresholdBinaryInv)
+
+	contours := gocv.FindContours(bin, gocv.RetrievalExternal, gocv.ChainApproxSimple)
+	defer contours.Close()
+	if contours.Size() != 1 {
+		return fmt.Errorf("OpenCV found %d contours in a test image with one square", contours.Size())
+	}
+	if r := gocv.BoundingRect(contours.At(0)); r != square {
+		return fmt.Errorf("OpenCV bounded the test square %v as %v", square, r)
+	}
+	return nil
+}
+
+// minimalPDF builds a valid one-page, 72x72pt blank PDF with a correct xref table.
+func minimalPDF() []byte {
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 72 72] /Resources << >> >>",
+	}
+
+	var b bytes.Buffer
+	b.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = b.Len()
+		fmt.Fprintf(&b, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+
+	xref := b.Len()
+	fmt.Fprintf(&b, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&b, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&b, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+	return b.Bytes()
+}
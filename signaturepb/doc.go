@@ -0,0 +1,7 @@
+// Package signaturepb holds the protobuf messages and the gRPC client and
+// server stubs of SignatureService, generated from signature.proto. The
+// service is implemented by serve -grpc; other services dial it with
+// NewSignatureServiceClient.
+package signaturepb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative signature.proto
@@ -0,0 +1,949 @@
+// SignatureService exposes the extraction pipeline over gRPC (serve -grpc).
+// Regenerate the Go code with `go generate ./signaturepb` (needs protoc,
+// protoc-gen-go and protoc-gen-go-grpc on PATH).
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.10
+// 	protoc        (unknown)
+// source: signature.proto
+
+package signaturepb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// ExtractOptions are the per-request settings, as the HTTP server's query
+// parameters and form fields.
+type ExtractOptions struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// pages is a -pages selection, e.g. "1,3,5-7,last"; empty means all.
+	Pages string `protobuf:"bytes,1,opt,name=pages,proto3" json:"pages,omitempty"`
+	// password opens encrypted PDFs.
+	Password string `protobuf:"bytes,2,opt,name=password,proto3" json:"password,omitempty"`
+	// all_regions returns every signature of a page instead of the largest.
+	AllRegions    bool `protobuf:"varint,3,opt,name=all_regions,json=allRegions,proto3" json:"all_regions,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ExtractOptions) Reset() {
+	*x = ExtractOptions{}
+	mi := &file_signature_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ExtractOptions) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ExtractOptions) ProtoMessage() {}
+
+func (x *ExtractOptions) ProtoReflect() protoreflect.Message {
+	mi := &file_signature_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ExtractOptions.ProtoReflect.Descriptor instead.
+func (*ExtractOptions) Descriptor() ([]byte, []int) {
+	return file_signature_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *ExtractOptions) GetPages() string {
+	if x != nil {
+		return x.Pages
+	}
+	return ""
+}
+
+func (x *ExtractOptions) GetPassword() string {
+	if x != nil {
+		return x.Password
+	}
+	return ""
+}
+
+func (x *ExtractOptions) GetAllRegions() bool {
+	if x != nil {
+		return x.AllRegions
+	}
+	return false
+}
+
+type ExtractRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Pdf           []byte                 `protobuf:"bytes,1,opt,name=pdf,proto3" json:"pdf,omitempty"`
+	Options       *ExtractOptions        `protobuf:"bytes,2,opt,name=options,proto3" json:"options,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ExtractRequest) Reset() {
+	*x = ExtractRequest{}
+	mi := &file_signature_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ExtractRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ExtractRequest) ProtoMessage() {}
+
+func (x *ExtractRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_signature_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ExtractRequest.ProtoReflect.Descriptor instead.
+func (*ExtractRequest) Descriptor() ([]byte, []int) {
+	return file_signature_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *ExtractRequest) GetPdf() []byte {
+	if x != nil {
+		return x.Pdf
+	}
+	return nil
+}
+
+func (x *ExtractRequest) GetOptions() *ExtractOptions {
+	if x != nil {
+		return x.Options
+	}
+	return nil
+}
+
+type ExtractChunk struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// options are read from the first chunk only.
+	Options *ExtractOptions `protobuf:"bytes,1,opt,name=options,proto3" json:"options,omitempty"`
+	// data is the next part of the PDF.
+	Data          []byte `protobuf:"bytes,2,opt,name=data,proto3" json:"data,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ExtractChunk) Reset() {
+	*x = ExtractChunk{}
+	mi := &file_signature_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ExtractChunk) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ExtractChunk) ProtoMessage() {}
+
+func (x *ExtractChunk) ProtoReflect() protoreflect.Message {
+	mi := &file_signature_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ExtractChunk.ProtoReflect.Descriptor instead.
+func (*ExtractChunk) Descriptor() ([]byte, []int) {
+	return file_signature_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *ExtractChunk) GetOptions() *ExtractOptions {
+	if x != nil {
+		return x.Options
+	}
+	return nil
+}
+
+func (x *ExtractChunk) GetData() []byte {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+// PixelRect is a rectangle in page pixels, origin top-left.
+type PixelRect struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	X             int32                  `protobuf:"varint,1,opt,name=x,proto3" json:"x,omitempty"`
+	Y             int32                  `protobuf:"varint,2,opt,name=y,proto3" json:"y,omitempty"`
+	Width         int32                  `protobuf:"varint,3,opt,name=width,proto3" json:"width,omitempty"`
+	Height        int32                  `protobuf:"varint,4,opt,name=height,proto3" json:"height,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PixelRect) Reset() {
+	*x = PixelRect{}
+	mi := &file_signature_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PixelRect) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PixelRect) ProtoMessage() {}
+
+func (x *PixelRect) ProtoReflect() protoreflect.Message {
+	mi := &file_signature_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PixelRect.ProtoReflect.Descriptor instead.
+func (*PixelRect) Descriptor() ([]byte, []int) {
+	return file_signature_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *PixelRect) GetX() int32 {
+	if x != nil {
+		return x.X
+	}
+	return 0
+}
+
+func (x *PixelRect) GetY() int32 {
+	if x != nil {
+		return x.Y
+	}
+	return 0
+}
+
+func (x *PixelRect) GetWidth() int32 {
+	if x != nil {
+		return x.Width
+	}
+	return 0
+}
+
+func (x *PixelRect) GetHeight() int32 {
+	if x != nil {
+		return x.Height
+	}
+	return 0
+}
+
+// PDFRect is a rectangle in PDF points, origin bottom-left.
+type PDFRect struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Llx           float64                `protobuf:"fixed64,1,opt,name=llx,proto3" json:"llx,omitempty"`
+	Lly           float64                `protobuf:"fixed64,2,opt,name=lly,proto3" json:"lly,omitempty"`
+	Urx           float64                `protobuf:"fixed64,3,opt,name=urx,proto3" json:"urx,omitempty"`
+	Ury           float64                `protobuf:"fixed64,4,opt,name=ury,proto3" json:"ury,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PDFRect) Reset() {
+	*x = PDFRect{}
+	mi := &file_signature_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PDFRect) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PDFRect) ProtoMessage() {}
+
+func (x *PDFRect) ProtoReflect() protoreflect.Message {
+	mi := &file_signature_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PDFRect.ProtoReflect.Descriptor instead.
+func (*PDFRect) Descriptor() ([]byte, []int) {
+	return file_signature_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *PDFRect) GetLlx() float64 {
+	if x != nil {
+		return x.Llx
+	}
+	return 0
+}
+
+func (x *PDFRect) GetLly() float64 {
+	if x != nil {
+		return x.Lly
+	}
+	return 0
+}
+
+func (x *PDFRect) GetUrx() float64 {
+	if x != nil {
+		return x.Urx
+	}
+	return 0
+}
+
+func (x *PDFRect) GetUry() float64 {
+	if x != nil {
+		return x.Ury
+	}
+	return 0
+}
+
+// Signature is one extracted signature; the fields match the JSON metadata.
+type Signature struct {
+	state  protoimpl.MessageState `protogen:"open.v1"`
+	Page   int32                  `protobuf:"varint,1,opt,name=page,proto3" json:"page,omitempty"`
+	Region int32                  `protobuf:"varint,2,opt,name=region,proto3" json:"region,omitempty"`
+	// png is the transparent signature image.
+	Png           []byte     `protobuf:"bytes,3,opt,name=png,proto3" json:"png,omitempty"`
+	Dpi           float64    `protobuf:"fixed64,4,opt,name=dpi,proto3" json:"dpi,omitempty"`
+	Bounds        *PixelRect `protobuf:"bytes,5,opt,name=bounds,proto3" json:"bounds,omitempty"`
+	PdfBounds     *PDFRect   `protobuf:"bytes,6,opt,name=pdf_bounds,json=pdfBounds,proto3" json:"pdf_bounds,omitempty"`
+	Confidence    float64    `protobuf:"fixed64,7,opt,name=confidence,proto3" json:"confidence,omitempty"`
+	SignatureType string     `protobuf:"bytes,8,opt,name=signature_type,json=signatureType,proto3" json:"signature_type,omitempty"`
+	EdgeTouch     bool       `protobuf:"varint,9,opt,name=edge_touch,json=edgeTouch,proto3" json:"edge_touch,omitempty"`
+	FormField     string     `protobuf:"bytes,10,opt,name=form_field,json=formField,proto3" json:"form_field,omitempty"`
+	Rotation      int32      `protobuf:"varint,11,opt,name=rotation,proto3" json:"rotation,omitempty"`
+	WidthMm       float64    `protobuf:"fixed64,12,opt,name=width_mm,json=widthMm,proto3" json:"width_mm,omitempty"`
+	HeightMm      float64    `protobuf:"fixed64,13,opt,name=height_mm,json=heightMm,proto3" json:"height_mm,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Signature) Reset() {
+	*x = Signature{}
+	mi := &file_signature_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Signature) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Signature) ProtoMessage() {}
+
+func (x *Signature) ProtoReflect() protoreflect.Message {
+	mi := &file_signature_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Signature.ProtoReflect.Descriptor instead.
+func (*Signature) Descriptor() ([]byte, []int) {
+	return file_signature_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *Signature) GetPage() int32 {
+	if x != nil {
+		return x.Page
+	}
+	return 0
+}
+
+func (x *Signature) GetRegion() int32 {
+	if x != nil {
+		return x.Region
+	}
+	return 0
+}
+
+func (x *Signature) GetPng() []byte {
+	if x != nil {
+		return x.Png
+	}
+	return nil
+}
+
+func (x *Signature) GetDpi() float64 {
+	if x != nil {
+		return x.Dpi
+	}
+	return 0
+}
+
+func (x *Signature) GetBounds() *PixelRect {
+	if x != nil {
+		return x.Bounds
+	}
+	return nil
+}
+
+func (x *Signature) GetPdfBounds() *PDFRect {
+	if x != nil {
+		return x.PdfBounds
+	}
+	return nil
+}
+
+func (x *Signature) GetConfidence() float64 {
+	if x != nil {
+		return x.Confidence
+	}
+	return 0
+}
+
+func (x *Signature) GetSignatureType() string {
+	if x != nil {
+		return x.SignatureType
+	}
+	return ""
+}
+
+func (x *Signature) GetEdgeTouch() bool {
+	if x != nil {
+		return x.EdgeTouch
+	}
+	return false
+}
+
+func (x *Signature) GetFormField() string {
+	if x != nil {
+		return x.FormField
+	}
+	return ""
+}
+
+func (x *Signature) GetRotation() int32 {
+	if x != nil {
+		return x.Rotation
+	}
+	return 0
+}
+
+func (x *Signature) GetWidthMm() float64 {
+	if x != nil {
+		return x.WidthMm
+	}
+	return 0
+}
+
+func (x *Signature) GetHeightMm() float64 {
+	if x != nil {
+		return x.HeightMm
+	}
+	return 0
+}
+
+type ExtractResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Signatures    []*Signature           `protobuf:"bytes,1,rep,name=signatures,proto3" json:"signatures,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ExtractResponse) Reset() {
+	*x = ExtractResponse{}
+	mi := &file_signature_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ExtractResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ExtractResponse) ProtoMessage() {}
+
+func (x *ExtractResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_signature_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ExtractResponse.ProtoReflect.Descriptor instead.
+func (*ExtractResponse) Descriptor() ([]byte, []int) {
+	return file_signature_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *ExtractResponse) GetSignatures() []*Signature {
+	if x != nil {
+		return x.Signatures
+	}
+	return nil
+}
+
+// Field is a named area where a signature is expected.
+type Field struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Page          int32                  `protobuf:"varint,2,opt,name=page,proto3" json:"page,omitempty"`
+	Rect          *PDFRect               `protobuf:"bytes,3,opt,name=rect,proto3" json:"rect,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Field) Reset() {
+	*x = Field{}
+	mi := &file_signature_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Field) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Field) ProtoMessage() {}
+
+func (x *Field) ProtoReflect() protoreflect.Message {
+	mi := &file_signature_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Field.ProtoReflect.Descriptor instead.
+func (*Field) Descriptor() ([]byte, []int) {
+	return file_signature_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *Field) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Field) GetPage() int32 {
+	if x != nil {
+		return x.Page
+	}
+	return 0
+}
+
+func (x *Field) GetRect() *PDFRect {
+	if x != nil {
+		return x.Rect
+	}
+	return nil
+}
+
+type VerifyRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Pdf   []byte                 `protobuf:"bytes,1,opt,name=pdf,proto3" json:"pdf,omitempty"`
+	// pages is a -pages selection checked without fields; empty means all.
+	Pages    string `protobuf:"bytes,2,opt,name=pages,proto3" json:"pages,omitempty"`
+	Password string `protobuf:"bytes,3,opt,name=password,proto3" json:"password,omitempty"`
+	// fields, when set, are checked instead of whole pages.
+	Fields []*Field `protobuf:"bytes,4,rep,name=fields,proto3" json:"fields,omitempty"`
+	// min_coverage is the share of an area that must be ink; 0 means the default.
+	MinCoverage   float64 `protobuf:"fixed64,5,opt,name=min_coverage,json=minCoverage,proto3" json:"min_coverage,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *VerifyRequest) Reset() {
+	*x = VerifyRequest{}
+	mi := &file_signature_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *VerifyRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*VerifyRequest) ProtoMessage() {}
+
+func (x *VerifyRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_signature_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use VerifyRequest.ProtoReflect.Descriptor instead.
+func (*VerifyRequest) Descriptor() ([]byte, []int) {
+	return file_signature_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *VerifyRequest) GetPdf() []byte {
+	if x != nil {
+		return x.Pdf
+	}
+	return nil
+}
+
+func (x *VerifyRequest) GetPages() string {
+	if x != nil {
+		return x.Pages
+	}
+	return ""
+}
+
+func (x *VerifyRequest) GetPassword() string {
+	if x != nil {
+		return x.Password
+	}
+	return ""
+}
+
+func (x *VerifyRequest) GetFields() []*Field {
+	if x != nil {
+		return x.Fields
+	}
+	return nil
+}
+
+func (x *VerifyRequest) GetMinCoverage() float64 {
+	if x != nil {
+		return x.MinCoverage
+	}
+	return 0
+}
+
+// FieldCheck is the verdict for one page or field.
+type FieldCheck struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Page          int32                  `protobuf:"varint,1,opt,name=page,proto3" json:"page,omitempty"`
+	Field         string                 `protobuf:"bytes,2,opt,name=field,proto3" json:"field,omitempty"`
+	Bounds        *PDFRect               `protobuf:"bytes,3,opt,name=bounds,proto3" json:"bounds,omitempty"`
+	InkCoverage   float64                `protobuf:"fixed64,4,opt,name=ink_coverage,json=inkCoverage,proto3" json:"ink_coverage,omitempty"`
+	Confidence    float64                `protobuf:"fixed64,5,opt,name=confidence,proto3" json:"confidence,omitempty"`
+	Signed        bool                   `protobuf:"varint,6,opt,name=signed,proto3" json:"signed,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *FieldCheck) Reset() {
+	*x = FieldCheck{}
+	mi := &file_signature_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FieldCheck) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FieldCheck) ProtoMessage() {}
+
+func (x *FieldCheck) ProtoReflect() protoreflect.Message {
+	mi := &file_signature_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FieldCheck.ProtoReflect.Descriptor instead.
+func (*FieldCheck) Descriptor() ([]byte, []int) {
+	return file_signature_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *FieldCheck) GetPage() int32 {
+	if x != nil {
+		return x.Page
+	}
+	return 0
+}
+
+func (x *FieldCheck) GetField() string {
+	if x != nil {
+		return x.Field
+	}
+	return ""
+}
+
+func (x *FieldCheck) GetBounds() *PDFRect {
+	if x != nil {
+		return x.Bounds
+	}
+	return nil
+}
+
+func (x *FieldCheck) GetInkCoverage() float64 {
+	if x != nil {
+		return x.InkCoverage
+	}
+	return 0
+}
+
+func (x *FieldCheck) GetConfidence() float64 {
+	if x != nil {
+		return x.Confidence
+	}
+	return 0
+}
+
+func (x *FieldCheck) GetSigned() bool {
+	if x != nil {
+		return x.Signed
+	}
+	return false
+}
+
+type VerifyResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Signed        bool                   `protobuf:"varint,1,opt,name=signed,proto3" json:"signed,omitempty"`
+	Checks        []*FieldCheck          `protobuf:"bytes,2,rep,name=checks,proto3" json:"checks,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *VerifyResponse) Reset() {
+	*x = VerifyResponse{}
+	mi := &file_signature_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *VerifyResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*VerifyResponse) ProtoMessage() {}
+
+func (x *VerifyResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_signature_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use VerifyResponse.ProtoReflect.Descriptor instead.
+func (*VerifyResponse) Descriptor() ([]byte, []int) {
+	return file_signature_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *VerifyResponse) GetSigned() bool {
+	if x != nil {
+		return x.Signed
+	}
+	return false
+}
+
+func (x *VerifyResponse) GetChecks() []*FieldCheck {
+	if x != nil {
+		return x.Checks
+	}
+	return nil
+}
+
+var File_signature_proto protoreflect.FileDescriptor
+
+const file_signature_proto_rawDesc = "" +
+	"\n" +
+	"\x0fsignature.proto\x12\fsignature.v1\"c\n" +
+	"\x0eExtractOptions\x12\x14\n" +
+	"\x05pages\x18\x01 \x01(\tR\x05pages\x12\x1a\n" +
+	"\bpassword\x18\x02 \x01(\tR\bpassword\x12\x1f\n" +
+	"\vall_regions\x18\x03 \x01(\bR\n" +
+	"allRegions\"Z\n" +
+	"\x0eExtractRequest\x12\x10\n" +
+	"\x03pdf\x18\x01 \x01(\fR\x03pdf\x126\n" +
+	"\aoptions\x18\x02 \x01(\v2\x1c.signature.v1.ExtractOptionsR\aoptions\"Z\n" +
+	"\fExtractChunk\x126\n" +
+	"\aoptions\x18\x01 \x01(\v2\x1c.signature.v1.ExtractOptionsR\aoptions\x12\x12\n" +
+	"\x04data\x18\x02 \x01(\fR\x04data\"U\n" +
+	"\tPixelRect\x12\f\n" +
+	"\x01x\x18\x01 \x01(\x05R\x01x\x12\f\n" +
+	"\x01y\x18\x02 \x01(\x05R\x01y\x12\x14\n" +
+	"\x05width\x18\x03 \x01(\x05R\x05width\x12\x16\n" +
+	"\x06height\x18\x04 \x01(\x05R\x06height\"Q\n" +
+	"\aPDFRect\x12\x10\n" +
+	"\x03llx\x18\x01 \x01(\x01R\x03llx\x12\x10\n" +
+	"\x03lly\x18\x02 \x01(\x01R\x03lly\x12\x10\n" +
+	"\x03urx\x18\x03 \x01(\x01R\x03urx\x12\x10\n" +
+	"\x03ury\x18\x04 \x01(\x01R\x03ury\"\x9b\x03\n" +
+	"\tSignature\x12\x12\n" +
+	"\x04page\x18\x01 \x01(\x05R\x04page\x12\x16\n" +
+	"\x06region\x18\x02 \x01(\x05R\x06region\x12\x10\n" +
+	"\x03png\x18\x03 \x01(\fR\x03png\x12\x10\n" +
+	"\x03dpi\x18\x04 \x01(\x01R\x03dpi\x12/\n" +
+	"\x06bounds\x18\x05 \x01(\v2\x17.signature.v1.PixelRectR\x06bounds\x124\n" +
+	"\n" +
+	"pdf_bounds\x18\x06 \x01(\v2\x15.signature.v1.PDFRectR\tpdfBounds\x12\x1e\n" +
+	"\n" +
+	"confidence\x18\a \x01(\x01R\n" +
+	"confidence\x12%\n" +
+	"\x0esignature_type\x18\b \x01(\tR\rsignatureType\x12\x1d\n" +
+	"\n" +
+	"edge_touch\x18\t \x01(\bR\tedgeTouch\x12\x1d\n" +
+	"\n" +
+	"form_field\x18\n" +
+	" \x01(\tR\tformField\x12\x1a\n" +
+	"\brotation\x18\v \x01(\x05R\brotation\x12\x19\n" +
+	"\bwidth_mm\x18\f \x01(\x01R\awidthMm\x12\x1b\n" +
+	"\theight_mm\x18\r \x01(\x01R\bheightMm\"J\n" +
+	"\x0fExtractResponse\x127\n" +
+	"\n" +
+	"signatures\x18\x01 \x03(\v2\x17.signature.v1.SignatureR\n" +
+	"signatures\"Z\n" +
+	"\x05Field\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x12\n" +
+	"\x04page\x18\x02 \x01(\x05R\x04page\x12)\n" +
+	"\x04rect\x18\x03 \x01(\v2\x15.signature.v1.PDFRectR\x04rect\"\xa3\x01\n" +
+	"\rVerifyRequest\x12\x10\n" +
+	"\x03pdf\x18\x01 \x01(\fR\x03pdf\x12\x14\n" +
+	"\x05pages\x18\x02 \x01(\tR\x05pages\x12\x1a\n" +
+	"\bpassword\x18\x03 \x01(\tR\bpassword\x12+\n" +
+	"\x06fields\x18\x04 \x03(\v2\x13.signature.v1.FieldR\x06fields\x12!\n" +
+	"\fmin_coverage\x18\x05 \x01(\x01R\vminCoverage\"\xc0\x01\n" +
+	"\n" +
+	"FieldCheck\x12\x12\n" +
+	"\x04page\x18\x01 \x01(\x05R\x04page\x12\x14\n" +
+	"\x05field\x18\x02 \x01(\tR\x05field\x12-\n" +
+	"\x06bounds\x18\x03 \x01(\v2\x15.signature.v1.PDFRectR\x06bounds\x12!\n" +
+	"\fink_coverage\x18\x04 \x01(\x01R\vinkCoverage\x12\x1e\n" +
+	"\n" +
+	"confidence\x18\x05 \x01(\x01R\n" +
+	"confidence\x12\x16\n" +
+	"\x06signed\x18\x06 \x01(\bR\x06signed\"Z\n" +
+	"\x0eVerifyResponse\x12\x16\n" +
+	"\x06signed\x18\x01 \x01(\bR\x06signed\x120\n" +
+	"\x06checks\x18\x02 \x03(\v2\x18.signature.v1.FieldCheckR\x06checks2\xed\x01\n" +
+	"\x10SignatureService\x12F\n" +
+	"\aExtract\x12\x1c.signature.v1.ExtractRequest\x1a\x1d.signature.v1.ExtractResponse\x12L\n" +
+	"\rExtractStream\x12\x1a.signature.v1.ExtractChunk\x1a\x1d.signature.v1.ExtractResponse(\x01\x12C\n" +
+	"\x06Verify\x12\x1b.signature.v1.VerifyRequest\x1a\x1c.signature.v1.VerifyResponseB\x15Z\x13poc-pdf/signaturepbb\x06proto3"
+
+var (
+	file_signature_proto_rawDescOnce sync.Once
+	file_signature_proto_rawDescData []byte
+)
+
+func file_signature_proto_rawDescGZIP() []byte {
+	file_signature_proto_rawDescOnce.Do(func() {
+		file_signature_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_signature_proto_rawDesc), len(file_signature_proto_rawDesc)))
+	})
+	return file_signature_proto_rawDescData
+}
+
+var file_signature_proto_msgTypes = make([]protoimpl.MessageInfo, 11)
+var file_signature_proto_goTypes = []any{
+	(*ExtractOptions)(nil),  // 0: signature.v1.ExtractOptions
+	(*ExtractRequest)(nil),  // 1: signature.v1.ExtractRequest
+	(*ExtractChunk)(nil),    // 2: signature.v1.ExtractChunk
+	(*PixelRect)(nil),       // 3: signature.v1.PixelRect
+	(*PDFRect)(nil),         // 4: signature.v1.PDFRect
+	(*Signature)(nil),       // 5: signature.v1.Signature
+	(*ExtractResponse)(nil), // 6: signature.v1.ExtractResponse
+	(*Field)(nil),           // 7: signature.v1.Field
+	(*VerifyRequest)(nil),   // 8: signature.v1.VerifyRequest
+	(*FieldCheck)(nil),      // 9: signature.v1.FieldCheck
+	(*VerifyResponse)(nil),  // 10: signature.v1.VerifyResponse
+}
+var file_signature_proto_depIdxs = []int32{
+	0,  // 0: signature.v1.ExtractRequest.options:type_name -> signature.v1.ExtractOptions
+	0,  // 1: signature.v1.ExtractChunk.options:type_name -> signature.v1.ExtractOptions
+	3,  // 2: signature.v1.Signature.bounds:type_name -> signature.v1.PixelRect
+	4,  // 3: signature.v1.Signature.pdf_bounds:type_name -> signature.v1.PDFRect
+	5,  // 4: signature.v1.ExtractResponse.signatures:type_name -> signature.v1.Signature
+	4,  // 5: signature.v1.Field.rect:type_name -> signature.v1.PDFRect
+	7,  // 6: signature.v1.VerifyRequest.fields:type_name -> signature.v1.Field
+	4,  // 7: signature.v1.FieldCheck.bounds:type_name -> signature.v1.PDFRect
+	9,  // 8: signature.v1.VerifyResponse.checks:type_name -> signature.v1.FieldCheck
+	1,  // 9: signature.v1.SignatureService.Extract:input_type -> signature.v1.ExtractRequest
+	2,  // 10: signature.v1.SignatureService.ExtractStream:input_type -> signature.v1.ExtractChunk
+	8,  // 11: signature.v1.SignatureService.Verify:input_type -> signature.v1.VerifyRequest
+	6,  // 12: signature.v1.SignatureService.Extract:output_type -> signature.v1.ExtractResponse
+	6,  // 13: signature.v1.SignatureService.ExtractStream:output_type -> signature.v1.ExtractResponse
+	10, // 14: signature.v1.SignatureService.Verify:output_type -> signature.v1.VerifyResponse
+	12, // [12:15] is the sub-list for method output_type
+	9,  // [9:12] is the sub-list for method input_type
+	9,  // [9:9] is the sub-list for extension type_name
+	9,  // [9:9] is the sub-list for extension extendee
+	0,  // [0:9] is the sub-list for field type_name
+}
+
+func init() { file_signature_proto_init() }
+func file_signature_proto_init() {
+	if File_signature_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_signature_proto_rawDesc), len(file_signature_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   11,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_signature_proto_goTypes,
+		DependencyIndexes: file_signature_proto_depIdxs,
+		MessageInfos:      file_signature_proto_msgTypes,
+	}.Build()
+	File_signature_proto = out.File
+	file_signature_proto_goTypes = nil
+	file_signature_proto_depIdxs = nil
+}
@@ -0,0 +1,203 @@
+// SignatureService exposes the extraction pipeline over gRPC (serve -grpc).
+// Regenerate the Go code with `go generate ./signaturepb` (needs protoc,
+// protoc-gen-go and protoc-gen-go-grpc on PATH).
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: signature.proto
+
+package signaturepb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	SignatureService_Extract_FullMethodName       = "/signature.v1.SignatureService/Extract"
+	SignatureService_ExtractStream_FullMethodName = "/signature.v1.SignatureService/ExtractStream"
+	SignatureService_Verify_FullMethodName        = "/signature.v1.SignatureService/Verify"
+)
+
+// SignatureServiceClient is the client API for SignatureService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type SignatureServiceClient interface {
+	// Extract finds the signatures in a PDF sent in one message.
+	Extract(ctx context.Context, in *ExtractRequest, opts ...grpc.CallOption) (*ExtractResponse, error)
+	// ExtractStream is Extract for PDFs too large for one message: the client
+	// streams the file in chunks, with the options in the first one.
+	ExtractStream(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[ExtractChunk, ExtractResponse], error)
+	// Verify reports which pages or fields of a PDF are signed.
+	Verify(ctx context.Context, in *VerifyRequest, opts ...grpc.CallOption) (*VerifyResponse, error)
+}
+
+type signatureServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewSignatureServiceClient(cc grpc.ClientConnInterface) SignatureServiceClient {
+	return &signatureServiceClient{cc}
+}
+
+func (c *signatureServiceClient) Extract(ctx context.Context, in *ExtractRequest, opts ...grpc.CallOption) (*ExtractResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ExtractResponse)
+	err := c.cc.Invoke(ctx, SignatureService_Extract_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *signatureServiceClient) ExtractStream(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[ExtractChunk, ExtractResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &SignatureService_ServiceDesc.Streams[0], SignatureService_ExtractStream_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[ExtractChunk, ExtractResponse]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type SignatureService_ExtractStreamClient = grpc.ClientStreamingClient[ExtractChunk, ExtractResponse]
+
+func (c *signatureServiceClient) Verify(ctx context.Context, in *VerifyRequest, opts ...grpc.CallOption) (*VerifyResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(VerifyResponse)
+	err := c.cc.Invoke(ctx, SignatureService_Verify_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// SignatureServiceServer is the server API for SignatureService service.
+// All implementations must embed UnimplementedSignatureServiceServer
+// for forward compatibility.
+type SignatureServiceServer interface {
+	// Extract finds the signatures in a PDF sent in one message.
+	Extract(context.Context, *ExtractRequest) (*ExtractResponse, error)
+	// ExtractStream is Extract for PDFs too large for one message: the client
+	// streams the file in chunks, with the options in the first one.
+	ExtractStream(grpc.ClientStreamingServer[ExtractChunk, ExtractResponse]) error
+	// Verify reports which pages or fields of a PDF are signed.
+	Verify(context.Context, *VerifyRequest) (*VerifyResponse, error)
+	mustEmbedUnimplementedSignatureServiceServer()
+}
+
+// UnimplementedSignatureServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedSignatureServiceServer struct{}
+
+func (UnimplementedSignatureServiceServer) Extract(context.Context, *ExtractRequest) (*ExtractResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Extract not implemented")
+}
+func (UnimplementedSignatureServiceServer) ExtractStream(grpc.ClientStreamingServer[ExtractChunk, ExtractResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method ExtractStream not implemented")
+}
+func (UnimplementedSignatureServiceServer) Verify(context.Context, *VerifyRequest) (*VerifyResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Verify not implemented")
+}
+func (UnimplementedSignatureServiceServer) mustEmbedUnimplementedSignatureServiceServer() {}
+func (UnimplementedSignatureServiceServer) testEmbeddedByValue()                          {}
+
+// UnsafeSignatureServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to SignatureServiceServer will
+// result in compilation errors.
+type UnsafeSignatureServiceServer interface {
+	mustEmbedUnimplementedSignatureServiceServer()
+}
+
+func RegisterSignatureServiceServer(s grpc.ServiceRegistrar, srv SignatureServiceServer) {
+	// If the following call pancis, it indicates UnimplementedSignatureServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&SignatureService_ServiceDesc, srv)
+}
+
+func _SignatureService_Extract_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ExtractRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SignatureServiceServer).Extract(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SignatureService_Extract_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SignatureServiceServer).Extract(ctx, req.(*ExtractRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SignatureService_ExtractStream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(SignatureServiceServer).ExtractStream(&grpc.GenericServerStream[ExtractChunk, ExtractResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type SignatureService_ExtractStreamServer = grpc.ClientStreamingServer[ExtractChunk, ExtractResponse]
+
+func _SignatureService_Verify_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(VerifyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SignatureServiceServer).Verify(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SignatureService_Verify_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SignatureServiceServer).Verify(ctx, req.(*VerifyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// SignatureService_ServiceDesc is the grpc.ServiceDesc for SignatureService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var SignatureService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "signature.v1.SignatureService",
+	HandlerType: (*SignatureServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Extract",
+			Handler:    _SignatureService_Extract_Handler,
+		},
+		{
+			MethodName: "Verify",
+			Handler:    _SignatureService_Verify_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "ExtractStream",
+			Handler:       _SignatureService_ExtractStream_Handler,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "signature.proto",
+}
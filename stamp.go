@@ -0,0 +1,155 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"image"
+	_ "image/png"
+	"math"
+	"os"
+	"strconv"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+
+	"poc-pdf/signature"
+)
+
+// stampRequest says where runStamp places which signature.
+type stampRequest struct {
+	pdfPath, pngPath, outPath string
+	page                      int
+	// rect is the box in PDF points the signature is fitted into.
+	rect signature.PDFRect
+}
+
+// runStamp implements the stamp subcommand: it overlays a transparent signature
+// PNG onto one page of a PDF and writes the result as a new PDF.
+func runStamp(args []string) error {
+	fs := flag.NewFlagSet("stamp", flag.ExitOnError)
+	pdfPath := fs.String("pdf", "", "PDF to stamp the signature into (required)")
+	pngPath := fs.String("png", "", "transparent signature PNG, e.g. signature_result.png")
+	page := fs.Int("page", 0, "page to stamp, 1-based (default 1)")
+	rect := fs.String("rect", "", "box the signature is fitted into, in PDF points: llx,lly,urx,ury")
+	meta := fs.String("meta", "", "take -png, -page and -rect from a .meta.json written by -json, so the signature lands where it was extracted")
+	out := addOutFlag(fs, "path of the stamped PDF (required)")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: go run . stamp -pdf <file.pdf> -png <signature.png> -rect llx,lly,urx,ury -out <signed.pdf> [flags]")
+		fmt.Fprintln(fs.Output(), "       go run . stamp -pdf <file.pdf> -meta <signature.meta.json> -out <signed.pdf>")
+		fs.PrintDefaults()
+	}
+	_, problems := parseFlags(fs, args)
+
+	req := stampRequest{pdfPath: *pdfPath, pngPath: *pngPath, outPath: *out, page: *page}
+	if *meta != "" {
+		// Explicit flags win over the metadata
+		m, err := readMetadata(*meta)
+		problems.check(err == nil, "-meta: %v", err)
+		if err == nil {
+			if req.pngPath == "" {
+				req.pngPath = m.OutputPath
+			}
+			if req.page == 0 {
+				req.page = m.Page
+			}
+			req.rect = m.PDFBounds
+		}
+	}
+	if *rect != "" {
+		r, err := signature.ParsePDFRect(*rect)
+		problems.check(err == nil, "-rect: %v", err)
+		req.rect = r
+	}
+	if req.page == 0 {
+		req.page = 1
+	}
+
+	problems.check(fs.NArg() == 0, "unexpected arguments: %v", fs.Args())
+	problems.check(req.pdfPath != "", "-pdf is required")
+	problems.check(req.pngPath != "", "-png (or -meta) is required")
+	problems.check(req.outPath != "", "-out is required")
+	problems.check(req.outPath == "" || req.outPath != req.pdfPath, "-out must differ from -pdf")
+	problems.check(req.page >= 1, "-page must be at least 1, got %d", req.page)
+	problems.check(req.rect.Width() > 0 && req.rect.Height() > 0, "-rect (or -meta) is required")
+	if len(problems) > 0 {
+		return problems
+	}
+
+	if err := stampPDF(req); err != nil {
+		return err
+	}
+	fmt.Printf("Stamped %s onto page %d of %s -> %s\n", req.pngPath, req.page, req.pdfPath, req.outPath)
+	return nil
+}
+
+// readMetadata loads a .meta.json file written by Options.Metadata.
+func readMetadata(path string) (signature.Metadata, error) {
+	var m signature.Metadata
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return m, err
+	}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return m, fmt.Errorf("invalid metadata %s: %v", path, err)
+	}
+	return m, nil
+}
+
+// stampPDF places the PNG of req on its page, scaled to fit req.rect without
+// distorting it and centred in the box. The PDF is otherwise left untouched.
+func stampPDF(req stampRequest) error {
+	visible, err := visibleBox(req.pdfPath, req.page)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Open(req.pngPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	cfg, _, err := image.DecodeConfig(f)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %v", req.pngPath, err)
+	}
+	if _, err := f.Seek(0, 0); err != nil {
+		return err
+	}
+
+	// pdfcpu lays an image out at one point per pixel; scale that to the box.
+	// Its offsets count from the corner of the visible box, not the user space origin.
+	scale := math.Min(req.rect.Width()/float64(cfg.Width), req.rect.Height()/float64(cfg.Height))
+	x := req.rect.LLX + (req.rect.Width()-float64(cfg.Width)*scale)/2 - visible.LL.X
+	y := req.rect.LLY + (req.rect.Height()-float64(cfg.Height)*scale)/2 - visible.LL.Y
+	desc := fmt.Sprintf("position:bl, offset:%.2f %.2f, scalefactor:%.6f abs, rotation:0, opacity:1", x, y, scale)
+
+	wm, err := api.ImageWatermarkForReader(f, desc, true, false, types.POINTS)
+	if err != nil {
+		return fmt.Errorf("failed to prepare stamp: %v", err)
+	}
+	if err := api.AddWatermarksFile(req.pdfPath, req.outPath, []string{strconv.Itoa(req.page)}, wm, nil); err != nil {
+		return fmt.Errorf("failed to stamp %s: %v", req.pdfPath, err)
+	}
+	return nil
+}
+
+// visibleBox returns the CropBox (or MediaBox) of page, checking it exists.
+func visibleBox(pdfPath string, page int) (*types.Rectangle, error) {
+	ctx, err := api.ReadContextFile(pdfPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", pdfPath, err)
+	}
+	if page > ctx.PageCount {
+		return nil, fmt.Errorf("page %d is out of range: %s has %d pages", page, pdfPath, ctx.PageCount)
+	}
+	boxes, err := ctx.PageBoundaries(types.IntSet{page: true})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the page boxes of %s: %v", pdfPath, err)
+	}
+	box := boxes[page-1].CropBox()
+	if box == nil {
+		return nil, fmt.Errorf("page %d of %s has no MediaBox", page, pdfPath)
+	}
+	return box, nil
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"poc-pdf/signature"
+)
+
+// stdioArg is the input that reads the document from stdin, and the -out that
+// writes the signature to stdout.
+const stdioArg = "-"
+
+// stdinName is the file name stdin is staged under. Images are told from PDFs
+// by their content, so the extension doesn't matter.
+const stdinName = "stdin.pdf"
+
+// stageStdin copies r, the document piped in, to stdinName below dir and
+// returns its path.
+func stageStdin(r io.Reader, dir string) (string, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, stdinName)
+	f, err := os.Create(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	n, err := io.Copy(f, r)
+	if err != nil {
+		return "", fmt.Errorf("failed to read stdin: %v", err)
+	}
+	if n == 0 {
+		return "", errors.New("stdin is empty")
+	}
+	return path, f.Close()
+}
+
+// streamOutput copies the output file of the first of results to w, for
+// -out -. A stream holds one file, so any further signatures are dropped.
+func streamOutput(w io.Writer, results []*signature.Result) error {
+	if len(results) == 0 {
+		return errors.New("no signature to write to stdout")
+	}
+	if len(results) > 1 {
+		slog.Warn("Only the first signature is written to stdout", "signatures", len(results), "page", results[0].Page)
+	}
+	f, err := os.Open(results[0].OutputPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err := io.Copy(w, f); err != nil {
+		return fmt.Errorf("failed to write stdout: %v", err)
+	}
+	return nil
+}
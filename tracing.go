@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"google.golang.org/grpc"
+)
+
+// Supported values of -trace-protocol, as in OTEL_EXPORTER_OTLP_PROTOCOL.
+const (
+	traceProtocolHTTP = "http/protobuf"
+	traceProtocolGRPC = "grpc"
+)
+
+const (
+	// traceServiceName is the service.name of the spans unless
+	// OTEL_SERVICE_NAME says otherwise.
+	traceServiceName = "poc-pdf"
+	// traceShutdownTimeout bounds the export of the spans still buffered on exit.
+	traceShutdownTimeout = 5 * time.Second
+)
+
+// traceFlags are the flags exporting OpenTelemetry spans over OTLP.
+type traceFlags struct {
+	endpoint *string
+	protocol *string
+	sample   *float64
+}
+
+// addTraceFlags registers -trace-endpoint, -trace-protocol and -trace-sample
+// on fs.
+func addTraceFlags(fs *flag.FlagSet) traceFlags {
+	return traceFlags{
+		endpoint: fs.String("trace-endpoint", "", "OTLP collector to export OpenTelemetry spans of every document and stage to: host:port (plaintext) or an http(s):// URL; empty disables tracing unless OTEL_EXPORTER_OTLP_ENDPOINT is set"),
+		protocol: fs.String("trace-protocol", traceProtocolHTTP, "OTLP transport of -trace-endpoint: http/protobuf (port 4318) or grpc (port 4317)"),
+		sample:   fs.Float64("trace-sample", 1, "fraction (0-1) of traces to record; a caller's sampled traceparent is always followed"),
+	}
+}
+
+// tracing is the OTLP export configured by traceFlags.
+type tracing struct {
+	// endpoint is "" for the exporter's own OTEL_EXPORTER_OTLP_* settings.
+	endpoint string
+	protocol string
+	sample   float64
+}
+
+// config reports flag problems into p and returns the tracing configured, or
+// nil when it is off.
+func (f traceFlags) config(p *configProblems) *tracing {
+	p.check(*f.protocol == traceProtocolHTTP || *f.protocol == traceProtocolGRPC, "-trace-protocol: want http/protobuf or grpc, got %q", *f.protocol)
+	p.check(*f.sample >= 0 && *f.sample <= 1, "-trace-sample must be between 0 and 1, got %v", *f.sample)
+	_, fromEnv := os.LookupEnv("OTEL_EXPORTER_OTLP_ENDPOINT")
+	_, tracesFromEnv := os.LookupEnv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT")
+	if *f.endpoint == "" && !fromEnv && !tracesFromEnv {
+		return nil
+	}
+	return &tracing{endpoint: *f.endpoint, protocol: *f.protocol, sample: *f.sample}
+}
+
+// start installs the global tracer provider and propagator, so the pipeline
+// (see signature.Options.TracerProvider) and the server instrumentation
+// export their spans, and returns the function flushing them on exit. With
+// tracing off it installs nothing.
+func (t *tracing) start(ctx context.Context) (func(), error) {
+	if t == nil {
+		return func() {}, nil
+	}
+	exporter, err := t.exporter(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// OTEL_SERVICE_NAME and OTEL_RESOURCE_ATTRIBUTES win over the defaults
+	res, err := resource.New(ctx,
+		resource.WithAttributes(attribute.String("service.name", traceServiceName)),
+		resource.WithTelemetrySDK(),
+		resource.WithHost(),
+		resource.WithFromEnv(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(t.sample))),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	slog.Info("Exporting traces", "endpoint", t.endpoint, "protocol", t.protocol, "sample", t.sample)
+
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), traceShutdownTimeout)
+		defer cancel()
+		if err := provider.Shutdown(ctx); err != nil {
+			slog.Warn("Failed to flush traces", "error", err)
+		}
+	}, nil
+}
+
+// exporter returns the OTLP exporter of t's protocol. A URL endpoint picks
+// TLS by its scheme; a bare host:port is plaintext, as collectors next to the
+// service usually are.
+func (t *tracing) exporter(ctx context.Context) (*otlptrace.Exporter, error) {
+	isURL := strings.Contains(t.endpoint, "://")
+	if t.protocol == traceProtocolGRPC {
+		var opts []otlptracegrpc.Option
+		switch {
+		case isURL:
+			opts = append(opts, otlptracegrpc.WithEndpointURL(t.endpoint))
+		case t.endpoint != "":
+			opts = append(opts, otlptracegrpc.WithEndpoint(t.endpoint), otlptracegrpc.WithInsecure())
+		}
+		return otlptracegrpc.New(ctx, opts...)
+	}
+	var opts []otlptracehttp.Option
+	switch {
+	case isURL:
+		opts = append(opts, otlptracehttp.WithEndpointURL(t.endpoint))
+	case t.endpoint != "":
+		opts = append(opts, otlptracehttp.WithEndpoint(t.endpoint), otlptracehttp.WithInsecure())
+	}
+	return otlptracehttp.New(ctx, opts...)
+}
+
+// httpHandler wraps h in a server span per request, named after the route it
+// matched and continuing the caller's traceparent. Prometheus scrapes and
+// health probes are left out. With tracing off h is returned as is.
+func (t *tracing) httpHandler(h http.Handler) http.Handler {
+	if t == nil {
+		return h
+	}
+	return otelhttp.NewHandler(h, "serve",
+		otelhttp.WithSpanNameFormatter(func(operation string, r *http.Request) string {
+			if r.Pattern != "" {
+				return r.Pattern
+			}
+			return r.Method + " " + operation
+		}),
+		otelhttp.WithFilter(func(r *http.Request) bool {
+			switch r.URL.Path {
+			case "/metrics", "/healthz", "/readyz":
+				return false
+			}
+			return true
+		}),
+	)
+}
+
+// grpcOptions returns the stats handler creating a server span per gRPC
+// call, or none with tracing off.
+func (t *tracing) grpcOptions() []grpc.ServerOption {
+	if t == nil {
+		return nil
+	}
+	return []grpc.ServerOption{grpc.StatsHandler(otelgrpc.NewServerHandler())}
+}
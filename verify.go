@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"poc-pdf/signature"
+)
+
+// runVerify implements the verify subcommand: it checks each PDF in args for a
+// handwritten signature, per page or per field, and prints the reports as JSON.
+func runVerify(args []string) error {
+	fs := flag.NewFlagSet("verify", flag.ExitOnError)
+	fieldsPath := fs.String("fields", "", "JSON file of named field areas to check instead of whole pages: [{\"name\":...,\"page\":N,\"rect\":{\"llx\":...,\"lly\":...,\"urx\":...,\"ury\":...}}]")
+	minCoverage := fs.Float64("min-coverage", signature.DefaultMinInkCoverage, "share of a page or field that must be ink for it to count as signed")
+	out := addOutFlag(fs, "write the JSON report to this file instead of standard output")
+	render := addRenderFlags(fs)
+	limits := addLimitFlags(fs)
+	pagesFlag := fs.String("pages", "", "pages to check without -fields, e.g. 1,3,5-7,last (default all)")
+	password := fs.String("password", "", "password of encrypted PDFs (poppler rasterizer only)")
+	binarization := fs.String("binarize", signature.BinarizeAuto, "how ink is separated from paper: fixed, otsu, adaptive or auto")
+	noShapeFilter := fs.Bool("no-shape-filter", false, "do not reject regions that look like printed text or solid graphics")
+	removeLines := fs.Bool("remove-lines", false, "erase printed signing lines and form box edges before detection")
+	mergeGap := fs.Int("merge-gap", 0, "group ink fragments within this many pixels into one region before detection")
+	flattenIllumination := fs.Bool("flatten-illumination", false, "even out shadows and gradients in phone photos before checking")
+	medianBlur := fs.Int("median-blur", 0, "median-filter pages with this odd kernel size before thresholding; 0 disables")
+	verbose := fs.Bool("v", false, "print progress to standard error")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: go run . verify [flags] <file.pdf> [more.pdf ...]")
+		fs.PrintDefaults()
+	}
+	_, problems := parseFlags(fs, args)
+
+	opts := signature.DefaultOptions()
+	render.apply(&opts)
+	limits.apply(&opts)
+	opts.Password = *password
+	opts.Binarization = *binarization
+	opts.NoShapeFilter = *noShapeFilter
+	opts.RemoveLines = *removeLines
+	opts.MergeGapPx = *mergeGap
+	opts.MedianBlur = *medianBlur
+	opts.FlattenIllumination = *flattenIllumination
+	if *verbose {
+		opts.Logf = func(format string, args ...any) { fmt.Fprintf(os.Stderr, format+"\n", args...) }
+	}
+	vopts := signature.VerifyOptions{MinInkCoverage: *minCoverage}
+
+	problems.check(fs.NArg() > 0, "no input PDF given")
+	problems.check(*minCoverage > 0 && *minCoverage < 1, "-min-coverage must be in (0, 1), got %g", *minCoverage)
+	if *pagesFlag != "" {
+		sel, err := signature.ParsePageSelection(*pagesFlag)
+		problems.check(err == nil, "-pages: %v", err)
+		opts.Pages = sel
+	}
+	if *fieldsPath != "" {
+		fields, err := signature.ParseFields(*fieldsPath)
+		problems.check(err == nil, "-fields: %v", err)
+		problems.check(err != nil || len(fields) > 0, "-fields: %s lists no fields", *fieldsPath)
+		problems.check(*pagesFlag == "", "-pages has no effect with -fields; each field names its page")
+		vopts.Fields = fields
+	}
+	problems = append(problems, validateOptions(opts, nil)...)
+	if len(problems) > 0 {
+		return problems
+	}
+
+	ex := signature.NewExtractor(opts)
+	reports := make([]*signature.VerifyReport, 0, fs.NArg())
+	var failed int
+	for _, path := range fs.Args() {
+		report, err := ex.Verify(context.Background(), path, vopts)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "FAILED %s: %v\n", path, err)
+			failed++
+			continue
+		}
+		reports = append(reports, report)
+	}
+
+	data, err := json.MarshalIndent(reports, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	if *out != "" {
+		err = os.WriteFile(*out, data, 0o644)
+	} else {
+		_, err = os.Stdout.Write(data)
+	}
+	if err != nil {
+		return err
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d documents could not be verified", failed, fs.NArg())
+	}
+	return nil
+}
@@ -0,0 +1,274 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+
+	"poc-pdf/signature"
+)
+
+// defaultWatchSettle is how long a dropped file must go unchanged before
+// -watch processes it, so a copy still in progress isn't read half-written.
+const defaultWatchSettle = 2 * time.Second
+
+// Subdirectories of the -watch directory processed documents are moved to.
+const (
+	watchDone   = "done"
+	watchFailed = "failed"
+)
+
+// watchOptions configures -watch.
+type watchOptions struct {
+	// Dir is the directory watched for new PDFs.
+	Dir string
+	// Settle is how long a file must go without writes before it is processed.
+	Settle time.Duration
+	// Workers is how many documents are processed at once.
+	Workers int
+}
+
+// hotFolder processes the PDFs dropped into a directory as they land.
+type hotFolder struct {
+	ex     *signature.Extractor
+	opts   watchOptions
+	report *batchReport
+	queue  chan string
+
+	mu sync.Mutex
+	// timers holds the settle timer of every file written to recently.
+	timers map[string]*time.Timer
+	// busy holds the files being processed.
+	busy map[string]bool
+	// names holds the names the files being processed were given (see
+	// reserveName).
+	names map[string]string
+}
+
+// runWatch processes every PDF in opts.Dir, then each one that lands there
+// until ctx is done. A processed document is moved into the done/ or failed/
+// subdirectory, and its outcome printed and written to report like a
+// document of a batch. Documents interrupted by the end of ctx are left in
+// place, to be processed by the next run.
+func runWatch(ctx context.Context, ex *signature.Extractor, opts watchOptions, report *batchReport) error {
+	for _, sub := range []string{watchDone, watchFailed} {
+		if err := os.MkdirAll(filepath.Join(opts.Dir, sub), 0o755); err != nil {
+			return withStatus(exitEnvironment, fmt.Errorf("failed to create %s directory: %v", sub, err))
+		}
+	}
+	fsw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return withStatus(exitEnvironment, fmt.Errorf("failed to watch %s: %v", opts.Dir, err))
+	}
+	defer fsw.Close()
+	// Watch before listing, so a file landing in between isn't missed
+	if err := fsw.Add(opts.Dir); err != nil {
+		return withStatus(exitEnvironment, fmt.Errorf("failed to watch %s: %v", opts.Dir, err))
+	}
+
+	// Workers and settle timers stop with ctx, also when the watch fails
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	h := &hotFolder{
+		ex:     ex,
+		opts:   opts,
+		report: report,
+		queue:  make(chan string),
+		timers: map[string]*time.Timer{},
+		busy:   map[string]bool{},
+		names:  map[string]string{},
+	}
+	var wg sync.WaitGroup
+	for range max(opts.Workers, 1) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case path := <-h.queue:
+					h.process(ctx, path)
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+
+	entries, err := os.ReadDir(opts.Dir)
+	if err != nil {
+		slog.Warn("Failed to list watched directory", "dir", opts.Dir, "error", err)
+	}
+	for _, e := range entries {
+		if e.Type().IsRegular() && isPDFName(e.Name()) {
+			h.schedule(ctx, filepath.Join(opts.Dir, e.Name()))
+		}
+	}
+	slog.Info("Watching for PDFs", "dir", opts.Dir, "settle", opts.Settle, "workers", max(opts.Workers, 1))
+
+	var watchErr error
+loop:
+	for {
+		select {
+		case <-ctx.Done():
+			break loop
+		case ev, ok := <-fsw.Events:
+			if !ok {
+				watchErr = withStatus(exitEnvironment, fmt.Errorf("watch of %s ended", opts.Dir))
+				break loop
+			}
+			// A file moved in shows up as a Create
+			if ev.Has(fsnotify.Create) || ev.Has(fsnotify.Write) {
+				if isPDFName(ev.Name) {
+					h.schedule(ctx, ev.Name)
+				}
+			}
+		case err, ok := <-fsw.Errors:
+			if !ok {
+				watchErr = withStatus(exitEnvironment, fmt.Errorf("watch of %s ended", opts.Dir))
+				break loop
+			}
+			slog.Warn("Watch error", "dir", opts.Dir, "error", err)
+		}
+	}
+
+	cancel()
+	h.mu.Lock()
+	for _, t := range h.timers {
+		t.Stop()
+	}
+	h.mu.Unlock()
+	wg.Wait()
+	slog.Info("Stopped watching", "dir", opts.Dir)
+	return watchErr
+}
+
+// isPDFName reports whether name has the .pdf extension, in any case, and
+// isn't hidden, as the partial files of many copy tools are.
+func isPDFName(name string) bool {
+	base := filepath.Base(name)
+	return strings.EqualFold(filepath.Ext(base), ".pdf") && !strings.HasPrefix(base, ".")
+}
+
+// schedule queues path once it has gone opts.Settle without another write,
+// restarting the wait on every call.
+func (h *hotFolder) schedule(ctx context.Context, path string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if t, ok := h.timers[path]; ok {
+		t.Reset(h.opts.Settle)
+		return
+	}
+	h.timers[path] = time.AfterFunc(h.opts.Settle, func() {
+		h.mu.Lock()
+		delete(h.timers, path)
+		if h.busy[path] {
+			h.mu.Unlock()
+			return
+		}
+		h.busy[path] = true
+		h.mu.Unlock()
+		select {
+		case h.queue <- path:
+		case <-ctx.Done():
+			h.done(path)
+		}
+	})
+}
+
+// done forgets that path is being processed, and the name it was given.
+func (h *hotFolder) done(path string) {
+	h.mu.Lock()
+	delete(h.busy, path)
+	delete(h.names, path)
+	h.mu.Unlock()
+}
+
+// reserveName gives the file at path the name its outputs are written under
+// and it is moved into done/ or failed/ as: its own, or numbered like a_2.pdf
+// when a document by that name was processed before or is being processed, so
+// a scanner reusing file names doesn't overwrite earlier outputs.
+func (h *hotFolder) reserveName(path string) string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	taken := map[string]bool{}
+	for _, name := range h.names {
+		taken[name] = true
+	}
+	ext := filepath.Ext(path)
+	base := strings.TrimSuffix(filepath.Base(path), ext)
+	name := base + ext
+	for n := 2; taken[name] || h.processed(name); n++ {
+		name = base + "_" + strconv.Itoa(n) + ext
+	}
+	h.names[path] = name
+	return name
+}
+
+// processed reports whether a document named name is in done/ or failed/.
+func (h *hotFolder) processed(name string) bool {
+	for _, sub := range []string{watchDone, watchFailed} {
+		if _, err := os.Lstat(filepath.Join(h.opts.Dir, sub, name)); !errors.Is(err, os.ErrNotExist) {
+			return true
+		}
+	}
+	return false
+}
+
+// process extracts the signatures of the document at path, naming its outputs
+// after the name reserveName gives it, and moves it into done/ or failed/
+// under that name. A file gone in the meantime is skipped, and one failing for
+// an environment error (see documentStatus) is left for the next run, as it
+// isn't the document's fault.
+func (h *hotFolder) process(ctx context.Context, path string) {
+	defer h.done(path)
+	if info, err := os.Stat(path); err != nil || !info.Mode().IsRegular() {
+		return
+	}
+	name := h.reserveName(path)
+	r := h.ex.ExtractAs(ctx, path, strings.TrimSuffix(name, filepath.Ext(name)))
+	if r.Err != nil && ctx.Err() != nil {
+		// Cut short by shutdown; the next run picks the file up again
+		return
+	}
+	printOutcome(r)
+	h.report.write(newReportRow(r))
+
+	sub := watchDone
+	switch {
+	case r.Err != nil && documentStatus(r.Err) == exitEnvironment:
+		slog.Error("Document left in place", "path", path, "error", r.Err)
+		return
+	case r.Err != nil:
+		sub = watchFailed
+	}
+	dest, err := moveInto(path, filepath.Join(h.opts.Dir, sub), name)
+	if err != nil {
+		slog.Error("Failed to move processed document", "path", path, "error", err)
+		return
+	}
+	slog.Info("Document moved", "from", path, "to", dest)
+}
+
+// moveInto moves the file at path into dir as name, numbering it like
+// a_2.pdf when dir holds one by that name already, and returns its new path.
+func moveInto(path, dir, name string) (string, error) {
+	ext := filepath.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	dest := filepath.Join(dir, name)
+	for n := 2; ; n++ {
+		if _, err := os.Lstat(dest); errors.Is(err, os.ErrNotExist) {
+			break
+		}
+		dest = filepath.Join(dir, base+"_"+strconv.Itoa(n)+ext)
+	}
+	return dest, os.Rename(path, dest)
+}
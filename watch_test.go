@@ -0,0 +1,38 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReserveName(t *testing.T) {
+	dir := t.TempDir()
+	for _, sub := range []string{watchDone, watchFailed} {
+		if err := os.Mkdir(filepath.Join(dir, sub), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Earlier drops of scan.pdf, one done and one failed
+	for _, p := range []string{"done/scan.pdf", "failed/scan_2.pdf"} {
+		if err := os.WriteFile(filepath.Join(dir, p), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	h := &hotFolder{opts: watchOptions{Dir: dir}, busy: map[string]bool{}, names: map[string]string{}}
+
+	if got := h.reserveName(filepath.Join(dir, "scan.pdf")); got != "scan_3.pdf" {
+		t.Errorf("reserveName(scan.pdf) = %s, want scan_3.pdf", got)
+	}
+	// scan_3.pdf is being processed under that name
+	if got := h.reserveName(filepath.Join(dir, "scan_3.pdf")); got != "scan_3_2.pdf" {
+		t.Errorf("reserveName(scan_3.pdf) = %s, want scan_3_2.pdf", got)
+	}
+	if got := h.reserveName(filepath.Join(dir, "other.pdf")); got != "other.pdf" {
+		t.Errorf("reserveName(other.pdf) = %s, want other.pdf", got)
+	}
+	h.done(filepath.Join(dir, "scan.pdf"))
+	if got := h.reserveName(filepath.Join(dir, "x", "scan.pdf")); got != "scan_3.pdf" {
+		t.Errorf("reserveName after done = %s, want the released scan_3.pdf", got)
+	}
+}
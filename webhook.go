@@ -0,0 +1,224 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"poc-pdf/signature"
+)
+
+const (
+	// defaultWebhookTimeout bounds a single delivery attempt.
+	defaultWebhookTimeout = 10 * time.Second
+	// defaultWebhookRetries is how many times a failed delivery is retried.
+	defaultWebhookRetries = 3
+	// webhookBackoff is the wait before the first retry; it doubles after each one.
+	webhookBackoff = 500 * time.Millisecond
+	// signatureHeader carries the HMAC-SHA256 of a signed body, as
+	// "sha256=<hex>".
+	signatureHeader = "X-Signature-256"
+)
+
+// webhook delivers results to an HTTP endpoint as JSON POSTs.
+type webhook struct {
+	// URL is the endpoint every result is posted to.
+	URL string
+	// Timeout bounds each attempt, including reading the response.
+	Timeout time.Duration
+	// Retries is how many more attempts follow a failed one.
+	Retries int
+	// IncludeImage adds the output PNG, base64-encoded, to the payload.
+	IncludeImage bool
+	// Secret, when set, signs every body into signatureHeader.
+	Secret []byte
+
+	client *http.Client
+}
+
+// newWebhook returns a webhook posting to url.
+func newWebhook(url string, timeout time.Duration, retries int, includeImage bool) *webhook {
+	return &webhook{URL: url, Timeout: timeout, Retries: retries, IncludeImage: includeImage, client: &http.Client{}}
+}
+
+// validWebhookURL reports whether s is an absolute http(s) URL.
+func validWebhookURL(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// webhookRect is a pixel rectangle in the payload.
+type webhookRect struct {
+	X      int `json:"x"`
+	Y      int `json:"y"`
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+// webhookPayload is the JSON body posted for each result.
+type webhookPayload struct {
+	Source     string      `json:"source"`
+	Page       int         `json:"page"`
+	Region     int         `json:"region,omitempty"`
+	OutputPath string      `json:"output_path,omitempty"`
+	DPI        float64     `json:"dpi"`
+	Bounds     webhookRect `json:"bounds"`
+	PDFBounds  [4]float64  `json:"pdf_bounds"`
+	// PageBox and PageRotate are the rendered page area in PDF points and its
+	// /Rotate, which PDFBounds is measured against.
+	PageBox    [4]float64 `json:"page_box"`
+	PageRotate int        `json:"page_rotate"`
+	Confidence float64    `json:"confidence"`
+	// ConfidenceFactors are the sub-scores of Confidence, as in the metadata.
+	ConfidenceFactors *signature.ConfidenceFactors `json:"confidence_factors,omitempty"`
+	SignatureType     string                       `json:"signature_type,omitempty"`
+	Quality           *signature.Quality           `json:"quality,omitempty"`
+	Class             string                       `json:"class,omitempty"`
+	SignerName        string                       `json:"signer_name,omitempty"`
+	SignerDate        string                       `json:"signer_date,omitempty"`
+	SignerText        []string                     `json:"signer_text,omitempty"`
+	EdgeTouch         bool                         `json:"edge_touch"`
+	Seal              int                          `json:"seal,omitempty"`
+	SealColor         string                       `json:"seal_color,omitempty"`
+	Mark              string                       `json:"mark,omitempty"`
+	Box               int                          `json:"box,omitempty"`
+	Rotation          int                          `json:"rotation"`
+	Skew              float64                      `json:"skew_deg,omitempty"`
+	WidthMM           float64                      `json:"width_mm"`
+	HeightMM          float64                      `json:"height_mm"`
+	Hash              string                       `json:"ink_hash,omitempty"`
+	DuplicateOf       string                       `json:"duplicate_of,omitempty"`
+	// ImagePNG is the base64-encoded transparent signature, when requested.
+	ImagePNG string `json:"image_png,omitempty"`
+}
+
+// newWebhookPayload converts res to its JSON form, embedding the image if asked to.
+func newWebhookPayload(res *signature.Result, includeImage bool) (webhookPayload, error) {
+	p := webhookPayload{
+		Source:        res.Source,
+		Page:          res.Page,
+		Region:        res.Region,
+		OutputPath:    res.OutputPath,
+		DPI:           res.DPI,
+		Bounds:        webhookRect{X: res.Bounds.Min.X, Y: res.Bounds.Min.Y, Width: res.Bounds.Dx(), Height: res.Bounds.Dy()},
+		PDFBounds:     [4]float64{res.PDFBounds.LLX, res.PDFBounds.LLY, res.PDFBounds.URX, res.PDFBounds.URY},
+		PageBox:       [4]float64{res.PageBox.LLX, res.PageBox.LLY, res.PageBox.URX, res.PageBox.URY},
+		PageRotate:    res.PageRotate,
+		Confidence:    res.Confidence,
+		SignatureType: res.SignatureType,
+		Quality:       res.Quality,
+		Class:         res.Class,
+		SignerName:    res.SignerName,
+		SignerDate:    res.SignerDate,
+		SignerText:    res.SignerText,
+		EdgeTouch:     res.EdgeTouch,
+		Seal:          res.Seal,
+		SealColor:     res.SealColor,
+		Mark:          res.Mark,
+		Box:           res.Box,
+		Rotation:      res.Rotation,
+		Skew:          res.Skew,
+		WidthMM:       res.WidthMM,
+		HeightMM:      res.HeightMM,
+		Hash:          res.Hash,
+		DuplicateOf:   res.DuplicateOf,
+	}
+	if res.ConfidenceFactors != (signature.ConfidenceFactors{}) {
+		p.ConfidenceFactors = &res.ConfidenceFactors
+	}
+	if includeImage && res.Image != nil {
+		data, err := encodePNG(res.Image)
+		if err != nil {
+			return p, fmt.Errorf("failed to encode image: %v", err)
+		}
+		p.ImagePNG = base64.StdEncoding.EncodeToString(data)
+	}
+	return p, nil
+}
+
+// post delivers res with send.
+func (w *webhook) post(ctx context.Context, res *signature.Result) error {
+	payload, err := newWebhookPayload(res, w.IncludeImage)
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode payload: %v", err)
+	}
+	return w.send(ctx, body)
+}
+
+// send posts body, retrying network errors and 429/5xx responses with
+// exponential backoff. Other responses are treated as final.
+func (w *webhook) send(ctx context.Context, body []byte) error {
+	backoff := webhookBackoff
+	for attempt := 0; ; attempt++ {
+		retry, err := w.attempt(ctx, body)
+		if err == nil {
+			return nil
+		}
+		if !retry || attempt >= w.Retries {
+			return fmt.Errorf("webhook delivery failed after %d attempts: %v", attempt+1, err)
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("webhook delivery aborted: %v (last error: %v)", ctx.Err(), err)
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// attempt makes one POST and reports whether a failure is worth retrying.
+func (w *webhook) attempt(ctx context.Context, body []byte) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, w.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if len(w.Secret) > 0 {
+		mac := hmac.New(sha256.New, w.Secret)
+		mac.Write(body)
+		req.Header.Set(signatureHeader, "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return false, nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return true, fmt.Errorf("server returned %s", resp.Status)
+	default:
+		return false, fmt.Errorf("server returned %s", resp.Status)
+	}
+}
+
+// encodePNG returns img as PNG bytes.
+func encodePNG(img image.Image) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"image"
+	"image/png"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"poc-pdf/signature"
+)
+
+func TestWebhookPostsSignedPayload(t *testing.T) {
+	secret := []byte("s3cret")
+	var got webhookPayload
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("%s with Content-Type %q, want a JSON POST", r.Method, r.Header.Get("Content-Type"))
+		}
+		mac := hmac.New(sha256.New, secret)
+		mac.Write(body)
+		if want := "sha256=" + hex.EncodeToString(mac.Sum(nil)); r.Header.Get(signatureHeader) != want {
+			t.Errorf("%s = %q, want %q", signatureHeader, r.Header.Get(signatureHeader), want)
+		}
+		if err := json.Unmarshal(body, &got); err != nil {
+			t.Errorf("payload: %v", err)
+		}
+	}))
+	defer srv.Close()
+
+	res := &signature.Result{
+		Source:     "contract.pdf",
+		Page:       2,
+		DPI:        150,
+		Bounds:     image.Rect(10, 20, 110, 60),
+		PDFBounds:  signature.PDFRect{LLX: 4.8, LLY: 763.2, URX: 52.8, URY: 782.4},
+		Confidence: 0.8,
+		Image:      image.NewRGBA(image.Rect(0, 0, 100, 40)),
+	}
+	w := newWebhook(srv.URL, time.Second, 0, true)
+	w.Secret = secret
+	if err := w.post(context.Background(), res); err != nil {
+		t.Fatal(err)
+	}
+	if got.Source != "contract.pdf" || got.Page != 2 || got.Bounds != (webhookRect{X: 10, Y: 20, Width: 100, Height: 40}) || got.Confidence != 0.8 {
+		t.Errorf("payload %+v does not describe the result", got)
+	}
+	data, err := base64.StdEncoding.DecodeString(got.ImagePNG)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("image_png: %v", err)
+	}
+	if img.Bounds().Size() != image.Pt(100, 40) {
+		t.Errorf("image_png is %v, want 100x40", img.Bounds().Size())
+	}
+}
+
+// flakyServer answers the first failures requests with status (or, when
+// status is 0, by stalling for a second) and the rest with 204, and
+// records when each request arrived.
+func flakyServer(t *testing.T, failures, status int) (*httptest.Server, func() []time.Time) {
+	var mu sync.Mutex
+	var arrivals []time.Time
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		arrivals = append(arrivals, time.Now())
+		n := len(arrivals)
+		mu.Unlock()
+		switch {
+		case n > failures:
+			w.WriteHeader(http.StatusNoContent)
+		case status == 0:
+			time.Sleep(time.Second)
+		default:
+			w.WriteHeader(status)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv, func() []time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]time.Time(nil), arrivals...)
+	}
+}
+
+func TestWebhookRetriesWithBackoff(t *testing.T) {
+	srv, arrivals := flakyServer(t, 2, http.StatusServiceUnavailable)
+	w := newWebhook(srv.URL, time.Second, defaultWebhookRetries, false)
+	if err := w.send(context.Background(), []byte(`{}`)); err != nil {
+		t.Fatalf("send = %v, want delivery on the third attempt", err)
+	}
+	times := arrivals()
+	if len(times) != 3 {
+		t.Fatalf("%d attempts, want 3", len(times))
+	}
+	// The wait doubles after each retry
+	for i, want := range []time.Duration{webhookBackoff, 2 * webhookBackoff} {
+		if gap := times[i+1].Sub(times[i]); gap < want {
+			t.Errorf("retry %d came after %v, want at least %v", i+1, gap, want)
+		}
+	}
+}
+
+func TestWebhookRetriesTimeouts(t *testing.T) {
+	srv, arrivals := flakyServer(t, 1, 0)
+	w := newWebhook(srv.URL, 100*time.Millisecond, 1, false)
+	if err := w.send(context.Background(), []byte(`{}`)); err != nil {
+		t.Fatalf("send = %v, want delivery after the timed-out attempt", err)
+	}
+	if n := len(arrivals()); n != 2 {
+		t.Errorf("%d attempts, want 2", n)
+	}
+}
+
+func TestWebhookGivesUp(t *testing.T) {
+	tests := []struct {
+		status, retries, attempts int
+	}{
+		{http.StatusInternalServerError, 1, 2},
+		{http.StatusTooManyRequests, 1, 2},
+		// A client error won't go away by retrying
+		{http.StatusBadRequest, 3, 1},
+	}
+	for _, tt := range tests {
+		srv, arrivals := flakyServer(t, 10, tt.status)
+		w := newWebhook(srv.URL, time.Second, tt.retries, false)
+		err := w.send(context.Background(), []byte(`{}`))
+		if err == nil || !strings.Contains(err.Error(), http.StatusText(tt.status)) {
+			t.Errorf("status %d: send = %v, want it reported", tt.status, err)
+		}
+		if n := len(arrivals()); n != tt.attempts {
+			t.Errorf("status %d with %d retries: %d attempts, want %d", tt.status, tt.retries, n, tt.attempts)
+		}
+	}
+}